@@ -0,0 +1,38 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Accessible mode
+//
+// Components with Accessible set render linear, plain text for screen
+// readers: labelled lines instead of box drawing, status words instead of
+// spinners, and numbered lists instead of highlighted selections.  It
+// defaults to the OLLAMATEA_ACCESSIBLE environment variable; see
+// [DefaultAccessible].
+
+// accessibleHelp describes the enabled key bindings as a sentence, such as
+// "Keys: enter send, esc quit."
+func accessibleHelp(bindings []key.Binding) string {
+	var parts []string
+	for _, binding := range bindings {
+		if !binding.Enabled() {
+			continue
+		}
+		help := binding.Help()
+		if help.Key == "" && help.Desc == "" {
+			continue
+		}
+		parts = append(parts, strings.TrimSpace(help.Key+" "+help.Desc))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Keys: " + strings.Join(parts, ", ") + "."
+}
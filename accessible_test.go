@@ -0,0 +1,100 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+	ollama "github.com/ollama/ollama/api"
+)
+
+// boxDrawing are characters screen readers announce poorly
+const boxDrawing = "─│┌┐└┘├┤┃━"
+
+func TestChatPanelAccessibleView(t *testing.T) {
+	session := ollamatea.NewSession()
+	session.Model = "llama3.2"
+	panel := ollamatea.NewChatPanel(session)
+	panel.Title = "Chat"
+	panel.Accessible = true
+
+	view := panel.View()
+	for _, want := range []string{"Chat\n", "Model: llama3.2\n", "Status: ready\n", "Prompt: \n", "Keys: enter send"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in the view %q", want, view)
+		}
+	}
+	if strings.ContainsAny(view, boxDrawing) {
+		t.Errorf("unexpected box drawing in the view %q", view)
+	}
+}
+
+func TestModelChooserAccessibleView(t *testing.T) {
+	chooser := ollamatea.NewModelChooser("http://localhost:11434")
+	chooser.Accessible = true
+	chooser, _ = chooser.Update(ollamatea.FetchModelListResponseMsg{
+		ID: chooser.ID(),
+		Models: []ollama.ListModelResponse{
+			{Name: "llama3.2", Size: 2_000_000_000},
+			{Name: "qwen2.5", Size: 4_000_000_000},
+		},
+	})
+	view := chooser.View()
+	for _, want := range []string{"1 of 2: llama3.2", "(current)\n", "2 of 2: qwen2.5", "enter select"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in the view %q", want, view)
+		}
+	}
+	if strings.ContainsAny(view, boxDrawing) {
+		t.Errorf("unexpected box drawing in the view %q", view)
+	}
+}
+
+func TestModelChooserFilter(t *testing.T) {
+	chooser := ollamatea.NewModelChooser("http://localhost:11434")
+	chooser.Accessible = true
+	chooser.Filter = "QWEN"
+	chooser.SetSelectionByName("qwen2.5-coder")
+	chooser, _ = chooser.Update(ollamatea.FetchModelListResponseMsg{
+		ID: chooser.ID(),
+		Models: []ollama.ListModelResponse{
+			{Name: "llama3.2"},
+			{Name: "qwen2.5"},
+			{Name: "qwen2.5-coder"},
+		},
+	})
+	view := chooser.View()
+	for _, want := range []string{"1 of 2: qwen2.5, ", "2 of 2: qwen2.5-coder,    (current)\n"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in the view %q", want, view)
+		}
+	}
+	if strings.Contains(view, "llama3.2") {
+		t.Errorf("unexpected filtered model in the view %q", view)
+	}
+}
+
+func TestChatPanelTruncatedResponse(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	session := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{strings.Repeat("a", 40), strings.Repeat("b", 40), "tail"}})
+	session.MaxResponseBytes = 32
+
+	panel := ollamatea.NewChatPanelWithSession(session)
+	panel.Accessible = true
+	update := func(msg tea.Msg) tea.Cmd {
+		return panel.UpdateInPlace(msg)
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(panel.Init(), session.Start()), ollamateatest.MatchGenerateDone(session.ID()), 0)
+	update(msg)
+
+	view := panel.View()
+	if !strings.Contains(view, "Truncated: ") || !strings.Contains(view, "btail\n") || strings.Contains(view, "aaaa") {
+		t.Errorf("expected the truncated tail in the view %q", view)
+	}
+}
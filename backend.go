@@ -0,0 +1,277 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	ollama "github.com/ollama/ollama/api"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Backends
+//
+// OllamaTea components speak to an LLM service through a Backend.
+// The Ollama API types are used as the lingua franca between the components
+// and the Backend implementations.  By default, components use the Backend
+// for their Host's URL scheme (usually Ollama); set a component's Backend
+// field to use another.
+
+// Type aliases in this package for convenience
+type (
+	GenerateRequest      = ollama.GenerateRequest
+	GenerateResponse     = ollama.GenerateResponse
+	GenerateResponseFunc = ollama.GenerateResponseFunc
+	EmbedRequest         = ollama.EmbedRequest
+	EmbedResponse        = ollama.EmbedResponse
+	ListResponse         = ollama.ListResponse
+	ChatRequest          = ollama.ChatRequest
+	ChatResponse         = ollama.ChatResponse
+	ChatResponseFunc     = ollama.ChatResponseFunc
+	Message              = ollama.Message
+)
+
+// Generator is a Backend that streams text generations.
+// Implementations must invoke fn for every partial response, the last of
+// which has Done set.
+type Generator interface {
+	Generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error
+}
+
+// Embedder is a Backend that creates embeddings.
+type Embedder interface {
+	Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error)
+}
+
+// ModelLister is a Backend that lists available models.
+type ModelLister interface {
+	List(ctx context.Context) (*ListResponse, error)
+}
+
+// Chatter is a Backend that streams chat completions, including tool calls.
+// It is optional; the Ollama API client [ollama.Client] satisfies it.
+type Chatter interface {
+	Chat(ctx context.Context, req *ChatRequest, fn ChatResponseFunc) error
+}
+
+// Backend is the interface to an LLM service used by OllamaTea components.
+// The Ollama API client [ollama.Client] satisfies this interface.
+type Backend interface {
+	Generator
+	Embedder
+	ModelLister
+}
+
+// Compile-time checks for Backend conformance
+var (
+	_ Backend           = (*ollama.Client)(nil)
+	_ Chatter           = (*ollama.Client)(nil)
+	_ Backend           = (*OpenAIBackend)(nil)
+	_ Backend           = (*LlamaCppBackend)(nil)
+	_ Backend           = (*LMStudioBackend)(nil)
+	_ Backend           = (*RecordingBackend)(nil)
+	_ Backend           = (*ReplayBackend)(nil)
+	_ Backend           = (*SimulatedBackend)(nil)
+	_ LogprobsGenerator = (*OpenAIBackend)(nil)
+	_ LogprobsGenerator = (*LlamaCppBackend)(nil)
+)
+
+// NewBackend returns a Backend for the given host URL, selected by its scheme:
+//
+//   - "llamacpp://" or "llamacpp+https://" selects a [LlamaCppBackend] over http or https
+//   - "lmstudio://" or "lmstudio+https://" selects a [LMStudioBackend] over http or https
+//   - "simulate://" selects a [SimulatedBackend], as does any host if [DefaultSimulate]
+//   - anything else selects an Ollama Backend, see [NewOllamaBackend]
+func NewBackend(host string) (Backend, error) {
+	return NewBackendWithHTTPClient(host, nil)
+}
+
+// NewBackendWithHTTPClient is like [NewBackend], but the Backend makes its requests
+// with httpClient, such as one from [HTTPClientWithHeader].  If httpClient is nil,
+// DefaultHTTPClient() is used.
+func NewBackendWithHTTPClient(host string, httpClient *http.Client) (Backend, error) {
+	if httpClient == nil {
+		httpClient = DefaultHTTPClient()
+	}
+	hostURL, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	if DefaultSimulate() && hostURL.Scheme != "simulate" {
+		return NewSimulatedBackend(), nil
+	}
+	switch hostURL.Scheme {
+	case "simulate":
+		return newSimulatedBackendFromURL(hostURL)
+	case "llamacpp", "llamacpp+http":
+		hostURL.Scheme = "http"
+		return newLlamaCppBackendWithHTTPClient(hostURL.String(), httpClient), nil
+	case "llamacpp+https":
+		hostURL.Scheme = "https"
+		return newLlamaCppBackendWithHTTPClient(hostURL.String(), httpClient), nil
+	case "lmstudio", "lmstudio+http":
+		hostURL.Scheme = "http"
+		setDefaultPort(hostURL, defaultLMStudioPort)
+		return newLMStudioBackendWithHTTPClient(hostURL.String(), httpClient), nil
+	case "lmstudio+https":
+		hostURL.Scheme = "https"
+		setDefaultPort(hostURL, defaultLMStudioPort)
+		return newLMStudioBackendWithHTTPClient(hostURL.String(), httpClient), nil
+	}
+	return ollama.NewClient(hostURL, httpClient), nil
+}
+
+// setDefaultPort sets the port of hostURL, if it has a host without one
+func setDefaultPort(hostURL *url.URL, port string) {
+	if hostURL.Hostname() != "" && hostURL.Port() == "" {
+		hostURL.Host = net.JoinHostPort(hostURL.Hostname(), port)
+	}
+}
+
+// NewOllamaBackend returns a Backend for the Ollama server at the given host URL.
+func NewOllamaBackend(host string) (Backend, error) {
+	ollamaURL, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	return ollama.NewClient(ollamaURL, DefaultHTTPClient()), nil
+}
+
+// newLlamaCppBackendWithHTTPClient returns a LlamaCppBackend using httpClient
+func newLlamaCppBackendWithHTTPClient(baseURL string, httpClient *http.Client) *LlamaCppBackend {
+	backend := NewLlamaCppBackend(baseURL)
+	backend.HTTPClient = httpClient
+	return backend
+}
+
+// newLMStudioBackendWithHTTPClient returns a LMStudioBackend using httpClient
+func newLMStudioBackendWithHTTPClient(host string, httpClient *http.Client) *LMStudioBackend {
+	backend := NewLMStudioBackend(host)
+	backend.HTTPClient = httpClient
+	return backend
+}
+
+// resolveBackend returns backend if non-nil, otherwise the shared Backend for host from [SharedBackend].
+func resolveBackend(backend Backend, host string) (Backend, error) {
+	if backend != nil {
+		return backend, nil
+	}
+	return SharedBackend(host)
+}
+
+// resolveClientBackend is like resolveBackend, but if httpClient or header is set,
+// a Backend for host using them is returned instead of the shared one.
+func resolveClientBackend(backend Backend, host string, httpClient *http.Client, header http.Header) (Backend, error) {
+	if backend != nil || (httpClient == nil && len(header) == 0) {
+		return resolveBackend(backend, host)
+	}
+	if len(header) > 0 {
+		httpClient = HTTPClientWithHeader(httpClient, header)
+	}
+	return NewBackendWithHTTPClient(host, httpClient)
+}
+
+// resolveChatter returns the Chatter for backend, or for host if backend is nil.
+// Returns an error if the Backend does not support chat.
+func resolveChatter(backend Backend, host string) (Chatter, error) {
+	backend, err := resolveBackend(backend, host)
+	if err != nil {
+		return nil, err
+	}
+	chatter, ok := backend.(Chatter)
+	if !ok {
+		return nil, fmt.Errorf("backend %T does not support chat", backend)
+	}
+	return chatter, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// HTTP helpers shared by the non-Ollama Backends
+
+// apiErrorResponse is the error body returned by OpenAI-compatible and llama.cpp servers
+type apiErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// joinURLPath appends path to baseURL, avoiding doubled slashes
+func joinURLPath(baseURL string, path string) string {
+	return strings.TrimRight(baseURL, "/") + path
+}
+
+// doHTTPRequest performs a request with a JSON body, if non-nil, and an optional Bearer apiKey.
+// Error responses are converted to an [ollama.StatusError].
+func doHTTPRequest(ctx context.Context, httpClient *http.Client, method string, requestURL string, apiKey string, body any, accept string) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bts, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = bytes.NewReader(bts)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", accept)
+	if apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	if httpClient == nil {
+		httpClient = DefaultHTTPClient()
+	}
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		logger().Debug("http request failed", "method", method, "url", requestURL, "error", err)
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		statusErr := ollama.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		var errResp apiErrorResponse
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
+			statusErr.ErrorMessage = errResp.Error.Message
+		} else {
+			statusErr.ErrorMessage = string(respBody)
+		}
+		logger().Debug("http request error status", "method", method, "url", requestURL, "status", resp.StatusCode, "error", statusErr.ErrorMessage)
+		return nil, statusErr
+	}
+	return resp, nil
+}
+
+// scanServerSentEvents invokes fn with the payload of each "data:" line of
+// a Server-Sent Events stream, until the stream ends or sends "[DONE]".
+func scanServerSentEvents(r io.Reader, fn func(data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, found := strings.CutPrefix(scanner.Text(), "data:")
+		if !found {
+			continue // skip comments, event names, and blank separators
+		}
+		data = strings.TrimPrefix(data, " ") // per the SSE spec, only one leading space is removed
+		if strings.TrimSpace(data) == "[DONE]" {
+			return nil
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,302 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// llama.cpp server Backend
+
+// LlamaCppBackend is a Backend speaking the native API of llama.cpp's server.
+// See https://github.com/ggerganov/llama.cpp/tree/master/examples/server
+//
+// Generations stream from /completion, or /infill when the request has a Suffix.
+// The native API does not apply the model's chat template, so a System prompt
+// is prepended to the Prompt.  Embeddings use /embedding, model lists use
+// /v1/models, and slot state is available with [LlamaCppBackend.Slots].
+//
+// A LlamaCppBackend is also selected by a Host with a "llamacpp://" or
+// "llamacpp+https://" scheme; see [NewBackend].
+type LlamaCppBackend struct {
+	BaseURL    string       // BaseURL is the server root, e.g. "http://localhost:8080"
+	APIKey     string       // APIKey is sent as a Bearer token, if set (server --api-key)
+	SlotID     int          // SlotID is the slot to generate with; -1 lets the server choose
+	HTTPClient *http.Client // HTTPClient is used for requests (default: DefaultHTTPClient())
+}
+
+// NewLlamaCppBackend returns a new LlamaCppBackend for the given server URL.
+func NewLlamaCppBackend(baseURL string) *LlamaCppBackend {
+	return &LlamaCppBackend{
+		BaseURL:    baseURL,
+		SlotID:     -1,
+		HTTPClient: DefaultHTTPClient(),
+	}
+}
+
+// LlamaCppSlot is the state of a llama.cpp server slot, as returned by /slots.
+type LlamaCppSlot struct {
+	ID           int    `json:"id"`
+	NCtx         int    `json:"n_ctx"`
+	IsProcessing bool   `json:"is_processing"`
+	Model        string `json:"model"`
+	Prompt       string `json:"prompt"`
+}
+
+// llamaCppImage is an image attached to a /completion request
+type llamaCppImage struct {
+	Data string `json:"data"`
+	ID   int    `json:"id"`
+}
+
+// llamaCppStreamChunk is a streamed chunk from /completion or /infill
+type llamaCppStreamChunk struct {
+	Content         string `json:"content"`
+	Stop            bool   `json:"stop"`
+	StopType        string `json:"stop_type"`
+	StoppedEOS      bool   `json:"stopped_eos"`
+	StoppedLimit    bool   `json:"stopped_limit"`
+	StoppedWord     bool   `json:"stopped_word"`
+	Model           string `json:"model"`
+	TokensPredicted int    `json:"tokens_predicted"`
+	TokensEvaluated int    `json:"tokens_evaluated"`
+	Timings         *struct {
+		PromptN     int     `json:"prompt_n"`
+		PromptMS    float64 `json:"prompt_ms"`
+		PredictedN  int     `json:"predicted_n"`
+		PredictedMS float64 `json:"predicted_ms"`
+	} `json:"timings"`
+	Probabilities []TokenLogprob `json:"completion_probabilities"` // requested with n_probs
+}
+
+// Generate streams a generation for req, invoking fn for each partial response.
+// Implements the [Generator] interface.
+func (b *LlamaCppBackend) Generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error {
+	return b.generate(ctx, req, false, 0, func(resp GenerateResponse, _ []TokenLogprob) error {
+		return fn(resp)
+	})
+}
+
+// GenerateLogprobs streams a generation for req, invoking fn for each partial
+// response with the log probabilities of its tokens.  The server reports the
+// alternatives of at least one token, so topLogprobs of 0 drops them.
+// Implements the [LogprobsGenerator] interface.
+func (b *LlamaCppBackend) GenerateLogprobs(ctx context.Context, req *GenerateRequest, topLogprobs int, fn LogprobsResponseFunc) error {
+	return b.generate(ctx, req, true, topLogprobs, fn)
+}
+
+// generate streams a generation for req, requesting log probabilities if logprobs is set
+func (b *LlamaCppBackend) generate(ctx context.Context, req *GenerateRequest, logprobs bool, topLogprobs int, fn LogprobsResponseFunc) error {
+	body := llamaCppOptions(req.Options)
+	body["stream"] = true
+	if logprobs {
+		body["n_probs"] = max(topLogprobs, 1)
+	}
+	if b.SlotID >= 0 {
+		body["id_slot"] = b.SlotID
+	}
+	if req.Format == "json" {
+		body["json_schema"] = map[string]any{}
+	}
+
+	prompt := req.Prompt
+	if req.System != "" && !req.Raw {
+		prompt = req.System + "\n\n" + prompt
+	}
+
+	path := "/completion"
+	if req.Suffix != "" {
+		path = "/infill"
+		body["input_prefix"] = prompt
+		body["input_suffix"] = req.Suffix
+	} else {
+		// images are referenced in the prompt as [img-ID]
+		if len(req.Images) > 0 {
+			images := make([]llamaCppImage, len(req.Images))
+			for i, img := range req.Images {
+				prompt += fmt.Sprintf(" [img-%d]", i)
+				images[i] = llamaCppImage{Data: base64.StdEncoding.EncodeToString(img), ID: i}
+			}
+			body["image_data"] = images
+		}
+		body["prompt"] = prompt
+	}
+
+	resp, err := b.do(ctx, http.MethodPost, path, body, "text/event-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return scanServerSentEvents(resp.Body, func(data string) error {
+		var chunk llamaCppStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+		genResp := GenerateResponse{
+			Model:     chunk.Model,
+			CreatedAt: time.Now(),
+			Response:  chunk.Content,
+			Done:      chunk.Stop,
+		}
+		if chunk.Stop {
+			genResp.DoneReason = chunk.doneReason()
+			genResp.PromptEvalCount = chunk.TokensEvaluated
+			genResp.EvalCount = chunk.TokensPredicted
+			if t := chunk.Timings; t != nil {
+				genResp.PromptEvalCount = t.PromptN
+				genResp.PromptEvalDuration = time.Duration(t.PromptMS * float64(time.Millisecond))
+				genResp.EvalCount = t.PredictedN
+				genResp.EvalDuration = time.Duration(t.PredictedMS * float64(time.Millisecond))
+			}
+		}
+		tokens := chunk.Probabilities
+		if topLogprobs <= 0 {
+			for i := range tokens {
+				tokens[i].TopLogprobs = nil
+			}
+		}
+		return fn(genResp, tokens)
+	})
+}
+
+// doneReason maps the llama.cpp stop type to an Ollama DoneReason
+func (c *llamaCppStreamChunk) doneReason() string {
+	switch {
+	case c.StopType == "limit" || c.StoppedLimit:
+		return "length"
+	case c.StopType == "none":
+		return ""
+	default:
+		return "stop"
+	}
+}
+
+// Embed creates embeddings for req.
+// Each input is embedded with a separate /embedding request.
+// Implements the [Embedder] interface.
+func (b *LlamaCppBackend) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	input, err := openAIEmbedInput(req.Input)
+	if err != nil {
+		return nil, err
+	}
+	inputs, ok := input.([]string)
+	if !ok {
+		inputs = []string{input.(string)}
+	}
+
+	embedResp := &EmbedResponse{Model: req.Model}
+	for _, content := range inputs {
+		embedding, err := b.embedOne(ctx, content)
+		if err != nil {
+			return nil, err
+		}
+		embedResp.Embeddings = append(embedResp.Embeddings, embedding)
+	}
+	return embedResp, nil
+}
+
+// embedOne embeds a single content string.
+// Older servers return {"embedding":[...]}, newer ones [{"index":0,"embedding":[...]}].
+func (b *LlamaCppBackend) embedOne(ctx context.Context, content string) ([]float32, error) {
+	resp, err := b.do(ctx, http.MethodPost, "/embedding", map[string]any{"content": content}, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	type embeddingResult struct {
+		Embedding json.RawMessage `json:"embedding"`
+	}
+	var result embeddingResult
+	if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+		var results []embeddingResult
+		if err := json.Unmarshal(raw, &results); err != nil {
+			return nil, fmt.Errorf("unmarshal: %w", err)
+		}
+		if len(results) == 0 {
+			return nil, fmt.Errorf("empty embedding response")
+		}
+		result = results[0]
+	} else if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	// a pooled embedding is a vector; an unpooled one is a vector per token
+	var embedding []float32
+	if err := json.Unmarshal(result.Embedding, &embedding); err == nil {
+		return embedding, nil
+	}
+	var tokenEmbeddings [][]float32
+	if err := json.Unmarshal(result.Embedding, &tokenEmbeddings); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	if len(tokenEmbeddings) == 0 {
+		return nil, fmt.Errorf("empty embedding response")
+	}
+	return tokenEmbeddings[len(tokenEmbeddings)-1], nil
+}
+
+// List lists the loaded model.  A llama.cpp server serves a single model.
+// Implements the [ModelLister] interface.
+func (b *LlamaCppBackend) List(ctx context.Context) (*ListResponse, error) {
+	openAI := OpenAIBackend{BaseURL: joinURLPath(b.BaseURL, "/v1"), APIKey: b.APIKey, HTTPClient: b.HTTPClient}
+	return openAI.List(ctx)
+}
+
+// Slots returns the state of the server's slots.
+// The server must be started with --slots (older servers expose them by default).
+func (b *LlamaCppBackend) Slots(ctx context.Context) ([]LlamaCppSlot, error) {
+	resp, err := b.do(ctx, http.MethodGet, "/slots", nil, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var slots []LlamaCppSlot
+	if err := json.NewDecoder(resp.Body).Decode(&slots); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return slots, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// do performs the request against the BaseURL
+func (b *LlamaCppBackend) do(ctx context.Context, method string, path string, body any, accept string) (*http.Response, error) {
+	return doHTTPRequest(ctx, b.HTTPClient, method, joinURLPath(b.BaseURL, path), b.APIKey, body, accept)
+}
+
+// llamaCppOptions maps Ollama model options to llama.cpp /completion fields.
+// Most share names; unrecognized options are dropped.
+func llamaCppOptions(options map[string]interface{}) map[string]any {
+	body := make(map[string]any)
+	for k, v := range options {
+		switch k {
+		case "temperature", "top_k", "top_p", "min_p", "typical_p", "tfs_z",
+			"seed", "stop", "repeat_penalty", "repeat_last_n",
+			"presence_penalty", "frequency_penalty",
+			"mirostat", "mirostat_tau", "mirostat_eta":
+			body[k] = v
+		case "num_predict":
+			body["n_predict"] = v
+		case "num_keep":
+			body["n_keep"] = v
+		case "penalize_newline":
+			body["penalize_nl"] = v
+		}
+	}
+	return body
+}
@@ -0,0 +1,100 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	ollama "github.com/ollama/ollama/api"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LM Studio Backend
+
+// DefaultLMStudioHost is the default URL of LM Studio's local server.
+const DefaultLMStudioHost = "http://localhost:" + defaultLMStudioPort
+
+// defaultLMStudioPort is the port of LM Studio's local server, used by a
+// "lmstudio://" Host without one
+const defaultLMStudioPort = "1234"
+
+// LMStudioBackend is a Backend preset for LM Studio's local server.
+// See https://lmstudio.ai/docs/api
+//
+// Generations and embeddings use its OpenAI-compatible /v1 endpoints.
+// Models are listed with its native /api/v0/models endpoint, which reports
+// each model's architecture, quantization, and type; servers without it
+// fall back to /v1/models.
+//
+// A LMStudioBackend is also selected by a Host with a "lmstudio://" or
+// "lmstudio+https://" scheme; see [NewBackend].
+type LMStudioBackend struct {
+	*OpenAIBackend
+
+	Host string // Host is the server root, e.g. [DefaultLMStudioHost]
+}
+
+// NewLMStudioBackend returns a new LMStudioBackend for the given server URL.
+// If host is empty, [DefaultLMStudioHost] is used.
+func NewLMStudioBackend(host string) *LMStudioBackend {
+	if host == "" {
+		host = DefaultLMStudioHost
+	}
+	return &LMStudioBackend{
+		OpenAIBackend: NewOpenAIBackend(joinURLPath(host, "/v1"), ""),
+		Host:          host,
+	}
+}
+
+// lmStudioModel is a model entry from /api/v0/models
+type lmStudioModel struct {
+	ID                string `json:"id"`
+	Type              string `json:"type"` // "llm", "vlm", or "embeddings"
+	Publisher         string `json:"publisher"`
+	Arch              string `json:"arch"`
+	CompatibilityType string `json:"compatibility_type"` // "gguf" or "mlx"
+	Quantization      string `json:"quantization"`
+	State             string `json:"state"` // "loaded" or "not-loaded"
+	MaxContextLength  int    `json:"max_context_length"`
+}
+
+// List lists the models available to LM Studio.
+// Implements the [ModelLister] interface.
+func (b *LMStudioBackend) List(ctx context.Context) (*ListResponse, error) {
+	resp, err := doHTTPRequest(ctx, b.HTTPClient, http.MethodGet,
+		joinURLPath(b.Host, "/api/v0/models"), b.APIKey, nil, "application/json")
+	if err != nil {
+		var statusErr ollama.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return b.OpenAIBackend.List(ctx)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var modelsResp struct {
+		Data []lmStudioModel `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	listResp := &ListResponse{}
+	for _, m := range modelsResp.Data {
+		listResp.Models = append(listResp.Models, ollama.ListModelResponse{
+			Name:  m.ID,
+			Model: m.ID,
+			Details: ollama.ModelDetails{
+				Format:            m.CompatibilityType,
+				Family:            m.Arch,
+				Families:          []string{m.Type},
+				QuantizationLevel: m.Quantization,
+			},
+		})
+	}
+	return listResp, nil
+}
@@ -0,0 +1,28 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func TestLMStudioBackendHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"lmstudio://localhost":             ollamatea.DefaultLMStudioHost,
+		"lmstudio+http://10.0.0.5":         "http://10.0.0.5:1234",
+		"lmstudio+https://example.com":     "https://example.com:1234",
+		"lmstudio://localhost:4321":        "http://localhost:4321",
+		"lmstudio+https://example.com:443": "https://example.com:443",
+	} {
+		backend, err := ollamatea.NewBackend(host)
+		if err != nil {
+			t.Fatalf("NewBackend(%q) failed: %v", host, err)
+		}
+		lmstudio, ok := backend.(*ollamatea.LMStudioBackend)
+		if !ok || lmstudio.Host != want {
+			t.Errorf("expected NewBackend(%q) to use %s, got %+v", host, want, backend)
+		}
+	}
+}
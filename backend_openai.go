@@ -0,0 +1,349 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	ollama "github.com/ollama/ollama/api"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// OpenAI-compatible Backend
+
+// OpenAIBackend is a Backend speaking the OpenAI-compatible HTTP API,
+// as served by vLLM, OpenRouter, LM Studio, or Ollama's own /v1 endpoint.
+//
+// Generations use the streaming /chat/completions endpoint, unless the
+// request has a Suffix or is Raw, in which case /completions is used.
+// Embeddings use /embeddings and model lists use /models.
+type OpenAIBackend struct {
+	BaseURL    string       // BaseURL is the API root, e.g. "http://localhost:11434/v1"
+	APIKey     string       // APIKey is sent as a Bearer token, if set
+	HTTPClient *http.Client // HTTPClient is used for requests (default: DefaultHTTPClient())
+}
+
+// NewOpenAIBackend returns a new OpenAIBackend for the given base URL and API key.
+func NewOpenAIBackend(baseURL string, apiKey string) *OpenAIBackend {
+	return &OpenAIBackend{
+		BaseURL:    baseURL,
+		APIKey:     apiKey,
+		HTTPClient: DefaultHTTPClient(),
+	}
+}
+
+// openAIMessage is an OpenAI chat message; Content is a string or a slice of parts.
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// openAIStreamChunk is a streamed chunk from either /chat/completions or /completions
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Created int64  `json:"created"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		Text         string          `json:"text"`
+		FinishReason *string         `json:"finish_reason"`
+		Logprobs     *openAILogprobs `json:"logprobs"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// openAILogprobs are a choice's log probabilities: Content from /chat/completions,
+// or the parallel Tokens, TokenLogprobs, and TopLogprobs from /completions
+type openAILogprobs struct {
+	Content       []TokenLogprob       `json:"content"`
+	Tokens        []string             `json:"tokens"`
+	TokenLogprobs []float64            `json:"token_logprobs"`
+	TopLogprobs   []map[string]float64 `json:"top_logprobs"`
+}
+
+// tokens returns the log probabilities as TokenLogprobs
+func (l *openAILogprobs) tokens() []TokenLogprob {
+	if l == nil {
+		return nil
+	}
+	if l.Content != nil {
+		return l.Content
+	}
+	tokens := make([]TokenLogprob, 0, len(l.Tokens))
+	for i, token := range l.Tokens {
+		t := TokenLogprob{Token: token}
+		if i < len(l.TokenLogprobs) {
+			t.Logprob = l.TokenLogprobs[i]
+		}
+		if i < len(l.TopLogprobs) {
+			for alt, logprob := range l.TopLogprobs[i] {
+				t.TopLogprobs = append(t.TopLogprobs, TokenLogprob{Token: alt, Logprob: logprob})
+			}
+			slices.SortFunc(t.TopLogprobs, func(a, b TokenLogprob) int {
+				return cmp.Compare(b.Logprob, a.Logprob)
+			})
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// Generate streams a generation for req, invoking fn for each partial response.
+// Implements the [Generator] interface.
+func (b *OpenAIBackend) Generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error {
+	return b.generate(ctx, req, false, 0, func(resp GenerateResponse, _ []TokenLogprob) error {
+		return fn(resp)
+	})
+}
+
+// GenerateLogprobs streams a generation for req, invoking fn for each partial
+// response with the log probabilities of its tokens.
+// Implements the [LogprobsGenerator] interface.
+func (b *OpenAIBackend) GenerateLogprobs(ctx context.Context, req *GenerateRequest, topLogprobs int, fn LogprobsResponseFunc) error {
+	return b.generate(ctx, req, true, topLogprobs, fn)
+}
+
+// generate streams a generation for req, requesting log probabilities if logprobs is set
+func (b *OpenAIBackend) generate(ctx context.Context, req *GenerateRequest, logprobs bool, topLogprobs int, fn LogprobsResponseFunc) error {
+	body := openAIOptions(req.Options)
+	body["model"] = req.Model
+	body["stream"] = true
+	body["stream_options"] = map[string]bool{"include_usage": true} // else streams omit the token counts
+
+	path := "/chat/completions"
+	if req.Suffix != "" || req.Raw {
+		path = "/completions"
+		body["prompt"] = req.Prompt
+		if req.Suffix != "" {
+			body["suffix"] = req.Suffix
+		}
+		if logprobs {
+			body["logprobs"] = max(topLogprobs, 0) // the count of alternatives
+		}
+	} else {
+		body["messages"] = openAIMessages(req)
+		if logprobs {
+			body["logprobs"] = true
+			if topLogprobs > 0 {
+				body["top_logprobs"] = topLogprobs
+			}
+		}
+	}
+	if req.Format == "json" {
+		body["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	resp, err := b.post(ctx, path, body, "text/event-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var doneReason string
+	var last GenerateResponse
+	err = scanServerSentEvents(resp.Body, func(data string) error {
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+		last.Model = chunk.Model
+		last.CreatedAt = time.Unix(chunk.Created, 0)
+		if chunk.Usage != nil {
+			last.PromptEvalCount = chunk.Usage.PromptTokens
+			last.EvalCount = chunk.Usage.CompletionTokens
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != nil {
+				doneReason = *choice.FinishReason
+			}
+			text := choice.Delta.Content + choice.Text
+			if text == "" {
+				continue
+			}
+			partial := last
+			partial.Response = text
+			if err := fn(partial, choice.Logprobs.tokens()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	last.Response = ""
+	last.Done = true
+	last.DoneReason = doneReason
+	return fn(last, nil)
+}
+
+// Embed creates embeddings for req.
+// Implements the [Embedder] interface.
+func (b *OpenAIBackend) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	input, err := openAIEmbedInput(req.Input)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]any{
+		"model": req.Model,
+		"input": input,
+	}
+
+	resp, err := b.post(ctx, "/embeddings", body, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embedResp struct {
+		Model string `json:"model"`
+		Data  []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	embeddings := make([][]float32, len(embedResp.Data))
+	for i, d := range embedResp.Data {
+		if d.Index >= 0 && d.Index < len(embeddings) {
+			embeddings[d.Index] = d.Embedding
+		} else {
+			embeddings[i] = d.Embedding
+		}
+	}
+	return &EmbedResponse{
+		Model:           embedResp.Model,
+		Embeddings:      embeddings,
+		PromptEvalCount: embedResp.Usage.PromptTokens,
+	}, nil
+}
+
+// List lists the available models.
+// Implements the [ModelLister] interface.
+func (b *OpenAIBackend) List(ctx context.Context) (*ListResponse, error) {
+	resp, err := b.do(ctx, http.MethodGet, "/models", nil, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var modelsResp struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Created int64  `json:"created"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	listResp := &ListResponse{}
+	for _, m := range modelsResp.Data {
+		listResp.Models = append(listResp.Models, ollama.ListModelResponse{
+			Name:       m.ID,
+			Model:      m.ID,
+			ModifiedAt: time.Unix(m.Created, 0),
+			Details:    ollama.ModelDetails{Family: m.OwnedBy},
+		})
+	}
+	return listResp, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// post performs a JSON POST to the path
+func (b *OpenAIBackend) post(ctx context.Context, path string, body any, accept string) (*http.Response, error) {
+	return b.do(ctx, http.MethodPost, path, body, accept)
+}
+
+// do performs the request against the BaseURL
+func (b *OpenAIBackend) do(ctx context.Context, method string, path string, body any, accept string) (*http.Response, error) {
+	return doHTTPRequest(ctx, b.HTTPClient, method, joinURLPath(b.BaseURL, path), b.APIKey, body, accept)
+}
+
+// openAIMessages converts a GenerateRequest to OpenAI chat messages
+func openAIMessages(req *GenerateRequest) []openAIMessage {
+	var messages []openAIMessage
+	if req.System != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: req.System})
+	}
+	if len(req.Images) == 0 {
+		return append(messages, openAIMessage{Role: "user", Content: req.Prompt})
+	}
+	parts := []openAIContentPart{{Type: "text", Text: req.Prompt}}
+	for _, img := range req.Images {
+		parts = append(parts, openAIContentPart{
+			Type: "image_url",
+			ImageURL: &openAIImageURL{
+				URL: "data:" + http.DetectContentType(img) + ";base64," + base64.StdEncoding.EncodeToString(img),
+			},
+		})
+	}
+	return append(messages, openAIMessage{Role: "user", Content: parts})
+}
+
+// openAIOptions maps Ollama model options to OpenAI request fields.
+// Unrecognized options are dropped.
+func openAIOptions(options map[string]interface{}) map[string]any {
+	body := make(map[string]any)
+	for k, v := range options {
+		switch k {
+		case "temperature", "top_p", "seed", "stop", "presence_penalty", "frequency_penalty":
+			body[k] = v
+		case "num_predict":
+			body["max_tokens"] = v
+		}
+	}
+	return body
+}
+
+// openAIEmbedInput converts an EmbedRequest Input to a string or []string
+func openAIEmbedInput(input any) (any, error) {
+	switch input := input.(type) {
+	case string, []string:
+		return input, nil
+	case []byte:
+		return string(input), nil
+	case []any:
+		strs := make([]string, len(input))
+		for i, v := range input {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid input type %T", v)
+			}
+			strs[i] = s
+		}
+		return strs, nil
+	default:
+		return nil, fmt.Errorf("invalid input type %T", input)
+	}
+}
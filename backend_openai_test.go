@@ -0,0 +1,46 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func TestOpenAIBackendUsage(t *testing.T) {
+	var reqBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"Hi"},"finish_reason":"stop"}]}`+"\n\n")
+		// with include_usage, the counts follow in a chunk without choices
+		fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":7,"completion_tokens":2}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	backend := ollamatea.NewOpenAIBackend(srv.URL, "")
+	var done ollamatea.GenerateResponse
+	err := backend.Generate(context.Background(), &ollamatea.GenerateRequest{Model: "gpt-4o-mini", Prompt: "hello"},
+		func(resp ollamatea.GenerateResponse) error {
+			if resp.Done {
+				done = resp
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options, _ := reqBody["stream_options"].(map[string]any); options["include_usage"] != true {
+		t.Errorf("expected usage requested, got %v", reqBody)
+	}
+	if done.PromptEvalCount != 7 || done.EvalCount != 2 {
+		t.Errorf("expected the usage counted, got %+v", done.Metrics)
+	}
+}
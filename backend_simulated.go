@@ -0,0 +1,219 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"time"
+	"unicode"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// Simulated Backend
+
+const (
+	// DefaultSimulatedTokensPerSecond is the pace of a SimulatedBackend from [NewSimulatedBackend].
+	DefaultSimulatedTokensPerSecond = 20
+	// DefaultSimulatedModel is the model listed by a SimulatedBackend without Models.
+	DefaultSimulatedModel = "simulated"
+	// DefaultSimulatedText is streamed by a SimulatedBackend without Text.
+	DefaultSimulatedText = "This is a simulated response from OllamaTea.  " +
+		"No model was run; the text is streamed at a steady pace so that demos, " +
+		"tests, and interfaces can be developed without Ollama installed."
+)
+
+// ErrSimulated is the error of a SimulatedBackend generation failed by its ErrorRate.
+var ErrSimulated = errors.New("simulated generation error")
+
+// SimulatedBackend is a Backend which needs no server.  Generations stream
+// canned text word by word at TokensPerSecond, optionally failing partway;
+// embeddings are derived deterministically from each input.
+//
+// A SimulatedBackend is also selected by a Host with a "simulate://" scheme,
+// whose query may set "tps", "error_rate", and "text"; see [NewBackend].
+// Setting the OLLAMATEA_SIMULATE environment variable selects it for every Host.
+type SimulatedBackend struct {
+	Text            string   // Text streamed by each generation; if empty, DefaultSimulatedText
+	TokensPerSecond float64  // TokensPerSecond paces the stream; 0 streams instantly
+	ErrorRate       float64  // ErrorRate is the probability, from 0 to 1, that a generation fails partway
+	Err             error    // Err is the error of failed generations (default: ErrSimulated)
+	Models          []string // Models to list; if empty, DefaultSimulatedModel
+	Dimensions      int      // Dimensions of embeddings (default: 8)
+}
+
+// NewSimulatedBackend returns a new SimulatedBackend with the default text and pace.
+func NewSimulatedBackend() *SimulatedBackend {
+	return &SimulatedBackend{
+		TokensPerSecond: DefaultSimulatedTokensPerSecond,
+	}
+}
+
+// newSimulatedBackendFromURL returns a SimulatedBackend configured by the query of a "simulate://" URL
+func newSimulatedBackendFromURL(hostURL *url.URL) (*SimulatedBackend, error) {
+	b := NewSimulatedBackend()
+	query := hostURL.Query()
+	if tps := query.Get("tps"); tps != "" {
+		v, err := strconv.ParseFloat(tps, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid simulate tps %q: %w", tps, err)
+		}
+		b.TokensPerSecond = v
+	}
+	if rate := query.Get("error_rate"); rate != "" {
+		v, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid simulate error_rate %q: %w", rate, err)
+		}
+		b.ErrorRate = v
+	}
+	b.Text = query.Get("text")
+	return b, nil
+}
+
+// Generate streams the Text word by word, at TokensPerSecond.
+// Implements the [Generator] interface.
+func (b *SimulatedBackend) Generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error {
+	text := b.Text
+	if text == "" {
+		text = DefaultSimulatedText
+	}
+	tokens := simulatedTokens(text)
+	failAt := -1
+	if b.ErrorRate > 0 && rand.Float64() < b.ErrorRate {
+		failAt = len(tokens) / 2
+	}
+	var interval time.Duration
+	if b.TokensPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / b.TokensPerSecond)
+	}
+
+	start := time.Now()
+	for i, token := range tokens {
+		if i == failAt {
+			if b.Err != nil {
+				return b.Err
+			}
+			return ErrSimulated
+		}
+		if interval > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := fn(GenerateResponse{Model: req.Model, CreatedAt: time.Now(), Response: token})
+		if err != nil {
+			return err
+		}
+	}
+
+	elapsed := time.Since(start)
+	return fn(GenerateResponse{
+		Model:      req.Model,
+		CreatedAt:  time.Now(),
+		Done:       true,
+		DoneReason: "stop",
+		Metrics: Metrics{
+			TotalDuration:   elapsed,
+			PromptEvalCount: len(simulatedTokens(req.Prompt)),
+			EvalCount:       len(tokens),
+			EvalDuration:    elapsed,
+		},
+	})
+}
+
+// Embed returns a deterministic, unit-length embedding of each input.
+// Implements the [Embedder] interface.
+func (b *SimulatedBackend) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	var inputs []string
+	switch input := req.Input.(type) {
+	case string:
+		inputs = []string{input}
+	case []string:
+		inputs = input
+	case []any:
+		for _, v := range input {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid embed input type %T", v)
+			}
+			inputs = append(inputs, str)
+		}
+	default:
+		return nil, fmt.Errorf("invalid embed input type %T", req.Input)
+	}
+	dimensions := b.Dimensions
+	if dimensions <= 0 {
+		dimensions = 8
+	}
+	resp := &EmbedResponse{Model: req.Model}
+	for _, input := range inputs {
+		resp.Embeddings = append(resp.Embeddings, simulatedEmbedding(input, dimensions))
+		resp.PromptEvalCount += len(simulatedTokens(input))
+	}
+	return resp, nil
+}
+
+// List returns the Models.
+// Implements the [ModelLister] interface.
+func (b *SimulatedBackend) List(ctx context.Context) (*ListResponse, error) {
+	models := b.Models
+	if len(models) == 0 {
+		models = []string{DefaultSimulatedModel}
+	}
+	resp := &ListResponse{}
+	for _, model := range models {
+		resp.Models = append(resp.Models, ListModelResponse{Name: model, Model: model})
+	}
+	return resp, nil
+}
+
+// simulatedTokens splits text into words, each with its trailing whitespace
+func simulatedTokens(text string) []string {
+	var tokens []string
+	start, prevSpace := 0, false
+	for i, r := range text {
+		isSpace := unicode.IsSpace(r)
+		if prevSpace && !isSpace {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		prevSpace = isSpace
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+// simulatedEmbedding returns a deterministic, unit-length embedding of input
+func simulatedEmbedding(input string, dimensions int) []float32 {
+	embedding := make([]float32, dimensions)
+	var norm float64
+	for i := range embedding {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%d:%s", i, input)
+		v := float64(h.Sum64()%2000)/1000 - 1
+		embedding[i] = float32(v)
+		norm += v * v
+	}
+	if norm > 0 {
+		scale := 1 / math.Sqrt(norm)
+		for i := range embedding {
+			embedding[i] *= float32(scale)
+		}
+	}
+	return embedding
+}
@@ -0,0 +1,43 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func TestSimulatedBackend(t *testing.T) {
+	backend, err := ollamatea.NewBackend("simulate://?tps=0&text=one%20two%20three")
+	if err != nil {
+		t.Fatalf("NewBackend failed: %v", err)
+	}
+	var chunks []string
+	var done bool
+	err = backend.Generate(context.Background(), &ollamatea.GenerateRequest{Prompt: "hi"}, func(resp ollamatea.GenerateResponse) error {
+		chunks = append(chunks, resp.Response)
+		done = resp.Done
+		return nil
+	})
+	if err != nil || !done || strings.Join(chunks, "") != "one two three" || len(chunks) != 4 {
+		t.Errorf("unexpected generation %q, done %v, err %v", chunks, done, err)
+	}
+
+	embed, err := backend.Embed(context.Background(), &ollamatea.EmbedRequest{Input: []string{"a", "b"}})
+	if err != nil || len(embed.Embeddings) != 2 || len(embed.Embeddings[0]) != 8 {
+		t.Errorf("unexpected embedding %+v, err %v", embed, err)
+	}
+}
+
+func TestSimulatedBackendError(t *testing.T) {
+	backend := ollamatea.NewSimulatedBackend()
+	backend.TokensPerSecond, backend.ErrorRate = 0, 1
+	err := backend.Generate(context.Background(), &ollamatea.GenerateRequest{}, func(ollamatea.GenerateResponse) error { return nil })
+	if !errors.Is(err, ollamatea.ErrSimulated) {
+		t.Errorf("expected ErrSimulated, got %v", err)
+	}
+}
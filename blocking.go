@@ -0,0 +1,31 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Blocking helpers
+//
+// Command-line tools and scripts often make a single request and exit.
+// Rather than running a headless tea.Program for it, they may call these
+// helpers, which make the request directly with the Session's configuration:
+//
+//	s := ollamatea.NewSession()
+//	s.Prompt = "Why is the sky blue?"
+//	response, err := ollamatea.GenerateSync(ctx, &s)
+//
+// An EmbedSession embeds the same way with [EmbedSession.Embed].
+
+// GenerateSync performs a blocking generation for the Session, bypassing the
+// BubbleTea message loop, and returns its full response.
+// See [Session.Generate] to also receive the streamed partial responses.
+func GenerateSync(ctx context.Context, s *Session) (string, error) {
+	doneMsg, err := s.Generate(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	return doneMsg.Response, nil
+}
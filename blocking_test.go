@@ -0,0 +1,50 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestGenerateSync(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	s := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"Hello", ", ", "world"}},
+		ollamateatest.GenerateScript{Status: 500, Error: "out of memory"})
+	response, err := ollamatea.GenerateSync(context.Background(), s)
+	if err != nil || response != "Hello, world" {
+		t.Fatalf("unexpected response %q, error %v", response, err)
+	}
+	if response, err := ollamatea.GenerateSync(context.Background(), s); err == nil || response != "" || s.Error() == nil {
+		t.Errorf("expected an error, got response %q", response)
+	}
+}
+
+func TestEmbedSessionEmbed(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithModel("all-minilm"),
+		ollamatea.WithInput("hello"))
+	resp, err := s.Embed(context.Background())
+	if err != nil || len(resp.Embeddings) != 1 || s.Response() != resp {
+		t.Fatalf("unexpected response %+v, error %v", resp, err)
+	}
+	if !slices.Equal(resp.Embeddings[0], ollamateatest.FakeEmbedding("hello", ollamateatest.DefaultEmbeddingDimensions)) {
+		t.Errorf("unexpected embedding %v", resp.Embeddings[0])
+	}
+
+	srv.QueueEmbed(ollamateatest.EmbedScript{Status: 500, Error: "out of memory"})
+	if _, err := s.Embed(context.Background()); err == nil || s.Response() != nil || s.Error() == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,212 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Response caching
+//
+// A Session with a Cache consults it before generating.  Only deterministic
+// requests are cached: those with a temperature of 0 or a fixed seed.  A hit
+// is delivered immediately as a single, Done response.
+
+// CachedResponse is a complete generation stored in a ResponseCache.
+type CachedResponse struct {
+	Response   string    `json:"response"`
+	DoneReason string    `json:"done_reason,omitempty"`
+	Context    []int     `json:"context,omitempty"`
+	Metrics    Metrics   `json:"metrics"`
+	CreatedAt  time.Time `json:"created_at"` // CreatedAt is when the response was cached
+}
+
+// ResponseCache stores complete generations by key.
+// Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	// Get returns the unexpired response for the key, if any.
+	Get(key string) (CachedResponse, bool)
+	// Set stores the response for the key.
+	Set(key string, resp CachedResponse) error
+}
+
+// ResponseCacheKey returns the cache key for a generation request to the host.
+// Returns false if the request is not deterministic, and so should not be cached.
+func ResponseCacheKey(host string, req *GenerateRequest) (string, bool) {
+	if !isDeterministic(req.Options) {
+		return "", false
+	}
+	keyData := struct {
+		Host     string
+		Request  *GenerateRequest
+		Encoding int // bump to invalidate old caches
+	}{host, req, 1}
+	bts, err := json.Marshal(keyData)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(bts)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// isDeterministic returns true if the options fix the sampling, with a
+// temperature of 0 or a seed other than -1 (random).
+func isDeterministic(options map[string]interface{}) bool {
+	if temp, ok := asFloat(options["temperature"]); ok && temp == 0 {
+		return true
+	}
+	if seed, ok := asFloat(options["seed"]); ok && seed != -1 {
+		return true
+	}
+	return false
+}
+
+// asFloat converts numeric option values to float64
+func asFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// MemoryCache is an in-memory ResponseCache.
+type MemoryCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]CachedResponse
+}
+
+// NewMemoryCache returns a new MemoryCache whose entries expire after ttl.
+// A ttl of 0 means entries never expire.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		ttl:     ttl,
+		entries: make(map[string]CachedResponse),
+	}
+}
+
+// Get returns the unexpired response for the key, if any.
+func (c *MemoryCache) Get(key string) (CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.entries[key]
+	if !ok {
+		return resp, false
+	}
+	if isExpired(resp, c.ttl) {
+		delete(c.entries, key)
+		return CachedResponse{}, false
+	}
+	return resp, true
+}
+
+// Set stores the response for the key.
+func (c *MemoryCache) Set(key string, resp CachedResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = resp
+	return nil
+}
+
+// Len returns the number of entries, including expired ones not yet evicted.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// DiskCache is a ResponseCache storing each entry as a JSON file in a directory,
+// so it persists across runs.
+type DiskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewDiskCache returns a new DiskCache in dir, creating it if needed.
+// Its entries expire after ttl; a ttl of 0 means entries never expire.
+func NewDiskCache(dir string, ttl time.Duration) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	return &DiskCache{dir: dir, ttl: ttl}, nil
+}
+
+// Dir returns the DiskCache's directory.
+func (c *DiskCache) Dir() string {
+	return c.dir
+}
+
+// Get returns the unexpired response for the key, if any.
+func (c *DiskCache) Get(key string) (CachedResponse, bool) {
+	var resp CachedResponse
+	bts, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return resp, false
+	}
+	if err := json.Unmarshal(bts, &resp); err != nil {
+		return resp, false
+	}
+	if isExpired(resp, c.ttl) {
+		os.Remove(c.path(key))
+		return CachedResponse{}, false
+	}
+	return resp, true
+}
+
+// Set stores the response for the key.
+// The file is written atomically, so concurrent readers never see partial entries.
+func (c *DiskCache) Set(key string, resp CachedResponse) error {
+	bts, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(bts)
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// path returns the file path for the key
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// isExpired returns true if the response is older than a non-zero ttl
+func isExpired(resp CachedResponse, ttl time.Duration) bool {
+	return ttl > 0 && time.Since(resp.CreatedAt) > ttl
+}
@@ -0,0 +1,190 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Text chunking
+//
+// Embedding models have small context windows, and retrieval works best over
+// passages rather than whole documents.  A Chunker splits text into
+// overlapping chunks by runes, sentences, or estimated tokens, keeping the
+// byte offsets of each so a match can be located in its source.  Set an
+// EmbedSession's Chunker to embed each chunk of its Input.
+
+// ChunkMode is the unit by which a Chunker measures chunks
+type ChunkMode int
+
+const (
+	ChunkByTokens    ChunkMode = iota // ChunkByTokens measures chunks in estimated tokens, breaking between words
+	ChunkByRunes                      // ChunkByRunes measures chunks in runes, breaking anywhere
+	ChunkBySentences                  // ChunkBySentences measures chunks in sentences
+)
+
+// DefaultChunkSize is the Size of a Chunker whose Size is not positive,
+// suited to small embedding models in ChunkByTokens mode.
+const DefaultChunkSize = 256
+
+// String returns the name of the ChunkMode
+func (m ChunkMode) String() string {
+	switch m {
+	case ChunkByTokens:
+		return "tokens"
+	case ChunkByRunes:
+		return "runes"
+	case ChunkBySentences:
+		return "sentences"
+	default:
+		return "unknown"
+	}
+}
+
+// Chunk is a piece of a source text
+type Chunk struct {
+	Text  string // Text of the chunk
+	Start int    // Start is the byte offset of Text in the source
+	End   int    // End is the byte offset following Text in the source
+}
+
+// Chunker splits text into chunks of Size units of its Mode, with consecutive
+// chunks sharing Overlap units, so a passage spanning a break is whole in one.
+type Chunker struct {
+	Mode    ChunkMode // Mode is the unit of Size and Overlap
+	Size    int       // Size of each chunk; if not positive, DefaultChunkSize is used
+	Overlap int       // Overlap of consecutive chunks; it must be less than Size
+	Model   string    // Model adjusts the token estimates of ChunkByTokens; it may be empty
+}
+
+// NewChunker returns a Chunker of size units of the mode, overlapping by overlap units
+func NewChunker(mode ChunkMode, size int, overlap int) *Chunker {
+	return &Chunker{Mode: mode, Size: size, Overlap: overlap}
+}
+
+// chunkUnit is a measured span of the source text
+type chunkUnit struct {
+	start, end int // byte offsets
+	weight     int // size in the Chunker's units
+}
+
+// Chunk splits the text into chunks, in order.  Whitespace around the text is
+// trimmed, as is whitespace around each chunk, except in ChunkByRunes mode.
+// A unit larger than Size, such as a long sentence, is a chunk by itself.
+// Returns nil if the text is blank.
+func (c *Chunker) Chunk(text string) []Chunk {
+	size := c.Size
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	overlap := min(max(c.Overlap, 0), size-1)
+
+	units := c.units(text)
+	var chunks []Chunk
+	for first := 0; first < len(units); {
+		// take units until the chunk is full
+		last, weight := first, 0
+		for last < len(units) && (last == first || weight+units[last].weight <= size) {
+			weight += units[last].weight
+			last++
+		}
+		start, end := units[first].start, units[last-1].end
+		chunks = append(chunks, Chunk{Text: text[start:end], Start: start, End: end})
+		if last == len(units) {
+			break
+		}
+		// step back over the overlap, but always move forward
+		next, shared := last, 0
+		for next-1 > first && shared+units[next-1].weight <= overlap {
+			shared += units[next-1].weight
+			next--
+		}
+		first = next
+	}
+	return chunks
+}
+
+// units splits the text into the measured spans of the Chunker's Mode, without surrounding whitespace
+func (c *Chunker) units(text string) []chunkUnit {
+	var units []chunkUnit
+	switch c.Mode {
+	case ChunkByRunes:
+		for i, r := range text {
+			if !unicode.IsSpace(r) || len(units) > 0 {
+				units = append(units, chunkUnit{start: i, end: i + utf8.RuneLen(r), weight: 1})
+			}
+		}
+		for len(units) > 0 {
+			if r, _ := utf8.DecodeRuneInString(text[units[len(units)-1].start:]); !unicode.IsSpace(r) {
+				break
+			}
+			units = units[:len(units)-1]
+		}
+
+	case ChunkBySentences:
+		for _, span := range sentenceSpans(text) {
+			units = append(units, chunkUnit{start: span[0], end: span[1], weight: 1})
+		}
+
+	default: // ChunkByTokens
+		for _, span := range wordSpans(text) {
+			word := text[span[0]:span[1]]
+			units = append(units, chunkUnit{start: span[0], end: span[1], weight: max(EstimateTokens(word, c.Model), 1)})
+		}
+	}
+	return units
+}
+
+// wordSpans returns the byte offsets of the whitespace-separated words of text
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r) && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		case !unicode.IsSpace(r) && start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// sentenceSpans returns the byte offsets of the sentences of text, which end
+// with terminal punctuation followed by whitespace, or with a blank line
+func sentenceSpans(text string) [][2]int {
+	var spans [][2]int
+	add := func(start, end int) {
+		sentence := text[start:end]
+		trimmed := strings.TrimLeftFunc(sentence, unicode.IsSpace)
+		start += len(sentence) - len(trimmed)
+		end = start + len(strings.TrimRightFunc(trimmed, unicode.IsSpace))
+		if end > start {
+			spans = append(spans, [2]int{start, end})
+		}
+	}
+	start := 0
+	for i, r := range text {
+		if !unicode.IsSpace(r) || i == 0 {
+			continue
+		}
+		prev, _ := utf8.DecodeLastRuneInString(text[:i])
+		switch {
+		case strings.ContainsRune(".!?。！？", prev):
+			add(start, i)
+			start = i
+		case r == '\n' && strings.HasPrefix(text[i+1:], "\n"):
+			add(start, i)
+			start = i
+		}
+	}
+	add(start, len(text))
+	return spans
+}
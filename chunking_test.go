@@ -0,0 +1,75 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func chunkTexts(chunks []ollamatea.Chunk) []string {
+	var texts []string
+	for _, chunk := range chunks {
+		texts = append(texts, chunk.Text)
+	}
+	return texts
+}
+
+func TestChunker(t *testing.T) {
+	text := "  One two three. Four five six!\n\nSeven eight nine? Ten.  "
+	tests := []struct {
+		chunker *ollamatea.Chunker
+		want    []string
+	}{
+		{ollamatea.NewChunker(ollamatea.ChunkBySentences, 2, 0), []string{"One two three. Four five six!", "Seven eight nine? Ten."}},
+		{ollamatea.NewChunker(ollamatea.ChunkBySentences, 2, 1), []string{"One two three. Four five six!", "Four five six!\n\nSeven eight nine?", "Seven eight nine? Ten."}},
+		{ollamatea.NewChunker(ollamatea.ChunkByTokens, 4, 0), []string{"One two three.", "Four five six!", "Seven eight nine?", "Ten."}},
+		{ollamatea.NewChunker(ollamatea.ChunkByTokens, 4, 2), []string{"One two three.", "three. Four five", "Four five six!", "six!\n\nSeven eight", "Seven eight nine?", "nine? Ten."}},
+		{ollamatea.NewChunker(ollamatea.ChunkByRunes, 12, 0), []string{"One two thre", "e. Four five", " six!\n\nSeven", " eight nine?", " Ten."}},
+	}
+	for _, test := range tests {
+		chunks := test.chunker.Chunk(text)
+		if got := chunkTexts(chunks); !slices.Equal(got, test.want) {
+			t.Errorf("%s: expected %q, got %q", test.chunker.Mode, test.want, got)
+		}
+		for _, chunk := range chunks {
+			if text[chunk.Start:chunk.End] != chunk.Text {
+				t.Errorf("%s: chunk %q has offsets of %q", test.chunker.Mode, chunk.Text, text[chunk.Start:chunk.End])
+			}
+		}
+	}
+	if chunks := ollamatea.NewChunker(ollamatea.ChunkByTokens, 0, 0).Chunk(" \n "); chunks != nil {
+		t.Errorf("expected no chunks of blank text, got %v", chunks)
+	}
+}
+
+func TestEmbedSessionChunker(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	text := "The sky is blue. Grass is green. Snow is white."
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithInput(text),
+		ollamatea.WithChunker(ollamatea.NewChunker(ollamatea.ChunkBySentences, 1, 0)))
+	if _, err := s.Embed(context.Background()); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	embeddings := s.ChunkEmbeddings()
+	if len(embeddings) != 3 || len(s.Chunks()) != 3 {
+		t.Fatalf("expected 3 chunk embeddings, got %d", len(embeddings))
+	}
+	for _, e := range embeddings {
+		if !strings.HasSuffix(e.Text, ".") || text[e.Start:e.End] != e.Text {
+			t.Errorf("unexpected chunk %+v", e.Chunk)
+		}
+		if !slices.Equal(e.Embedding, ollamateatest.FakeEmbedding(e.Text, ollamateatest.DefaultEmbeddingDimensions)) {
+			t.Errorf("unexpected embedding of %q", e.Text)
+		}
+	}
+}
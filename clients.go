@@ -0,0 +1,120 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Shared clients
+//
+// Sessions, EmbedSessions, and model listings without an explicit Backend
+// share one Backend per host, so connections are reused across requests
+// instead of a new client being built for each.  Backends created by
+// OllamaTea use DefaultHTTPClient, the one place to configure transport.
+
+// defaultMaxIdleConnsPerHost raises net/http's default of 2, which would
+// otherwise close connections between concurrent streaming requests
+const defaultMaxIdleConnsPerHost = 16
+
+var globalHTTPClient atomic.Pointer[http.Client]
+
+var (
+	backendCacheMu sync.Mutex
+	backendCache   = make(map[string]Backend)
+)
+
+// DefaultHTTPClient returns the http.Client used by Backends and other
+// requests made by OllamaTea, unless one is set explicitly.
+func DefaultHTTPClient() *http.Client {
+	if client := globalHTTPClient.Load(); client != nil {
+		return client
+	}
+	client := newDefaultHTTPClient()
+	if globalHTTPClient.CompareAndSwap(nil, client) {
+		return client
+	}
+	return globalHTTPClient.Load()
+}
+
+// SetDefaultHTTPClient sets the http.Client returned by DefaultHTTPClient,
+// such as one with a proxy, TLS configuration, or tracing transport.
+// Shared Backends are discarded, so later requests use the new client.
+// Pass nil to restore the default.
+func SetDefaultHTTPClient(client *http.Client) {
+	old := globalHTTPClient.Swap(client)
+	ResetSharedBackends()
+	if old != nil {
+		old.CloseIdleConnections()
+	}
+}
+
+// newDefaultHTTPClient returns an http.Client tuned for many long-lived requests to few hosts
+func newDefaultHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	transport.IdleConnTimeout = 5 * time.Minute
+	return &http.Client{Transport: transport}
+}
+
+// SharedBackend returns the Backend for host from [NewBackend], creating it on
+// first use and reusing it thereafter.  Backends are safe for concurrent use.
+func SharedBackend(host string) (Backend, error) {
+	backendCacheMu.Lock()
+	defer backendCacheMu.Unlock()
+	if backend, ok := backendCache[host]; ok {
+		return backend, nil
+	}
+	backend, err := NewBackend(host)
+	if err != nil {
+		return nil, err
+	}
+	backendCache[host] = backend
+	return backend, nil
+}
+
+// ResetSharedBackends discards all shared Backends, closing their idle connections.
+func ResetSharedBackends() {
+	backendCacheMu.Lock()
+	defer backendCacheMu.Unlock()
+	clear(backendCache)
+	if client := globalHTTPClient.Load(); client != nil {
+		client.CloseIdleConnections()
+	}
+}
+
+// HTTPClientWithHeader returns a copy of client, or of DefaultHTTPClient() if nil,
+// which sets header on each request, such as an Authorization bearer token for
+// an Ollama server behind a reverse proxy.  The header replaces any existing
+// values of the same keys.
+func HTTPClientWithHeader(client *http.Client, header http.Header) *http.Client {
+	if client == nil {
+		client = DefaultHTTPClient()
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	withHeader := *client
+	withHeader.Transport = &headerTransport{base: base, header: header.Clone()}
+	return &withHeader
+}
+
+// headerTransport is an http.RoundTripper which sets a header on each request
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
+
+// RoundTrip sets the header on a copy of the request and sends it with the base RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, vs := range t.header {
+		req.Header[k] = vs
+	}
+	return t.base.RoundTrip(req)
+}
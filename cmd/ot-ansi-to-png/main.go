@@ -4,25 +4,48 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
 	"github.com/spf13/pflag"
 )
 
 /////////////////////////////////////////////////////////////////////////////////////
 
-var usageFormatShort string = `usage:  %s [--help] [--in <ansitext-filename>] --out <png-filename>`
+const watchPollInterval = 500 * time.Millisecond
 
-var usageFormat string = `usage:  %s [--help] [--in <ansitext-filename>] --out <png-filename>
+var usageFormatShort string = `usage:  %s [--help] [options] [--in <ansitext-filename>] --out <image-filename>`
+
+var usageFormat string = `usage:  %s [--help] [options] [--in <ansitext-filename>] --out <image-filename>
 
 Converts input ANSI terminal text from stdin (or a file with --in)
-and renders it visually as a PNG image file saved to --out.
+and renders it visually as an image file saved to --out.
 
 If --in is '-' then stdin is used. If --out is '-' then stdout is used.
 
+If any of --font-size, --max-width, --theme, or --format are given, the
+image is sized to fit the text; otherwise it is a fixed 80x24 page.
+The --format defaults to the --out extension (png, jpeg, or gif).
+
+With --watch, the --in file is re-rendered to --out whenever it changes.
+
+With --json, a JSON object describing each rendered image is written to
+stdout, so --out must then be a file.
+
+Shell completion scripts are printed with --completion bash, zsh, or fish.
+
 Example:  $ echo -e "\033[31mHello\033[0m World" | ot-ansi-to-png --out hello.png
 
 `
@@ -31,12 +54,25 @@ Example:  $ echo -e "\033[31mHello\033[0m World" | ot-ansi-to-png --out hello.pn
 
 func main() {
 	var inputTXTFilename, outputPNGFilename string
-	var showHelp bool
-	var err error
+	var theme, format string
+	var fontSize float64
+	var maxWidth int
+	var watch, outputJSON, showHelp bool
 
+	defaultOpts := ollamatea.DefaultConvertOptions()
 	pflag.StringVarP(&inputTXTFilename, "in", "i", "", "Input text filename (default: stdin)")
-	pflag.StringVarP(&outputPNGFilename, "out", "o", "", "Output PNG filename ('-' is stdout)")
+	pflag.StringVarP(&outputPNGFilename, "out", "o", "", "Output image filename ('-' is stdout)")
+	pflag.Float64VarP(&fontSize, "font-size", "", defaultOpts.FontSize, "Font size in points")
+	pflag.IntVarP(&maxWidth, "max-width", "", 0, "Maximum image width in columns (0 is unlimited)")
+	pflag.StringVarP(&theme, "theme", "", string(defaultOpts.Theme), "Color theme: dark or light")
+	pflag.StringVarP(&format, "format", "f", "", "Image format: png, jpeg, or gif (default: from --out, else png)")
+	pflag.BoolVarP(&watch, "watch", "w", false, "Re-render whenever the --in file changes")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, clikit.JSONFlagUsage)
 	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-ansi-to-png").
+		FileFlags("in", "out").
+		ValuesFlag("theme", string(ollamatea.ConvertThemeDark), string(ollamatea.ConvertThemeLight)).
+		ValuesFlag("format", string(ollamatea.ConvertFormatPNG), string(ollamatea.ConvertFormatJPEG), string(ollamatea.ConvertFormatGIF))
 	pflag.Parse()
 
 	if showHelp {
@@ -44,19 +80,132 @@ func main() {
 		pflag.PrintDefaults()
 		os.Exit(0)
 	}
+	if handled, err := completion.Handle(os.Stdout, ""); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if len(outputPNGFilename) == 0 {
 		fmt.Fprintf(os.Stderr, "ERROR: missing required argument: --out\n")
 		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
 		os.Exit(1)
 	}
+	if watch && (inputTXTFilename == "" || inputTXTFilename == "-" || outputPNGFilename == "-") {
+		fmt.Fprintf(os.Stderr, "ERROR: --watch requires --in and --out files\n")
+		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+		os.Exit(1)
+	}
+	if outputJSON && outputPNGFilename == "-" {
+		fmt.Fprintf(os.Stderr, "ERROR: --json requires an --out file\n")
+		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+		os.Exit(1)
+	}
 
+	// Only use the sized converter if an option was requested, so the default output is unchanged
+	var convertOpts *ollamatea.ConvertOptions
+	if pflag.CommandLine.Changed("font-size") || pflag.CommandLine.Changed("max-width") ||
+		pflag.CommandLine.Changed("theme") || format != "" || formatFromFilename(outputPNGFilename) != "" {
+		if format == "" {
+			format = formatFromFilename(outputPNGFilename)
+		}
+		convertOpts = &ollamatea.ConvertOptions{
+			FontSize: fontSize,
+			MaxWidth: maxWidth,
+			Theme:    ollamatea.ConvertTheme(strings.ToLower(theme)),
+			Format:   ollamatea.ConvertFormat(strings.ToLower(format)),
+		}
+	}
+
+	if !watch {
+		imageBytes, err := convertFile(inputTXTFilename, outputPNGFilename, convertOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if outputJSON {
+			writeJSONResult(inputTXTFilename, outputPNGFilename, imageBytes)
+		}
+		return
+	}
+
+	// Watch mode: poll the input file's modification time until interrupted
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	var lastModTime time.Time
+	for {
+		info, err := os.Stat(inputTXTFilename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to stat input file %s\n", err.Error())
+		} else if info.ModTime() != lastModTime {
+			lastModTime = info.ModTime()
+			if imageBytes, err := convertFile(inputTXTFilename, outputPNGFilename, convertOpts); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			} else if outputJSON {
+				writeJSONResult(inputTXTFilename, outputPNGFilename, imageBytes)
+			} else {
+				fmt.Fprintf(os.Stderr, "INFO: rendered %s to %s\n", inputTXTFilename, outputPNGFilename)
+			}
+		}
+		select {
+		case <-sigCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// jsonResult is the output written with --json, for each rendered image
+type jsonResult struct {
+	In     string `json:"in"`
+	Out    string `json:"out"`
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Bytes  int    `json:"bytes"`
+}
+
+// writeJSONResult writes the jsonResult for imageBytes to stdout
+func writeJSONResult(inputFilename string, outputFilename string, imageBytes []byte) {
+	result := jsonResult{In: inputFilename, Out: outputFilename, Bytes: len(imageBytes)}
+	if result.In == "" {
+		result.In = "-"
+	}
+	if config, format, err := image.DecodeConfig(bytes.NewReader(imageBytes)); err == nil {
+		result.Format, result.Width, result.Height = format, config.Width, config.Height
+	}
+	if err := clikit.WriteJSON(os.Stdout, result); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+	}
+}
+
+// formatFromFilename returns the image format implied by the filename's extension,
+// or "" if it is not recognized or is the default PNG.
+func formatFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg":
+		return string(ollamatea.ConvertFormatJPEG)
+	case ".gif":
+		return string(ollamatea.ConvertFormatGIF)
+	default:
+		return ""
+	}
+}
+
+// convertFile converts the ANSI text in inputFilename to an image in outputFilename,
+// returning the image.  If convertOpts is nil, the fixed-page PNG converter is used.
+func convertFile(inputFilename string, outputFilename string, convertOpts *ollamatea.ConvertOptions) ([]byte, error) {
 	// Open input TXT file for reading, or use Stdin
+	var err error
 	infile := os.Stdin
-	if len(inputTXTFilename) != 0 && inputTXTFilename != "-" {
-		infile, err = os.OpenFile(inputTXTFilename, os.O_RDONLY, 0777)
+	if len(inputFilename) != 0 && inputFilename != "-" {
+		infile, err = os.OpenFile(inputFilename, os.O_RDONLY, 0777)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR: failed to open input file %s\n", err.Error())
-			os.Exit(1)
+			return nil, fmt.Errorf("failed to open input file %w", err)
 		}
 		defer infile.Close()
 	}
@@ -64,32 +213,34 @@ func main() {
 	// Capture file until EOF
 	ansitextData, err := io.ReadAll(infile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: failed to read file %s\n", err.Error())
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to read file %w", err)
 	}
 	infile.Close() // we don't need it anymore
 
 	// Use OllamaTeas's machinery to convert to image
-	pngBytes, err := ollamatea.ConvertTerminalTextToImage(string(ansitextData), nil)
+	var imageBytes []byte
+	if convertOpts == nil {
+		imageBytes, err = ollamatea.ConvertTerminalTextToImage(string(ansitextData), nil)
+	} else {
+		imageBytes, err = ollamatea.ConvertTerminalTextToImageWithOptions(string(ansitextData), *convertOpts)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: failed to convert to PNG %s\n", err.Error())
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to convert to image %w", err)
 	}
 
 	// Write file
 	outfile := os.Stdout
-	if outputPNGFilename != "" && outputPNGFilename != "-" {
-		outfile, err = os.OpenFile(outputPNGFilename, os.O_CREATE|os.O_WRONLY, 0644)
+	if outputFilename != "" && outputFilename != "-" {
+		outfile, err = os.OpenFile(outputFilename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR: failed to open output file %s\n", err.Error())
-			os.Exit(1)
+			return nil, fmt.Errorf("failed to open output file %w", err)
 		}
 		defer outfile.Close()
 	}
 
-	_, err = outfile.Write(pngBytes)
+	_, err = outfile.Write(imageBytes)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: failed to write PNG %s\n", err.Error())
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to write image %w", err)
 	}
+	return imageBytes, nil
 }
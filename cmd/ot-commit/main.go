@@ -0,0 +1,427 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+// ot-commit
+//
+// Commit-message generator TUI using ollamatea.Session and ollamatea.ModelChooser
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/pflag"
+)
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+const defaultSystemPrompt = `You write git commit messages in the Conventional Commits format.
+The first line is "<type>(<optional scope>): <summary>" with type one of:
+feat, fix, docs, style, refactor, perf, test, build, ci, chore, revert.
+The summary is imperative, lowercase, and at most 72 characters.
+If the change needs explanation, add a blank line and a short body wrapped at 72 columns.
+Reply with the commit message only: no preamble, no code fences.`
+
+const defaultUserInput = "Write a commit message for the staged changes in the diff above."
+
+const defaultMaxDiffBytes = 32 * 1024
+
+var usageFormat string = `usage:  %s [--help] [options] [-- <git-commit-args>]
+
+Generates a Conventional Commits message for the currently staged changes
+(from 'git diff --staged') and lets you edit it before accepting.
+
+Once accepted with ctrl+s, the message is printed to stdout, or committed
+with 'git commit' if --commit is set.  Press esc to abort.
+Arguments after '--' are passed to 'git commit'.
+
+With --json, the message is generated without the TUI and written as a
+JSON object; it is still committed if --commit is set.
+
+Shell completion scripts are printed with --completion bash, zsh, or fish.
+
+Example:  $ git add -p && ot-commit --commit -m qwen2.5-coder
+
+`
+
+/////////////////////////////////////////////////////////////////////////////////////
+// Keys
+
+type commitKeyMap struct {
+	Accept      key.Binding
+	Regenerate  key.Binding
+	ChooseModel key.Binding
+	Abort       key.Binding
+}
+
+func (k commitKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Accept, k.Regenerate, k.ChooseModel, k.Abort}
+}
+
+func (k commitKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
+
+var defaultKeyMap = commitKeyMap{
+	Accept: key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("ctrl+s", "accept"),
+	),
+	Regenerate: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("ctrl+r", "regenerate"),
+	),
+	ChooseModel: key.NewBinding(
+		key.WithKeys("ctrl+l"),
+		key.WithHelp("ctrl+l", "models"),
+	),
+	Abort: key.NewBinding(
+		key.WithKeys("esc", "ctrl+c"),
+		key.WithHelp("esc", "abort"),
+	),
+}
+
+var headerStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("63")) // purple
+
+/////////////////////////////////////////////////////////////////////////////////////
+// commitModel
+
+type commitModel struct {
+	session       *ollamatea.Session
+	modelChooser  ollamatea.ModelChooser
+	choosingModel bool
+
+	message  textarea.Model
+	spinner  spinner.Model
+	help     help.Model
+	keyMap   commitKeyMap
+	width    int
+	accepted bool
+}
+
+func newCommitModel(session *ollamatea.Session) commitModel {
+	message := textarea.New()
+	message.Placeholder = "Generating commit message..."
+	message.ShowLineNumbers = false
+	message.CharLimit = 0
+	message.Prompt = "│ "
+
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+
+	chooser := ollamatea.NewModelChooser(session.Host)
+	chooser.FetchOnInit = false
+
+	return commitModel{
+		session:      session,
+		modelChooser: chooser,
+		message:      message,
+		spinner:      s,
+		help:         help.New(),
+		keyMap:       defaultKeyMap,
+	}
+}
+
+func (m commitModel) Init() tea.Cmd {
+	return tea.Batch(
+		m.session.Init(), // Session Init is required to be chained
+		m.session.StartGenerateMsg,
+		m.spinner.Tick,
+		textarea.Blink,
+	)
+}
+
+func (m commitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.message.SetWidth(msg.Width)
+		m.message.SetHeight(msg.Height - 3) // header, help, padding
+		m.modelChooser.SetWidth(msg.Width)
+		m.modelChooser.SetHeight(msg.Height)
+		m.help.Width = msg.Width
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.choosingModel {
+			m.modelChooser, cmd = m.modelChooser.Update(msg)
+			return m, cmd
+		}
+		switch {
+		case key.Matches(msg, m.keyMap.Abort):
+			return m, tea.Quit
+		case key.Matches(msg, m.keyMap.Accept):
+			if m.session.IsGenerating() || strings.TrimSpace(m.message.Value()) == "" {
+				return m, nil
+			}
+			m.accepted = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keyMap.Regenerate):
+			m.startRegenerate()
+			return m, m.session.StartGenerateMsg
+		case key.Matches(msg, m.keyMap.ChooseModel):
+			m.choosingModel = true
+			m.modelChooser.SetSelectionByName(m.session.Model)
+			return m, ollamatea.Cmdize(m.modelChooser.FetchListMsg())
+		}
+		if m.session.IsGenerating() {
+			return m, nil // no editing while streaming
+		}
+		m.message, cmd = m.message.Update(msg)
+		return m, cmd
+
+	case ollamatea.ModelChooserAbortedMsg:
+		if msg.ID == m.modelChooser.ID() {
+			m.choosingModel = false
+		}
+		return m, nil
+
+	case ollamatea.ModelChooserSelectedMsg:
+		if msg.ID == m.modelChooser.ID() {
+			m.choosingModel = false
+			m.session.Model = msg.Selection.Model
+			m.startRegenerate()
+			return m, m.session.StartGenerateMsg
+		}
+		return m, nil
+
+	case ollamatea.GenerateResponseMsg:
+		if msg.ID == m.session.ID() {
+			m.message.SetValue(m.session.Response())
+		}
+		return m, nil
+
+	case ollamatea.GenerateDoneMsg:
+		if msg.ID == m.session.ID() {
+			m.message.SetValue(cleanMessage(m.session.Response()))
+			cmd = m.message.Focus()
+		}
+		return m, cmd
+	}
+
+	var cmds []tea.Cmd
+	_, cmd = m.session.Update(msg)
+	cmds = append(cmds, cmd)
+	m.spinner, cmd = m.spinner.Update(msg)
+	cmds = append(cmds, cmd)
+	m.message, cmd = m.message.Update(msg)
+	cmds = append(cmds, cmd)
+	m.modelChooser, cmd = m.modelChooser.Update(msg)
+	cmds = append(cmds, cmd)
+	return m, tea.Batch(cmds...)
+}
+
+func (m commitModel) View() string {
+	if m.choosingModel {
+		return m.modelChooser.View()
+	}
+	var status string
+	if err := m.session.Error(); err != nil {
+		status = fmt.Sprintf("ERROR: %s", err.Error())
+	} else if m.session.IsGenerating() {
+		status = m.spinner.View() + " generating with " + m.session.Model
+	} else {
+		status = "commit message from " + m.session.Model
+	}
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		headerStyle.Render("─ ot-commit ─ "+status),
+		m.message.View(),
+		m.help.View(m.keyMap),
+	)
+}
+
+// startRegenerate resets the message for a new generation
+func (m *commitModel) startRegenerate() {
+	m.session.ClearResponse()
+	m.session.ClearError()
+	m.message.Blur()
+	m.message.Reset()
+}
+
+// cleanMessage strips code fences and surrounding whitespace some models add
+func cleanMessage(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		if idx := strings.Index(s, "\n"); idx >= 0 {
+			s = s[idx+1:]
+		}
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	}
+	return strings.TrimSpace(s)
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+// stagedDiff returns the output of `git diff --staged`
+func stagedDiff() (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "diff", "--staged")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git diff --staged failed: %w %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// gitCommit runs `git commit` with the given message, with its output to stdout
+func gitCommit(message string, extraArgs []string, stdout io.Writer) error {
+	args := append([]string{"commit", "-F", "-"}, extraArgs...)
+	cmd := exec.Command("git", args...)
+	cmd.Stdin = strings.NewReader(message + "\n")
+	cmd.Stdout, cmd.Stderr = stdout, os.Stderr
+	return cmd.Run()
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+func main() {
+	var ollamaSystem, extraPrompt string
+	var maxDiffBytes int
+	var doCommit, outputJSON, showHelp bool
+
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost().AddModel().AddVerbose()
+	pflag.StringVarP(&ollamaSystem, "system", "s", defaultSystemPrompt, "System prompt for Ollama")
+	pflag.StringVarP(&extraPrompt, "prompt", "p", "", "Extra instructions for the commit message")
+	pflag.IntVarP(&maxDiffBytes, "max-diff", "", defaultMaxDiffBytes, "Maximum bytes of diff sent to the model")
+	pflag.BoolVarP(&doCommit, "commit", "c", false, "Run 'git commit' with the accepted message")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, clikit.JSONFlagUsage)
+	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-commit").
+		ModelFlags("model")
+	pflag.Parse()
+
+	if showHelp {
+		fmt.Fprintf(os.Stdout, usageFormat, os.Args[0])
+		pflag.PrintDefaults()
+		os.Exit(0)
+	}
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	diff, err := stagedDiff()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Fprintf(os.Stderr, "ERROR: no staged changes\n")
+		os.Exit(1)
+	}
+	if maxDiffBytes > 0 && len(diff) > maxDiffBytes {
+		end := maxDiffBytes
+		for end > 0 && !utf8.RuneStart(diff[end]) {
+			end-- // don't cut a rune in half
+		}
+		diff = diff[:end] + "\n[diff truncated]\n"
+	}
+	if cli.Verbose {
+		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s diff=%d bytes\n", cli.Host, cli.Model, len(diff))
+	}
+
+	session := ollamatea.NewSession()
+	session.Host = cli.Host
+	session.Model = cli.Model
+	builder := ollamatea.NewPromptBuilder().
+		System(ollamaSystem).
+		Context(diff).
+		UserInput(defaultUserInput)
+	if extraPrompt != "" {
+		builder.Constraints(extraPrompt)
+	}
+	prompt, err := builder.Build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	prompt.Apply(&session)
+
+	if outputJSON {
+		generateJSON(&session, doCommit)
+		return
+	}
+
+	// Create commitModel and run the BubbleTea Program
+	m := newCommitModel(&session)
+	mret, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	m = mret.(commitModel)
+	if !m.accepted {
+		fmt.Fprintf(os.Stderr, "Aborted\n")
+		os.Exit(1)
+	}
+
+	message := strings.TrimSpace(m.message.Value())
+	if !doCommit {
+		fmt.Fprintln(os.Stdout, message)
+		return
+	}
+	if err := gitCommit(message, pflag.Args(), os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: git commit failed %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// jsonResult is the output written with --json
+type jsonResult struct {
+	Model     string `json:"model"`
+	Message   string `json:"message"`
+	Committed bool   `json:"committed"`
+}
+
+// generateJSON generates the message without the TUI and writes it as a jsonResult.
+// The message is committed if doCommit is set, with git's output to stderr.
+func generateJSON(session *ollamatea.Session, doCommit bool) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	doneMsg, err := session.Generate(ctx, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: generation failed %s\n", err.Error())
+		os.Exit(1)
+	}
+	result := jsonResult{Model: session.Model, Message: cleanMessage(doneMsg.Response)}
+	if result.Message == "" {
+		fmt.Fprintf(os.Stderr, "ERROR: empty commit message\n")
+		os.Exit(1)
+	}
+	if doCommit {
+		if err := gitCommit(result.Message, pflag.Args(), os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: git commit failed %s\n", err.Error())
+			os.Exit(1)
+		}
+		result.Committed = true
+	}
+	if err := clikit.WriteJSON(os.Stdout, result); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
@@ -4,75 +4,66 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/NimbleMarkets/ollamatea"
-	tea "github.com/charmbracelet/bubbletea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
 	"github.com/spf13/pflag"
 )
 
 /////////////////////////////////////////////////////////////////////////////////////
 
-var usageFormatShort string = `usage:  %s [--help] [options] --in <input-filename>`
+var usageFormatShort string = `usage:  %s [--help] [options] (--in <input-filename> | --dir <directory>)`
 
-var usageFormat string = `usage:  %s [--help] [options] --in <input-filename>
+var usageFormat string = `usage:  %s [--help] [options] (--in <input-filename> | --dir <directory>)
 
 Creates an embedding for the input data.
-Outputs as JSON to output, or per --out.
+Outputs the response as JSON to output, or per --out.  With --format, outputs
+the embedding with its input text and ID instead, as jsonl, csv, float32
+(little-endian, for numpy.fromfile), or parquet.  --json is accepted for
+consistency with the other commands.
 
-Example:  $ ot-embed --in hello.txt -m llava
-
-`
-
-/////////////////////////////////////////////////////////////////////////////////////
-// Simple BubbleTea model that does the embedding and exits
-
-type model struct {
-	EmbedSession ollamatea.EmbedSession
-}
-
-func (m model) Init() tea.Cmd {
-	m.EmbedSession.Init()
-	return m.EmbedSession.StartEmbedCmd() // Kick off an embed
-}
+With --dir, embeds each text file under the directory in chunks of
+--chunk-size tokens, selected by --include and --exclude globs, and outputs
+each chunk's embedding with its file name and offsets, in --format (default
+jsonl).
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	m.EmbedSession.Update(msg)
+Shell completion scripts are printed with --completion bash, zsh, or fish.
 
-	switch msg := msg.(type) {
-	case ollamatea.EmbedResponseMsg:
-		if msg.ID != m.EmbedSession.ID() {
-			return m, nil // Ignore messages for other sessions
-		}
-		return m, tea.Quit
-	case ollamatea.EmbedErrorMsg:
-		// Quit after the first message
-		return m, tea.Quit
-	}
-	_, cmd := m.EmbedSession.Update(msg)
-	return m, cmd
-}
+Example:  $ ot-embed --in hello.txt -m llava
+          $ ot-embed --in hello.txt -m nomic-embed-text --format csv -o hello.csv
+          $ ot-embed --dir docs --include '*.md' --exclude drafts -m nomic-embed-text -o docs.jsonl
 
-func (m model) View() string {
-	return ""
-}
+`
 
 /////////////////////////////////////////////////////////////////////////////////////
 
 func main() {
-	var inputFilename, outputFilename string
-	var ollamaHost, ollamaModel string
-	var verbose, showHelp bool
+	var inputFilename, outputFilename, dirName string
+	var webhookURL, formatName string
+	var includes, excludes []string
+	var chunkSize, chunkOverlap int
+	var outputJSON, showHelp bool
 
 	pflag.StringVarP(&inputFilename, "in", "i", "", "Input filename ('-' is stdin)")
 	pflag.StringVarP(&outputFilename, "out", "o", "", "Output filename ('-' is stdout)")
-	pflag.StringVarP(&ollamaHost, "host", "h", ollamatea.DefaultHost(), "Host for Ollama (also OLLAMATEA_HOST env)")
-	pflag.StringVarP(&ollamaModel, "model", "m", ollamatea.DefaultModel(), "Model for Ollama (also OLLAMATEA_MODEL env)")
-	pflag.BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	pflag.StringVarP(&dirName, "dir", "d", "", "Directory of text files to embed in chunks")
+	pflag.StringArrayVarP(&includes, "include", "", nil, "With --dir, glob of files to embed, such as '*.md' (repeatable)")
+	pflag.StringArrayVarP(&excludes, "exclude", "", nil, "With --dir, glob of files or directories to skip (repeatable)")
+	pflag.IntVarP(&chunkSize, "chunk-size", "", ollamatea.DefaultChunkSize, "With --dir, size of each chunk in tokens")
+	pflag.IntVarP(&chunkOverlap, "chunk-overlap", "", 0, "With --dir, tokens shared by consecutive chunks")
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost().AddModel().AddVerbose()
+	pflag.StringVarP(&webhookURL, "webhook", "", "", "URL to POST a JSON event to when embedding completes")
+	pflag.StringVarP(&formatName, "format", "f", "", "Export format: jsonl, csv, float32, or parquet (default: the JSON response)")
+	pflag.BoolVarP(&outputJSON, "json", "j", true, clikit.JSONFlagUsage)
 	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-embed").
+		ModelFlags("model").
+		FileFlags("in", "out", "dir").
+		ValuesFlag("format", "jsonl", "csv", "float32", "parquet")
 	pflag.Parse()
 
 	if showHelp {
@@ -80,31 +71,41 @@ func main() {
 		pflag.PrintDefaults()
 		os.Exit(0)
 	}
-	if len(inputFilename) == 0 {
-		fmt.Fprintf(os.Stderr, "ERROR: missing required argument: --in\n")
-		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 		os.Exit(1)
 	}
-	if verbose {
-		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s\n", ollamaHost, ollamaModel)
-	}
-
-	// Open input file for reading, or use Stdin
-	var err error
-	infile := os.Stdin
-	if len(inputFilename) != 0 && inputFilename != "-" {
-		infile, err = os.OpenFile(inputFilename, os.O_RDONLY, 0777)
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR: failed to open input file %s\n", err.Error())
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 			os.Exit(1)
 		}
-		defer infile.Close()
+		os.Exit(0)
+	}
+	if (len(inputFilename) == 0) == (len(dirName) == 0) {
+		fmt.Fprintf(os.Stderr, "ERROR: exactly one of --in or --dir is required\n")
+		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+		os.Exit(1)
+	}
+	var format ollamatea.ExportFormat
+	if formatName != "" {
+		var err error
+		if format, err = ollamatea.ParseExportFormat(formatName); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+	} else if dirName != "" {
+		format = ollamatea.ExportJSONL
+	}
+	if cli.Verbose {
+		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s\n", cli.Host, cli.Model)
 	}
 
 	// Open output file now, or use Stdout.  Error now rather than after an whole embed request
+	var err error
 	outfile := os.Stdout
 	if outputFilename != "" && outputFilename != "-" {
-		outfile, err = os.OpenFile(outputFilename, os.O_CREATE|os.O_WRONLY, 0644)
+		outfile, err = os.OpenFile(outputFilename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: failed to open output file %s\n", err.Error())
 			os.Exit(1)
@@ -112,6 +113,52 @@ func main() {
 		defer outfile.Close()
 	}
 
+	// Use ollamatea.EmbedSession's machinery to embed input
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(cli.Host),
+		ollamatea.WithModel(cli.Model))
+	if webhookURL != "" {
+		hooks := ollamatea.NewWebhookHooks(webhookURL)
+		hooks.OnError = func(err error) {
+			fmt.Fprintf(os.Stderr, "WARN: %s\n", err.Error())
+		}
+		s.Hooks = hooks
+	}
+
+	// Batch mode: embed the directory's files in chunks
+	if dirName != "" {
+		s.Chunker = &ollamatea.Chunker{Mode: ollamatea.ChunkByTokens, Size: chunkSize, Overlap: chunkOverlap, Model: cli.Model}
+		records, err := s.EmbedDir(context.Background(), dirName, ollamatea.WalkOptions{
+			Include: includes,
+			Exclude: excludes,
+			OnFile: func(path string, records int, err error) {
+				if cli.Verbose && err == nil {
+					fmt.Fprintf(os.Stderr, "INFO: embedded %s in %d chunks\n", path, records)
+				}
+			},
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Embedding failed: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if err := ollamatea.WriteVectors(outfile, format, records); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to export embeddings %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Open input file for reading, or use Stdin
+	infile := os.Stdin
+	if inputFilename != "-" {
+		infile, err = os.OpenFile(inputFilename, os.O_RDONLY, 0777)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to open input file %s\n", err.Error())
+			os.Exit(1)
+		}
+		defer infile.Close()
+	}
+
 	// Capture input until EOF
 	inputData, err := io.ReadAll(infile)
 	if err != nil {
@@ -120,41 +167,31 @@ func main() {
 	}
 	infile.Close() // we don't need it anymore
 
-	// Use ollamatea.EmbedSession's machinery to embed input
-	s := ollamatea.NewEmbedSession(
-		ollamatea.WithHost(ollamaHost),
-		ollamatea.WithModel(ollamaModel),
-		ollamatea.WithInput(inputData))
-	m := model{EmbedSession: s}
-
-	mret, err := tea.NewProgram(m, tea.WithInput(nil)).Run()
+	s.Input = string(inputData)
+	resp, err := s.Embed(context.Background())
 	if err != nil {
-		fmt.Println("Error running program:", err)
+		fmt.Fprintf(os.Stderr, "Embedding failed: %s\n", err.Error())
 		os.Exit(1)
 	}
-	m = mret.(model)
 
-	// Check response
-	resp := m.EmbedSession.Response()
-	if resp == nil {
-		if err := m.EmbedSession.Error(); err != nil {
-			fmt.Fprintf(os.Stderr, "Embedding failed: %s\n", err.Error())
-		} else {
-			fmt.Fprintf(os.Stderr, "no embedding response\n")
+	// Export the embedding with its input, or write the JSON response
+	if format != "" {
+		var metadata map[string]string
+		if inputFilename != "-" {
+			metadata = map[string]string{ollamatea.MetadataSource: inputFilename}
 		}
-		os.Exit(1)
-	}
-	jstr, err := json.Marshal(resp)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: failed to JSON marshal response %s\n", err.Error())
-		os.Exit(1)
+		records, err := s.Records(metadata)
+		if err == nil {
+			err = ollamatea.WriteVectors(outfile, format, records)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to export embedding %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
 	}
-
-	// Write JSON
-	_, err = outfile.Write(jstr)
-	if err != nil {
+	if err := clikit.WriteJSON(outfile, resp); err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: failed to write response %s\n", err.Error())
 		os.Exit(1)
 	}
-	outfile.WriteString("\n")
 }
@@ -0,0 +1,223 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+// ot-fim
+//
+// Fill-in-the-middle code completion using ollamatea.CompletionSession
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
+	"github.com/spf13/pflag"
+)
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+const defaultFIMModel = "qwen2.5-coder:1.5b"
+
+var usageFormatShort string = `usage:  %s [--help] [options] (--in <filename> (--pos <line:col> | --offset <n>) | --prefix <text> [--suffix <text>])`
+
+var usageFormat string = `usage:  %s [--help] [options] (--in <filename> (--pos <line:col> | --offset <n>) | --prefix <text> [--suffix <text>])
+
+Generates a fill-in-the-middle code completion and prints only the completion.
+
+The code before the cursor is sent as the prompt and the code after it as
+the suffix.  The cursor is given either as a 1-based --pos line:col or as a
+0-based byte --offset into the --in file ('-' is stdin).  Alternatively,
+pass the --prefix and --suffix text directly.
+
+The model must support infill (for example qwen2.5-coder, codellama:code,
+or starcoder2).  Without --model, OLLAMATEA_MODEL, or a config file model,
+qwen2.5-coder:1.5b is used.
+
+With --json, the completion is written as a JSON object instead.
+
+Shell completion scripts are printed with --completion bash, zsh, or fish.
+
+Example:  $ ot-fim --in main.go --pos 42:5 --max-tokens 64
+
+`
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+// jsonResult is the output written with --json
+type jsonResult struct {
+	Model      string    `json:"model"`
+	Completion string    `json:"completion"`
+	CreatedAt  time.Time `json:"created_at"`
+	DoneReason string    `json:"done_reason,omitempty"`
+}
+
+// splitAtPos splits the text at the 1-based line:col position.
+// The column is counted in bytes; a column past the end of the line is clamped.
+func splitAtPos(text string, pos string) (string, string, error) {
+	lineStr, colStr, found := strings.Cut(pos, ":")
+	if !found {
+		colStr = "1"
+	}
+	line, err := strconv.Atoi(lineStr)
+	if err != nil || line < 1 {
+		return "", "", fmt.Errorf("bad line in --pos '%s'", pos)
+	}
+	col, err := strconv.Atoi(colStr)
+	if err != nil || col < 1 {
+		return "", "", fmt.Errorf("bad column in --pos '%s'", pos)
+	}
+
+	offset := 0
+	for i := 1; i < line; i++ {
+		idx := strings.IndexByte(text[offset:], '\n')
+		if idx < 0 {
+			return "", "", fmt.Errorf("--pos line %d is past the end of input", line)
+		}
+		offset += idx + 1
+	}
+	lineLen := strings.IndexByte(text[offset:], '\n')
+	if lineLen < 0 {
+		lineLen = len(text) - offset
+	}
+	offset += min(col-1, lineLen)
+	return text[:offset], text[offset:], nil
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+func main() {
+	var inputFilename, cursorPos, prefix, suffix string
+	var offset, maxTokens int
+	var temperature float64
+	var timeout time.Duration
+	var outputJSON, showHelp bool
+
+	pflag.StringVarP(&inputFilename, "in", "i", "", "Input source filename ('-' is stdin)")
+	pflag.StringVarP(&cursorPos, "pos", "p", "", "Cursor position in --in as 1-based line:col")
+	pflag.IntVarP(&offset, "offset", "", -1, "Cursor position in --in as a 0-based byte offset")
+	pflag.StringVarP(&prefix, "prefix", "", "", "Code before the cursor (instead of --in)")
+	pflag.StringVarP(&suffix, "suffix", "", "", "Code after the cursor (instead of --in)")
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost().AddModelOr(defaultFIMModel).AddVerbose()
+	pflag.IntVarP(&maxTokens, "max-tokens", "n", 128, "Maximum number of tokens to generate (num_predict)")
+	pflag.Float64VarP(&temperature, "temperature", "", 0, "Sampling temperature")
+	pflag.DurationVarP(&timeout, "timeout", "t", 0, "Timeout for the generation (0 is none)")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, clikit.JSONFlagUsage)
+	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-fim").
+		ModelFlags("model").
+		FileFlags("in")
+	pflag.Parse()
+
+	if showHelp {
+		fmt.Fprintf(os.Stdout, usageFormat, os.Args[0])
+		pflag.PrintDefaults()
+		os.Exit(0)
+	}
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if inputFilename != "" {
+		if prefix != "" || suffix != "" {
+			fmt.Fprintf(os.Stderr, "ERROR: --in may not be used with --prefix or --suffix\n")
+			fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+			os.Exit(1)
+		}
+		if (cursorPos == "") == (offset < 0) {
+			fmt.Fprintf(os.Stderr, "ERROR: --in requires exactly one of --pos or --offset\n")
+			fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+			os.Exit(1)
+		}
+
+		// Open input file for reading, or use Stdin
+		var err error
+		infile := os.Stdin
+		if inputFilename != "-" {
+			infile, err = os.OpenFile(inputFilename, os.O_RDONLY, 0777)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: failed to open input file %s\n", err.Error())
+				os.Exit(1)
+			}
+			defer infile.Close()
+		}
+		inputData, err := io.ReadAll(infile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to read file %s\n", err.Error())
+			os.Exit(1)
+		}
+		infile.Close() // we don't need it anymore
+
+		text := string(inputData)
+		if cursorPos != "" {
+			prefix, suffix, err = splitAtPos(text, cursorPos)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+				os.Exit(1)
+			}
+		} else {
+			if offset > len(text) {
+				fmt.Fprintf(os.Stderr, "ERROR: --offset %d is past the end of input\n", offset)
+				os.Exit(1)
+			}
+			prefix, suffix = text[:offset], text[offset:]
+		}
+	} else if prefix == "" {
+		fmt.Fprintf(os.Stderr, "ERROR: missing required argument: --in or --prefix\n")
+		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+		os.Exit(1)
+	}
+	if cli.Verbose {
+		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s prefix=%d bytes suffix=%d bytes\n",
+			cli.Host, cli.Model, len(prefix), len(suffix))
+	}
+
+	// Cancel on interrupt or timeout
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cli.EnableDebugLog()
+
+	s := ollamatea.NewCompletionSession()
+	s.Host = cli.Host
+	s.Model = cli.Model
+	s.SetNumPredict(maxTokens).SetTemperature(temperature)
+
+	doneMsg, err := s.Complete(ctx, prefix, suffix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: generation failed %s\n", err.Error())
+		os.Exit(1)
+	}
+	if !outputJSON {
+		fmt.Fprint(os.Stdout, doneMsg.Completion)
+		return
+	}
+	err = clikit.WriteJSON(os.Stdout, jsonResult{
+		Model:      s.Model,
+		Completion: doneMsg.Completion,
+		CreatedAt:  doneMsg.CreatedAt,
+		DoneReason: doneMsg.DoneReason,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
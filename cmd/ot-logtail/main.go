@@ -0,0 +1,541 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+// ot-logtail
+//
+// Streaming log summarizer using ollamatea.Session
+//
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/pflag"
+)
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+const defaultPromptTemplate = `You are monitoring a log stream from {{.Source}}.
+Summarize the following {{len .Lines}} log lines in a few sentences.
+Call out any errors, warnings, or anomalies.  Be concise.
+
+{{range .Lines}}{{.}}
+{{end}}`
+
+const (
+	defaultWindowLines  = 50
+	defaultInterval     = 30 * time.Second
+	defaultMaxLogLines  = 1000
+	defaultMaxSummaries = 20
+	followPollInterval  = 250 * time.Millisecond
+)
+
+var usageFormatShort string = `usage:  %s [--help] [options] [--in <log-filename>]`
+
+var usageFormat string = `usage:  %s [--help] [options] [--in <log-filename>]
+
+Tails a log file (or stdin), batches lines into windows, and streams periodic
+Ollama summaries of each window.  Raw logs are shown on top, summaries below.
+
+A summary is requested once --window lines have accumulated, or every
+--interval if any lines are pending.  The prompt is a Go text/template
+rendered with .Source (the input name) and .Lines (the window's lines).
+It may be loaded from a file with --template.  The default template is:
+
+` + defaultPromptTemplate + `
+With --json, there is no TUI; each summary is written to stdout as a JSON
+object, one per line.  With --no-follow or stdin, it exits after the last.
+
+Shell completion scripts are printed with --completion bash, zsh, or fish.
+
+Example:  $ ot-logtail --in /var/log/syslog --window 100 --interval 1m
+
+`
+
+var logStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("245")) // grey
+
+var summaryTimeStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("6")) // cyan
+
+var seperatorStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("63")) // purple
+
+/////////////////////////////////////////////////////////////////////////////////////
+// Log tailing messages
+
+// logLineMsg is sent for every line read from the input
+type logLineMsg struct {
+	Line string
+}
+
+// logEOFMsg is sent when the input is exhausted (non-follow mode)
+type logEOFMsg struct{}
+
+// logErrorMsg is sent when reading the input fails
+type logErrorMsg struct {
+	Error error
+}
+
+// summaryTickMsg is sent every --interval to flush pending lines
+type summaryTickMsg time.Time
+
+// tailLines reads lines from r and dispatches them on logCh.
+// If follow is true, EOF is polled for new data rather than being terminal.
+func tailLines(r io.Reader, follow bool, logCh chan<- tea.Msg) {
+	reader := bufio.NewReader(r)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		partial += line
+		if err == nil {
+			logCh <- logLineMsg{Line: strings.TrimRight(partial, "\r\n")}
+			partial = ""
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			logCh <- logErrorMsg{Error: err}
+			return
+		}
+		if !follow {
+			if partial != "" {
+				logCh <- logLineMsg{Line: partial}
+			}
+			logCh <- logEOFMsg{}
+			return
+		}
+		time.Sleep(followPollInterval)
+	}
+}
+
+// waitForLogMsg is a command that waits for the next message on the channel
+func waitForLogMsg(logCh <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-logCh
+	}
+}
+
+func summaryTickCmd(d time.Duration) tea.Cmd {
+	return tea.Tick(d, func(t time.Time) tea.Msg {
+		return summaryTickMsg(t)
+	})
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// logtailModel
+
+// logSummary is a completed summary of a window of lines
+type logSummary struct {
+	At       time.Time
+	NumLines int
+	Text     string
+}
+
+// jsonSummary is the output written with --json, for each summary
+type jsonSummary struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	NumLines int       `json:"num_lines"`
+	Model    string    `json:"model"`
+	Summary  string    `json:"summary"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// promptData is the data passed to the prompt template
+type promptData struct {
+	Source string
+	Lines  []string
+}
+
+type logtailModel struct {
+	session *ollamatea.Session
+	prompt  *template.Template
+	source  string
+
+	windowLines  int
+	interval     time.Duration
+	maxLogLines  int
+	maxSummaries int
+
+	logCh    chan tea.Msg
+	logLines []string
+	pending  []string // lines not yet summarized
+	inFlight int      // number of lines in the current generation
+	eof      bool
+
+	summaries []logSummary
+	lastError error
+
+	width, height int
+	logView       viewport.Model
+	summaryView   viewport.Model
+
+	jsonOut io.Writer // if not nil, summaries are written as JSON and there is no TUI
+}
+
+func newLogtailModel(session *ollamatea.Session, prompt *template.Template, source string) logtailModel {
+	return logtailModel{
+		session:      session,
+		prompt:       prompt,
+		source:       source,
+		windowLines:  defaultWindowLines,
+		interval:     defaultInterval,
+		maxLogLines:  defaultMaxLogLines,
+		maxSummaries: defaultMaxSummaries,
+		logCh:        make(chan tea.Msg, 1024),
+		logView:      viewport.New(80, 10),
+		summaryView:  viewport.New(80, 10),
+	}
+}
+
+func (m logtailModel) Init() tea.Cmd {
+	return tea.Batch(
+		m.session.Init(), // Session Init is required to be chained
+		waitForLogMsg(m.logCh),
+		summaryTickCmd(m.interval),
+	)
+}
+
+func (m logtailModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.updateSizes()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "s": // summarize now
+			cmd := m.startSummary()
+			return m, cmd
+		}
+		var cmd tea.Cmd
+		m.summaryView, cmd = m.summaryView.Update(msg)
+		return m, cmd
+
+	case logLineMsg:
+		m.logLines = append(m.logLines, msg.Line)
+		if len(m.logLines) > m.maxLogLines {
+			m.logLines = m.logLines[len(m.logLines)-m.maxLogLines:]
+		}
+		m.pending = append(m.pending, msg.Line)
+		m.logView.SetContent(logStyle.Render(strings.Join(m.logLines, "\n")))
+		m.logView.GotoBottom()
+
+		cmds := []tea.Cmd{waitForLogMsg(m.logCh)}
+		if len(m.pending) >= m.windowLines {
+			cmds = append(cmds, m.startSummary())
+		}
+		return m, tea.Batch(cmds...)
+
+	case logEOFMsg:
+		m.eof = true
+		cmd := m.startSummary()
+		if cmd == nil && m.inFlight == 0 && m.jsonOut != nil {
+			return m, tea.Quit
+		}
+		return m, cmd
+
+	case logErrorMsg:
+		m.lastError = msg.Error
+		if m.jsonOut != nil {
+			return m, tea.Quit
+		}
+		return m, nil
+
+	case summaryTickMsg:
+		cmd := m.startSummary()
+		return m, tea.Batch(cmd, summaryTickCmd(m.interval))
+
+	case ollamatea.GenerateResponseMsg:
+		if msg.ID != m.session.ID() {
+			return m, nil
+		}
+		m.refreshSummaryView()
+		return m, nil
+
+	case ollamatea.GenerateDoneMsg:
+		if msg.ID != m.session.ID() {
+			return m, nil
+		}
+		// a summary stopped at --max-response keeps its capped text in the session
+		text := msg.Response
+		if errors.Is(msg.Error, ollamatea.ErrResponseLimit) {
+			text = m.session.Response()
+		} else if msg.Error != nil {
+			m.lastError = fmt.Errorf("failed to summarize %d lines: %w", m.inFlight, msg.Error)
+		}
+		if msg.Error == nil || text != "" {
+			m.summaries = append(m.summaries, logSummary{
+				At:       msg.CreatedAt,
+				NumLines: m.inFlight,
+				Text:     text,
+			})
+			if len(m.summaries) > m.maxSummaries {
+				m.summaries = m.summaries[len(m.summaries)-m.maxSummaries:]
+			}
+		}
+		if m.jsonOut != nil {
+			m.writeJSONSummary(msg, text)
+		}
+		m.inFlight = 0
+		m.session.ClearResponse()
+		m.refreshSummaryView()
+		// catch up if a full window accumulated while we were generating
+		if len(m.pending) >= m.windowLines || (m.eof && len(m.pending) > 0) {
+			cmd := m.startSummary()
+			return m, cmd
+		}
+		if m.eof && m.jsonOut != nil {
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	_, cmd := m.session.Update(msg)
+	return m, cmd
+}
+
+func (m logtailModel) View() string {
+	var status string
+	switch {
+	case m.lastError != nil:
+		status = fmt.Sprintf("ERROR: %s", m.lastError.Error())
+	case m.session.IsGenerating():
+		status = fmt.Sprintf("summarizing %d lines with %s...", m.inFlight, m.session.Model)
+	default:
+		status = fmt.Sprintf("%d lines pending", len(m.pending))
+		if m.eof {
+			status += " (EOF)"
+		}
+	}
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		m.logView.View(),
+		m.seperatorView(status),
+		m.summaryView.View(),
+	)
+}
+
+func (m *logtailModel) seperatorView(status string) string {
+	prefix := "─ " + m.source + " ─ " + status + " "
+	width := m.width - lipgloss.Width(prefix)
+	if width < 0 {
+		width = 0
+	}
+	return seperatorStyle.Render(prefix + strings.Repeat("─", width))
+}
+
+// updateSizes splits the screen between the log view and summary view
+func (m *logtailModel) updateSizes() {
+	avail := m.height - 1 // seperator
+	logHeight := avail / 2
+	m.logView.Width, m.logView.Height = m.width, logHeight
+	m.summaryView.Width, m.summaryView.Height = m.width, avail-logHeight
+	m.logView.GotoBottom()
+	m.refreshSummaryView()
+}
+
+// refreshSummaryView renders the completed summaries plus any in-progress one
+func (m *logtailModel) refreshSummaryView() {
+	var sb strings.Builder
+	for _, s := range m.summaries {
+		sb.WriteString(summaryTimeStyle.Render(
+			fmt.Sprintf("[%s] %d lines", s.At.Format(time.TimeOnly), s.NumLines)))
+		sb.WriteString("\n")
+		sb.WriteString(strings.TrimSpace(s.Text))
+		sb.WriteString("\n\n")
+	}
+	if m.session.IsGenerating() {
+		sb.WriteString(m.session.Response())
+	}
+	m.summaryView.SetContent(lipgloss.NewStyle().Width(m.width).Render(sb.String()))
+	m.summaryView.GotoBottom()
+}
+
+// writeJSONSummary writes the summary text of a completed generation to jsonOut
+func (m *logtailModel) writeJSONSummary(msg ollamatea.GenerateDoneMsg, text string) {
+	summary := jsonSummary{
+		Time:     msg.CreatedAt,
+		Source:   m.source,
+		NumLines: m.inFlight,
+		Model:    m.session.Model,
+		Summary:  strings.TrimSpace(text),
+	}
+	if msg.Error != nil {
+		summary.Error = msg.Error.Error()
+	}
+	if err := clikit.WriteJSON(m.jsonOut, summary); err != nil {
+		m.lastError = err
+	}
+}
+
+// startSummary renders the prompt for the pending lines and starts a generation.
+// Returns nil if there is nothing to summarize or a generation is underway.
+func (m *logtailModel) startSummary() tea.Cmd {
+	if len(m.pending) == 0 || m.session.IsGenerating() || m.inFlight != 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	err := m.prompt.Execute(&buf, promptData{Source: m.source, Lines: m.pending})
+	if err != nil {
+		m.lastError = fmt.Errorf("failed to render prompt template %w", err)
+		return nil
+	}
+	m.inFlight = len(m.pending)
+	m.pending = nil
+	m.session.Prompt = buf.String()
+	m.session.ClearResponse()
+	return m.session.StartGenerateMsg
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+func main() {
+	var inputFilename, templateFilename, metricsAddr string
+	var windowLines, maxLogLines, maxResponse int
+	var interval time.Duration
+	var fromStart, noFollow, outputJSON, showHelp bool
+
+	pflag.StringVarP(&inputFilename, "in", "i", "-", "Input log filename ('-' is stdin)")
+	pflag.StringVarP(&templateFilename, "template", "", "", "Prompt template filename (see --help for default)")
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost().AddModel().AddVerbose()
+	pflag.IntVarP(&windowLines, "window", "w", defaultWindowLines, "Number of lines per summary window")
+	pflag.DurationVarP(&interval, "interval", "", defaultInterval, "Summarize pending lines at least this often")
+	pflag.IntVarP(&maxLogLines, "max-lines", "", defaultMaxLogLines, "Maximum number of raw log lines to display")
+	pflag.IntVarP(&maxResponse, "max-response", "", 0, "Stop summaries longer than this many bytes (0 is unlimited)")
+	pflag.BoolVarP(&fromStart, "from-start", "", false, "Read the file from the beginning rather than the end")
+	pflag.BoolVarP(&noFollow, "no-follow", "", false, "Stop at end of file rather than waiting for more lines")
+	pflag.StringVarP(&metricsAddr, "metrics-addr", "", "", "Serve Prometheus /metrics and expvar /debug/vars on this address, e.g. ':9090'")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, clikit.JSONFlagUsage)
+	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-logtail").
+		ModelFlags("model").
+		FileFlags("in", "template")
+	pflag.Parse()
+
+	if showHelp {
+		fmt.Fprintf(os.Stdout, usageFormat, os.Args[0])
+		pflag.PrintDefaults()
+		os.Exit(0)
+	}
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if windowLines <= 0 || interval <= 0 {
+		fmt.Fprintf(os.Stderr, "ERROR: --window and --interval must be positive\n")
+		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+		os.Exit(1)
+	}
+	if cli.Verbose {
+		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s window=%d interval=%s\n", cli.Host, cli.Model, windowLines, interval)
+	}
+
+	// Load the prompt template
+	templateText := defaultPromptTemplate
+	if templateFilename != "" {
+		templateBytes, err := os.ReadFile(templateFilename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to read template file %s\n", err.Error())
+			os.Exit(1)
+		}
+		templateText = string(templateBytes)
+	}
+	prompt, err := template.New("prompt").Parse(templateText)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to parse template %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	// Open input file for reading, or use Stdin
+	source := "stdin"
+	follow := !noFollow
+	infile := os.Stdin
+	if inputFilename != "" && inputFilename != "-" {
+		infile, err = os.OpenFile(inputFilename, os.O_RDONLY, 0777)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to open input file %s\n", err.Error())
+			os.Exit(1)
+		}
+		defer infile.Close()
+		if !fromStart {
+			if _, err = infile.Seek(0, io.SeekEnd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: failed to seek input file %s\n", err.Error())
+				os.Exit(1)
+			}
+		}
+		source = inputFilename
+	} else {
+		follow = false // stdin EOF is final
+	}
+
+	if metricsAddr != "" {
+		metrics := ollamatea.NewMetricsRegistry()
+		metrics.PublishExpvar("ollamatea")
+		ollamatea.SetMetrics(metrics)
+		http.Handle("/metrics", metrics) // expvar registers /debug/vars itself
+		listener, err := net.Listen("tcp", metricsAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to listen on --metrics-addr %s\n", err.Error())
+			os.Exit(1)
+		}
+		go http.Serve(listener, nil)
+	}
+
+	session := ollamatea.NewSession()
+	session.Host = cli.Host
+	session.Model = cli.Model
+	session.MaxResponseBytes = maxResponse
+	session.ResponseLimit = ollamatea.ResponseLimitStop
+
+	m := newLogtailModel(&session, prompt, source)
+	m.windowLines = windowLines
+	m.interval = interval
+	m.maxLogLines = maxLogLines
+	go tailLines(infile, follow, m.logCh)
+
+	// stdin is our log source, so the TUI does not read keyboard from it
+	var progOpts []tea.ProgramOption
+	if outputJSON {
+		m.jsonOut = os.Stdout
+		progOpts = append(progOpts, tea.WithoutRenderer(), tea.WithInput(nil))
+	} else {
+		progOpts = append(progOpts, tea.WithAltScreen())
+		if infile == os.Stdin {
+			progOpts = append(progOpts, tea.WithInputTTY())
+		}
+	}
+	mret, err := tea.NewProgram(m, progOpts...).Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if m = mret.(logtailModel); outputJSON && m.lastError != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", m.lastError.Error())
+		os.Exit(1)
+	}
+}
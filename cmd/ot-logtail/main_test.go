@@ -0,0 +1,52 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestLogtailMaxResponse(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	session := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"disk ", "full ", "on ", "sda1 ", "and ", "more ", "words"}, Delay: 10 * time.Millisecond})
+	session.MaxResponseBytes = 12
+	session.ResponseLimit = ollamatea.ResponseLimitStop
+
+	m := newLogtailModel(session, template.Must(template.New("prompt").Parse(defaultPromptTemplate)), "test.log")
+	var out bytes.Buffer
+	m.jsonOut = &out
+	update := func(msg tea.Msg) tea.Cmd {
+		model, cmd := m.Update(msg)
+		m = model.(logtailModel)
+		return cmd
+	}
+	update(logLineMsg{Line: "kernel: sda1: No space left on device"})
+	cmd := update(logEOFMsg{})
+	msg := ollamateatest.Run(t, update, cmd, ollamateatest.MatchType[ollamatea.GenerateDoneMsg](), 0)
+	update(msg)
+
+	if len(m.summaries) != 1 || m.summaries[0].Text != "disk full on" || m.lastError != nil {
+		t.Fatalf("expected the capped summary, got %+v, error %v", m.summaries, m.lastError)
+	}
+	var summary jsonSummary
+	if err := json.Unmarshal(out.Bytes(), &summary); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	if summary.Summary != "disk full on" || summary.NumLines != 1 || !strings.Contains(summary.Error, "limit") {
+		t.Errorf("unexpected JSON summary %+v", summary)
+	}
+	if m.session.Response() != "" {
+		t.Errorf("expected the response cleared, got %q", m.session.Response())
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 
 	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/pflag"
 )
@@ -19,6 +20,17 @@ import (
 
 var usageFormat string = `usage:  %s [--help] [options]
 Simple exercise of ollamatea.ModelChooser
+
+The chooser is drawn on stderr, so only the selection is written to stdout,
+for use in scripts.  With --json, the selected model is written to stdout
+as a JSON object.  Exits with status 1 if nothing was selected.
+
+--filter lists only the models whose names contain it, ignoring case,
+and --select preselects a model by name.
+
+Example:  $ ot-model-chooser --json --filter coder | jq -r .name
+
+Shell completion scripts are printed with --completion bash, zsh, or fish.
 `
 
 /////////////////////////////////////////////////////////////////////////////////////
@@ -31,9 +43,14 @@ type simpleModelChooserModel struct {
 	lastError      error
 }
 
-func newSimpleModelChooserModel(ollamaHost string) simpleModelChooserModel {
+func newSimpleModelChooserModel(ollamaHost string, filter string, selectName string) simpleModelChooserModel {
+	modelChooser := ollamatea.NewModelChooser(ollamaHost)
+	modelChooser.Filter = filter
+	if selectName != "" {
+		modelChooser.SetSelectionByName(selectName)
+	}
 	return simpleModelChooserModel{
-		modelChooser: ollamatea.NewModelChooser(ollamaHost),
+		modelChooser: modelChooser,
 	}
 }
 
@@ -68,11 +85,16 @@ func (m simpleModelChooserModel) View() string {
 /////////////////////////////////////////////////////////////////////////////////////
 
 func main() {
-	var ollamaHost string
-	var showHelp bool
+	var filter, selectName string
+	var outputJSON, showHelp bool
 
-	pflag.StringVarP(&ollamaHost, "host", "h", ollamatea.DefaultHost(), "Host for Ollama (also OLLAMATEA_HOST env)")
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost()
+	pflag.StringVarP(&filter, "filter", "f", "", "List only models whose names contain this, ignoring case")
+	pflag.StringVarP(&selectName, "select", "", "", "Preselect the model with this name")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, clikit.JSONFlagUsage)
 	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-model-chooser").
+		ModelFlags("select")
 	pflag.Parse()
 
 	if showHelp {
@@ -80,10 +102,21 @@ func main() {
 		pflag.PrintDefaults()
 		os.Exit(0)
 	}
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	// Create simpleChooserModel and run the BubbleTea Program
-	m := newSimpleModelChooserModel(ollamaHost)
-	model, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion()).Run()
+	m := newSimpleModelChooserModel(cli.Host, filter, selectName)
+	model, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithOutput(os.Stderr)).Run()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 		os.Exit(1)
@@ -96,6 +129,12 @@ func main() {
 	}
 	if m.finalSelection == nil {
 		fmt.Fprintf(os.Stderr, "No selection\n")
+		os.Exit(1)
+	} else if outputJSON {
+		if err := clikit.WriteJSON(os.Stdout, m.finalSelection); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
 	} else {
 		fmt.Fprintf(os.Stdout, "Selected:   %s  %s\n", m.finalSelection.Name, m.finalSelection.Digest)
 	}
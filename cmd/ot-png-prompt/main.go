@@ -4,12 +4,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/NimbleMarkets/ollamatea"
-	tea "github.com/charmbracelet/bubbletea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
 	"github.com/spf13/pflag"
 )
 
@@ -27,58 +29,43 @@ The prompt may be specified with  --prompt or the OLLAMATEA_PROMPT envvar.
 The default prompt is:
   ` + defaultOllamaPrompt + `'.
 
-Example:  $ ot-png-prompt --in hello.png -m llava
-
-`
-
-/////////////////////////////////////////////////////////////////////////////////////
-// Simple BubbleTea model that does the inference and exits
+With --json, the response is not streamed; a JSON object with the full
+response is written to stdout, or per --out, once it completes.
 
-type model struct {
-	Session ollamatea.Session
-}
+Shell completion scripts are printed with --completion bash, zsh, or fish.
 
-func (m model) Init() tea.Cmd {
-	return tea.Batch(
-		m.Session.Init(),           // Session Init is required to be chained
-		m.Session.StartGenerateMsg, // Kick off a generate
-	)
-}
+Example:  $ ot-png-prompt --in hello.png -m llava
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case ollamatea.GenerateResponseMsg:
-		if msg.ID != m.Session.ID() {
-			return m, nil // Ignore messages for other sessions
-		}
-		fmt.Fprintf(os.Stdout, msg.Response)
-		return m, nil
-	case ollamatea.GenerateDoneMsg:
-		// Quit after the first message
-		return m, tea.Quit
-	}
-	_, cmd := m.Session.Update(msg)
-	return m, cmd
-}
+`
 
-func (m model) View() string {
-	return ""
+// jsonResult is the output written with --json
+type jsonResult struct {
+	In         string    `json:"in"`
+	Model      string    `json:"model"`
+	Prompt     string    `json:"prompt"`
+	Response   string    `json:"response"`
+	CreatedAt  time.Time `json:"created_at"`
+	DoneReason string    `json:"done_reason,omitempty"`
 }
 
 /////////////////////////////////////////////////////////////////////////////////////
 
 func main() {
 	var inputPNGFilename, outputTXTFilename string
-	var ollamaHost, ollamaModel, ollamaPrompt string
-	var verbose, showHelp bool
+	var cacheDir string
+	var cacheTTL time.Duration
+	var outputJSON, showHelp bool
 
 	pflag.StringVarP(&inputPNGFilename, "in", "i", "", "Input PNG filename ('-' is stdin)")
 	pflag.StringVarP(&outputTXTFilename, "out", "o", "", "Output PNG filename")
-	pflag.StringVarP(&ollamaHost, "host", "h", ollamatea.DefaultHost(), "Host for Ollama (also OLLAMATEA_HOST env)")
-	pflag.StringVarP(&ollamaModel, "model", "m", ollamatea.DefaultModel(), "Model for Ollama (also OLLAMATEA_MODEL env)")
-	pflag.StringVarP(&ollamaPrompt, "prompt", "p", "", "Prompt for Ollama (see --help for default)")
-	pflag.BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost().AddModel().AddPrompt().AddVerbose()
+	pflag.StringVarP(&cacheDir, "cache-dir", "", "", "Cache responses in this directory (implies temperature 0)")
+	pflag.DurationVarP(&cacheTTL, "cache-ttl", "", 0, "Expire cached responses after this duration (0 is never)")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, clikit.JSONFlagUsage)
 	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-png-prompt").
+		ModelFlags("model").
+		FileFlags("in", "out", "cache-dir")
 	pflag.Parse()
 
 	if showHelp {
@@ -86,16 +73,27 @@ func main() {
 		pflag.PrintDefaults()
 		os.Exit(0)
 	}
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if len(inputPNGFilename) == 0 {
 		fmt.Fprintf(os.Stderr, "ERROR: missing required argument: --out\n")
 		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
 		os.Exit(1)
 	}
-	if len(ollamaPrompt) == 0 {
-		ollamaPrompt = defaultOllamaPrompt
+	if len(cli.Prompt) == 0 {
+		cli.Prompt = defaultOllamaPrompt
 	}
-	if verbose {
-		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s oprompt=\"%s\"\n", ollamaHost, ollamaModel, ollamaPrompt)
+	if cli.Verbose {
+		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s oprompt=\"%s\"\n", cli.Host, cli.Model, cli.Prompt)
 	}
 
 	// Open input PNG file for reading, or use Stdin
@@ -120,15 +118,27 @@ func main() {
 
 	// Use ollamatea.Session's machinery to convert to image
 	s := ollamatea.NewSession()
-	s.Host = ollamaHost
-	s.Model = ollamaModel
-	s.Prompt = ollamaPrompt
+	s.Host = cli.Host
+	s.Model = cli.Model
+	s.Prompt = cli.Prompt
 	s.Images = []ollamatea.ImageData{imageData}
-	m := model{Session: s}
-
-	_, err = tea.NewProgram(m, tea.WithInput(nil)).Run()
+	if cacheDir != "" {
+		cache, err := ollamatea.NewDiskCache(cacheDir, cacheTTL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		s.Cache = cache
+		s.SetTemperature(0)
+	}
+	onResponse := func(msg ollamatea.GenerateResponseMsg) {
+		if !outputJSON {
+			fmt.Fprint(os.Stdout, msg.Response)
+		}
+	}
+	doneMsg, err := s.Generate(context.Background(), onResponse)
 	if err != nil {
-		fmt.Println("Error running program:", err)
+		fmt.Fprintf(os.Stderr, "ERROR: generation failed %s\n", err.Error())
 		os.Exit(1)
 	}
 
@@ -143,7 +153,23 @@ func main() {
 		defer outfile.Close()
 	}
 
-	_, err = outfile.Write([]byte(m.Session.Response()))
+	if outputJSON {
+		err = clikit.WriteJSON(outfile, jsonResult{
+			In:         inputPNGFilename,
+			Model:      s.Model,
+			Prompt:     s.Prompt,
+			Response:   doneMsg.Response,
+			CreatedAt:  doneMsg.CreatedAt,
+			DoneReason: doneMsg.DoneReason,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	_, err = outfile.Write([]byte(s.Response()))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: failed to write prompt %s\n", err.Error())
 		os.Exit(1)
@@ -0,0 +1,225 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+// ot-prompt
+//
+// One-shot text generation without a TUI, using ollamatea.Session.Generate
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
+	"github.com/spf13/pflag"
+)
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+var usageFormatShort string = `usage:  %s [--help] [options] [prompt...]`
+
+var usageFormat string = `usage:  %s [--help] [options] [prompt...]
+
+Generates an Ollama response for a text prompt and streams it to stdout.
+
+The prompt is the remaining arguments joined by spaces.  If there are none,
+or the only argument is '-', the prompt is read from stdin.
+
+Model options may be set with repeated --option key=value flags.
+Values are parsed as JSON if possible, otherwise used as strings.
+
+With --json, a single JSON object with the full response is written
+once the generation completes, instead of streaming text.
+
+With --record DIR, the generation is also saved to a fixture in DIR.
+With --replay DIR, it is replayed from DIR without contacting Ollama,
+at the recorded pace scaled by --replay-speed (negative is instant).
+
+Shell completion scripts are printed with --completion bash, zsh, or fish.
+
+Example:  $ ot-prompt -m llama3.2 --option temperature=0 "Why is the sky blue?"
+
+`
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+// jsonResult is the output written with --json
+type jsonResult struct {
+	Model      string    `json:"model"`
+	Prompt     string    `json:"prompt"`
+	Response   string    `json:"response"`
+	CreatedAt  time.Time `json:"created_at"`
+	DoneReason string    `json:"done_reason,omitempty"`
+	Context    []int     `json:"context,omitempty"`
+}
+
+// parseOptions converts key=value strings to an Ollama options map
+func parseOptions(kvs []string) (map[string]interface{}, error) {
+	if len(kvs) == 0 {
+		return nil, nil
+	}
+	options := make(map[string]interface{})
+	for _, kv := range kvs {
+		key, value, found := strings.Cut(kv, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("bad option '%s', expected key=value", kv)
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+			options[key] = parsed
+		} else if b, err := strconv.ParseBool(value); err == nil {
+			options[key] = b
+		} else {
+			options[key] = value
+		}
+	}
+	return options, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+func main() {
+	var webhookURL, recordDir, replayDir string
+	var optionKVs []string
+	var timeout time.Duration
+	var replaySpeed float64
+	var outputJSON, showHelp bool
+
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost().AddModel().AddSystem().AddVerbose()
+	pflag.StringArrayVarP(&optionKVs, "option", "o", nil, "Model option as key=value (repeatable)")
+	pflag.DurationVarP(&timeout, "timeout", "t", 0, "Timeout for the generation (0 is none)")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, "Output the full response as JSON")
+	pflag.StringVarP(&webhookURL, "webhook", "", "", "URL to POST a JSON event to when generation completes")
+	pflag.StringVarP(&recordDir, "record", "", "", "Record the generation to a fixture in this directory")
+	pflag.StringVarP(&replayDir, "replay", "", "", "Replay the generation from a fixture in this directory")
+	pflag.Float64VarP(&replaySpeed, "replay-speed", "", 1, "Replay speed multiplier (negative is instant)")
+	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-prompt").
+		ModelFlags("model").
+		FileFlags("record", "replay")
+	pflag.Parse()
+
+	if showHelp {
+		fmt.Fprintf(os.Stdout, usageFormat, os.Args[0])
+		pflag.PrintDefaults()
+		os.Exit(0)
+	}
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	options, err := parseOptions(optionKVs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+		os.Exit(1)
+	}
+	if recordDir != "" && replayDir != "" {
+		fmt.Fprintf(os.Stderr, "ERROR: --record and --replay are exclusive\n")
+		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+		os.Exit(1)
+	}
+
+	// Prompt from args, or from stdin
+	prompt := strings.Join(pflag.Args(), " ")
+	if prompt == "" || prompt == "-" {
+		promptBytes, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to read stdin %s\n", err.Error())
+			os.Exit(1)
+		}
+		prompt = string(promptBytes)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		fmt.Fprintf(os.Stderr, "ERROR: empty prompt\n")
+		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+		os.Exit(1)
+	}
+	if cli.Verbose {
+		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s options=%v\n", cli.Host, cli.Model, options)
+	}
+
+	// Cancel on interrupt or timeout
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cli.EnableDebugLog()
+
+	s := ollamatea.NewSession()
+	s.Host = cli.Host
+	s.Model = cli.Model
+	s.System = cli.System
+	s.Prompt = prompt
+	s.Options = options
+	if webhookURL != "" {
+		hooks := ollamatea.NewWebhookHooks(webhookURL)
+		hooks.OnError = func(err error) {
+			fmt.Fprintf(os.Stderr, "WARN: %s\n", err.Error())
+		}
+		s.Hooks = hooks
+	}
+	if replayDir != "" {
+		s.Backend = ollamatea.NewReplayBackend(replayDir, replaySpeed)
+	} else if recordDir != "" {
+		backend, err := ollamatea.NewBackend(cli.Host)
+		if err == nil {
+			s.Backend, err = ollamatea.NewRecordingBackend(backend, recordDir)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if !outputJSON {
+		s.StreamTo(os.Stdout)
+	}
+
+	doneMsg, err := s.Generate(ctx, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: generation failed %s\n", err.Error())
+		var ollamaErr *ollamatea.OllamaError
+		if errors.As(err, &ollamaErr) && ollamaErr.Hint() != "" {
+			fmt.Fprintf(os.Stderr, "HINT: %s\n", ollamaErr.Hint())
+		}
+		os.Exit(1)
+	}
+
+	if !outputJSON {
+		fmt.Fprintln(os.Stdout)
+		return
+	}
+	err = clikit.WriteJSON(os.Stdout, jsonResult{
+		Model:      s.Model,
+		Prompt:     s.Prompt,
+		Response:   doneMsg.Response,
+		CreatedAt:  doneMsg.CreatedAt,
+		DoneReason: doneMsg.DoneReason,
+		Context:    doneMsg.Context,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
@@ -9,8 +9,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/pflag"
 )
@@ -19,59 +21,119 @@ import (
 
 const defaultOllamaPrompt = "Describe this image for a visually impaired person"
 
-var usageFormatShort string = `usage:  %s [--help] [options] --in <input-csv-filename>`
+var usageFormat string = `usage:  %s [--help] [options]
 
-var usageFormat string = `usage:  %s [--help] [options] --in <input-csv-filename>
+A simple chat with an Ollama model.  Each prompt continues the conversation.
+
+--prompt is sent as soon as the chat starts.  --image attaches an image to the
+first prompt; if there is no --prompt, the image is described.  With
+--save-transcript, the conversation is written as Markdown on exit.
+
+Example:  $ ot-simplegen -m llava --image photo.png -p "What is in this photo?"
+
+With --json, the chat is drawn on stderr, and the last prompt and response
+are written to stdout as a JSON object on exit.
+
+Shell completion scripts are printed with --completion bash, zsh, or fish.
 `
 
 /////////////////////////////////////////////////////////////////////////////////////
 // simpleGenModel
 
 type simpleGenModel struct {
-	chatPanel ollamatea.ChatPanelModel
+	session     *ollamatea.Session
+	chatPanel   *ollamatea.ChatPanelModel // updated in place, so not copied with simpleGenModel
+	startPrompt bool                      // send the Session's Prompt on Init
+	exchanges   []exchange                // completed prompts and responses, for the transcript
 }
 
-func newSimpleGenModel(title string) simpleGenModel {
+// exchange is a prompt and its response
+type exchange struct {
+	Prompt   string
+	Response string
+	Error    error
+}
+
+func newSimpleGenModel(title string, session *ollamatea.Session) simpleGenModel {
 	m := simpleGenModel{
-		chatPanel: ollamatea.NewChatPanel(ollamatea.NewSession()),
+		session:     session,
+		chatPanel:   ollamatea.NewChatPanelWithSession(session),
+		startPrompt: session.Prompt != "",
 	}
 	m.chatPanel.Title = title
 	return m
 }
 
 func (m simpleGenModel) Init() tea.Cmd {
+	if m.startPrompt {
+		return tea.Batch(m.chatPanel.Init(), m.session.Start())
+	}
 	return m.chatPanel.Init()
 }
 
 func (m simpleGenModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c": // quit
 			return m, tea.Quit
 		}
+	case ollamatea.GenerateDoneMsg:
+		if msg.ID == m.session.ID() {
+			m.exchanges = append(m.exchanges, exchange{Prompt: m.session.Prompt, Response: msg.Response, Error: msg.Error})
+			if msg.Error == nil {
+				// images were sent with the first prompt; AutoContext carries on the conversation
+				m.session.Images = nil
+			}
+		}
 	}
 
-	m.chatPanel, cmd = m.chatPanel.Update(msg)
-	return m, cmd
+	return m, m.chatPanel.UpdateInPlace(msg)
 }
 
 func (m simpleGenModel) View() string {
 	return m.chatPanel.View()
 }
 
+// jsonResult is the output written with --json
+type jsonResult struct {
+	Model    string `json:"model"`
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+	Error    string `json:"error,omitempty"`
+}
+
+// writeTranscript writes the exchanges to path as Markdown
+func writeTranscript(path string, title string, session *ollamatea.Session, exchanges []exchange) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\nModel: %s\n", title, session.Model)
+	if session.System != "" {
+		fmt.Fprintf(&sb, "\nSystem: %s\n", session.System)
+	}
+	for _, ex := range exchanges {
+		fmt.Fprintf(&sb, "\n## Prompt\n\n%s\n\n## Response\n\n%s\n", ex.Prompt, ex.Response)
+		if ex.Error != nil {
+			fmt.Fprintf(&sb, "\nERROR: %s\n", ex.Error.Error())
+		}
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
 /////////////////////////////////////////////////////////////////////////////////////
 
 func main() {
-	var ollamaHost, ollamaModel, chatTitle string
-	var verbose, showHelp bool
+	var chatTitle, transcriptPath string
+	var imagePaths []string
+	var outputJSON, showHelp bool
 
-	pflag.StringVarP(&ollamaHost, "host", "h", ollamatea.DefaultHost(), "Host for Ollama (also OLLAMATEA_HOST env)")
-	pflag.StringVarP(&ollamaModel, "model", "m", ollamatea.DefaultModel(), "Model for Ollama (also OLLAMATEA_MODEL env)")
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost().AddModel().AddPrompt().AddSystem().AddVerbose()
 	pflag.StringVarP(&chatTitle, "title", "t", "simplegen", "Title for chat")
-	pflag.BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	pflag.StringArrayVarP(&imagePaths, "image", "i", nil, "Image file to attach to the first prompt [repeatable]")
+	pflag.StringVarP(&transcriptPath, "save-transcript", "", "", "Write the conversation to this Markdown file on exit")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, clikit.JSONFlagUsage)
 	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-simplegen").
+		FileFlags("image", "save-transcript")
 	pflag.Parse()
 
 	if showHelp {
@@ -79,15 +141,72 @@ func main() {
 		pflag.PrintDefaults()
 		os.Exit(0)
 	}
-	if verbose {
-		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s\n", ollamaHost, ollamaModel)
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if cli.Verbose {
+		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s images=%d\n", cli.Host, cli.Model, len(imagePaths))
+	}
+
+	session := ollamatea.NewSession()
+	session.Host = cli.Host
+	session.Model = cli.Model
+	session.Prompt = cli.Prompt
+	session.System = cli.System
+	session.AutoContext = true
+	for _, imagePath := range imagePaths {
+		imageData, err := os.ReadFile(imagePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to read image %s\n", err.Error())
+			os.Exit(1)
+		}
+		session.Images = append(session.Images, imageData)
+	}
+	if len(session.Images) != 0 && session.Prompt == "" {
+		session.Prompt = defaultOllamaPrompt
 	}
 
 	// Create simpleGenModel and run the BubbleTea Program
-	m := newSimpleGenModel(chatTitle)
-	_, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion()).Run()
+	m := newSimpleGenModel(chatTitle, &session)
+	programOptions := []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseCellMotion()}
+	if outputJSON {
+		// keep stdout for the JSON
+		programOptions = append(programOptions, tea.WithOutput(os.Stderr))
+	}
+	model, err := tea.NewProgram(m, programOptions...).Run()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 		os.Exit(1)
 	}
+	m = model.(simpleGenModel)
+
+	if transcriptPath != "" {
+		if err := writeTranscript(transcriptPath, chatTitle, m.session, m.exchanges); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to write transcript %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if !outputJSON {
+		return
+	}
+	result := jsonResult{
+		Model:    m.session.Model,
+		Prompt:   m.session.Prompt,
+		Response: m.session.Response(),
+	}
+	if err := m.session.Error(); err != nil {
+		result.Error = err.Error()
+	}
+	if err := clikit.WriteJSON(os.Stdout, result); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,591 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+// ot-table-prompt
+//
+// Generalizes ot-timechart to arbitrary tabular data.
+// Loads CSV/TSV/JSON, renders a table (and optionally a sparkline chart),
+// and lets the user ask questions about it with column statistics appended.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NimbleMarkets/ntcharts/sparkline"
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/pflag"
+)
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+const (
+	defaultSampleRows  = 20
+	defaultColumnWidth = 12
+	maxTopValues       = 5
+	chartHeight        = 6
+)
+
+var usageFormatShort string = `usage:  %s [--help] [options] --in <input-filename> [--json [question...]]`
+
+var usageFormat string = `usage:  %s [--help] [options] --in <input-filename> [--json [question...]]
+
+A mini-TUI for asking Ollama questions about tabular data.
+
+The input may be CSV, TSV, or JSON (an array of objects or an array of arrays).
+The format is detected from the filename extension or set with --format.
+The first row of CSV/TSV is the header row.
+
+Each prompt has statistics for every column appended, along with the first
+--sample rows of data.  Use --chart to plot a numeric column as a sparkline.
+
+Press 'tab' to switch focus between the table and the chat panel.
+
+With --json, there is no TUI.  A JSON object with the column statistics is
+written to stdout, along with Ollama's answer if a question is given as the
+remaining arguments.
+
+Shell completion scripts are printed with --completion bash, zsh, or fish.
+
+Example:  $ ot-table-prompt --in data.csv --chart price
+
+`
+
+const inputTextPlaceholder = "Ask about the table..."
+
+/////////////////////////////////////////////////////////////////////////////////////
+// Style
+
+var defaultStyle = lipgloss.NewStyle().
+	BorderStyle(lipgloss.NormalBorder()).
+	BorderForeground(lipgloss.Color("63")) // purple
+
+var focusedStyle = lipgloss.NewStyle().
+	BorderStyle(lipgloss.NormalBorder()).
+	BorderForeground(lipgloss.Color("205")) // pink
+
+/////////////////////////////////////////////////////////////////////////////////////
+// Tabular data
+
+// tableData holds the loaded header and rows
+type tableData struct {
+	Header []string
+	Rows   [][]string
+}
+
+// columnStats holds summary statistics for a single column
+type columnStats struct {
+	Name     string   `json:"name"`
+	Count    int      `json:"count"` // non-empty values
+	Numeric  bool     `json:"numeric"`
+	Min      float64  `json:"min"`
+	Max      float64  `json:"max"`
+	Mean     float64  `json:"mean"`
+	StdDev   float64  `json:"stddev"`
+	Distinct int      `json:"distinct"`
+	Top      []string `json:"top,omitempty"` // most frequent values, for non-numeric columns
+}
+
+// String renders the column statistics as a line of text for the prompt
+func (c columnStats) String() string {
+	if c.Numeric {
+		return fmt.Sprintf("%s: numeric, count=%d min=%g max=%g mean=%g stddev=%g",
+			c.Name, c.Count, c.Min, c.Max, c.Mean, c.StdDev)
+	}
+	return fmt.Sprintf("%s: text, count=%d distinct=%d top=[%s]",
+		c.Name, c.Count, c.Distinct, strings.Join(c.Top, ", "))
+}
+
+// computeColumnStats computes statistics for every column of the data
+func computeColumnStats(data tableData) []columnStats {
+	stats := make([]columnStats, len(data.Header))
+	for col, name := range data.Header {
+		cs := columnStats{Name: name, Numeric: true}
+		counts := make(map[string]int)
+		var values []float64
+		for _, row := range data.Rows {
+			if col >= len(row) || row[col] == "" {
+				continue
+			}
+			cs.Count++
+			counts[row[col]]++
+			if f, err := strconv.ParseFloat(row[col], 64); err == nil {
+				values = append(values, f)
+			} else {
+				cs.Numeric = false
+			}
+		}
+		cs.Distinct = len(counts)
+		if cs.Count == 0 {
+			cs.Numeric = false
+		}
+
+		if cs.Numeric {
+			cs.Min, cs.Max = math.MaxFloat64, -math.MaxFloat64
+			var sum float64
+			for _, v := range values {
+				cs.Min = math.Min(cs.Min, v)
+				cs.Max = math.Max(cs.Max, v)
+				sum += v
+			}
+			cs.Mean = sum / float64(len(values))
+			var sumSq float64
+			for _, v := range values {
+				sumSq += (v - cs.Mean) * (v - cs.Mean)
+			}
+			cs.StdDev = math.Sqrt(sumSq / float64(len(values)))
+		} else {
+			type valueCount struct {
+				value string
+				count int
+			}
+			var vcs []valueCount
+			for v, c := range counts {
+				vcs = append(vcs, valueCount{v, c})
+			}
+			sort.Slice(vcs, func(i, j int) bool {
+				if vcs[i].count != vcs[j].count {
+					return vcs[i].count > vcs[j].count
+				}
+				return vcs[i].value < vcs[j].value
+			})
+			for i := 0; i < len(vcs) && i < maxTopValues; i++ {
+				cs.Top = append(cs.Top, fmt.Sprintf("%s(%d)", vcs[i].value, vcs[i].count))
+			}
+		}
+		stats[col] = cs
+	}
+	return stats
+}
+
+// columnIndex returns the index of the named column, or -1 if not found
+func (d tableData) columnIndex(name string) int {
+	for i, h := range d.Header {
+		if h == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// readDelimited reads CSV/TSV data with the given delimiter
+func readDelimited(r io.Reader, delim rune) (tableData, error) {
+	var data tableData
+	csvReader := csv.NewReader(r)
+	csvReader.Comma = delim
+	csvReader.FieldsPerRecord = -1
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return data, err
+	}
+	if len(records) == 0 {
+		return data, errors.New("no rows in input")
+	}
+	data.Header, data.Rows = records[0], records[1:]
+	return data, nil
+}
+
+// readJSON reads a JSON array of objects or array of arrays
+func readJSON(r io.Reader) (tableData, error) {
+	var data tableData
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return data, fmt.Errorf("expected JSON array %w", err)
+	}
+	if len(raw) == 0 {
+		return data, errors.New("no rows in input")
+	}
+
+	// Array of arrays: first row is the header
+	var firstArray []any
+	if err := json.Unmarshal(raw[0], &firstArray); err == nil {
+		for i, rowRaw := range raw {
+			var row []any
+			if err := json.Unmarshal(rowRaw, &row); err != nil {
+				return data, fmt.Errorf("row %d: %w", i, err)
+			}
+			cells := make([]string, len(row))
+			for j, v := range row {
+				cells[j] = jsonCellString(v)
+			}
+			if i == 0 {
+				data.Header = cells
+			} else {
+				data.Rows = append(data.Rows, cells)
+			}
+		}
+		return data, nil
+	}
+
+	// Array of objects: header is the sorted union of keys
+	var objects []map[string]any
+	keySet := make(map[string]bool)
+	for i, rowRaw := range raw {
+		var obj map[string]any
+		if err := json.Unmarshal(rowRaw, &obj); err != nil {
+			return data, fmt.Errorf("row %d: %w", i, err)
+		}
+		for k := range obj {
+			keySet[k] = true
+		}
+		objects = append(objects, obj)
+	}
+	for k := range keySet {
+		data.Header = append(data.Header, k)
+	}
+	sort.Strings(data.Header)
+	for _, obj := range objects {
+		cells := make([]string, len(data.Header))
+		for j, k := range data.Header {
+			if v, ok := obj[k]; ok {
+				cells[j] = jsonCellString(v)
+			}
+		}
+		data.Rows = append(data.Rows, cells)
+	}
+	return data, nil
+}
+
+// jsonCellString converts a decoded JSON value to a table cell
+func jsonCellString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// detectFormat returns the input format from the filename extension
+func detectFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tsv", ".tab":
+		return "tsv"
+	case ".json":
+		return "json"
+	default:
+		return "csv"
+	}
+}
+
+// readTableData reads the tabular data in the given format
+func readTableData(r io.Reader, format string) (tableData, error) {
+	switch format {
+	case "csv":
+		return readDelimited(r, ',')
+	case "tsv":
+		return readDelimited(r, '\t')
+	case "json":
+		return readJSON(r)
+	default:
+		return tableData{}, fmt.Errorf("unknown format '%s'", format)
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+// tablePromptModel
+
+// tablePromptModel is the primary BubbleTea model for the table-prompt TUI
+type tablePromptModel struct {
+	Title string
+
+	data       tableData
+	stats      []columnStats
+	sampleRows int
+
+	table      table.Model
+	chart      *sparkline.Model
+	chartName  string
+	chatPanel  ollamatea.ChatPanelModel
+	tableFocus bool
+}
+
+func newTablePromptModel(data tableData, session ollamatea.Session) tablePromptModel {
+	columns := make([]table.Column, len(data.Header))
+	for i, h := range data.Header {
+		columns[i] = table.Column{Title: h, Width: max(len(h), defaultColumnWidth)}
+	}
+	rows := make([]table.Row, len(data.Rows))
+	for i, r := range data.Rows {
+		row := make(table.Row, len(columns))
+		copy(row, r)
+		rows[i] = row
+	}
+
+	m := tablePromptModel{
+		data:       data,
+		stats:      computeColumnStats(data),
+		sampleRows: defaultSampleRows,
+		table: table.New(
+			table.WithColumns(columns),
+			table.WithRows(rows),
+		),
+		chatPanel: ollamatea.NewChatPanel(session),
+	}
+	m.chatPanel.SetPlaceholder(inputTextPlaceholder)
+	return m
+}
+
+// setChart sets up a sparkline of the named numeric column
+func (m *tablePromptModel) setChart(name string) error {
+	col := m.data.columnIndex(name)
+	if col < 0 {
+		return fmt.Errorf("unknown chart column '%s'", name)
+	}
+	if !m.stats[col].Numeric {
+		return fmt.Errorf("chart column '%s' is not numeric", name)
+	}
+	chart := sparkline.New(defaultColumnWidth, chartHeight)
+	for _, row := range m.data.Rows {
+		if col < len(row) {
+			if f, err := strconv.ParseFloat(row[col], 64); err == nil {
+				chart.Push(f)
+			}
+		}
+	}
+	m.chart, m.chartName = &chart, name
+	return nil
+}
+
+// promptContext renders the column statistics and sample rows for the prompt
+func (m *tablePromptModel) promptContext() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The table has %d rows and %d columns.\n", len(m.data.Rows), len(m.data.Header))
+	sb.WriteString("Column statistics:\n")
+	for _, cs := range m.stats {
+		sb.WriteString("  " + cs.String() + "\n")
+	}
+	if m.sampleRows > 0 && len(m.data.Rows) > 0 {
+		n := min(m.sampleRows, len(m.data.Rows))
+		fmt.Fprintf(&sb, "The first %d rows as CSV:\n", n)
+		csvWriter := csv.NewWriter(&sb)
+		csvWriter.Write(m.data.Header)
+		csvWriter.WriteAll(m.data.Rows[:n])
+	}
+	return sb.String()
+}
+
+func (m tablePromptModel) Init() tea.Cmd {
+	return m.chatPanel.Init()
+}
+
+func (m tablePromptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.chatPanel.SetHeight(msg.Height - 1)
+
+		// chat window has a constant width and the table fills the rest
+		tableWidth := msg.Width - m.chatPanel.Width() - 2 // 2 for border
+		tableHeight := msg.Height - 2
+		if m.chart != nil {
+			tableHeight -= chartHeight + 1
+			m.chart.Resize(tableWidth, chartHeight)
+			m.chart.Draw()
+		}
+		m.table.SetWidth(tableWidth)
+		m.table.SetHeight(tableHeight)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "tab":
+			m.tableFocus = !m.tableFocus
+			if m.tableFocus {
+				m.table.Focus()
+			} else {
+				m.table.Blur()
+			}
+			return m, nil
+		}
+		if m.tableFocus {
+			if msg.String() == "q" {
+				return m, tea.Quit
+			}
+			var cmd tea.Cmd
+			m.table, cmd = m.table.Update(msg)
+			return m, cmd
+		}
+
+	case ollamatea.StartGenerateMsg:
+		// Before we start generating, append the table context to the prompt
+		if msg.ID == m.chatPanel.Session.ID() {
+			m.chatPanel.Session.Prompt += "\n\n" + m.promptContext()
+		}
+	}
+
+	var cmd tea.Cmd
+	m.chatPanel, cmd = m.chatPanel.Update(msg)
+	return m, cmd
+}
+
+func (m tablePromptModel) View() string {
+	style := defaultStyle
+	if m.tableFocus {
+		style = focusedStyle
+	}
+	left := m.Title + m.table.View()
+	if m.chart != nil {
+		left = lipgloss.JoinVertical(lipgloss.Left, left, m.chartName, m.chart.View())
+	}
+	return lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		style.Render(left),
+		m.chatPanel.View())
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+// jsonResult is the output written with --json
+type jsonResult struct {
+	Rows     int           `json:"rows"`
+	Columns  []columnStats `json:"columns"`
+	Model    string        `json:"model,omitempty"`
+	Question string        `json:"question,omitempty"`
+	Answer   string        `json:"answer,omitempty"`
+}
+
+// answerJSON writes the table statistics as a jsonResult, answering the question if it is not empty
+func answerJSON(m tablePromptModel, session *ollamatea.Session, question string) error {
+	result := jsonResult{Rows: len(m.data.Rows), Columns: m.stats}
+	if question != "" {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		session.Prompt = question + "\n\n" + m.promptContext()
+		doneMsg, err := session.Generate(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("generation failed %w", err)
+		}
+		result.Model, result.Question, result.Answer = session.Model, question, doneMsg.Response
+	}
+	return clikit.WriteJSON(os.Stdout, result)
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+
+func main() {
+	var inputFilename, inputFormat, chartColumn string
+	var tableTitle string
+	var sampleRows int
+	var outputJSON, showHelp bool
+
+	pflag.StringVarP(&inputFilename, "in", "i", "", "Input filename ('-' is stdin)")
+	pflag.StringVarP(&inputFormat, "format", "f", "", "Input format: csv, tsv, or json (default: from extension, else csv)")
+	pflag.StringVarP(&chartColumn, "chart", "c", "", "Numeric column to chart as a sparkline")
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost().AddModel().AddVerbose()
+	pflag.StringVarP(&tableTitle, "title", "t", "", "Title for the table")
+	pflag.IntVarP(&sampleRows, "sample", "s", defaultSampleRows, "Number of rows to include in each prompt")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, clikit.JSONFlagUsage)
+	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-table-prompt").
+		ModelFlags("model").
+		FileFlags("in").
+		ValuesFlag("format", "csv", "tsv", "json")
+	pflag.Parse()
+
+	if showHelp {
+		fmt.Fprintf(os.Stdout, usageFormat, os.Args[0])
+		pflag.PrintDefaults()
+		os.Exit(0)
+	}
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if len(inputFilename) == 0 {
+		fmt.Fprintf(os.Stderr, "ERROR: missing required argument: --in\n")
+		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
+		os.Exit(1)
+	}
+	if inputFormat == "" {
+		inputFormat = detectFormat(inputFilename)
+	}
+	if cli.Verbose {
+		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s format=%s\n", cli.Host, cli.Model, inputFormat)
+	}
+
+	// Open input file for reading, or use Stdin
+	var err error
+	infile := os.Stdin
+	if inputFilename != "-" {
+		infile, err = os.OpenFile(inputFilename, os.O_RDONLY, 0777)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to open input file %s\n", err.Error())
+			os.Exit(1)
+		}
+		defer infile.Close()
+	}
+
+	data, err := readTableData(infile, strings.ToLower(inputFormat))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to read table %s\n", err.Error())
+		os.Exit(1)
+	}
+	infile.Close() // we don't need it anymore
+
+	// Create tablePromptModel and run the BubbleTea Program
+	session := ollamatea.NewSession()
+	session.Host = cli.Host
+	session.Model = cli.Model
+	session.AutoContext = true // carry on the Ollama conversation's Context
+
+	m := newTablePromptModel(data, session)
+	m.sampleRows = sampleRows
+	if tableTitle != "" {
+		m.Title = tableTitle + "\n"
+	}
+	if chartColumn != "" {
+		if err := m.setChart(chartColumn); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if outputJSON {
+		if err := answerJSON(m, &session, strings.Join(pflag.Args(), " ")); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	var progOpts []tea.ProgramOption
+	progOpts = append(progOpts, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	if inputFilename == "-" {
+		progOpts = append(progOpts, tea.WithInputTTY())
+	}
+	_, err = tea.NewProgram(m, progOpts...).Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
@@ -7,18 +7,21 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"time"
 
 	tslc "github.com/NimbleMarkets/ntcharts/linechart/timeserieslinechart"
 	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
 	"github.com/ollama/ollama/api"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -42,12 +45,22 @@ The prompt may be specified with  --prompt or the OLLAMATEA_PROMPT envvar.
 The default prompt is:
   ` + defaultOllamaPrompt + `'.
 
+With --json, there is no TUI.  The chart is rendered at 80x24, the prompt is
+sent with its image, and a JSON object with the response is written to stdout.
+
+Shell completion scripts are printed with --completion bash, zsh, or fish.
+
 See https://github.com/NimbleMarkets/ollamatea/tree/main/cmd/ot-timechart
 
 `
 
 const inputTextPlaceholder = "Prompt about the chart..."
 
+const (
+	jsonChartWidth  = 80 // chart width for --json
+	jsonChartHeight = 24 // chart height for --json
+)
+
 /////////////////////////////////////////////////////////////////////////////////////
 // Style
 
@@ -76,6 +89,7 @@ type timechartModel struct {
 func newTimechartModel(timePoints []tslc.TimePoint) timechartModel {
 	otSession := ollamatea.NewSession()
 	otSession.Prompt = defaultOllamaPrompt
+	otSession.AutoContext = true // carry on the Ollama conversation's Context
 
 	m := timechartModel{
 		chart: tslc.New(20, 10,
@@ -151,10 +165,6 @@ func (m timechartModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		m.chatPanel.Session.Images = []api.ImageData{pngBytes}
-	case ollamatea.GenerateDoneMsg:
-		// When done, maintain the Ollama conversation's Context
-		m.chatPanel.Session.Context = msg.Context
-		return m, nil
 	}
 
 	var cmds []tea.Cmd
@@ -192,22 +202,80 @@ func (m *timechartModel) resetTimeRange() {
 
 /////////////////////////////////////////////////////////////////////////////////////
 
+// jsonResult is the output written with --json
+type jsonResult struct {
+	Points   int       `json:"points"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Min      float64   `json:"min"`
+	Max      float64   `json:"max"`
+	Model    string    `json:"model"`
+	Prompt   string    `json:"prompt"`
+	Response string    `json:"response"`
+}
+
+// describeJSON renders the chart of records without the TUI, sends it with the
+// session's prompt, and writes the response as a jsonResult
+func describeJSON(m timechartModel, records []tslc.TimePoint, session *ollamatea.Session) error {
+	m.chart.Resize(jsonChartWidth, jsonChartHeight)
+	if m.UseBraille {
+		m.chart.DrawBrailleAll()
+	} else {
+		m.chart.DrawAll()
+	}
+	pngBytes, err := ollamatea.ConvertTerminalTextToImage(m.Title+m.chart.View(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to convert chart to image %w", err)
+	}
+	session.Images = []api.ImageData{pngBytes}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	doneMsg, err := session.Generate(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("generation failed %w", err)
+	}
+	result := jsonResult{
+		Points:   len(records),
+		Model:    session.Model,
+		Prompt:   session.Prompt,
+		Response: doneMsg.Response,
+	}
+	for i, tp := range records {
+		if i == 0 || tp.Time.Before(result.Start) {
+			result.Start = tp.Time
+		}
+		if i == 0 || tp.Time.After(result.End) {
+			result.End = tp.Time
+		}
+		if i == 0 || tp.Value < result.Min {
+			result.Min = tp.Value
+		}
+		if i == 0 || tp.Value > result.Max {
+			result.Max = tp.Value
+		}
+	}
+	return clikit.WriteJSON(os.Stdout, result)
+}
+
+/////////////////////////////////////////////////////////////////////////////////////
+
 func main() {
 	var inputCSVFilename string
 	var inputIsZstd, useBraille bool
-	var ollamaHost, ollamaModel, ollamaPrompt string
 	var chartTitle string
-	var verbose, showHelp bool
+	var outputJSON, showHelp bool
 
 	pflag.StringVarP(&inputCSVFilename, "in", "i", "", "Input CSV filename ('-' is stdin)")
-	pflag.StringVarP(&ollamaHost, "host", "h", ollamatea.DefaultHost(), "Host for Ollama (also OLLAMATEA_HOST env)")
-	pflag.StringVarP(&ollamaModel, "model", "m", ollamatea.DefaultModel(), "Model for Ollama (also OLLAMATEA_MODEL env)")
-	pflag.StringVarP(&ollamaPrompt, "prompt", "p", "", "Prompt for Ollama (see --help for default)")
+	cli := clikit.NewFlags(pflag.CommandLine).AddHost().AddModel().AddPrompt().AddVerbose()
 	pflag.StringVarP(&chartTitle, "title", "t", "", "Title for the chart")
 	pflag.BoolVarP(&inputIsZstd, "zstd", "z", false, "Input is ZSTD compressed (otherwise uses filename ending in .zst or zstd)")
 	pflag.BoolVar(&useBraille, "braille", false, "use braille lines (default: arc lines)")
-	pflag.BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	pflag.BoolVarP(&outputJSON, "json", "j", false, clikit.JSONFlagUsage)
 	pflag.BoolVarP(&showHelp, "help", "", false, "show help")
+	completion := clikit.NewCompletion(pflag.CommandLine, "ot-timechart").
+		ModelFlags("model").
+		FileFlags("in")
 	pflag.Parse()
 
 	if showHelp {
@@ -215,16 +283,27 @@ func main() {
 		pflag.PrintDefaults()
 		os.Exit(0)
 	}
+	if err := cli.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if handled, err := completion.Handle(os.Stdout, cli.Host); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if len(inputCSVFilename) == 0 {
 		fmt.Fprintf(os.Stderr, "ERROR: missing required argument: --in\n")
 		fmt.Fprintf(os.Stderr, usageFormatShort, os.Args[0])
 		os.Exit(1)
 	}
-	if len(ollamaPrompt) == 0 {
-		ollamaPrompt = defaultOllamaPrompt
+	if len(cli.Prompt) == 0 {
+		cli.Prompt = defaultOllamaPrompt
 	}
-	if verbose {
-		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s oprompt=\"%s\"\n", ollamaHost, ollamaModel, ollamaPrompt)
+	if cli.Verbose {
+		fmt.Fprintf(os.Stderr, "INFO: ohost=%s omodel=%s oprompt=\"%s\"\n", cli.Host, cli.Model, cli.Prompt)
 	}
 
 	// Read the CSV file and build the dataset
@@ -246,6 +325,18 @@ func main() {
 	m.Title = chartTitle + "\n"
 	m.UseBraille = useBraille
 
+	if outputJSON {
+		session := ollamatea.NewSession()
+		session.Host = cli.Host
+		session.Model = cli.Model
+		session.Prompt = cli.Prompt
+		if err := describeJSON(m, records, &session); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	_, err = tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion()).Run()
 	if err != nil {
 		fmt.Println("Error running program:", err)
@@ -0,0 +1,186 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Code completion
+//
+// A CompletionSession generates fill-in-the-middle (FIM) completions with a
+// code model, such as qwen2.5-coder, codellama:code, or starcoder2: given the
+// code before and after a cursor, the model generates the code between them.
+//
+//	s := ollamatea.NewCompletionSession()
+//	s.Model = "qwen2.5-coder:1.5b"
+//	...
+//	return m, s.Infill(before, after)
+//	...
+//	case ollamatea.CompletionDoneMsg:
+//	    m.editor.InsertString(msg.Completion)
+
+// CompletionDoneMsg is sent after the GenerateDoneMsg of a CompletionSession,
+// with only the infilled text.
+type CompletionDoneMsg struct {
+	ID         int64     // ID is the completion session ID
+	Prefix     string    // Prefix is the code before the cursor
+	Suffix     string    // Suffix is the code after the cursor
+	Completion string    // Completion is the infilled text, without any FIM or end-of-text tokens
+	CreatedAt  time.Time // CreatedAt is the timestamp of the completion
+	DoneReason string    // DoneReason is the reason the model stopped generating text
+	Metrics    Metrics   // Metrics are the durations and token counts of the generation
+	Error      error     // Error is why the generation failed, if it did; see [OllamaError]
+}
+
+// FIMTemplate is a model family's fill-in-the-middle prompt format, for
+// prompting with Raw when the model's template does not handle a Suffix.
+type FIMTemplate struct {
+	Prefix string // Prefix is the token before the code before the cursor
+	Suffix string // Suffix is the token before the code after the cursor
+	Middle string // Middle is the token after which the model generates the infill
+}
+
+// FIM templates of common code model families
+var (
+	FIMTemplateQwenCoder     = FIMTemplate{Prefix: "<|fim_prefix|>", Suffix: "<|fim_suffix|>", Middle: "<|fim_middle|>"}
+	FIMTemplateCodeLlama     = FIMTemplate{Prefix: "<PRE> ", Suffix: " <SUF>", Middle: " <MID>"}
+	FIMTemplateStarCoder     = FIMTemplate{Prefix: "<fim_prefix>", Suffix: "<fim_suffix>", Middle: "<fim_middle>"}
+	FIMTemplateDeepSeekCoder = FIMTemplate{Prefix: "<｜fim▁begin｜>", Suffix: "<｜fim▁hole｜>", Middle: "<｜fim▁end｜>"}
+)
+
+// fimStopTokens end an infill if a model generates them as text
+var fimStopTokens = []string{"<|endoftext|>", "<|file_sep|>", "<|fim_pad|>", "<EOT>", "<|EOT|>"}
+
+// Format returns the raw prompt infilling between prefix and suffix
+func (t FIMTemplate) Format(prefix string, suffix string) string {
+	return t.Prefix + prefix + t.Suffix + suffix + t.Middle
+}
+
+// clean returns the completion up to any FIM or end-of-text token
+func (t FIMTemplate) clean(completion string) string {
+	for _, token := range append([]string{t.Prefix, t.Suffix, t.Middle}, fimStopTokens...) {
+		if token = strings.TrimSpace(token); token == "" {
+			continue
+		}
+		if idx := strings.Index(completion, token); idx >= 0 {
+			completion = completion[:idx]
+		}
+	}
+	return completion
+}
+
+// FIMTemplateFor returns the FIMTemplate of the model's family, by its name.
+// Returns false if the family is unknown; such models are prompted with a
+// Suffix, for the model's own template to handle.
+func FIMTemplateFor(model string) (FIMTemplate, bool) {
+	name := strings.ToLower(model)
+	switch {
+	case strings.Contains(name, "qwen") && strings.Contains(name, "coder"):
+		return FIMTemplateQwenCoder, true
+	case strings.Contains(name, "codellama"):
+		return FIMTemplateCodeLlama, true
+	case strings.Contains(name, "starcoder"):
+		return FIMTemplateStarCoder, true
+	case strings.Contains(name, "deepseek-coder"):
+		return FIMTemplateDeepSeekCoder, true
+	}
+	return FIMTemplate{}, false
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// CompletionSession is a Session for fill-in-the-middle code completion.
+// Infill sets the Session's Prompt, Suffix, and Raw for the Model, clearing
+// the System prompt and Context, which infill does not use.
+//
+// Its Update passes messages to the Session and, after each GenerateDoneMsg,
+// sends a CompletionDoneMsg.  Like Session, its methods take pointer receivers.
+type CompletionSession struct {
+	Session
+
+	// FIM is the prompt format, sent Raw.  If nil, FIMTemplateFor(Model) is used;
+	// if the Model's family is unknown, the code after the cursor is sent as the
+	// Suffix for the model's own template to handle.
+	FIM *FIMTemplate
+
+	// Private
+	prefix string
+	suffix string
+}
+
+var _ SessionModel = (*CompletionSession)(nil)
+
+// NewCompletionSession returns a new CompletionSession with the default values.
+// Set its Model to one which supports infill.
+func NewCompletionSession() CompletionSession {
+	return CompletionSession{Session: NewSession()}
+}
+
+// Infill returns a command which starts generating the completion between prefix and suffix.
+func (c *CompletionSession) Infill(prefix string, suffix string) tea.Cmd {
+	c.prepare(prefix, suffix)
+	return c.Start()
+}
+
+// Update handles BubbleTea messages for the CompletionSession, sending a
+// CompletionDoneMsg after each of its GenerateDoneMsg.
+func (c *CompletionSession) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	_, cmd := c.Session.Update(msg)
+	if doneMsg, ok := msg.(GenerateDoneMsg); ok && doneMsg.ID == c.ID() {
+		return c, tea.Batch(cmd, Cmdize(c.makeCompletionDoneMsg(doneMsg)))
+	}
+	return c, cmd
+}
+
+// Complete performs a blocking completion between prefix and suffix, outside of BubbleTea.
+// Returns the CompletionDoneMsg, with Error also returned if it failed.
+func (c *CompletionSession) Complete(ctx context.Context, prefix string, suffix string) (CompletionDoneMsg, error) {
+	c.prepare(prefix, suffix)
+	doneMsg, err := c.Generate(ctx, nil)
+	return c.makeCompletionDoneMsg(doneMsg), err
+}
+
+// template returns the FIM prompt format for the Model, if any
+func (c *CompletionSession) template() (FIMTemplate, bool) {
+	if c.FIM != nil {
+		return *c.FIM, true
+	}
+	return FIMTemplateFor(c.Model)
+}
+
+// prepare sets the Session's request fields for infilling between prefix and suffix
+func (c *CompletionSession) prepare(prefix string, suffix string) {
+	c.prefix, c.suffix = prefix, suffix
+	c.System = "" // infill templates do not use a system prompt
+	c.Context = nil
+	if template, ok := c.template(); ok {
+		c.Prompt = template.Format(prefix, suffix)
+		c.Session.Suffix = ""
+		c.Raw = true
+	} else {
+		c.Prompt = prefix
+		c.Session.Suffix = suffix
+		c.Raw = false
+	}
+}
+
+// makeCompletionDoneMsg returns the CompletionDoneMsg for the generation's GenerateDoneMsg
+func (c *CompletionSession) makeCompletionDoneMsg(doneMsg GenerateDoneMsg) CompletionDoneMsg {
+	template, _ := c.template()
+	return CompletionDoneMsg{
+		ID:         doneMsg.ID,
+		Prefix:     c.prefix,
+		Suffix:     c.suffix,
+		Completion: template.clean(doneMsg.Response),
+		CreatedAt:  doneMsg.CreatedAt,
+		DoneReason: doneMsg.DoneReason,
+		Metrics:    doneMsg.Metrics,
+		Error:      doneMsg.Error,
+	}
+}
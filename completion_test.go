@@ -0,0 +1,52 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestCompletionSession(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.QueueGenerate(
+		ollamateatest.GenerateScript{Chunks: []string{"return a + b", "<|endoftext|>"}},
+		ollamateatest.GenerateScript{Chunks: []string{"a * b"}})
+
+	completion := ollamatea.NewCompletionSession()
+	completion.Host = srv.URL
+	completion.Model = "qwen2.5-coder:1.5b"
+	completion.Context = []int{1, 2, 3}
+	done, err := completion.Complete(context.Background(), "def add(a, b):\n    ", "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Completion != "return a + b" || done.Prefix != "def add(a, b):\n    " || done.Suffix != "\n" {
+		t.Errorf("unexpected completion %+v", done)
+	}
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 1 || !reqs[0].Raw || reqs[0].Suffix != "" || len(reqs[0].Context) != 0 ||
+		reqs[0].Prompt != "<|fim_prefix|>def add(a, b):\n    <|fim_suffix|>\n<|fim_middle|>" {
+		t.Fatalf("unexpected request %+v", reqs)
+	}
+
+	// an unknown family is sent with a Suffix for its own template
+	completion.Model = "mystery-code"
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := completion.Update(msg)
+		return cmd
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(completion.Init(), completion.Infill("mul(", ")")),
+		ollamateatest.MatchType[ollamatea.CompletionDoneMsg](), 0)
+	if done := msg.(ollamatea.CompletionDoneMsg); done.ID != completion.ID() || done.Completion != "a * b" {
+		t.Errorf("unexpected completion %+v", done)
+	}
+	if reqs := srv.GenerateRequests(); len(reqs) != 2 || reqs[1].Raw || reqs[1].Prompt != "mul(" || reqs[1].Suffix != ")" {
+		t.Errorf("unexpected request %+v", reqs)
+	}
+}
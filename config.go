@@ -15,26 +15,47 @@ var (
 	defaultOllamaModel  = "llama3.2-vision:11b"    // OLLAMATEA_MODEL overrides
 	defaultOllamaPrompt = ""                       // OLLAMATEA_PROMPT overrides
 	defaultOllamaSystem = ""                       // OLLAMATEA_SYSTEM overrides
+	defaultSimulate     = false                    // OLLAMATEA_SIMULATE overrides
+	defaultAccessible   = false                    // OLLAMATEA_ACCESSIBLE overrides
+)
+
+var (
+	noEnv  bool            // OLLAMATEA_NOENV was set
+	envSet map[string]bool // environment variables which set a default, which a config file does not override
+
+	configModelSet bool // a config file set the default model
 )
 
 func init() {
+	envSet = make(map[string]bool)
 	if ollamaNoEnv := os.Getenv("OLLAMATEA_NOENV"); ollamaNoEnv != "" {
 		ollamaNoEnv = strings.ToLower(ollamaNoEnv)
 		if ollamaNoEnv == "true" || ollamaNoEnv == "yes" || ollamaNoEnv == "1" {
+			noEnv = true
 			return
 		}
 	}
 	if ollamaHost := os.Getenv("OLLAMATEA_HOST"); ollamaHost != "" {
 		defaultOllamaHost = ollamaHost
+		envSet["OLLAMATEA_HOST"] = true
 	}
 	if ollamaModel := os.Getenv("OLLAMATEA_MODEL"); ollamaModel != "" {
 		defaultOllamaModel = ollamaModel
+		envSet["OLLAMATEA_MODEL"] = true
 	}
 	if ollamaPrompt := os.Getenv("OLLAMATEA_PROMPT"); ollamaPrompt != "" {
 		defaultOllamaPrompt = ollamaPrompt
+		envSet["OLLAMATEA_PROMPT"] = true
 	}
 	if ollamaSystem := os.Getenv("OLLAMATEA_SYSTEM"); ollamaSystem != "" {
 		defaultOllamaSystem = ollamaSystem
+		envSet["OLLAMATEA_SYSTEM"] = true
+	}
+	if simulate := strings.ToLower(os.Getenv("OLLAMATEA_SIMULATE")); simulate != "" {
+		defaultSimulate = simulate == "true" || simulate == "yes" || simulate == "1"
+	}
+	if accessible := strings.ToLower(os.Getenv("OLLAMATEA_ACCESSIBLE")); accessible != "" {
+		defaultAccessible = accessible == "true" || accessible == "yes" || accessible == "1"
 	}
 }
 
@@ -46,6 +67,11 @@ func DefaultModel() string {
 	return defaultOllamaModel
 }
 
+// DefaultModelSet returns true if OLLAMATEA_MODEL or a config file set the [DefaultModel].
+func DefaultModelSet() bool {
+	return envSet["OLLAMATEA_MODEL"] || configModelSet
+}
+
 func DefaultPrompt() string {
 	return defaultOllamaPrompt
 }
@@ -53,3 +79,14 @@ func DefaultPrompt() string {
 func DefaultSystemPrompt() string {
 	return defaultOllamaSystem
 }
+
+// DefaultSimulate returns true if OLLAMATEA_SIMULATE selects a [SimulatedBackend] for every Host.
+func DefaultSimulate() bool {
+	return defaultSimulate
+}
+
+// DefaultAccessible returns true if OLLAMATEA_ACCESSIBLE enables accessible mode,
+// the default for the Accessible field of new components.
+func DefaultAccessible() bool {
+	return defaultAccessible
+}
@@ -0,0 +1,162 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Config files
+//
+// A config file sets the same defaults as the OLLAMATEA_* environment
+// variables, optionally grouped into named profiles.  It is JSON:
+//
+//	{
+//	  "host": "http://localhost:11434",
+//	  "model": "llama3.2",
+//	  "profile": "work",
+//	  "profiles": {
+//	    "work": { "host": "http://gpu-box:11434", "model": "qwen2.5:32b" }
+//	  }
+//	}
+//
+// Settings in the selected profile override the top-level ones.  Environment
+// variables override both, and applications' own flags override everything.
+
+// ConfigSettings are the defaults which a config file or profile may set.
+// Empty settings are unset.
+type ConfigSettings struct {
+	Host   string `json:"host,omitempty"`   // Host is the default Ollama server URL
+	Model  string `json:"model,omitempty"`  // Model is the default model name
+	Prompt string `json:"prompt,omitempty"` // Prompt is the default prompt
+	System string `json:"system,omitempty"` // System is the default system prompt
+}
+
+// ConfigFile is the contents of a config file.
+type ConfigFile struct {
+	ConfigSettings                           // ConfigSettings are the top-level settings
+	Profile        string                    `json:"profile,omitempty"`  // Profile is selected when none is requested
+	Profiles       map[string]ConfigSettings `json:"profiles,omitempty"` // Profiles are named settings
+}
+
+// DefaultConfigPath returns the config file path from OLLAMATEA_CONFIG, else
+// "ollamatea/config.json" in the user's config directory.  Returns "" if
+// there is no user config directory.
+func DefaultConfigPath() string {
+	if path := os.Getenv("OLLAMATEA_CONFIG"); path != "" && !noEnv {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ollamatea", "config.json")
+}
+
+// ReadConfigFile reads and parses the config file at path.
+func ReadConfigFile(path string) (ConfigFile, error) {
+	var config ConfigFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Settings returns the top-level settings overlaid with those of the named profile.
+// If profile is "", the file's Profile is used, if any.  Returns an error if the
+// profile is not in the file.
+func (c ConfigFile) Settings(profile string) (ConfigSettings, error) {
+	if profile == "" {
+		profile = c.Profile
+	}
+	settings := c.ConfigSettings
+	if profile == "" {
+		return settings, nil
+	}
+	overlay, ok := c.Profiles[profile]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for name := range c.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return settings, fmt.Errorf("unknown config profile %q, expected one of: %s", profile, strings.Join(names, ", "))
+	}
+	if overlay.Host != "" {
+		settings.Host = overlay.Host
+	}
+	if overlay.Model != "" {
+		settings.Model = overlay.Model
+	}
+	if overlay.Prompt != "" {
+		settings.Prompt = overlay.Prompt
+	}
+	if overlay.System != "" {
+		settings.System = overlay.System
+	}
+	return settings, nil
+}
+
+// ApplyConfigSettings sets the defaults returned by [DefaultHost], [DefaultModel],
+// [DefaultPrompt], and [DefaultSystemPrompt].  Empty settings, and those whose
+// OLLAMATEA_* environment variable is set, are left unchanged.
+func ApplyConfigSettings(settings ConfigSettings) {
+	if settings.Host != "" && !envSet["OLLAMATEA_HOST"] {
+		defaultOllamaHost = settings.Host
+	}
+	if settings.Model != "" && !envSet["OLLAMATEA_MODEL"] {
+		defaultOllamaModel = settings.Model
+		configModelSet = true
+	}
+	if settings.Prompt != "" && !envSet["OLLAMATEA_PROMPT"] {
+		defaultOllamaPrompt = settings.Prompt
+	}
+	if settings.System != "" && !envSet["OLLAMATEA_SYSTEM"] {
+		defaultOllamaSystem = settings.System
+	}
+}
+
+// LoadConfig reads the config file at path and applies the settings of the named
+// profile with [ApplyConfigSettings].  If profile is "", OLLAMATEA_PROFILE is used,
+// else the file's Profile.  If path is "", the [DefaultConfigPath] is read,
+// and it is not an error for that file to be missing.
+func LoadConfig(path string, profile string) error {
+	if profile == "" && !noEnv {
+		profile = os.Getenv("OLLAMATEA_PROFILE")
+	}
+	explicit := path != ""
+	if !explicit {
+		if path = DefaultConfigPath(); path == "" {
+			return nil
+		}
+	}
+	config, err := ReadConfigFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			if profile != "" {
+				return fmt.Errorf("config profile %q requested, but there is no config file %s", profile, path)
+			}
+			return nil
+		}
+		return err
+	}
+	settings, err := config.Settings(profile)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	ApplyConfigSettings(settings)
+	logger().Debug("config loaded", "path", path, "profile", profile)
+	return nil
+}
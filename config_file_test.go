@@ -0,0 +1,82 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+const testConfig = `{
+  "host": "http://top:11434",
+  "model": "top-model",
+  "profile": "work",
+  "profiles": {
+    "work": { "model": "work-model" },
+    "home": { "host": "http://home:11434" }
+  }
+}`
+
+func writeTestConfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFileSettings(t *testing.T) {
+	config, err := ollamatea.ReadConfigFile(writeTestConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := config.Settings("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Host != "http://top:11434" || settings.Model != "work-model" {
+		t.Errorf("expected the file's profile over the top level, got %+v", settings)
+	}
+
+	settings, err = config.Settings("home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Host != "http://home:11434" || settings.Model != "top-model" {
+		t.Errorf("expected the home profile over the top level, got %+v", settings)
+	}
+
+	_, err = config.Settings("nope")
+	if err == nil || !strings.Contains(err.Error(), "home, work") {
+		t.Errorf("expected an unknown profile error listing the profiles, got %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	prevHost, prevModel := ollamatea.DefaultHost(), ollamatea.DefaultModel()
+	t.Cleanup(func() {
+		ollamatea.ApplyConfigSettings(ollamatea.ConfigSettings{Host: prevHost, Model: prevModel})
+	})
+
+	if err := ollamatea.LoadConfig(filepath.Join(t.TempDir(), "missing.json"), ""); err == nil {
+		t.Errorf("expected an error for a missing explicit config file")
+	}
+
+	if err := ollamatea.LoadConfig(writeTestConfig(t), "home"); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("OLLAMATEA_HOST") == "" && ollamatea.DefaultHost() != "http://home:11434" {
+		t.Errorf("expected the profile's host, got %q", ollamatea.DefaultHost())
+	}
+	if os.Getenv("OLLAMATEA_MODEL") == "" && ollamatea.DefaultModel() != "top-model" {
+		t.Errorf("expected the top-level model, got %q", ollamatea.DefaultModel())
+	}
+	if !ollamatea.DefaultModelSet() {
+		t.Errorf("expected the config file to set the default model")
+	}
+}
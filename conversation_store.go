@@ -0,0 +1,361 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Conversation persistence
+//
+// A ConversationStore saves conversations -- their messages, model, options,
+// and timestamps -- so that applications can restore them across runs.
+// MemoryConversationStore is for tests and ephemeral use; DiskConversationStore
+// stores each conversation as a JSON file in a directory.
+
+// ErrConversationNotFound is returned when loading or deleting an unknown conversation.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// Conversation is a saved conversation.
+type Conversation struct {
+	ID        string                 `json:"id"`                // ID is assigned by Save, if empty
+	Title     string                 `json:"title,omitempty"`   // Title for display, if any
+	Host      string                 `json:"host,omitempty"`    // Host the conversation was held with
+	Model     string                 `json:"model"`             // Model the conversation was held with
+	System    string                 `json:"system,omitempty"`  // System prompt
+	Options   map[string]interface{} `json:"options,omitempty"` // Options lists model-specific options
+	Messages  []Message              `json:"messages"`          // Messages, without the System prompt
+	Context   []int                  `json:"context,omitempty"` // Context of a Session's generations, if any
+	CreatedAt time.Time              `json:"created_at"`        // CreatedAt is set by the first Save
+	UpdatedAt time.Time              `json:"updated_at"`        // UpdatedAt is set by each Save
+}
+
+// ConversationFromAgent returns a new Conversation holding the ToolAgent's history.
+func ConversationFromAgent(agent *ToolAgent) *Conversation {
+	return &Conversation{
+		Host:     agent.Host,
+		Model:    agent.Model,
+		System:   agent.System,
+		Options:  agent.Options,
+		Messages: slices.Clone(agent.Messages),
+	}
+}
+
+// ConversationFromChat returns a new Conversation holding the ChatSession's history.
+func ConversationFromChat(chat *ChatSession) *Conversation {
+	return &Conversation{
+		Host:     chat.Host,
+		Model:    chat.Model,
+		System:   chat.System,
+		Options:  chat.Options,
+		Messages: slices.Clone(chat.Messages),
+	}
+}
+
+// ConversationFromSession returns a new Conversation holding the Session's
+// History, or if it has none, its last exchange, and its generation Context.
+func ConversationFromSession(session *Session) *Conversation {
+	c := &Conversation{
+		Host:    session.Host,
+		Model:   session.Model,
+		System:  session.System,
+		Options: session.Options,
+		Context: slices.Clone(session.Context),
+	}
+	if len(session.history) > 0 {
+		c.Messages = turnMessages(session.history)
+		return c
+	}
+	if session.Prompt != "" {
+		c.Messages = append(c.Messages, Message{Role: "user", Content: session.Prompt})
+	}
+	if response := session.Response(); response != "" {
+		c.Messages = append(c.Messages, Message{Role: "assistant", Content: response})
+	}
+	return c
+}
+
+// RestoreAgent sets the ToolAgent's model, system prompt, options, and history
+// from the Conversation, so the next run continues it.
+func (c *Conversation) RestoreAgent(agent *ToolAgent) {
+	agent.Model = c.Model
+	agent.System = c.System
+	agent.Options = c.Options
+	agent.Messages = slices.Clone(c.Messages)
+}
+
+// RestoreChat sets the ChatSession's model, system prompt, options, and history
+// from the Conversation, so the next reply continues it.
+func (c *Conversation) RestoreChat(chat *ChatSession) {
+	chat.Model = c.Model
+	chat.System = c.System
+	chat.Options = c.Options
+	chat.Messages = slices.Clone(c.Messages)
+}
+
+// RestoreSession sets the Session's model, system prompt, options, and
+// generation Context from the Conversation, so the next generation continues it.
+func (c *Conversation) RestoreSession(session *Session) {
+	session.Model = c.Model
+	session.System = c.System
+	session.Options = c.Options
+	session.Context = slices.Clone(c.Context)
+}
+
+// ConversationInfo summarizes a saved Conversation, as returned by List.
+type ConversationInfo struct {
+	ID           string    // ID of the Conversation
+	Title        string    // Title of the Conversation
+	Model        string    // Model the conversation was held with
+	MessageCount int       // MessageCount is the number of Messages
+	CreatedAt    time.Time // CreatedAt is when the Conversation was first saved
+	UpdatedAt    time.Time // UpdatedAt is when the Conversation was last saved
+}
+
+// info returns the ConversationInfo of c
+func (c *Conversation) info() ConversationInfo {
+	return ConversationInfo{
+		ID:           c.ID,
+		Title:        c.Title,
+		Model:        c.Model,
+		MessageCount: len(c.Messages),
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+	}
+}
+
+// ConversationStore saves and restores Conversations.
+// Implementations must be safe for concurrent use.
+type ConversationStore interface {
+	// Save stores the Conversation, assigning its ID if empty and updating its timestamps.
+	Save(c *Conversation) error
+	// Load returns the Conversation with the ID, or ErrConversationNotFound.
+	Load(id string) (*Conversation, error)
+	// List returns the stored Conversations, most recently updated first.
+	List() ([]ConversationInfo, error)
+	// Delete removes the Conversation with the ID, or returns ErrConversationNotFound.
+	Delete(id string) error
+}
+
+// NewConversationID returns a new, unique, time-ordered Conversation ID.
+func NewConversationID() string {
+	return newTimeOrderedID()
+}
+
+// newTimeOrderedID returns a new, unique ID, which sorts by its creation time
+func newTimeOrderedID() string {
+	var suffix [4]byte
+	rand.Read(suffix[:])
+	return time.Now().UTC().Format("20060102T150405.000000") + "-" + hex.EncodeToString(suffix[:])
+}
+
+// prepareSave assigns c's ID and timestamps before saving
+func prepareSave(c *Conversation) {
+	if c.ID == "" {
+		c.ID = NewConversationID()
+	}
+	now := time.Now()
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = now
+	}
+	c.UpdatedAt = now
+}
+
+// sortConversationInfos sorts infos most recently updated first
+func sortConversationInfos(infos []ConversationInfo) {
+	slices.SortFunc(infos, func(a, b ConversationInfo) int {
+		if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// MemoryConversationStore is an in-memory ConversationStore.
+type MemoryConversationStore struct {
+	mu            sync.Mutex
+	conversations map[string][]byte // JSON, so callers cannot alias stored state
+}
+
+// NewMemoryConversationStore returns a new, empty MemoryConversationStore.
+func NewMemoryConversationStore() *MemoryConversationStore {
+	return &MemoryConversationStore{conversations: make(map[string][]byte)}
+}
+
+// Save stores the Conversation, assigning its ID if empty and updating its timestamps.
+func (s *MemoryConversationStore) Save(c *Conversation) error {
+	prepareSave(c)
+	bts, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conversations[c.ID] = bts
+	return nil
+}
+
+// Load returns the Conversation with the ID, or ErrConversationNotFound.
+func (s *MemoryConversationStore) Load(id string) (*Conversation, error) {
+	s.mu.Lock()
+	bts, ok := s.conversations[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrConversationNotFound
+	}
+	var c Conversation
+	if err := json.Unmarshal(bts, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// List returns the stored Conversations, most recently updated first.
+func (s *MemoryConversationStore) List() ([]ConversationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]ConversationInfo, 0, len(s.conversations))
+	for _, bts := range s.conversations {
+		var c Conversation
+		if err := json.Unmarshal(bts, &c); err != nil {
+			return nil, err
+		}
+		infos = append(infos, c.info())
+	}
+	sortConversationInfos(infos)
+	return infos, nil
+}
+
+// Delete removes the Conversation with the ID, or returns ErrConversationNotFound.
+func (s *MemoryConversationStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conversations[id]; !ok {
+		return ErrConversationNotFound
+	}
+	delete(s.conversations, id)
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// DiskConversationStore is a ConversationStore storing each Conversation as a
+// JSON file in a directory, so they persist across runs.
+type DiskConversationStore struct {
+	dir string
+}
+
+// NewDiskConversationStore returns a new DiskConversationStore in dir, creating it if needed.
+func NewDiskConversationStore(dir string) (*DiskConversationStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create conversation dir: %w", err)
+	}
+	return &DiskConversationStore{dir: dir}, nil
+}
+
+// Dir returns the DiskConversationStore's directory.
+func (s *DiskConversationStore) Dir() string {
+	return s.dir
+}
+
+// Save stores the Conversation, assigning its ID if empty and updating its timestamps.
+// The file is written atomically, so concurrent readers never see partial conversations.
+func (s *DiskConversationStore) Save(c *Conversation) error {
+	if c.ID != "" && !isValidConversationID(c.ID) {
+		return fmt.Errorf("invalid conversation ID %q", c.ID)
+	}
+	prepareSave(c)
+	bts, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, c.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(bts)
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(c.ID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Load returns the Conversation with the ID, or ErrConversationNotFound.
+func (s *DiskConversationStore) Load(id string) (*Conversation, error) {
+	if !isValidConversationID(id) {
+		return nil, ErrConversationNotFound
+	}
+	bts, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrConversationNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var c Conversation
+	if err := json.Unmarshal(bts, &c); err != nil {
+		return nil, fmt.Errorf("failed to read conversation %q: %w", id, err)
+	}
+	return &c, nil
+}
+
+// List returns the stored Conversations, most recently updated first.
+// Unreadable files are skipped.
+func (s *DiskConversationStore) List() ([]ConversationInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ConversationInfo, 0, len(paths))
+	for _, path := range paths {
+		c, err := s.Load(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			logger().Warn("skipping conversation", "path", path, "error", err)
+			continue
+		}
+		infos = append(infos, c.info())
+	}
+	sortConversationInfos(infos)
+	return infos, nil
+}
+
+// Delete removes the Conversation with the ID, or returns ErrConversationNotFound.
+func (s *DiskConversationStore) Delete(id string) error {
+	if !isValidConversationID(id) {
+		return ErrConversationNotFound
+	}
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrConversationNotFound
+	}
+	return err
+}
+
+// path returns the file path of the conversation with the id
+func (s *DiskConversationStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// isValidConversationID returns true if id is safe as a file name
+func isValidConversationID(id string) bool {
+	if id == "" || id == "." || id == ".." {
+		return false
+	}
+	return !strings.ContainsAny(id, `/\`+"\x00")
+}
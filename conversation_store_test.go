@@ -0,0 +1,85 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func TestConversationStores(t *testing.T) {
+	disk, err := ollamatea.NewDiskConversationStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]ollamatea.ConversationStore{
+		"memory": ollamatea.NewMemoryConversationStore(),
+		"disk":   disk,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			first := &ollamatea.Conversation{
+				Model:    "llama3.2",
+				Options:  map[string]interface{}{"temperature": 0.5},
+				Messages: []ollamatea.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}},
+			}
+			if err := store.Save(first); err != nil {
+				t.Fatal(err)
+			}
+			if first.ID == "" || first.CreatedAt.IsZero() {
+				t.Fatalf("expected Save to assign an ID and timestamps, got %#v", first)
+			}
+			second := &ollamatea.Conversation{Model: "qwen2.5", Title: "second"}
+			if err := store.Save(second); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := store.Load(first.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loaded.Model != "llama3.2" || len(loaded.Messages) != 2 || loaded.Messages[1].Content != "hello" ||
+				loaded.Options["temperature"] != 0.5 {
+				t.Errorf("unexpected loaded conversation %#v", loaded)
+			}
+
+			// updating first makes it the most recent
+			first.Title = "first"
+			if err := store.Save(first); err != nil {
+				t.Fatal(err)
+			}
+			infos, err := store.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(infos) != 2 || infos[0].ID != first.ID || infos[0].Title != "first" || infos[0].MessageCount != 2 {
+				t.Errorf("unexpected list %#v", infos)
+			}
+
+			if err := store.Delete(second.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Load(second.ID); !errors.Is(err, ollamatea.ErrConversationNotFound) {
+				t.Errorf("expected ErrConversationNotFound, got %v", err)
+			}
+			if err := store.Delete(second.ID); !errors.Is(err, ollamatea.ErrConversationNotFound) {
+				t.Errorf("expected ErrConversationNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConversationRestoreAgent(t *testing.T) {
+	agent := ollamatea.NewToolAgent(nil)
+	agent.Messages = []ollamatea.Message{{Role: "user", Content: "2+2?"}, {Role: "assistant", Content: "4"}}
+	conv := ollamatea.ConversationFromAgent(&agent)
+
+	restored := ollamatea.NewToolAgent(nil)
+	restored.Model = "other"
+	conv.RestoreAgent(&restored)
+	if restored.Model != agent.Model || len(restored.Messages) != 2 || restored.Messages[1].Content != "4" {
+		t.Errorf("unexpected restored agent model %q messages %v", restored.Model, restored.Messages)
+	}
+}
@@ -0,0 +1,151 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Embedding pipelines
+//
+// An EmbedManager embeds large corpora by splitting the inputs into batches,
+// one per request, and sending them from a pool of workers.  Tune Workers,
+// BatchSize, and MaxInflight to saturate a GPU without overwhelming the server.
+
+const (
+	DefaultEmbedWorkers   = 4  // DefaultEmbedWorkers is the default EmbedManager.Workers
+	DefaultEmbedBatchSize = 32 // DefaultEmbedBatchSize is the default EmbedManager.BatchSize
+)
+
+// EmbedManager embeds many inputs with parallel, batched requests.
+// It is safe for concurrent use; MaxInflight applies across all concurrent calls.
+type EmbedManager struct {
+	Host    string                 // Ollama Host -- really the service's URL
+	Model   string                 // Ollama embedding model
+	Options map[string]interface{} // Options lists model-specific options
+	Backend Backend                // Backend to embed with; if nil, SharedBackend(Host) is used
+
+	Workers     int // Workers is the number of concurrent workers per Embed call (default 4)
+	BatchSize   int // BatchSize is the number of inputs per request (default 32)
+	MaxInflight int // MaxInflight limits requests in flight across all Embed calls (0 is Workers)
+
+	inflightOnce sync.Once
+	inflight     chan struct{} // semaphore of MaxInflight
+}
+
+// NewEmbedManager returns a new EmbedManager for the host and model with the default tuning.
+func NewEmbedManager(host string, model string) *EmbedManager {
+	return &EmbedManager{
+		Host:      host,
+		Model:     model,
+		Workers:   DefaultEmbedWorkers,
+		BatchSize: DefaultEmbedBatchSize,
+	}
+}
+
+// Embed returns the embeddings of the inputs, in order.  If onProgress is
+// non-nil, it is called with the number of inputs embedded so far after each
+// batch; calls are serialized.  The first failed batch cancels the rest and
+// its error is returned.
+func (m *EmbedManager) Embed(ctx context.Context, inputs []string, onProgress func(done int, total int)) ([][]float32, error) {
+	backend, err := resolveBackend(m.Backend, m.Host)
+	if err != nil {
+		logger().Error("embed backend failed", "host", m.Host, "error", err)
+		return nil, err
+	}
+	workers, batchSize := max(m.Workers, 1), max(m.BatchSize, 1)
+	m.inflightOnce.Do(func() {
+		maxInflight := m.MaxInflight
+		if maxInflight <= 0 {
+			maxInflight = workers
+		}
+		m.inflight = make(chan struct{}, maxInflight)
+	})
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	batches := make(chan int) // start index of each batch
+	go func() {
+		defer close(batches)
+		for start := 0; start < len(inputs); start += batchSize {
+			select {
+			case batches <- start:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	embeddings := make([][]float32, len(inputs))
+	var mu sync.Mutex // guards done and onProgress
+	done := 0
+	var wg sync.WaitGroup
+	for range min(workers, (len(inputs)+batchSize-1)/batchSize) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range batches {
+				end := min(start+batchSize, len(inputs))
+				batch, err := m.embedBatch(ctx, backend, inputs[start:end])
+				if err != nil {
+					cancel(err)
+					return
+				}
+				copy(embeddings[start:end], batch)
+
+				mu.Lock()
+				done += end - start
+				if onProgress != nil {
+					onProgress(done, len(inputs))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// embedBatch embeds one batch within the MaxInflight limit
+func (m *EmbedManager) embedBatch(ctx context.Context, backend Backend, batch []string) ([][]float32, error) {
+	select {
+	case m.inflight <- struct{}{}:
+		defer func() { <-m.inflight }()
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+
+	ctx, span := startSpan(ctx, "ollamatea.embed",
+		Attr("ollamatea.host", m.Host),
+		Attr("ollamatea.model", m.Model),
+		Attr("ollamatea.batch_size", len(batch)))
+	defer span.End()
+	start := time.Now()
+	resp, err := backend.Embed(ctx, &EmbedRequest{Model: m.Model, Input: batch, Options: m.Options})
+	err = ClassifyError(err, m.Host, m.Model)
+	if err == nil && len(resp.Embeddings) != len(batch) {
+		err = fmt.Errorf("embed returned %d embeddings for %d inputs", len(resp.Embeddings), len(batch))
+	}
+	promptTokens := 0
+	if err != nil {
+		span.RecordError(err)
+		logger().Error("embed batch failed", "host", m.Host, "model", m.Model, "inputs", len(batch), "error", err)
+	} else {
+		promptTokens = resp.PromptEvalCount
+		logger().Debug("embed batch done", "host", m.Host, "model", m.Model, "inputs", len(batch), "latency", time.Since(start))
+	}
+	observeMetrics("embed", m.Host, m.Model, time.Since(start), promptTokens, 0, err)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Embeddings, nil
+}
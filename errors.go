@@ -0,0 +1,158 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	ollama "github.com/ollama/ollama/api"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Error taxonomy
+//
+// Failures from a Backend arrive as HTTP status errors, network errors, or
+// plain strings from a stream.  OllamaTea classifies them into an OllamaError
+// with an ErrorKind, so that UIs can show actionable guidance with Hint
+// rather than raw error text.  Use errors.As to retrieve it from any error
+// reported by OllamaTea components.
+
+// ErrorKind is the category of an OllamaError.
+type ErrorKind int
+
+const (
+	ErrorKindUnknown           ErrorKind = iota // ErrorKindUnknown is an unclassified failure
+	ErrorKindConnectionRefused                  // ErrorKindConnectionRefused is when the server could not be reached
+	ErrorKindModelNotFound                      // ErrorKindModelNotFound is when the model is not available on the server
+	ErrorKindUnauthorized                       // ErrorKindUnauthorized is when the server rejected the credentials
+	ErrorKindTimeout                            // ErrorKindTimeout is when the request or connection timed out
+	ErrorKindContextTooLong                     // ErrorKindContextTooLong is when the input exceeds the model's context
+	ErrorKindRateLimited                        // ErrorKindRateLimited is when the server rejected the request as too many
+	ErrorKindBadRequest                         // ErrorKindBadRequest is when the server rejected the request
+	ErrorKindServerError                        // ErrorKindServerError is when the server failed internally
+)
+
+// String returns the name of the ErrorKind.
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindConnectionRefused:
+		return "connection refused"
+	case ErrorKindModelNotFound:
+		return "model not found"
+	case ErrorKindUnauthorized:
+		return "unauthorized"
+	case ErrorKindTimeout:
+		return "timeout"
+	case ErrorKindContextTooLong:
+		return "context too long"
+	case ErrorKindRateLimited:
+		return "rate limited"
+	case ErrorKindBadRequest:
+		return "bad request"
+	case ErrorKindServerError:
+		return "server error"
+	}
+	return "unknown"
+}
+
+// OllamaError is a classified failure of a request to a Backend.
+// Its message is that of the underlying error, which it wraps.
+type OllamaError struct {
+	Kind       ErrorKind // Kind is the category of the failure
+	StatusCode int       // StatusCode is the HTTP status, if any
+	Host       string    // Host the request was made to
+	Model      string    // Model of the request, if any
+	Err        error     // Err is the underlying error
+}
+
+// Error returns the message of the underlying error.
+func (e *OllamaError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *OllamaError) Unwrap() error {
+	return e.Err
+}
+
+// Hint returns guidance for resolving the failure, or "" if there is none.
+func (e *OllamaError) Hint() string {
+	switch e.Kind {
+	case ErrorKindConnectionRefused:
+		return fmt.Sprintf("Is Ollama running at %s?  Start it with `ollama serve`.", e.Host)
+	case ErrorKindModelNotFound:
+		return fmt.Sprintf("Download the model with `ollama pull %s`.", e.Model)
+	case ErrorKindUnauthorized:
+		return "Check the API key or credentials for the server."
+	case ErrorKindTimeout:
+		return "The server took too long; it may be loading the model, so try again."
+	case ErrorKindContextTooLong:
+		return "Shorten the prompt or conversation, or raise the model's num_ctx option."
+	case ErrorKindRateLimited:
+		return "Too many requests; wait a moment, or use a RateLimiter."
+	}
+	return ""
+}
+
+// ErrorKindOf returns the ErrorKind of err, or ErrorKindUnknown if it is not an OllamaError.
+func ErrorKindOf(err error) ErrorKind {
+	var ollamaErr *OllamaError
+	if errors.As(err, &ollamaErr) {
+		return ollamaErr.Kind
+	}
+	return ErrorKindUnknown
+}
+
+// ClassifyError wraps err from a request to host for model in an OllamaError.
+// Returns err unchanged if it is nil, already an OllamaError, or a cancellation
+// (context.Canceled or ErrResponseLimit), which are not server failures.
+func ClassifyError(err error, host string, model string) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrResponseLimit) {
+		return err
+	}
+	var ollamaErr *OllamaError
+	if errors.As(err, &ollamaErr) {
+		return err
+	}
+
+	classified := &OllamaError{Kind: ErrorKindUnknown, Host: host, Model: model, Err: err}
+	var statusErr ollama.StatusError
+	if errors.As(err, &statusErr) {
+		classified.StatusCode = statusErr.StatusCode
+		switch {
+		case statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden:
+			classified.Kind = ErrorKindUnauthorized
+		case statusErr.StatusCode == http.StatusRequestTimeout || statusErr.StatusCode == http.StatusGatewayTimeout:
+			classified.Kind = ErrorKindTimeout
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			classified.Kind = ErrorKindRateLimited
+		case statusErr.StatusCode >= http.StatusInternalServerError:
+			classified.Kind = ErrorKindServerError
+		case statusErr.StatusCode >= http.StatusBadRequest:
+			classified.Kind = ErrorKindBadRequest
+		}
+	}
+
+	// the message is more specific than the status, and streams may only have a message
+	var netErr net.Error
+	msg := strings.ToLower(err.Error())
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(msg, "connection refused"):
+		classified.Kind = ErrorKindConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		classified.Kind = ErrorKindTimeout
+	case strings.Contains(msg, "not found") && (strings.Contains(msg, "model") || statusErr.StatusCode == http.StatusNotFound):
+		classified.Kind = ErrorKindModelNotFound
+	case strings.Contains(msg, "context length") || strings.Contains(msg, "context window") || strings.Contains(msg, "too many tokens"):
+		classified.Kind = ErrorKindContextTooLong
+	case strings.Contains(msg, "unauthorized") || strings.Contains(msg, "invalid api key"):
+		classified.Kind = ErrorKindUnauthorized
+	}
+	return classified
+}
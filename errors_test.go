@@ -0,0 +1,49 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	ollama "github.com/ollama/ollama/api"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind ollamatea.ErrorKind
+	}{
+		{ollama.StatusError{StatusCode: 404, ErrorMessage: `model "x" not found, try pulling it first`}, ollamatea.ErrorKindModelNotFound},
+		{errors.New(`model "x" not found, try pulling it first`), ollamatea.ErrorKindModelNotFound},
+		{ollama.StatusError{StatusCode: 401, Status: "401 Unauthorized"}, ollamatea.ErrorKindUnauthorized},
+		{ollama.StatusError{StatusCode: 429}, ollamatea.ErrorKindRateLimited},
+		{ollama.StatusError{StatusCode: 500, ErrorMessage: "boom"}, ollamatea.ErrorKindServerError},
+		{errors.New("input length exceeds maximum context length"), ollamatea.ErrorKindContextTooLong},
+		{fmt.Errorf("post: %w", context.DeadlineExceeded), ollamatea.ErrorKindTimeout},
+		{errors.New("dial tcp 127.0.0.1:1: connect: connection refused"), ollamatea.ErrorKindConnectionRefused},
+		{errors.New("something else"), ollamatea.ErrorKindUnknown},
+	}
+	for _, test := range tests {
+		err := ollamatea.ClassifyError(test.err, "http://localhost:11434", "x")
+		if kind := ollamatea.ErrorKindOf(err); kind != test.kind {
+			t.Errorf("%v: expected %v, got %v", test.err, test.kind, kind)
+		}
+		if err.Error() != test.err.Error() || !errors.Is(err, test.err) {
+			t.Errorf("%v: classification should wrap the error", test.err)
+		}
+	}
+
+	var ollamaErr *ollamatea.OllamaError
+	err := ollamatea.ClassifyError(errors.New(`model "x" not found`), "", "x")
+	if !errors.As(err, &ollamaErr) || !strings.Contains(ollamaErr.Hint(), "ollama pull x") {
+		t.Errorf("unexpected hint %q", ollamaErr.Hint())
+	}
+	if err := ollamatea.ClassifyError(context.Canceled, "", ""); err != context.Canceled {
+		t.Errorf("cancellations should not be classified, got %T", err)
+	}
+}
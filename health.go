@@ -0,0 +1,234 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Health checks
+//
+// A HealthMonitor pings an Ollama server every Interval, delivering an
+// OllamaUpMsg or OllamaDownMsg with the latency of each check, so a TUI can
+// show a connection indicator and disable sending while the server is
+// unreachable.  Pass all messages to its Update, which schedules the checks.
+
+const (
+	// DefaultHealthInterval is the Interval of a new HealthMonitor.
+	DefaultHealthInterval = 10 * time.Second
+	// DefaultHealthTimeout is the Timeout of a new HealthMonitor.
+	DefaultHealthTimeout = 5 * time.Second
+)
+
+// OllamaUpMsg is sent when a HealthMonitor's check reaches the server.
+type OllamaUpMsg struct {
+	ID        int64         // ID is the HealthMonitor ID
+	Host      string        // Host is the Ollama Host checked
+	CheckedAt time.Time     // CheckedAt is when the check completed
+	Latency   time.Duration // Latency is the round-trip time of the check
+	Changed   bool          // Changed is true if the server was down, or not yet checked
+}
+
+// OllamaDownMsg is sent when a HealthMonitor's check fails.
+type OllamaDownMsg struct {
+	ID        int64         // ID is the HealthMonitor ID
+	Host      string        // Host is the Ollama Host checked
+	CheckedAt time.Time     // CheckedAt is when the check completed
+	Latency   time.Duration // Latency is how long the check took to fail
+	Changed   bool          // Changed is true if the server was up, or not yet checked
+	Error     error         // Error is why the check failed; see [OllamaError]
+}
+
+// healthTickMsg is the private message prompting a HealthMonitor's next check
+type healthTickMsg struct {
+	ID  int64 // ID is the HealthMonitor ID
+	Seq int   // Seq is the run of checks it was scheduled for
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// HealthMonitor periodically checks that an Ollama server is reachable.
+// It asks a Backend which is a [Versioner] for its version, which is cheap,
+// and otherwise lists its models.  Like Session, its methods take pointer receivers.
+type HealthMonitor struct {
+	Host     string        // Ollama Host to check
+	Backend  Backend       // Backend to check; if nil, SharedBackend(Host) is used
+	Interval time.Duration // Interval between checks; if not positive, DefaultHealthInterval is used
+	Timeout  time.Duration // Timeout of each check; if not positive, DefaultHealthTimeout is used
+
+	// Private
+	id        int64
+	seq       int  // counts scheduling changes, so stale ticks are ignored
+	running   bool // checks are scheduled
+	inFlight  bool // a check is in flight; its result schedules the next
+	checked   bool // a check has completed
+	up        bool
+	latency   time.Duration
+	lastError error
+}
+
+var _ tea.Model = (*HealthMonitor)(nil)
+
+// NewHealthMonitor returns a new HealthMonitor of the Ollama host with the default values.
+func NewHealthMonitor(ollamaHost string) HealthMonitor {
+	return HealthMonitor{
+		Host:     ollamaHost,
+		Interval: DefaultHealthInterval,
+		Timeout:  DefaultHealthTimeout,
+		id:       nextSessionID(),
+	}
+}
+
+// ID returns the unique ID of the HealthMonitor
+func (h *HealthMonitor) ID() int64 {
+	return h.id
+}
+
+// Checked returns true once a check has completed
+func (h *HealthMonitor) Checked() bool {
+	return h.checked
+}
+
+// IsUp returns true if the last check reached the server
+func (h *HealthMonitor) IsUp() bool {
+	return h.up
+}
+
+// IsDown returns true if the last check failed.
+// Before the first check completes, the server is neither up nor down.
+func (h *HealthMonitor) IsDown() bool {
+	return h.checked && !h.up
+}
+
+// Latency returns the duration of the last check
+func (h *HealthMonitor) Latency() time.Duration {
+	return h.latency
+}
+
+// Error returns the error of the last check, if it failed
+func (h *HealthMonitor) Error() error {
+	return h.lastError
+}
+
+// Check returns a command which checks the server now, without waiting for the Interval.
+// Returns nil if a check is already in flight.
+func (h *HealthMonitor) Check() tea.Cmd {
+	h.seq++ // the check's result schedules the next
+	return h.checkCmd()
+}
+
+// Stop stops scheduling checks; Init starts them again.
+func (h *HealthMonitor) Stop() {
+	h.seq++
+	h.running = false
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea interface
+
+// Init starts the checks, returning the command for the first
+func (h *HealthMonitor) Init() tea.Cmd {
+	h.running = true
+	return h.Check()
+}
+
+// Update records the result of each check and schedules the next.
+func (h *HealthMonitor) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case healthTickMsg:
+		if msg.ID != h.id || msg.Seq != h.seq || !h.running {
+			return h, nil
+		}
+		return h, h.checkCmd()
+
+	case OllamaUpMsg:
+		if msg.ID != h.id {
+			return h, nil
+		}
+		h.inFlight = false
+		h.checked, h.up, h.latency, h.lastError = true, true, msg.Latency, nil
+		return h, h.tickCmd()
+
+	case OllamaDownMsg:
+		if msg.ID != h.id {
+			return h, nil
+		}
+		h.inFlight = false
+		h.checked, h.up, h.latency, h.lastError = true, false, msg.Latency, msg.Error
+		return h, h.tickCmd()
+	}
+	return h, nil
+}
+
+// View renders a connection indicator, such as "● up 12ms"
+func (h *HealthMonitor) View() string {
+	switch {
+	case !h.checked:
+		return "○ checking"
+	case h.up:
+		return fmt.Sprintf("● up %s", h.latency.Round(time.Millisecond))
+	default:
+		return "○ down"
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// tickCmd returns a command delivering a healthTickMsg after the Interval, if running
+func (h *HealthMonitor) tickCmd() tea.Cmd {
+	if !h.running {
+		return nil
+	}
+	interval := h.Interval
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	id, seq := h.id, h.seq
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return healthTickMsg{ID: id, Seq: seq}
+	})
+}
+
+// checkCmd returns a command which checks the server, or nil if a check is in flight
+func (h *HealthMonitor) checkCmd() tea.Cmd {
+	if h.inFlight {
+		return nil
+	}
+	h.inFlight = true
+	id, host, backend, timeout, wasChecked, wasUp := h.id, h.Host, h.Backend, h.Timeout, h.checked, h.up
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	return func() tea.Msg {
+		start := time.Now()
+		err := pingBackend(backend, host, timeout)
+		latency := time.Since(start)
+		if err != nil {
+			logger().Debug("health check failed", "health_id", id, "host", host, "latency", latency, "error", err)
+			return OllamaDownMsg{ID: id, Host: host, CheckedAt: time.Now(), Latency: latency, Changed: !wasChecked || wasUp, Error: err}
+		}
+		logger().Debug("health check done", "health_id", id, "host", host, "latency", latency)
+		return OllamaUpMsg{ID: id, Host: host, CheckedAt: time.Now(), Latency: latency, Changed: !wasChecked || !wasUp}
+	}
+}
+
+// pingBackend makes a cheap request of the Backend for the host, within the timeout
+func pingBackend(backend Backend, host string, timeout time.Duration) error {
+	backend, err := resolveBackend(backend, host)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if versioner, ok := backend.(Versioner); ok {
+		_, err = versioner.Version(ctx)
+	} else {
+		_, err = backend.List(ctx)
+	}
+	return ClassifyError(err, host, "")
+}
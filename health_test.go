@@ -0,0 +1,50 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestHealthMonitor(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	monitor := ollamatea.NewHealthMonitor(srv.URL)
+	monitor.Interval = 20 * time.Millisecond
+	monitor.Timeout = time.Second
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := monitor.Update(msg)
+		return cmd
+	}
+
+	var ups int
+	match := func(msg tea.Msg) bool {
+		if up, ok := msg.(ollamatea.OllamaUpMsg); ok {
+			if up.ID != monitor.ID() || up.Host != srv.URL || up.Latency <= 0 || up.Changed != (ups == 0) {
+				t.Errorf("unexpected %+v", up)
+			}
+			ups++
+		}
+		return ups == 2 // the second is scheduled after the Interval
+	}
+	update(ollamateatest.Run(t, update, monitor.Init(), match, 0)) // the matched message is not passed to update
+	if !monitor.IsUp() || monitor.IsDown() || monitor.View() == "○ checking" {
+		t.Errorf("expected the server up, got %q", monitor.View())
+	}
+
+	srv.Close()
+	msg := ollamateatest.Run(t, update, monitor.Check(), ollamateatest.MatchType[ollamatea.OllamaDownMsg](), 0)
+	down := msg.(ollamatea.OllamaDownMsg)
+	if !down.Changed || down.Error == nil {
+		t.Errorf("unexpected %+v", down)
+	}
+	update(msg)
+	monitor.Stop()
+	if !monitor.IsDown() || monitor.Error() == nil || monitor.View() != "○ down" {
+		t.Errorf("expected the server down, got %q", monitor.View())
+	}
+}
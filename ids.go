@@ -0,0 +1,56 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import "sync/atomic"
+
+//////////////////////////////////////////////////////////////////////////////
+// ID allocation
+//
+// Sessions, EmbedSessions, ToolAgents, and ModelChoosers are given unique IDs
+// from global counters, so that messages are received only by the components
+// that sent them.  Tests and replay tooling may need stable IDs instead:
+// either set a component's ID explicitly with SetID, or install an IDAllocator.
+
+// IDAllocator allocates the IDs of new components.
+// Implementations must be safe for concurrent use.
+type IDAllocator interface {
+	NextID() int64
+}
+
+type idAllocatorHolder struct {
+	allocator IDAllocator
+}
+
+var globalIDAllocator atomic.Value // idAllocatorHolder
+
+// SetIDAllocator sets the IDAllocator used for all new components.
+// Pass nil to restore the default global counters.
+func SetIDAllocator(allocator IDAllocator) {
+	globalIDAllocator.Store(idAllocatorHolder{allocator: allocator})
+}
+
+// allocateID returns the next ID from the global IDAllocator, or from counter if there is none.
+func allocateID(counter *int64) int64 {
+	if holder, ok := globalIDAllocator.Load().(idAllocatorHolder); ok && holder.allocator != nil {
+		return holder.allocator.NextID()
+	}
+	return atomic.AddInt64(counter, 1)
+}
+
+// SequentialIDs is an IDAllocator which counts up from a starting ID.
+type SequentialIDs struct {
+	next atomic.Int64
+}
+
+// NewSequentialIDs returns a SequentialIDs whose first ID is start.
+func NewSequentialIDs(start int64) *SequentialIDs {
+	s := &SequentialIDs{}
+	s.next.Store(start)
+	return s
+}
+
+// NextID returns the next ID in sequence.
+func (s *SequentialIDs) NextID() int64 {
+	return s.next.Add(1) - 1
+}
@@ -0,0 +1,28 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func TestIDAllocator(t *testing.T) {
+	ollamatea.SetIDAllocator(ollamatea.NewSequentialIDs(100))
+	defer ollamatea.SetIDAllocator(nil)
+
+	session := ollamatea.NewSession()
+	embed := ollamatea.NewEmbedSession()
+	chooser := ollamatea.NewModelChooser("")
+	if session.ID() != 100 || embed.ID() != 101 || chooser.ID() != 102 {
+		t.Errorf("unexpected IDs %d, %d, %d", session.ID(), embed.ID(), chooser.ID())
+	}
+
+	session.SetID(7)
+	chooser.SetID(8)
+	embed = ollamatea.NewEmbedSession(ollamatea.WithID(9))
+	if session.ID() != 7 || chooser.ID() != 8 || embed.ID() != 9 {
+		t.Errorf("unexpected IDs %d, %d, %d", session.ID(), chooser.ID(), embed.ID())
+	}
+}
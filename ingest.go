@@ -0,0 +1,158 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// File ingestion
+//
+// EmbedFile and EmbedDir embed the text of files in chunks, returning
+// VectorRecords whose Metadata names their source file and offsets, ready for
+// a VectorStore or export:
+//
+//	records, err := s.EmbedDir(ctx, "docs", ollamatea.WalkOptions{Include: []string{"*.md"}})
+//	store.Add(records...)
+
+// MetadataSource is the Metadata key of the file a record was embedded from
+const MetadataSource = "source"
+
+// WalkOptions select the files of EmbedDir.  Patterns are path.Match globs,
+// matched against each file's base name and its slash-separated path
+// relative to the root; for example, "*.md" or "notes/*.txt".
+type WalkOptions struct {
+	Include []string // Include, if set, embeds only files matching one of its patterns
+	Exclude []string // Exclude skips files and directories matching one of its patterns
+
+	// OnFile, if set, is called after each file is embedded, with its record count,
+	// or with the error failing the walk.
+	OnFile func(path string, records int, err error)
+}
+
+// EmbedFile embeds the text of the file at name in chunks, returning their
+// records, tagged with MetadataSource and the chunk offsets.  Files are chunked
+// by the EmbedSession's Chunker, or by tokens of DefaultChunkSize if it has
+// none.  A blank file has no records, and one which is not UTF-8 text is an
+// error.  It is blocking, like Embed, and sets the EmbedSession's Input.
+func (s *EmbedSession) EmbedFile(ctx context.Context, name string) ([]VectorRecord, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if !isText(data) {
+		return nil, fmt.Errorf("%s is not UTF-8 text", name)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return nil, nil
+	}
+
+	if s.Chunker == nil {
+		s.Chunker = &Chunker{Mode: ChunkByTokens, Model: s.Model}
+		defer func() { s.Chunker = nil }()
+	}
+	s.Input = string(data)
+	if _, err := s.Embed(ctx); err != nil {
+		return nil, fmt.Errorf("failed to embed %s: %w", name, err)
+	}
+	return s.Records(map[string]string{MetadataSource: name})
+}
+
+// EmbedDir walks the directory tree at root, embedding each text file selected
+// by the opts with EmbedFile, in lexical order.  Files which are not UTF-8
+// text are skipped.  Returns the records of all the files, or those embedded
+// before an error.
+func (s *EmbedSession) EmbedDir(ctx context.Context, root string, opts WalkOptions) ([]VectorRecord, error) {
+	var records []VectorRecord
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && matchAnyGlob(opts.Exclude, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || matchAnyGlob(opts.Exclude, rel) ||
+			(len(opts.Include) > 0 && !matchAnyGlob(opts.Include, rel)) {
+			return nil
+		}
+		if text, err := isTextFile(name); err != nil || !text {
+			return err
+		}
+
+		fileRecords, err := s.EmbedFile(ctx, name)
+		if opts.OnFile != nil {
+			opts.OnFile(name, len(fileRecords), err)
+		}
+		records = append(records, fileRecords...)
+		return err
+	})
+	return records, err
+}
+
+// matchAnyGlob returns true if one of the patterns matches the slash-separated
+// path rel, or its base name.  Malformed patterns match nothing.
+func matchAnyGlob(patterns []string, rel string) bool {
+	base := path.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isTextFile returns true if the start of the file at name looks like UTF-8 text
+func isTextFile(name string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	buf := make([]byte, 8192)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return isText(trimPartialRune(buf[:n])), nil
+}
+
+// isText returns true if data is UTF-8 without NUL bytes, as binary files have
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !strings.ContainsRune(string(data), 0)
+}
+
+// trimPartialRune returns data without an incomplete UTF-8 sequence at its end
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
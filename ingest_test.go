@@ -0,0 +1,69 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestEmbedDir(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"a.md":      "The sky is blue. Grass is green.",
+		"b.txt":     "Not included.",
+		"blank.md":  " \n",
+		"bin.md":    "binary\x00data",
+		"sub/c.md":  "Snow is white.",
+		"skip/d.md": "Excluded.",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithChunker(ollamatea.NewChunker(ollamatea.ChunkBySentences, 1, 0)))
+	var files []string
+	records, err := s.EmbedDir(context.Background(), root, ollamatea.WalkOptions{
+		Include: []string{"*.md"},
+		Exclude: []string{"skip"},
+		OnFile: func(path string, records int, err error) {
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, filepath.ToSlash(rel))
+		},
+	})
+	if err != nil {
+		t.Fatalf("EmbedDir failed: %v", err)
+	}
+	if !slices.Equal(files, []string{"a.md", "blank.md", "sub/c.md"}) {
+		t.Errorf("unexpected files %v", files)
+	}
+	var texts []string
+	for _, record := range records {
+		texts = append(texts, record.Text)
+	}
+	if !slices.Equal(texts, []string{"The sky is blue.", "Grass is green.", "Snow is white."}) {
+		t.Fatalf("unexpected records %v", texts)
+	}
+	if md := records[1].Metadata; md[ollamatea.MetadataSource] != filepath.Join(root, "a.md") ||
+		md[ollamatea.MetadataChunkStart] != "17" || md[ollamatea.MetadataChunkEnd] != "32" {
+		t.Errorf("unexpected metadata %v", md)
+	}
+
+	if _, err := s.EmbedFile(context.Background(), filepath.Join(root, "bin.md")); err == nil {
+		t.Error("expected an error embedding a binary file")
+	}
+}
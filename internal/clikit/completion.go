@@ -0,0 +1,362 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+// Package clikit holds helpers shared by the OllamaTea commands.
+package clikit
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/spf13/pflag"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Shell completion
+//
+// pflag has no completion support of its own, so Completion generates bash,
+// zsh, and fish scripts from a command's FlagSet.  Model flags complete the
+// models installed on the host, which the scripts fetch by re-invoking the
+// command with the hidden --complete-models flag.
+
+const (
+	completionFlag     = "completion"
+	completeModelsFlag = "complete-models"
+)
+
+// Shells are the shells supported by --completion.
+var Shells = []string{"bash", "zsh", "fish"}
+
+// Completion adds the --completion flag to a FlagSet and generates its scripts.
+type Completion struct {
+	prog       string
+	flags      *pflag.FlagSet
+	shell      string
+	listModels bool
+	modelFlags map[string]bool
+	fileFlags  map[string]bool
+	values     map[string][]string
+}
+
+// NewCompletion registers the --completion and hidden --complete-models flags
+// on flags, for the command named prog.  Call it before parsing flags.
+// The shared --model and --config flags of [Flags] are completed by default.
+func NewCompletion(flags *pflag.FlagSet, prog string) *Completion {
+	c := &Completion{
+		prog:       prog,
+		flags:      flags,
+		modelFlags: make(map[string]bool),
+		fileFlags:  make(map[string]bool),
+		values:     make(map[string][]string),
+	}
+	flags.StringVarP(&c.shell, completionFlag, "", "", "Print a shell completion script: bash, zsh, or fish")
+	flags.BoolVarP(&c.listModels, completeModelsFlag, "", false, "List the host's model names, for completion scripts")
+	flags.MarkHidden(completeModelsFlag)
+	c.values[completionFlag] = Shells
+	c.modelFlags["model"] = true
+	c.fileFlags["config"] = true
+	return c
+}
+
+// ModelFlags marks flags as taking a model name, completed from the host.
+func (c *Completion) ModelFlags(names ...string) *Completion {
+	for _, name := range names {
+		c.modelFlags[name] = true
+	}
+	return c
+}
+
+// FileFlags marks flags as taking a filename.
+func (c *Completion) FileFlags(names ...string) *Completion {
+	for _, name := range names {
+		c.fileFlags[name] = true
+	}
+	return c
+}
+
+// ValuesFlag marks a flag as taking one of a fixed set of values.
+func (c *Completion) ValuesFlag(name string, values ...string) *Completion {
+	c.values[name] = values
+	return c
+}
+
+// Handle serves --completion and --complete-models, writing to w.
+// It returns true if either was given, in which case the command should exit.
+// The models of ollamaHost are listed for --complete-models.
+func (c *Completion) Handle(w io.Writer, ollamaHost string) (bool, error) {
+	if c.listModels {
+		return true, WriteModelNames(w, ollamaHost)
+	}
+	if c.shell != "" {
+		return true, c.WriteScript(w, c.shell)
+	}
+	return false, nil
+}
+
+// WriteModelNames writes the names of the models on ollamaHost, one per line.
+func WriteModelNames(w io.Writer, ollamaHost string) error {
+	switch msg := ollamatea.FetchModelList(ollamaHost, ollamatea.GetNextModelChooserID()).(type) {
+	case ollamatea.FetchModelListErrorMsg:
+		return msg.Error
+	case ollamatea.FetchModelListResponseMsg:
+		names := make([]string, 0, len(msg.Models))
+		for _, model := range msg.Models {
+			names = append(names, model.Name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := fmt.Fprintln(w, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteScript writes the completion script for shell to w.
+func (c *Completion) WriteScript(w io.Writer, shell string) error {
+	var script string
+	switch strings.ToLower(shell) {
+	case "bash":
+		script = c.bashScript()
+	case "zsh":
+		script = c.zshScript()
+	case "fish":
+		script = c.fishScript()
+	default:
+		return fmt.Errorf("unknown shell %q for --%s, expected one of: %s",
+			shell, completionFlag, strings.Join(Shells, ", "))
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// visibleFlags returns the flags which are not hidden, sorted by name
+func (c *Completion) visibleFlags() []*pflag.Flag {
+	var flags []*pflag.Flag
+	c.flags.VisitAll(func(f *pflag.Flag) {
+		if !f.Hidden {
+			flags = append(flags, f)
+		}
+	})
+	return flags
+}
+
+// takesValue returns true if the flag needs an argument
+func takesValue(f *pflag.Flag) bool {
+	return f.NoOptDefVal == ""
+}
+
+// isRepeatable returns true if the flag may be given more than once
+func isRepeatable(f *pflag.Flag) bool {
+	return strings.HasSuffix(f.Value.Type(), "Array") || strings.HasSuffix(f.Value.Type(), "Slice")
+}
+
+// hasModelFlags returns true if the command has a flag taking a model name
+func (c *Completion) hasModelFlags() bool {
+	for name := range c.modelFlags {
+		if c.flags.Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasHostFlag returns true if the command has a --host flag to pass along
+func (c *Completion) hasHostFlag() bool {
+	return c.flags.Lookup("host") != nil
+}
+
+// funcName is the prog as a shell function name
+func (c *Completion) funcName() string {
+	return "_" + strings.NewReplacer("-", "_", ".", "_").Replace(c.prog)
+}
+
+// flagSpellings returns "-s --long" spellings of a flag, for case patterns
+func flagSpellings(f *pflag.Flag, sep string) string {
+	if f.Shorthand != "" {
+		return "-" + f.Shorthand + sep + "--" + f.Name
+	}
+	return "--" + f.Name
+}
+
+// hostSpellings are the spellings of --host, for scanning a command line
+func (c *Completion) hostSpellings(sep string) string {
+	return flagSpellings(c.flags.Lookup("host"), sep)
+}
+
+func (c *Completion) bashScript() string {
+	fn := c.funcName()
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", c.prog)
+	fmt.Fprintf(&b, "# Load with: source <(%s --completion bash)\n\n", c.prog)
+	if c.hasModelFlags() {
+		fmt.Fprintf(&b, "%s_models() {\n", fn)
+		fmt.Fprintf(&b, "    local host=() i\n")
+		if c.hasHostFlag() {
+			fmt.Fprintf(&b, "    for ((i=1; i<COMP_CWORD-1; i++)); do\n")
+			fmt.Fprintf(&b, "        case \"${COMP_WORDS[i]}\" in %s) host=(--host \"${COMP_WORDS[i+1]}\");; esac\n", c.hostSpellings("|"))
+			fmt.Fprintf(&b, "    done\n")
+		}
+		fmt.Fprintf(&b, "    %s --%s \"${host[@]}\" 2>/dev/null\n", c.prog, completeModelsFlag)
+		fmt.Fprintf(&b, "}\n\n")
+	}
+
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	fmt.Fprintf(&b, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(&b, "    case \"$prev\" in\n")
+	var words []string
+	var plain []string
+	for _, f := range c.visibleFlags() {
+		words = append(words, "--"+f.Name)
+		if f.Shorthand != "" {
+			words = append(words, "-"+f.Shorthand)
+		}
+		if !takesValue(f) {
+			continue
+		}
+		switch {
+		case c.modelFlags[f.Name]:
+			fmt.Fprintf(&b, "        %s)\n", flagSpellings(f, "|"))
+			fmt.Fprintf(&b, "            COMPREPLY=($(compgen -W \"$(%s_models)\" -- \"$cur\"))\n", fn)
+			fmt.Fprintf(&b, "            if declare -F __ltrim_colon_completions >/dev/null; then __ltrim_colon_completions \"$cur\"; fi\n")
+			fmt.Fprintf(&b, "            return;;\n")
+		case c.fileFlags[f.Name]:
+			fmt.Fprintf(&b, "        %s)\n", flagSpellings(f, "|"))
+			fmt.Fprintf(&b, "            COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+			fmt.Fprintf(&b, "            return;;\n")
+		case len(c.values[f.Name]) != 0:
+			fmt.Fprintf(&b, "        %s)\n", flagSpellings(f, "|"))
+			fmt.Fprintf(&b, "            COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(c.values[f.Name], " "))
+			fmt.Fprintf(&b, "            return;;\n")
+		default:
+			plain = append(plain, flagSpellings(f, "|"))
+		}
+	}
+	if len(plain) != 0 {
+		fmt.Fprintf(&b, "        %s)\n", strings.Join(plain, "|"))
+		fmt.Fprintf(&b, "            return;;\n")
+	}
+	fmt.Fprintf(&b, "    esac\n")
+	fmt.Fprintf(&b, "    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(words, " "))
+	fmt.Fprintf(&b, "    fi\n")
+	fmt.Fprintf(&b, "}\n\n")
+	fmt.Fprintf(&b, "complete -o default -F %s %s\n", fn, c.prog)
+	return b.String()
+}
+
+// zshQuote escapes a flag description for an _arguments spec
+func zshQuote(s string) string {
+	return strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
+
+func (c *Completion) zshScript() string {
+	fn := c.funcName()
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n", c.prog)
+	fmt.Fprintf(&b, "# zsh completion for %s\n", c.prog)
+	fmt.Fprintf(&b, "# Load with: source <(%s --completion zsh)\n\n", c.prog)
+	if c.hasModelFlags() {
+		fmt.Fprintf(&b, "%s_models() {\n", fn)
+		fmt.Fprintf(&b, "    local -a host models\n")
+		if c.hasHostFlag() {
+			fmt.Fprintf(&b, "    local i\n")
+			fmt.Fprintf(&b, "    for ((i=2; i<CURRENT-1; i++)); do\n")
+			fmt.Fprintf(&b, "        case $words[i] in %s) host=(--host $words[i+1]);; esac\n", c.hostSpellings("|"))
+			fmt.Fprintf(&b, "    done\n")
+		}
+		fmt.Fprintf(&b, "    models=(${(f)\"$(%s --%s $host 2>/dev/null)\"})\n", c.prog, completeModelsFlag)
+		fmt.Fprintf(&b, "    compadd -a models\n")
+		fmt.Fprintf(&b, "}\n\n")
+	}
+
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	fmt.Fprintf(&b, "    _arguments -s \\\n")
+	for _, f := range c.visibleFlags() {
+		var action string
+		if takesValue(f) {
+			switch {
+			case c.modelFlags[f.Name]:
+				action = ":model:" + fn + "_models"
+			case c.fileFlags[f.Name]:
+				action = ":file:_files"
+			case len(c.values[f.Name]) != 0:
+				action = ":" + f.Name + ":(" + strings.Join(c.values[f.Name], " ") + ")"
+			default:
+				action = ":" + f.Name + ": "
+			}
+		}
+		desc := "[" + zshQuote(f.Usage) + "]" + action
+		switch {
+		case f.Shorthand == "" && isRepeatable(f):
+			fmt.Fprintf(&b, "        '*--%s%s' \\\n", f.Name, desc)
+		case f.Shorthand == "":
+			fmt.Fprintf(&b, "        '--%s%s' \\\n", f.Name, desc)
+		case isRepeatable(f):
+			fmt.Fprintf(&b, "        '*'{-%s,--%s}'%s' \\\n", f.Shorthand, f.Name, desc)
+		default:
+			fmt.Fprintf(&b, "        '(-%s --%s)'{-%s,--%s}'%s' \\\n", f.Shorthand, f.Name, f.Shorthand, f.Name, desc)
+		}
+	}
+	fmt.Fprintf(&b, "        '*:argument:_default'\n")
+	fmt.Fprintf(&b, "}\n\n")
+	fmt.Fprintf(&b, "if [ \"$funcstack[1]\" = \"%s\" ]; then\n", fn)
+	fmt.Fprintf(&b, "    %s \"$@\"\n", fn)
+	fmt.Fprintf(&b, "else\n")
+	fmt.Fprintf(&b, "    compdef %s %s\n", fn, c.prog)
+	fmt.Fprintf(&b, "fi\n")
+	return b.String()
+}
+
+// fishQuote single-quotes a string for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func (c *Completion) fishScript() string {
+	fn := c.funcName()
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", c.prog)
+	fmt.Fprintf(&b, "# Load with: %s --completion fish | source\n\n", c.prog)
+	if c.hasModelFlags() {
+		fmt.Fprintf(&b, "function %s_models\n", fn)
+		fmt.Fprintf(&b, "    set -l host\n")
+		if c.hasHostFlag() {
+			fmt.Fprintf(&b, "    set -l tokens (commandline -opc)\n")
+			fmt.Fprintf(&b, "    for i in (seq 2 (math (count $tokens) - 1))\n")
+			fmt.Fprintf(&b, "        if contains -- $tokens[$i] %s\n", c.hostSpellings(" "))
+			fmt.Fprintf(&b, "            set host --host $tokens[(math $i + 1)]\n")
+			fmt.Fprintf(&b, "        end\n")
+			fmt.Fprintf(&b, "    end\n")
+		}
+		fmt.Fprintf(&b, "    %s --%s $host 2>/dev/null\n", c.prog, completeModelsFlag)
+		fmt.Fprintf(&b, "end\n\n")
+	}
+
+	for _, f := range c.visibleFlags() {
+		fmt.Fprintf(&b, "complete -c %s", c.prog)
+		if f.Shorthand != "" {
+			fmt.Fprintf(&b, " -s %s", f.Shorthand)
+		}
+		fmt.Fprintf(&b, " -l %s", f.Name)
+		if takesValue(f) {
+			switch {
+			case c.modelFlags[f.Name]:
+				fmt.Fprintf(&b, " -x -a '(%s_models)'", fn)
+			case c.fileFlags[f.Name]:
+				fmt.Fprintf(&b, " -r -F")
+			case len(c.values[f.Name]) != 0:
+				fmt.Fprintf(&b, " -x -a %s", fishQuote(strings.Join(c.values[f.Name], " ")))
+			default:
+				fmt.Fprintf(&b, " -x")
+			}
+		}
+		fmt.Fprintf(&b, " -d %s\n", fishQuote(f.Usage))
+	}
+	return b.String()
+}
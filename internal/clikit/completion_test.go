@@ -0,0 +1,108 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package clikit_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea/internal/clikit"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	"github.com/spf13/pflag"
+)
+
+func newTestFlags() (*pflag.FlagSet, *clikit.Completion) {
+	flags := pflag.NewFlagSet("ot-test", pflag.ContinueOnError)
+	flags.StringP("host", "h", "", "Host for Ollama")
+	flags.StringP("model", "m", "", "Model for Ollama")
+	flags.StringP("in", "i", "", "Input filename ('-' is stdin)")
+	flags.String("theme", "dark", "Color theme: dark or light")
+	flags.StringArrayP("option", "o", nil, "Model option as key=value [repeatable]")
+	flags.BoolP("verbose", "v", false, "verbose output")
+	c := clikit.NewCompletion(flags, "ot-test").
+		ModelFlags("model").
+		FileFlags("in").
+		ValuesFlag("theme", "dark", "light")
+	return flags, c
+}
+
+func TestCompletionScripts(t *testing.T) {
+	_, c := newTestFlags()
+	for _, shell := range clikit.Shells {
+		var buf bytes.Buffer
+		if err := c.WriteScript(&buf, shell); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		script := buf.String()
+		for _, want := range []string{"ot-test", "--complete-models", "verbose", "theme", "dark light", "--completion"} {
+			if !strings.Contains(script, want) {
+				t.Errorf("%s script missing %q:\n%s", shell, want, script)
+			}
+		}
+		if strings.Contains(script, "List the host's model names") {
+			t.Errorf("%s script includes the hidden flag", shell)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteScript(&buf, "tcsh"); err == nil {
+		t.Errorf("expected an error for an unknown shell")
+	}
+}
+
+func TestCompletionZshQuoting(t *testing.T) {
+	_, c := newTestFlags()
+	var buf bytes.Buffer
+	if err := c.WriteScript(&buf, "zsh"); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+	if !strings.Contains(script, `key=value \[repeatable\]`) {
+		t.Errorf("expected brackets escaped:\n%s", script)
+	}
+	if !strings.Contains(script, `('\''-'\'' is stdin)`) {
+		t.Errorf("expected quotes escaped:\n%s", script)
+	}
+	if !strings.Contains(script, `'*'{-o,--option}`) {
+		t.Errorf("expected --option to be repeatable:\n%s", script)
+	}
+}
+
+func TestCompletionHandle(t *testing.T) {
+	server := ollamateatest.NewServer()
+	defer server.Close()
+	server.AddModel("zeta:latest", 1)
+	server.AddModel("alpha:7b", 2)
+
+	flags, c := newTestFlags()
+	if err := flags.Parse([]string{"-v"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if handled, err := c.Handle(&buf, server.URL); handled || err != nil {
+		t.Fatalf("expected nothing handled, got %v %v", handled, err)
+	}
+
+	flags, c = newTestFlags()
+	if err := flags.Parse([]string{"--complete-models"}); err != nil {
+		t.Fatal(err)
+	}
+	handled, err := c.Handle(&buf, server.URL)
+	if !handled || err != nil {
+		t.Fatalf("expected models handled, got %v %v", handled, err)
+	}
+	if buf.String() != "alpha:7b\nzeta:latest\n" {
+		t.Errorf("unexpected model names %q", buf.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := clikit.WriteJSON(&buf, map[string]string{"a": "<b>"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "{\"a\":\"<b>\"}\n" {
+		t.Errorf("unexpected JSON %q", buf.String())
+	}
+}
@@ -0,0 +1,122 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package clikit
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/spf13/pflag"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Shared flags
+//
+// Flags registers the flags the tools have in common, so their spelling,
+// help text, and precedence are the same everywhere.  A setting comes from
+// the first of:
+//
+//  1. its command-line flag
+//  2. its OLLAMATEA_* environment variable
+//  3. the --profile of the --config file
+//  4. the top level of the --config file
+//  5. the built-in default
+
+// Flags are the flags shared by the OllamaTea tools.
+// Only the fields of added flags are set.
+type Flags struct {
+	Host    string // Host is the Ollama host, from --host
+	Model   string // Model is the model name, from --model
+	Prompt  string // Prompt is the prompt, from --prompt
+	System  string // System is the system prompt, from --system
+	Verbose bool   // Verbose is set by --verbose
+
+	ConfigPath string // ConfigPath is the config file, from --config
+	Profile    string // Profile is the config profile, from --profile
+
+	flags    *pflag.FlagSet
+	defaults map[string]func() string // defaults of the added flags, after the config is loaded
+	targets  map[string]*string       // fields of the added flags
+}
+
+// NewFlags registers the --config and --profile flags on flags.
+// Add the other shared flags with its Add methods, then call [Flags.Load]
+// after parsing.
+func NewFlags(flags *pflag.FlagSet) *Flags {
+	f := &Flags{
+		flags:    flags,
+		defaults: make(map[string]func() string),
+		targets:  make(map[string]*string),
+	}
+	flags.StringVarP(&f.ConfigPath, "config", "", "", "Config file (also OLLAMATEA_CONFIG env) (default: user config dir's ollamatea/config.json)")
+	flags.StringVarP(&f.Profile, "profile", "", "", "Config file profile (also OLLAMATEA_PROFILE env)")
+	return f
+}
+
+// addString registers a shared string flag
+func (f *Flags) addString(target *string, name string, shorthand string, defaultFn func() string, usage string) *Flags {
+	f.flags.StringVarP(target, name, shorthand, defaultFn(), usage)
+	f.defaults[name] = defaultFn
+	f.targets[name] = target
+	return f
+}
+
+// AddHost adds --host (-h).
+func (f *Flags) AddHost() *Flags {
+	return f.addString(&f.Host, "host", "h", ollamatea.DefaultHost, "Host for Ollama (also OLLAMATEA_HOST env)")
+}
+
+// AddModel adds --model (-m).
+func (f *Flags) AddModel() *Flags {
+	return f.addString(&f.Model, "model", "m", ollamatea.DefaultModel, "Model for Ollama (also OLLAMATEA_MODEL env)")
+}
+
+// AddModelOr adds --model (-m), defaulting to fallback rather than the built-in
+// default model, unless OLLAMATEA_MODEL or the config file sets one.
+// It is for tools which need a particular kind of model.
+func (f *Flags) AddModelOr(fallback string) *Flags {
+	return f.addString(&f.Model, "model", "m", func() string {
+		if ollamatea.DefaultModelSet() {
+			return ollamatea.DefaultModel()
+		}
+		return fallback
+	}, "Model for Ollama (also OLLAMATEA_MODEL env)")
+}
+
+// AddPrompt adds --prompt (-p).
+func (f *Flags) AddPrompt() *Flags {
+	return f.addString(&f.Prompt, "prompt", "p", ollamatea.DefaultPrompt, "Prompt for Ollama (also OLLAMATEA_PROMPT env)")
+}
+
+// AddSystem adds --system (-s).
+func (f *Flags) AddSystem() *Flags {
+	return f.addString(&f.System, "system", "s", ollamatea.DefaultSystemPrompt, "System prompt for Ollama (also OLLAMATEA_SYSTEM env)")
+}
+
+// AddVerbose adds --verbose (-v).
+func (f *Flags) AddVerbose() *Flags {
+	f.flags.BoolVarP(&f.Verbose, "verbose", "v", false, "verbose output")
+	return f
+}
+
+// Load loads the config file and profile, then sets the added flags which were
+// not given on the command line to the resulting defaults.  Call it after parsing.
+func (f *Flags) Load() error {
+	if err := ollamatea.LoadConfig(f.ConfigPath, f.Profile); err != nil {
+		return err
+	}
+	for name, defaultFn := range f.defaults {
+		if !f.flags.Changed(name) {
+			*f.targets[name] = defaultFn()
+		}
+	}
+	return nil
+}
+
+// EnableDebugLog sends debug logs to stderr if --verbose was given.
+func (f *Flags) EnableDebugLog() {
+	if f.Verbose {
+		ollamatea.SetLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	}
+}
@@ -0,0 +1,33 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package clikit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// JSON output
+//
+// With --json, every command writes its results as JSON objects, one per
+// line, so they may be piped to tools like jq.  Commands with one result
+// write one line; commands which stream results write a line for each.
+
+// JSONFlagUsage is the shared help text of the --json flag.
+const JSONFlagUsage = "Output results as JSON, one object per line"
+
+// WriteJSON writes v to w as a single line of JSON.
+// HTML characters are not escaped, as the output is not for browsers.
+func WriteJSON(w io.Writer, v interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to JSON marshal %w", err)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
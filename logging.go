@@ -0,0 +1,61 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Logging
+//
+// OllamaTea components log request starts, completions, cancellations, and
+// failures with log/slog.  Logging is off until SetLogger is called, since
+// stray output would corrupt a TUI; log to a file when running one.
+// A Session, EmbedSession, or ModelChooser with its own Logger logs there instead.
+
+var globalLogger atomic.Pointer[slog.Logger]
+
+// SetLogger sets the slog.Logger used by all OllamaTea components.
+// Pass nil to disable logging.
+func SetLogger(logger *slog.Logger) {
+	globalLogger.Store(logger)
+}
+
+// logger returns the package Logger, or a Logger which discards everything.
+func logger() *slog.Logger {
+	if l := globalLogger.Load(); l != nil {
+		return l
+	}
+	return discardLogger
+}
+
+// loggerOr returns l, or the package Logger if l is nil
+func loggerOr(l *slog.Logger) *slog.Logger {
+	if l != nil {
+		return l
+	}
+	return logger()
+}
+
+// logger returns the Session's Logger, or the package Logger
+func (s *Session) logger() *slog.Logger {
+	return loggerOr(s.Logger)
+}
+
+// logger returns the EmbedSession's Logger, or the package Logger
+func (s *EmbedSession) logger() *slog.Logger {
+	return loggerOr(s.Logger)
+}
+
+var discardLogger = slog.New(discardHandler{})
+
+// discardHandler is a slog.Handler which is never enabled
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
@@ -0,0 +1,44 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestSessionLogger(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})).With("app", "dashboard")
+	s := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Chunks: []string{"Hello"}})
+	s.Logger = logger
+	if _, err := ollamatea.GenerateSync(context.Background(), s); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for _, want := range []string{"msg=\"generate start\"", "msg=\"generate done\"", "eval_count=", "latency=", "app=dashboard"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in the log %q", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	embed := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithInput("hello"),
+		ollamatea.WithLogger(logger))
+	if _, err := embed.Embed(context.Background()); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "msg=\"embed done\"") {
+		t.Errorf("expected the embedding logged, got %q", buf.String())
+	}
+}
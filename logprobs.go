@@ -0,0 +1,88 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"math"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Token log probabilities
+//
+// A Session with Logprobs set requests the log probability of each generated
+// token, and its top alternatives, from Backends which report them: the
+// OpenAIBackend (and so LMStudioBackend) and the LlamaCppBackend.  The tokens
+// of each chunk are delivered in its GenerateResponseMsg, for confidence
+// highlighting or perplexity displays.  Ollama's API does not report them, so
+// its responses have no Tokens.
+
+// TokenLogprob is a generated token with its log probability.
+type TokenLogprob struct {
+	Token       string         `json:"token"`                  // Token is the text of the token
+	Logprob     float64        `json:"logprob"`                // Logprob is the natural log of the token's probability
+	TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"` // TopLogprobs are the most likely tokens at its position, if requested
+}
+
+// Probability returns the token's probability, between 0 and 1
+func (t TokenLogprob) Probability() float64 {
+	return math.Exp(t.Logprob)
+}
+
+// Perplexity returns the perplexity of the tokens, the exponential of their
+// mean negative log probability, or 0 if there are none.  Lower is more confident.
+func Perplexity(tokens []TokenLogprob) float64 {
+	if len(tokens) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, t := range tokens {
+		sum += t.Logprob
+	}
+	return math.Exp(-sum / float64(len(tokens)))
+}
+
+// LogprobsResponseFunc is invoked with each partial response of a
+// LogprobsGenerator, with the tokens of its text, if any.
+type LogprobsResponseFunc func(resp GenerateResponse, tokens []TokenLogprob) error
+
+// LogprobsGenerator is a Generator which can also report token log probabilities.
+// It is optional; Sessions with Logprobs set use it when their Backend implements it.
+type LogprobsGenerator interface {
+	// GenerateLogprobs streams a generation like Generate, requesting each
+	// token's log probability and, if topLogprobs is positive, that many
+	// of the most likely alternatives.
+	GenerateLogprobs(ctx context.Context, req *GenerateRequest, topLogprobs int, fn LogprobsResponseFunc) error
+}
+
+// logprobsBackend is a Backend which generates with its LogprobsGenerator,
+// passing each chunk's tokens to setTokens before the chunk's response
+type logprobsBackend struct {
+	Backend
+	generator   LogprobsGenerator
+	topLogprobs int
+	setTokens   func([]TokenLogprob)
+}
+
+// Generate streams the generation with log probabilities.
+// Implements the [Generator] interface.
+func (b logprobsBackend) Generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error {
+	return b.generator.GenerateLogprobs(ctx, req, b.topLogprobs, func(resp GenerateResponse, tokens []TokenLogprob) error {
+		b.setTokens(tokens)
+		return fn(resp)
+	})
+}
+
+// withLogprobs returns the backend, generating with log probabilities passed
+// to setTokens if the Session's Logprobs is set and the backend supports them
+func (m *Session) withLogprobs(backend Backend, setTokens func([]TokenLogprob)) Backend {
+	if !m.Logprobs {
+		return backend
+	}
+	generator, ok := backend.(LogprobsGenerator)
+	if !ok {
+		m.logger().Debug("generate logprobs unsupported", "session_id", m.id, "host", m.Host)
+		return backend
+	}
+	return logprobsBackend{Backend: backend, generator: generator, topLogprobs: m.TopLogprobs, setTokens: setTokens}
+}
@@ -0,0 +1,71 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPerplexity(t *testing.T) {
+	if p := Perplexity(nil); p != 0 {
+		t.Errorf("expected 0 for no tokens, got %v", p)
+	}
+	tokens := []TokenLogprob{{Token: "a", Logprob: math.Log(0.5)}, {Token: "b", Logprob: math.Log(0.5)}}
+	if p := Perplexity(tokens); math.Abs(p-2) > 1e-9 {
+		t.Errorf("expected perplexity 2, got %v", p)
+	}
+	if p := tokens[0].Probability(); math.Abs(p-0.5) > 1e-9 {
+		t.Errorf("expected probability 0.5, got %v", p)
+	}
+}
+
+func TestSessionLogprobs(t *testing.T) {
+	var reqBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"Hi"},"logprobs":{"content":[{"token":"Hi","logprob":-0.1,"top_logprobs":[{"token":"Hi","logprob":-0.1}]}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"!"},"finish_reason":"stop","logprobs":{"content":[{"token":"!","logprob":-0.5}]}}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	session := NewSession()
+	session.Backend = NewOpenAIBackend(srv.URL, "")
+	session.Logprobs = true
+	session.TopLogprobs = 1
+	var tokens []TokenLogprob
+	_, err := session.Generate(context.Background(), func(msg GenerateResponseMsg) {
+		tokens = append(tokens, msg.Tokens...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqBody["logprobs"] != true || reqBody["top_logprobs"] != float64(1) {
+		t.Errorf("expected logprobs requested, got %v", reqBody)
+	}
+	if len(tokens) != 2 || tokens[0].Token != "Hi" || len(tokens[0].TopLogprobs) != 1 || tokens[1].Logprob != -0.5 {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+}
+
+func TestOpenAICompletionLogprobs(t *testing.T) {
+	l := &openAILogprobs{
+		Tokens:        []string{"a", "b"},
+		TokenLogprobs: []float64{-1, -2},
+		TopLogprobs:   []map[string]float64{{"x": -3, "a": -1}, {}},
+	}
+	tokens := l.tokens()
+	if len(tokens) != 2 || tokens[1].Logprob != -2 {
+		t.Fatalf("unexpected tokens %+v", tokens)
+	}
+	if top := tokens[0].TopLogprobs; len(top) != 2 || top[0].Token != "a" || top[1].Token != "x" {
+		t.Errorf("expected alternatives by likelihood, got %+v", top)
+	}
+}
@@ -0,0 +1,192 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Chat memory
+//
+// A chat history grows without bound, but a model's context does not.
+// A Memory decides which prior messages to send with each chat request;
+// set a ToolAgent's Memory to select its strategy.  The full history is
+// kept in the agent's Messages regardless.
+
+// Memory selects the messages to send with a chat request.
+// Implementations must be safe for concurrent use.
+type Memory interface {
+	// Select returns the messages to send from the full history, which
+	// excludes the System prompt.  The history must not be modified.
+	Select(ctx context.Context, history []Message) ([]Message, error)
+}
+
+// trimToTurn drops leading messages until a user message, so that a selection
+// does not begin with tool results or an assistant reply severed from its prompt.
+// Returns messages unchanged if there is no user message.
+func trimToTurn(messages []Message) []Message {
+	for i, message := range messages {
+		if message.Role == "user" {
+			return messages[i:]
+		}
+	}
+	return messages
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// WindowMemory is a Memory which sends the most recent messages, up to Size.
+type WindowMemory struct {
+	Size int // Size is the maximum number of messages to send
+}
+
+// NewWindowMemory returns a WindowMemory sending at most size messages.
+func NewWindowMemory(size int) *WindowMemory {
+	return &WindowMemory{Size: size}
+}
+
+// Select returns the last Size messages, starting at a user message.
+func (w *WindowMemory) Select(ctx context.Context, history []Message) ([]Message, error) {
+	if w.Size <= 0 || len(history) <= w.Size {
+		return history, nil
+	}
+	return trimToTurn(history[len(history)-w.Size:]), nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// TokenBudgetMemory is a Memory which sends the most recent messages that fit
+// within a budget of estimated tokens; see [EstimateMessageTokens].
+type TokenBudgetMemory struct {
+	MaxTokens int    // MaxTokens is the budget for the messages
+	Model     string // Model whose tokenizer is estimated, if known
+}
+
+// NewTokenBudgetMemory returns a TokenBudgetMemory with the budget of maxTokens.
+func NewTokenBudgetMemory(maxTokens int, model string) *TokenBudgetMemory {
+	return &TokenBudgetMemory{MaxTokens: maxTokens, Model: model}
+}
+
+// Select returns the most recent messages within MaxTokens, starting at a user
+// message.  The last message is always sent, even if it alone exceeds the budget.
+func (b *TokenBudgetMemory) Select(ctx context.Context, history []Message) ([]Message, error) {
+	if b.MaxTokens <= 0 {
+		return history, nil
+	}
+	start, tokens := len(history), 0
+	for start > 0 {
+		tokens += EstimateMessageTokens(history[start-1:start], b.Model)
+		if tokens > b.MaxTokens && start < len(history) {
+			break
+		}
+		start--
+	}
+	if start == 0 {
+		return history, nil
+	}
+	return trimToTurn(history[start:]), nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// SummaryMemory is a Memory which sends the most recent messages verbatim,
+// preceded by an LLM-generated summary of those before them.  The summary is
+// extended incrementally as the conversation grows, so each message is
+// summarized once.
+type SummaryMemory struct {
+	Host       string                 // Host of the Ollama server (default: DefaultHost())
+	Model      string                 // Model to summarize with (default: DefaultModel())
+	Backend    Backend                // Backend to generate with; if nil, SharedBackend(Host) is used
+	Options    map[string]interface{} // Options lists model-specific options
+	KeepRecent int                    // KeepRecent is the number of recent messages sent verbatim (default: 6)
+
+	mu         sync.Mutex
+	summary    string // summary of the first summarized messages
+	summarized int    // number of messages in the summary
+}
+
+const defaultSummaryMemoryKeepRecent = 6
+
+// summaryMemorySystemPrompt instructs the model to extend the running summary
+const summaryMemorySystemPrompt = "You maintain a running summary of a conversation between a user and an assistant.  " +
+	"Extend the summary with the new messages, keeping the facts, decisions, and open questions.  " +
+	"Reply with only the updated summary."
+
+// NewSummaryMemory returns a SummaryMemory summarizing with the model on the Ollama host.
+func NewSummaryMemory(ollamaHost string, model string) *SummaryMemory {
+	return &SummaryMemory{Host: ollamaHost, Model: model, KeepRecent: defaultSummaryMemoryKeepRecent}
+}
+
+// Summary returns the current summary of the earlier messages, if any.
+func (s *SummaryMemory) Summary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summary
+}
+
+// Select returns a summary of the earlier messages, as a system message,
+// followed by the KeepRecent most recent messages.
+func (s *SummaryMemory) Select(ctx context.Context, history []Message) ([]Message, error) {
+	keep := s.KeepRecent
+	if keep <= 0 {
+		keep = defaultSummaryMemoryKeepRecent
+	}
+	if len(history) <= keep {
+		return history, nil
+	}
+	recent := trimToTurn(history[len(history)-keep:])
+	older := history[:len(history)-len(recent)]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.summarized > len(older) {
+		// the history was replaced, such as by restoring a conversation
+		s.summary, s.summarized = "", 0
+	}
+	if s.summarized < len(older) {
+		summary, err := s.extendSummary(ctx, older[s.summarized:])
+		if err != nil {
+			return nil, err
+		}
+		s.summary, s.summarized = summary, len(older)
+	}
+
+	selected := make([]Message, 0, len(recent)+1)
+	selected = append(selected, Message{Role: "system", Content: "Summary of the earlier conversation: " + s.summary})
+	return append(selected, recent...), nil
+}
+
+// extendSummary returns the summary extended with the messages; s.mu must be held
+func (s *SummaryMemory) extendSummary(ctx context.Context, messages []Message) (string, error) {
+	var prompt strings.Builder
+	if s.summary != "" {
+		fmt.Fprintf(&prompt, "Summary so far:\n%s\n\n", s.summary)
+	}
+	prompt.WriteString("New messages:\n")
+	for _, message := range messages {
+		if message.Content != "" {
+			fmt.Fprintf(&prompt, "%s: %s\n\n", message.Role, message.Content)
+		}
+	}
+
+	session := NewSession()
+	if s.Host != "" {
+		session.Host = s.Host
+	}
+	if s.Model != "" {
+		session.Model = s.Model
+	}
+	session.Backend = s.Backend
+	session.Options = s.Options
+	session.System = summaryMemorySystemPrompt
+	session.Prompt = prompt.String()
+	done, err := session.Generate(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("summarizing memory: %w", err)
+	}
+	return strings.TrimSpace(done.Response), nil
+}
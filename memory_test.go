@@ -0,0 +1,94 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+// makeHistory returns n exchanges of user and assistant messages
+func makeHistory(n int) []ollamatea.Message {
+	var history []ollamatea.Message
+	for i := range n {
+		history = append(history,
+			ollamatea.Message{Role: "user", Content: fmt.Sprintf("question %d", i)},
+			ollamatea.Message{Role: "assistant", Content: fmt.Sprintf("answer %d", i)})
+	}
+	return history
+}
+
+func TestWindowMemory(t *testing.T) {
+	history := makeHistory(5)
+	selected, err := ollamatea.NewWindowMemory(3).Select(context.Background(), history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the window of 3 begins with an answer, so it is trimmed to the last turn
+	if len(selected) != 2 || selected[0].Content != "question 4" {
+		t.Errorf("unexpected selection %v", selected)
+	}
+	if selected, _ := ollamatea.NewWindowMemory(20).Select(context.Background(), history); len(selected) != len(history) {
+		t.Errorf("expected the whole history, got %d messages", len(selected))
+	}
+}
+
+func TestTokenBudgetMemory(t *testing.T) {
+	history := makeHistory(10)
+	selected, err := ollamatea.NewTokenBudgetMemory(30, "").Select(context.Background(), history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) == 0 || len(selected) >= len(history) || selected[0].Role != "user" ||
+		selected[len(selected)-1].Content != "answer 9" {
+		t.Errorf("unexpected selection %v", selected)
+	}
+	if tokens := ollamatea.EstimateMessageTokens(selected, ""); tokens > 30 {
+		t.Errorf("selection of %d tokens exceeds the budget", tokens)
+	}
+
+	huge := []ollamatea.Message{{Role: "user", Content: strings.Repeat("word ", 100)}}
+	if selected, _ := ollamatea.NewTokenBudgetMemory(10, "").Select(context.Background(), huge); len(selected) != 1 {
+		t.Errorf("expected the last message to always be sent, got %v", selected)
+	}
+}
+
+func TestSummaryMemory(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.QueueGenerate(
+		ollamateatest.GenerateScript{Chunks: []string{"first summary"}},
+		ollamateatest.GenerateScript{Chunks: []string{"second summary"}},
+	)
+
+	memory := ollamatea.NewSummaryMemory(srv.URL, "llama3.2")
+	memory.KeepRecent = 2
+	selected, err := memory.Select(context.Background(), makeHistory(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 3 || selected[0].Role != "system" || !strings.Contains(selected[0].Content, "first summary") ||
+		selected[1].Content != "question 2" {
+		t.Errorf("unexpected selection %v", selected)
+	}
+
+	// selecting again summarizes only the new messages
+	if _, err := memory.Select(context.Background(), makeHistory(3)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memory.Select(context.Background(), makeHistory(4)); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 2 || !strings.Contains(reqs[1].Prompt, "first summary") || strings.Contains(reqs[1].Prompt, "question 0") {
+		t.Errorf("expected an incremental summary request, got %+v", reqs)
+	}
+	if memory.Summary() != "second summary" {
+		t.Errorf("unexpected summary %q", memory.Summary())
+	}
+}
@@ -0,0 +1,262 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Metrics
+//
+// A MetricsRegistry counts requests, errors, tokens, and latencies for
+// generations and embeddings, per operation, host, and model.  Collection is
+// off until SetMetrics is called.  The registry serves the Prometheus text
+// format as an http.Handler, and may also be published with expvar.
+
+// DefaultLatencyBuckets are the default upper bounds, in seconds, of the latency histogram.
+var DefaultLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// MetricsRegistry holds OllamaTea request metrics.  It is safe for concurrent use.
+type MetricsRegistry struct {
+	mu      sync.Mutex
+	buckets []float64
+	series  map[metricsKey]*metricsSeries
+}
+
+// metricsKey identifies a metrics series
+type metricsKey struct {
+	Op    string // "generate" or "embed"
+	Host  string
+	Model string
+}
+
+// metricsSeries holds the metrics for a series
+type metricsSeries struct {
+	Requests      uint64
+	Errors        uint64
+	PromptTokens  uint64
+	EvalTokens    uint64
+	Dropped       uint64   // streamed chunks dropped by backpressure
+	Coalesced     uint64   // streamed chunks coalesced by backpressure
+	LatencyCounts []uint64 // per bucket, non-cumulative; the last is +Inf
+	LatencySum    float64  // seconds
+}
+
+// NewMetricsRegistry returns a new MetricsRegistry with the given latency
+// histogram buckets, in seconds.  If none are given, [DefaultLatencyBuckets] are used.
+func NewMetricsRegistry(buckets ...float64) *MetricsRegistry {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &MetricsRegistry{
+		buckets: buckets,
+		series:  make(map[metricsKey]*metricsSeries),
+	}
+}
+
+// metricsHolder wraps the registry, as atomic.Value requires a consistent concrete type
+type metricsHolder struct {
+	registry *MetricsRegistry
+}
+
+var globalMetrics atomic.Value // metricsHolder
+
+// SetMetrics sets the MetricsRegistry used by all OllamaTea components.
+// Pass nil to disable metrics.
+func SetMetrics(registry *MetricsRegistry) {
+	globalMetrics.Store(metricsHolder{registry: registry})
+}
+
+// observeMetrics records a request with the global MetricsRegistry, if any
+func observeMetrics(op string, host string, model string, latency time.Duration, promptTokens int, evalTokens int, err error) {
+	if holder, ok := globalMetrics.Load().(metricsHolder); ok && holder.registry != nil {
+		holder.registry.Observe(op, host, model, latency, promptTokens, evalTokens, err)
+	}
+}
+
+// observeChunks records backpressure on a generation with the global MetricsRegistry, if any
+func observeChunks(host string, model string, dropped int, coalesced int) {
+	if holder, ok := globalMetrics.Load().(metricsHolder); ok && holder.registry != nil {
+		holder.registry.ObserveChunks(host, model, dropped, coalesced)
+	}
+}
+
+// Observe records a request.  It is called by OllamaTea components, and may
+// also be called directly to record requests made by other means.
+func (r *MetricsRegistry) Observe(op string, host string, model string, latency time.Duration, promptTokens int, evalTokens int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	series := r.getSeries(metricsKey{Op: op, Host: host, Model: model})
+	series.Requests++
+	if err != nil {
+		series.Errors++
+	}
+	series.PromptTokens += uint64(max(promptTokens, 0))
+	series.EvalTokens += uint64(max(evalTokens, 0))
+
+	seconds := latency.Seconds()
+	series.LatencySum += seconds
+	bucket := sort.SearchFloat64s(r.buckets, seconds) // first bucket >= seconds, or +Inf
+	series.LatencyCounts[bucket]++
+}
+
+// ObserveChunks records streamed chunks of a generation which were dropped or
+// coalesced because the Session's response buffer was full.
+func (r *MetricsRegistry) ObserveChunks(host string, model string, dropped int, coalesced int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	series := r.getSeries(metricsKey{Op: "generate", Host: host, Model: model})
+	series.Dropped += uint64(max(dropped, 0))
+	series.Coalesced += uint64(max(coalesced, 0))
+}
+
+// getSeries returns the series for the key, creating it if needed; r.mu must be held
+func (r *MetricsRegistry) getSeries(key metricsKey) *metricsSeries {
+	series, ok := r.series[key]
+	if !ok {
+		series = &metricsSeries{LatencyCounts: make([]uint64, len(r.buckets)+1)}
+		r.series[key] = series
+	}
+	return series
+}
+
+// MetricsSnapshot is a point-in-time copy of a metrics series, as published to expvar.
+type MetricsSnapshot struct {
+	Op             string  `json:"op"`
+	Host           string  `json:"host"`
+	Model          string  `json:"model"`
+	Requests       uint64  `json:"requests"`
+	Errors         uint64  `json:"errors"`
+	PromptTokens   uint64  `json:"prompt_tokens"`
+	EvalTokens     uint64  `json:"eval_tokens"`
+	Dropped        uint64  `json:"dropped_chunks"`
+	Coalesced      uint64  `json:"coalesced_chunks"`
+	LatencySeconds float64 `json:"latency_seconds"` // LatencySeconds is the sum of all latencies
+}
+
+// Snapshot returns a copy of all series, sorted by op, host, and model.
+func (r *MetricsRegistry) Snapshot() []MetricsSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshots := make([]MetricsSnapshot, 0, len(r.series))
+	for _, key := range r.sortedKeys() {
+		series := r.series[key]
+		snapshots = append(snapshots, MetricsSnapshot{
+			Op:             key.Op,
+			Host:           key.Host,
+			Model:          key.Model,
+			Requests:       series.Requests,
+			Errors:         series.Errors,
+			PromptTokens:   series.PromptTokens,
+			EvalTokens:     series.EvalTokens,
+			Dropped:        series.Dropped,
+			Coalesced:      series.Coalesced,
+			LatencySeconds: series.LatencySum,
+		})
+	}
+	return snapshots
+}
+
+// PublishExpvar publishes the registry's Snapshot as the named expvar,
+// served at /debug/vars by the expvar package's handler.
+// Like expvar.Publish, it panics if the name is already registered.
+func (r *MetricsRegistry) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return r.Snapshot()
+	}))
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WritePrometheus(w)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+func (r *MetricsRegistry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sb strings.Builder
+	keys := r.sortedKeys()
+	counters := []struct {
+		name, help string
+		value      func(*metricsSeries) uint64
+	}{
+		{"ollamatea_requests_total", "Total OllamaTea requests.", func(s *metricsSeries) uint64 { return s.Requests }},
+		{"ollamatea_errors_total", "Total OllamaTea requests which failed.", func(s *metricsSeries) uint64 { return s.Errors }},
+		{"ollamatea_prompt_tokens_total", "Total prompt tokens evaluated.", func(s *metricsSeries) uint64 { return s.PromptTokens }},
+		{"ollamatea_eval_tokens_total", "Total tokens generated.", func(s *metricsSeries) uint64 { return s.EvalTokens }},
+		{"ollamatea_dropped_chunks_total", "Total streamed chunks dropped by backpressure.", func(s *metricsSeries) uint64 { return s.Dropped }},
+		{"ollamatea_coalesced_chunks_total", "Total streamed chunks coalesced by backpressure.", func(s *metricsSeries) uint64 { return s.Coalesced }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, key := range keys {
+			fmt.Fprintf(&sb, "%s{%s} %d\n", c.name, promLabels(key), c.value(r.series[key]))
+		}
+	}
+
+	const histName = "ollamatea_request_duration_seconds"
+	fmt.Fprintf(&sb, "# HELP %s OllamaTea request latency.\n# TYPE %s histogram\n", histName, histName)
+	for _, key := range keys {
+		series, labels := r.series[key], promLabels(key)
+		var cumulative uint64
+		for i, le := range r.buckets {
+			cumulative += series.LatencyCounts[i]
+			fmt.Fprintf(&sb, "%s_bucket{%s,le=\"%s\"} %d\n", histName, labels,
+				strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		cumulative += series.LatencyCounts[len(r.buckets)]
+		fmt.Fprintf(&sb, "%s_bucket{%s,le=\"+Inf\"} %d\n", histName, labels, cumulative)
+		fmt.Fprintf(&sb, "%s_sum{%s} %s\n", histName, labels, strconv.FormatFloat(series.LatencySum, 'g', -1, 64))
+		fmt.Fprintf(&sb, "%s_count{%s} %d\n", histName, labels, cumulative)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// sortedKeys returns the series keys in a stable order; r.mu must be held
+func (r *MetricsRegistry) sortedKeys() []metricsKey {
+	keys := make([]metricsKey, 0, len(r.series))
+	for key := range r.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Op != keys[j].Op {
+			return keys[i].Op < keys[j].Op
+		}
+		if keys[i].Host != keys[j].Host {
+			return keys[i].Host < keys[j].Host
+		}
+		return keys[i].Model < keys[j].Model
+	})
+	return keys
+}
+
+// promLabels formats the key as Prometheus labels
+func promLabels(key metricsKey) string {
+	return fmt.Sprintf(`op="%s",host="%s",model="%s"`,
+		promEscape(key.Op), promEscape(key.Host), promEscape(key.Model))
+}
+
+// promLabelEscaper escapes Prometheus label values
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(s string) string {
+	return promLabelEscaper.Replace(s)
+}
@@ -3,16 +3,20 @@
 package ollamatea
 
 import (
+	"cmp"
 	"context"
 	"fmt"
-	"net/http"
-	"net/url"
-	"sync/atomic"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/dustin/go-humanize"
 	ollama "github.com/ollama/ollama/api"
 )
@@ -26,7 +30,7 @@ var lastFetchModelListID int64
 // GetNextFetchModelListID atomically returns the next FetchModelList ID.
 // Call this to get a unique ID for a [FetchModelList] request.
 func GetNextModelChooserID() int64 {
-	return atomic.AddInt64(&lastFetchModelListID, 1)
+	return allocateID(&lastFetchModelListID)
 }
 
 // Type alias in this package for convenience
@@ -52,17 +56,39 @@ type FetchModelListErrorMsg struct {
 // It is independent of any Model, so can be used as an independent [tea.Msg] generator
 // to implement one's own model selection interfaces.
 func FetchModelList(ollamaHost string, id int64) tea.Msg {
-	ollamaURL, err := url.Parse(ollamaHost)
-	if err != nil {
-		return FetchModelListErrorMsg{ID: id, OllamaHost: ollamaHost, Error: err}
+	return FetchModelListFromBackend(nil, ollamaHost, id)
+}
+
+// FetchModelListFromBackend is like [FetchModelList], but lists models with the given
+// Backend.  If backend is nil, the shared Backend for ollamaHost from [SharedBackend] is used.
+func FetchModelListFromBackend(backend ModelLister, ollamaHost string, id int64) tea.Msg {
+	return fetchModelList(backend, ollamaHost, id, logger())
+}
+
+// fetchModelList implements FetchModelListFromBackend, logging to log
+func fetchModelList(backend ModelLister, ollamaHost string, id int64, log *slog.Logger) tea.Msg {
+	if backend == nil {
+		ollamaBackend, err := SharedBackend(ollamaHost)
+		if err != nil {
+			log.Error("list backend failed", "host", ollamaHost, "error", err)
+			return FetchModelListErrorMsg{ID: id, OllamaHost: ollamaHost, Error: err}
+		}
+		backend = ollamaBackend
 	}
 
-	ollamaClient := ollama.NewClient(ollamaURL, http.DefaultClient)
-	ctx := context.Background()
-	listResponse, err := ollamaClient.List(ctx)
+	ctx, span := startSpan(context.Background(), "ollamatea.list",
+		Attr("ollamatea.host", ollamaHost))
+	defer span.End()
+	log.Debug("list start", "host", ollamaHost)
+	listResponse, err := backend.List(ctx)
 	if err != nil {
+		err = ClassifyError(err, ollamaHost, "")
+		log.Error("list failed", "host", ollamaHost, "error", err)
+		span.RecordError(err)
 		return FetchModelListErrorMsg{ID: id, OllamaHost: ollamaHost, Error: err}
 	}
+	span.SetAttributes(Attr("ollamatea.models", len(listResponse.Models)))
+	log.Debug("list done", "host", ollamaHost, "models", len(listResponse.Models))
 
 	return FetchModelListResponseMsg{ID: id, OllamaHost: ollamaHost, Models: listResponse.Models}
 }
@@ -72,6 +98,27 @@ func FetchModelList(ollamaHost string, id int64) tea.Msg {
 const (
 	defaultModelChooserWaiting    = "Loading models..."
 	defaultModelChooserMenuPrompt = "Select Ollama model"
+	modelDetailsSectionLines      = 8 // lines of each long section of the details pane
+)
+
+var modelChooserDetailsKeyBinding = key.NewBinding(
+	key.WithKeys("tab"),
+	key.WithHelp("tab", "details"),
+)
+
+var modelChooserPullKeyBinding = key.NewBinding(
+	key.WithKeys("p"),
+	key.WithHelp("p", "pull"),
+)
+
+var modelChooserRefreshKeyBinding = key.NewBinding(
+	key.WithKeys("r"),
+	key.WithHelp("r", "refresh"),
+)
+
+var modelChooserSortKeyBinding = key.NewBinding(
+	key.WithKeys("s"),
+	key.WithHelp("s", "sort"),
 )
 
 var modelChooserExtraKeyBindings = []key.Binding{
@@ -82,31 +129,108 @@ var modelChooserExtraKeyBindings = []key.Binding{
 		key.WithKeys("esc"),
 		key.WithHelp("esc", "exit"),
 	),
+	modelChooserDetailsKeyBinding,
+	modelChooserPullKeyBinding,
+	modelChooserRefreshKeyBinding,
+	modelChooserSortKeyBinding,
+}
+
+// modelDetailsStyle separates the details pane from the list
+var modelDetailsStyle = lipgloss.NewStyle().
+	Border(lipgloss.NormalBorder(), false, false, false, true).
+	PaddingLeft(1)
+
+// ModelSort is the order of a ModelChooser's list.
+type ModelSort int
+
+const (
+	ModelSortServer   ModelSort = iota // ModelSortServer keeps the server's order (default)
+	ModelSortName                      // ModelSortName sorts by name, alphabetically
+	ModelSortSize                      // ModelSortSize sorts by size, largest first
+	ModelSortModified                  // ModelSortModified sorts by modification time, newest first
+)
+
+// modelSortCount is the number of ModelSorts, which the sort key cycles through
+const modelSortCount = 4
+
+// String returns the name of the ModelSort, such as "size".
+func (s ModelSort) String() string {
+	switch s {
+	case ModelSortServer:
+		return "server"
+	case ModelSortName:
+		return "name"
+	case ModelSortSize:
+		return "size"
+	case ModelSortModified:
+		return "modified"
+	}
+	return fmt.Sprintf("ModelSort(%d)", int(s))
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // ollamatea.ModelChooser
 //
-// TODO: retry logic?? cancellation?
+// TODO: cancellation?
 
 // ModelChooser is a Terminal UX for selecting a local LLM model from Ollama.
 type ModelChooser struct {
-	Waiting     string // Waiting to load message (default is "Loading models..")
-	MenuPrompt  string // Menu prompt (default is "Select Ollama model")
-	FetchOnInit bool   // FetchOnInit indicates whether to fetch the model list in Init (default: true)
-	//Filter     string // Filter for model selection (default: none)
+	Waiting     string  // Waiting to load message (default is "Loading models..")
+	MenuPrompt  string  // Menu prompt (default is "Select Ollama model")
+	FetchOnInit bool    // FetchOnInit indicates whether to fetch the model list in Init (default: true)
+	Backend     Backend // Backend to list models with; if nil, SharedBackend(Host) is used
+	Accessible  bool    // Accessible renders linear, plain text for screen readers (default: DefaultAccessible())
+	Filter      string  // Filter lists only models whose names contain it, ignoring case; applied when fetched (default: none)
+
+	// ShowDetails shows a pane beside the list with the highlighted model's
+	// parameters, system prompt, template, and license, fetched with ShowModelCmd.
+	// The tab key toggles it (default: false)
+	ShowDetails bool
+
+	// RefreshInterval, if positive, refetches the model list that often after
+	// each fetch, so the list reflects models pulled or removed by other
+	// processes.  The r key refreshes it at once (default: none)
+	RefreshInterval time.Duration
+
+	// Retry, if set, retries fetching the model list after transient failures,
+	// announcing each retry with a RetryingMsg (default: none)
+	Retry *RetryPolicy
+
+	// Logger, if set, logs the model list and version requests, rather than
+	// the Logger of SetLogger (default: none)
+	Logger *slog.Logger
 
 	modelList list.Model
 	spinner   spinner.Model
+	pullBar   progress.Model
 
-	listedModels  []ListModelResponse
+	serverModels  []ListModelResponse // filtered models in the server's order
+	listedModels  []ListModelResponse // serverModels in the sort order
 	selectedModel *ListModelResponse
 	selectedName  string // Name of the selected model, for before we have a fetched list
 
 	id         int64
 	ollamaHost string // Ollama Host -- really the service's URL (default: OllamaTea default)
 	isFetching bool
+	fetched    bool         // whether the list has been fetched
+	retrying   *RetryingMsg // the fetch's pending retry, if any
 	lastError  error
+	version    *ServerVersion // version of the server, once fetched
+	width      int
+	height     int
+	details    map[string]*modelDetails // details of models by name, once requested
+	pulling    *PullProgressMsg         // progress of the model being pulled, if any
+	pullFailed *PullDoneMsg             // the last pull, if it failed
+	pullCancel context.CancelFunc       // cancels the pull, if any
+	sort       ModelSort                // order of the list
+	refreshSeq int                      // counts scheduled refreshes, so stale ticks are ignored
+	highlight  string                   // name of the model to highlight once listed, such as one pulled
+}
+
+// modelDetails is the outcome of showing a model for the details pane
+type modelDetails struct {
+	info *ShowResponse // info of the model, once shown
+	err  error         // err is why showing it failed, if it did
 }
 
 // NewModelChooser returns a new ModelChooser for the given Ollama Host.
@@ -130,9 +254,11 @@ func NewModelChooser(ollamaHost string) ModelChooser {
 		Waiting:      defaultModelChooserWaiting,
 		MenuPrompt:   defaultModelChooserMenuPrompt,
 		FetchOnInit:  true,
+		Accessible:   DefaultAccessible(),
 		selectedName: "",
 		modelList:    l,
 		spinner:      s,
+		pullBar:      progress.New(progress.WithDefaultGradient(), progress.WithWidth(20)),
 		ollamaHost:   ollamaHost,
 	}
 }
@@ -142,6 +268,12 @@ func (m ModelChooser) ID() int64 {
 	return m.id
 }
 
+// SetID sets the ModelChooser's ID, such as to a stable ID for tests or replays.
+// The ID must be unique among ModelChoosers receiving the same messages.
+func (m *ModelChooser) SetID(id int64) {
+	m.id = id
+}
+
 // Host returns the Ollama Host URL for the ModelChooser.
 func (m ModelChooser) Host() string {
 	return m.ollamaHost
@@ -158,6 +290,15 @@ func (m ModelChooser) IsFetching() bool {
 	return m.isFetching
 }
 
+// ServerVersion returns the version of the ModelChooser's server.
+// Returns false if it has not been fetched, or the Backend does not report one.
+func (m ModelChooser) ServerVersion() (ServerVersion, bool) {
+	if m.version == nil {
+		return ServerVersion{}, false
+	}
+	return *m.version, true
+}
+
 // SelectedModel returns the selected model from the ModelChooser.
 // Returns nil if there is no selected model.
 func (m ModelChooser) SelectedModel() *ollama.ListModelResponse {
@@ -183,6 +324,72 @@ func (m *ModelChooser) SetSelectionByName(name string) bool {
 	return false
 }
 
+// Sort returns the order of the ModelChooser's list.
+func (m ModelChooser) Sort() ModelSort {
+	return m.sort
+}
+
+// SetSort sets the order of the ModelChooser's list, reordering a fetched list
+// with the highlighted model kept under the cursor.  The s key cycles through
+// the orders.  Returns the list's command, if any, to refilter it.
+func (m *ModelChooser) SetSort(sort ModelSort) tea.Cmd {
+	m.sort = sort
+	m.updateTitle()
+	if !m.fetched {
+		return nil
+	}
+	return m.setListedModels(m.serverModels)
+}
+
+// setListedModels lists the models, given in the server's order, in the sort
+// order, keeping the highlighted model under the cursor, such as when
+// refreshing or sorting, else moving the cursor to the selected model.
+// Returns the list's command, if any.
+func (m *ModelChooser) setListedModels(models []ListModelResponse) tea.Cmd {
+	highlight := m.highlight
+	if current := m.HighlightedModel(); highlight == "" && current != nil {
+		highlight = current.Name
+	}
+	m.highlight = ""
+	m.serverModels = models
+	m.listedModels = sortModelList(models, m.sort)
+
+	var items []list.Item
+	selectedIndex, highlightIndex := -1, -1
+	for i, model := range m.listedModels {
+		items = append(items, makeModelChooserListItem(i, model))
+		if (m.selectedModel != nil && model.Name == m.selectedModel.Name) ||
+			(m.selectedName != "" && (model.Name == m.selectedName || model.Name == m.selectedName+":latest")) {
+			selectedIndex = i
+		}
+		if model.Name == highlight || model.Name == highlight+":latest" {
+			highlightIndex = i
+		}
+	}
+	if selectedIndex < 0 {
+		m.selectedModel = nil
+	} else {
+		m.modelList.Select(selectedIndex)
+		m.selectedName = m.listedModels[selectedIndex].Name
+	}
+	if highlightIndex >= 0 {
+		m.modelList.Select(highlightIndex)
+	}
+	return m.modelList.SetItems(items)
+}
+
+// updateTitle titles the list with the MenuPrompt, the server's version, and the sort order
+func (m *ModelChooser) updateTitle() {
+	title := m.MenuPrompt
+	if m.version != nil {
+		title = fmt.Sprintf("%s (Ollama %s)", title, m.version)
+	}
+	if m.sort != ModelSortServer {
+		title += ", by " + m.sort.String()
+	}
+	m.modelList.Title = title
+}
+
 // Styles returns the list.Styles for the ModelChooser.
 func (m ModelChooser) Styles() list.Styles {
 	return m.modelList.Styles
@@ -198,23 +405,60 @@ func (m *ModelChooser) SetStyles(styles list.Styles) {
 
 // Width returns the width of the model chooser
 func (m ModelChooser) Width() int {
-	return m.modelList.Width()
+	return m.width
 }
 
 // SetWidth sets the width of the model chooser
 func (m *ModelChooser) SetWidth(w int) {
-	m.modelList.SetWidth(w)
+	m.width = w
+	m.modelList.SetWidth(m.listWidth())
 }
 
 // Height returns the height of the ModelChooser
 func (m ModelChooser) Height() int {
-	return m.modelList.Height()
+	return m.height
 }
 
 func (m *ModelChooser) SetHeight(h int) {
+	m.height = h
 	m.modelList.SetHeight(h)
 }
 
+// listWidth returns the width of the list, which shares the width with the details pane if shown
+func (m ModelChooser) listWidth() int {
+	if m.ShowDetails && !m.Accessible {
+		return m.width / 2
+	}
+	return m.width
+}
+
+// HighlightedModel returns the model under the list's cursor, which the details pane shows.
+// Returns nil if there are no models.
+func (m ModelChooser) HighlightedModel() *ListModelResponse {
+	item, ok := m.modelList.SelectedItem().(modelChooserListItem)
+	if !ok || item.index >= len(m.listedModels) {
+		return nil
+	}
+	return &m.listedModels[item.index]
+}
+
+// detailsCmd returns a command showing the highlighted model, if the details
+// pane is shown and it has not been requested already
+func (m *ModelChooser) detailsCmd() tea.Cmd {
+	model := m.HighlightedModel()
+	if !m.ShowDetails || model == nil {
+		return nil
+	}
+	if _, ok := m.details[model.Name]; ok {
+		return nil
+	}
+	if m.details == nil {
+		m.details = make(map[string]*modelDetails)
+	}
+	m.details[model.Name] = &modelDetails{}
+	return showModelCmd(m.Backend, m.ollamaHost, model.Name)
+}
+
 //////////////////////////////////////////////////////////////////////////////
 
 type ModelChooserSelectedMsg struct {
@@ -228,6 +472,13 @@ type ModelChooserAbortedMsg struct {
 	Error error // Error that caused the exit, if any
 }
 
+// modelChooserRefreshMsg is the private message prompting a ModelChooser's
+// next refresh of its list
+type modelChooserRefreshMsg struct {
+	ID  int64 // ID of the ModelChooser
+	Seq int   // Seq is the refresh it was scheduled for
+}
+
 // fetchListMsg is sent to fetch the list of models from the Ollama server.
 type fetchListMsg struct {
 	ID         int64  // ID of the original request
@@ -240,10 +491,94 @@ func (m ModelChooser) FetchListMsg() fetchListMsg {
 	return fetchListMsg{ID: m.id, OllamaHost: m.ollamaHost}
 }
 
+// pullModelMsg is sent to pull a model to the Ollama server.
+type pullModelMsg struct {
+	ID    int64  // ID of the ModelChooser
+	Model string // Model to pull
+}
+
+// PullModelMsg is the message to send the ModelChooser to make it pull the
+// model to the Ollama server, showing its progress, then select it.  The p key
+// sends it for a model which is not installed; see [ModelChooser.MissingModel].
+func (m ModelChooser) PullModelMsg(model string) pullModelMsg {
+	return pullModelMsg{ID: m.id, Model: model}
+}
+
+// IsPulling returns true if the ModelChooser is pulling a model.
+func (m ModelChooser) IsPulling() bool {
+	return m.pulling != nil
+}
+
+// StopPull stops the model being pulled, if any, so its download does not
+// continue after the ModelChooser is closed.  Selecting a model, aborting with
+// esc, or pulling another model stops the pull too.
+func (m *ModelChooser) StopPull() {
+	if m.pullCancel != nil {
+		m.pullCancel()
+		m.pullCancel = nil
+	}
+	m.pulling = nil
+}
+
+// MissingModel returns the name of the model the user wants which is not
+// installed, if any: the name of SetSelectionByName, or the list's filter
+// text.  Returns "" while the list is being fetched.
+func (m ModelChooser) MissingModel() string {
+	if !m.fetched || m.isFetching {
+		return ""
+	}
+	for _, name := range []string{m.selectedName, strings.TrimSpace(m.modelList.FilterValue())} {
+		if name != "" && !m.isListed(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// isListed returns true if the named model is listed, with or without its ":latest" tag
+func (m ModelChooser) isListed(name string) bool {
+	for _, model := range m.listedModels {
+		if model.Name == name || model.Name == name+":latest" {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshCmd returns a command delivering a modelChooserRefreshMsg after the
+// RefreshInterval, if positive, superseding any refresh scheduled before
+func (m *ModelChooser) refreshCmd() tea.Cmd {
+	m.refreshSeq++
+	if m.RefreshInterval <= 0 {
+		return nil
+	}
+	id, seq := m.id, m.refreshSeq
+	return tea.Tick(m.RefreshInterval, func(time.Time) tea.Msg {
+		return modelChooserRefreshMsg{ID: id, Seq: seq}
+	})
+}
+
 // startFetchingCmd returns a command to start fetching the model list.
 func (m ModelChooser) startFetchingCmd() tea.Cmd {
+	return m.fetchAttemptCmd(1, 0)
+}
+
+// fetchAttemptCmd returns a command which waits for delay, then makes the
+// attempt to fetch the model list.  A retryable failure returns a RetryingMsg.
+func (m ModelChooser) fetchAttemptCmd(attempt int, delay time.Duration) tea.Cmd {
 	return func() tea.Msg {
-		return FetchModelList(m.ollamaHost, m.id)
+		time.Sleep(delay)
+		var lister ModelLister
+		if m.Backend != nil {
+			lister = m.Backend
+		}
+		msg := fetchModelList(lister, m.ollamaHost, m.id, loggerOr(m.Logger))
+		if errMsg, ok := msg.(FetchModelListErrorMsg); ok {
+			if retryMsg, ok := m.Retry.retryingMsg(m.id, "list", attempt, errMsg.Error); ok {
+				return retryMsg
+			}
+		}
+		return msg
 	}
 }
 
@@ -260,15 +595,56 @@ func (i modelChooserListItem) Description() string { return i.desc }
 func (i modelChooserListItem) FilterValue() string { return i.title }
 
 func makeModelChooserListItem(index int, model ollama.ListModelResponse) modelChooserListItem {
+	desc := fmt.Sprintf("%s %s %s",
+		model.Details.Family,
+		model.Details.ParameterSize,
+		model.Details.QuantizationLevel,
+	)
+	if model.Size > 0 { // not all backends report sizes
+		desc = fmt.Sprintf("(%s) %s", humanize.Bytes(uint64(model.Size)), desc)
+	}
 	return modelChooserListItem{
 		index: index,
 		title: model.Name,
-		desc: fmt.Sprintf("(%s) %s %s %s",
-			humanize.Bytes(uint64(model.Size)),
-			model.Details.Family,
-			model.Details.ParameterSize,
-			model.Details.QuantizationLevel,
-		)}
+		desc:  desc,
+	}
+}
+
+// sortModelList returns a copy of the models in the sort order.
+// Models which tie keep the server's order.
+func sortModelList(models []ListModelResponse, sort ModelSort) []ListModelResponse {
+	sorted := slices.Clone(models)
+	switch sort {
+	case ModelSortName:
+		slices.SortStableFunc(sorted, func(a, b ListModelResponse) int {
+			return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		})
+	case ModelSortSize:
+		slices.SortStableFunc(sorted, func(a, b ListModelResponse) int {
+			return cmp.Compare(b.Size, a.Size)
+		})
+	case ModelSortModified:
+		slices.SortStableFunc(sorted, func(a, b ListModelResponse) int {
+			return b.ModifiedAt.Compare(a.ModifiedAt)
+		})
+	}
+	return sorted
+}
+
+// filterModelList returns the models whose names contain filter, ignoring case.
+// Returns models if filter is empty.
+func filterModelList(models []ListModelResponse, filter string) []ListModelResponse {
+	if filter == "" {
+		return models
+	}
+	filter = strings.ToLower(filter)
+	var filtered []ListModelResponse
+	for _, model := range models {
+		if strings.Contains(strings.ToLower(model.Name), filter) {
+			filtered = append(filtered, model)
+		}
+	}
+	return filtered
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -293,45 +669,103 @@ func (m ModelChooser) Update(msg tea.Msg) (ModelChooser, tea.Cmd) {
 		}
 		// TODO: cancel current
 		m.isFetching = true
-		return m, tea.Batch(m.startFetchingCmd(), m.spinner.Tick)
+		m.retrying = nil
+		backend, host, log := m.Backend, m.ollamaHost, loggerOr(m.Logger)
+		fetchVersion := func() tea.Msg {
+			return fetchVersion(backend, host, log)
+		}
+		return m, tea.Batch(m.startFetchingCmd(), fetchVersion, m.spinner.Tick)
+
+	case VersionMsg:
+		if msg.OllamaHost != m.ollamaHost {
+			return m, nil
+		}
+		version := msg.Version
+		m.version = &version
+		m.updateTitle()
+		return m, nil
 
 	case FetchModelListResponseMsg:
 		if msg.ID != m.id {
 			return m, nil
 		}
 		m.isFetching = false
-		m.listedModels = msg.Models
+		m.fetched = true
+		m.retrying = nil
 		m.lastError = nil
+		cmd := m.setListedModels(filterModelList(msg.Models, m.Filter))
+		return m, tea.Batch(cmd, m.detailsCmd(), m.refreshCmd())
 
-		var items []list.Item
-		selectedIndex := -1
-		for i, model := range m.listedModels {
-			items = append(items, makeModelChooserListItem(i, model))
-			if (m.selectedModel != nil && model.Name == m.selectedModel.Name) ||
-				(m.selectedName != "" && model.Name == m.selectedName) {
-				selectedIndex = i
-			}
+	case modelChooserRefreshMsg:
+		if msg.ID != m.id || msg.Seq != m.refreshSeq || m.isFetching {
+			return m, nil
+		}
+		return m, Cmdize(m.FetchListMsg())
+
+	case pullModelMsg:
+		if msg.ID != m.id || (m.pulling != nil && m.pulling.Model == msg.Model) {
+			return m, nil
 		}
-		if selectedIndex < 0 {
-			m.selectedModel = nil
-		} else {
-			m.modelList.Select(selectedIndex)
-			m.selectedName = m.listedModels[selectedIndex].Name
+		m.StopPull() // replaced by this pull
+		ctx, cancel := context.WithCancel(context.Background())
+		m.pulling = &PullProgressMsg{ID: m.id, OllamaHost: m.ollamaHost, Model: msg.Model, Status: "starting"}
+		m.pullFailed = nil
+		m.pullCancel = cancel
+		return m, pullModelCmd(ctx, m.Backend, m.ollamaHost, msg.Model, m.id)
+
+	case PullProgressMsg:
+		if msg.ID != m.id || m.pulling == nil || msg.Model != m.pulling.Model {
+			return m, nil // such as a stopped pull's
 		}
-		cmd := m.modelList.SetItems(items)
-		return m, cmd
+		m.pulling = &msg
+		return m, msg.Next()
+
+	case PullDoneMsg:
+		if msg.ID != m.id || m.pulling == nil || msg.Model != m.pulling.Model {
+			return m, nil
+		}
+		m.StopPull()
+		if msg.Error != nil {
+			m.pullFailed = &msg
+			return m, nil
+		}
+		// highlight the pulled model once it is listed
+		m.highlight = msg.Model
+		m.modelList.ResetFilter()
+		return m, Cmdize(m.FetchListMsg())
+
+	case ModelShownMsg:
+		if details, ok := m.details[msg.Model]; ok && msg.OllamaHost == m.ollamaHost {
+			details.info, details.err = msg.Info, nil
+		}
+		return m, nil
+
+	case ModelManageErrorMsg:
+		if details, ok := m.details[msg.Model]; ok && msg.OllamaHost == m.ollamaHost && msg.Op == ModelOpShow {
+			details.info, details.err = nil, msg.Error
+		}
+		return m, nil
 
 	case FetchModelListErrorMsg:
 		if msg.ID != m.id {
 			return m, nil
 		}
 		m.isFetching = false
+		m.retrying = nil
 		m.lastError = msg.Error
-		return m, nil
+		return m, m.refreshCmd()
+
+	case RetryingMsg:
+		if msg.ID != m.id || msg.Request != "list" || !m.isFetching {
+			return m, nil
+		}
+		m.retrying = &msg
+		return m, m.fetchAttemptCmd(msg.Attempt, msg.Delay)
 
 	case tea.KeyMsg:
 		switch keypress := msg.String(); keypress {
 		case "esc":
+			m.StopPull()
 			return m, Cmdize(ModelChooserAbortedMsg{ID: m.id, Error: m.lastError})
 		case "enter":
 			item, ok := m.modelList.SelectedItem().(modelChooserListItem)
@@ -344,15 +778,37 @@ func (m ModelChooser) Update(msg tea.Msg) (ModelChooser, tea.Cmd) {
 				return m, nil
 			}
 			m.selectedModel = &m.listedModels[item.index]
+			m.StopPull()
 			return m, Cmdize(ModelChooserSelectedMsg{
 				ID: m.id, OllamaHost: m.ollamaHost, Selection: *m.selectedModel})
 		}
+		if key.Matches(msg, modelChooserSortKeyBinding) && m.modelList.FilterState() != list.Filtering {
+			return m, m.SetSort((m.sort + 1) % modelSortCount)
+		}
+		if key.Matches(msg, modelChooserRefreshKeyBinding) && m.modelList.FilterState() != list.Filtering {
+			if m.isFetching {
+				return m, nil
+			}
+			return m, Cmdize(m.FetchListMsg())
+		}
+		if key.Matches(msg, modelChooserPullKeyBinding) && m.modelList.FilterState() != list.Filtering {
+			if missing := m.MissingModel(); missing != "" {
+				return m, Cmdize(m.PullModelMsg(missing))
+			}
+			return m, nil
+		}
+		if key.Matches(msg, modelChooserDetailsKeyBinding) && m.modelList.FilterState() != list.Filtering {
+			m.ShowDetails = !m.ShowDetails
+			m.SetWidth(m.width)
+			return m, m.detailsCmd()
+		}
 		var cmd tea.Cmd
 		m.modelList, cmd = m.modelList.Update(msg)
-		return m, cmd
+		return m, tea.Batch(cmd, m.detailsCmd())
 
 	case tea.WindowSizeMsg:
-		m.modelList.SetSize(msg.Width, msg.Height)
+		m.SetWidth(msg.Width)
+		m.SetHeight(msg.Height)
 		return m, nil
 
 	case spinner.TickMsg:
@@ -373,15 +829,158 @@ func (m ModelChooser) Update(msg tea.Msg) (ModelChooser, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// retryingView renders the pending retry, such as " (retrying 2/3…)", if any
+func (m ModelChooser) retryingView() string {
+	if m.retrying == nil {
+		return ""
+	}
+	return fmt.Sprintf(" (retrying %d/%d…)", m.retrying.Attempt, m.retrying.MaxAttempts)
+}
+
 // View renders the ModelChooser's view.
 func (m ModelChooser) View() string {
 	if m.lastError != nil {
 		return fmt.Sprintf("ERROR: %s", m.lastError.Error())
-	} else if m.isFetching {
-		return m.spinner.View() + " " + m.Waiting
+	} else if m.Accessible {
+		return m.accessibleView()
+	} else if m.isFetching && !m.fetched {
+		return m.spinner.View() + " " + m.Waiting + m.retryingView()
 	}
+	status := m.pullView()
 	if len(m.listedModels) == 0 {
+		if status != "" {
+			return status
+		}
 		return "<empty>"
 	}
-	return m.modelList.View()
+	if status != "" {
+		if m.height > 0 {
+			m.modelList.SetHeight(max(m.height-lipgloss.Height(status), 0))
+		}
+		return lipgloss.JoinVertical(lipgloss.Left, status, m.listView())
+	}
+	return m.listView()
+}
+
+// pullView renders the progress of a pull, its failure, or a prompt to pull a
+// missing model, if any
+func (m ModelChooser) pullView() string {
+	switch {
+	case m.pulling != nil:
+		view := fmt.Sprintf("Pulling %s: %s", m.pulling.Model, m.pulling.Status)
+		if m.pulling.Total > 0 {
+			if m.Accessible {
+				view += fmt.Sprintf(" %.0f%%", 100*m.pulling.Percent())
+			} else {
+				view += " " + m.pullBar.ViewAs(m.pulling.Percent())
+			}
+		}
+		return view
+	case m.pullFailed != nil:
+		return fmt.Sprintf("Failed to pull %s: %s", m.pullFailed.Model, m.pullFailed.Error)
+	}
+	if missing := m.MissingModel(); missing != "" {
+		return fmt.Sprintf("%s is not installed; press p to pull it", missing)
+	}
+	return ""
+}
+
+// listView renders the list, with the details pane beside it if shown
+func (m ModelChooser) listView() string {
+	if !m.ShowDetails {
+		return m.modelList.View()
+	}
+	detailsWidth := max(m.width-m.listWidth()-modelDetailsStyle.GetHorizontalFrameSize(), 1)
+	details := lipgloss.NewStyle().Width(detailsWidth).MaxHeight(max(m.height, 1)).Render(m.detailsView())
+	return lipgloss.JoinHorizontal(lipgloss.Top, m.modelList.View(), modelDetailsStyle.Render(details))
+}
+
+// detailsView renders the details of the highlighted model, as plain text
+func (m ModelChooser) detailsView() string {
+	model := m.HighlightedModel()
+	if model == nil {
+		return ""
+	}
+	details := m.details[model.Name]
+	switch {
+	case details == nil || (details.info == nil && details.err == nil):
+		return model.Name + "\nLoading details..."
+	case details.err != nil:
+		return model.Name + "\nERROR: " + details.err.Error()
+	}
+	info := details.info
+
+	var sb strings.Builder
+	sb.WriteString(model.Name + "\n")
+	var facts []string
+	for _, fact := range [][2]string{
+		{"Family", info.Details.Family},
+		{"Parameters", info.Details.ParameterSize},
+		{"Quantization", info.Details.QuantizationLevel},
+		{"Format", info.Details.Format},
+	} {
+		if fact[1] != "" {
+			facts = append(facts, fact[0]+": "+fact[1])
+		}
+	}
+	if len(facts) > 0 {
+		sb.WriteString(strings.Join(facts, ", ") + "\n")
+	}
+	for _, section := range [][2]string{
+		{"Parameters", info.Parameters},
+		{"System", info.System},
+		{"Template", info.Template},
+		{"License", info.License},
+	} {
+		if text := strings.TrimSpace(section[1]); text != "" {
+			sb.WriteString("\n" + section[0] + ":\n" + firstLines(text, modelDetailsSectionLines) + "\n")
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// firstLines returns the first n lines of text, marking any omitted
+func firstLines(text string, n int) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= n {
+		return text
+	}
+	return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n… %d more lines", len(lines)-n)
+}
+
+// accessibleView renders the chooser as a numbered list, without spinners or highlighting
+func (m ModelChooser) accessibleView() string {
+	if m.isFetching && !m.fetched {
+		return m.Waiting + m.retryingView() + "\n"
+	}
+	status := m.pullView()
+	if len(m.listedModels) == 0 {
+		if status != "" {
+			return "No models available.\n" + status + "\n"
+		}
+		return "No models available.\n"
+	}
+	var sb strings.Builder
+	sb.WriteString(m.modelList.Title + "\n")
+	if status != "" {
+		sb.WriteString(status + "\n")
+	}
+	cursor := m.modelList.Index()
+	for i, item := range m.modelList.Items() {
+		listItem, ok := item.(modelChooserListItem)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&sb, "%d of %d: %s, %s", i+1, len(m.listedModels), listItem.title, listItem.desc)
+		if i == cursor {
+			sb.WriteString(" (current)")
+		}
+		sb.WriteString("\n")
+	}
+	if m.ShowDetails {
+		sb.WriteString("Details:\n" + m.detailsView() + "\n")
+	}
+	bindings := append([]key.Binding{m.modelList.KeyMap.CursorUp, m.modelList.KeyMap.CursorDown}, modelChooserExtraKeyBindings...)
+	sb.WriteString(accessibleHelp(bindings) + "\n")
+	return sb.String()
 }
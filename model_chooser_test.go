@@ -0,0 +1,260 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+	ollama "github.com/ollama/ollama/api"
+)
+
+func TestModelChooserDetails(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.AddModel("llama3.2", 1000)
+	srv.AddModel("qwen2.5", 2000)
+
+	chooser := ollamatea.NewModelChooser(srv.URL)
+	chooser.Accessible = true
+	update := func(msg tea.Msg) tea.Cmd {
+		var cmd tea.Cmd
+		chooser, cmd = chooser.Update(msg)
+		return cmd
+	}
+	update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	msg := ollamateatest.Run(t, update, chooser.Init(), ollamateatest.MatchType[ollamatea.FetchModelListResponseMsg](), 0)
+	update(msg)
+	if view := chooser.View(); strings.Contains(view, "Details:") {
+		t.Errorf("expected no details until toggled, got %q", view)
+	}
+
+	cmd := update(tea.KeyMsg{Type: tea.KeyTab})
+	if !chooser.ShowDetails || cmd == nil {
+		t.Fatal("expected tab to show the details")
+	}
+	if view := chooser.View(); !strings.Contains(view, "Details:\nllama3.2\nLoading details...") {
+		t.Errorf("expected the details loading in the view %q", view)
+	}
+	msg = ollamateatest.Run(t, update, cmd, ollamateatest.MatchType[ollamatea.ModelShownMsg](), 0)
+	update(msg)
+	if view := chooser.View(); !strings.Contains(view, "Details:\nllama3.2\n") || !strings.Contains(view, "Template:\n{{ .Prompt }}\n") {
+		t.Errorf("expected the details in the view %q", view)
+	}
+
+	// moving the cursor fetches the next model's details
+	cmd = update(tea.KeyMsg{Type: tea.KeyDown})
+	msg = ollamateatest.Run(t, update, cmd, ollamateatest.MatchType[ollamatea.ModelShownMsg](), 0)
+	if shown := msg.(ollamatea.ModelShownMsg); shown.Model != "qwen2.5" {
+		t.Errorf("expected qwen2.5 shown, got %q", shown.Model)
+	}
+
+	update(tea.KeyMsg{Type: tea.KeyTab})
+	if view := chooser.View(); chooser.ShowDetails || strings.Contains(view, "Details:") {
+		t.Errorf("expected tab to hide the details, got %q", view)
+	}
+}
+
+func TestModelChooserPull(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.AddModel("llama3.2", 1000)
+
+	chooser := ollamatea.NewModelChooser(srv.URL)
+	chooser.Accessible = true
+	chooser.SetSelectionByName("qwen2.5")
+	var progress []string
+	update := func(msg tea.Msg) tea.Cmd {
+		var cmd tea.Cmd
+		chooser, cmd = chooser.Update(msg)
+		if _, ok := msg.(ollamatea.PullProgressMsg); ok && chooser.IsPulling() {
+			view := chooser.View()
+			progress = append(progress, view[strings.Index(view, "Pulling"):strings.Index(view, "\n1 of")])
+		}
+		return cmd
+	}
+	update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	msg := ollamateatest.Run(t, update, chooser.Init(), ollamateatest.MatchType[ollamatea.FetchModelListResponseMsg](), 0)
+	update(msg)
+	if missing := chooser.MissingModel(); missing != "qwen2.5" {
+		t.Errorf("expected qwen2.5 missing, got %q", missing)
+	}
+	if view := chooser.View(); !strings.Contains(view, "qwen2.5 is not installed; press p to pull it\n") {
+		t.Errorf("expected a prompt to pull in the view %q", view)
+	}
+
+	cmd := update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	msg = ollamateatest.Run(t, update, cmd, ollamateatest.MatchType[ollamatea.FetchModelListResponseMsg](), 0)
+	update(msg)
+	halfway := slices.IndexFunc(progress, func(line string) bool { return strings.HasSuffix(line, " 50%") })
+	if len(progress) == 0 || progress[0] != "Pulling qwen2.5: pulling manifest" || halfway < 0 ||
+		progress[len(progress)-1] != "Pulling qwen2.5: success" {
+		t.Errorf("unexpected progress %q", progress)
+	}
+	if model := chooser.HighlightedModel(); chooser.IsPulling() || model == nil || model.Name != "qwen2.5" || chooser.MissingModel() != "" {
+		t.Errorf("expected the pulled model highlighted, got %+v", model)
+	}
+}
+
+func TestModelChooserStopPull(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	chooser := ollamatea.NewModelChooser(srv.URL)
+	chooser.Accessible = true
+	update := func(msg tea.Msg) tea.Cmd {
+		var cmd tea.Cmd
+		chooser, cmd = chooser.Update(msg)
+		return cmd
+	}
+	update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	update(ollamateatest.Run(t, update, chooser.Init(), ollamateatest.MatchType[ollamatea.FetchModelListResponseMsg](), 0))
+
+	// pulling another model replaces the pull, ignoring the first's progress
+	first := update(chooser.PullModelMsg("llama3.2"))
+	cmd := update(chooser.PullModelMsg("qwen2.5"))
+	if msg := first(); msg != nil && update(msg) != nil {
+		t.Errorf("expected the replaced pull's %+v ignored", msg)
+	}
+	progress, ok := cmd().(ollamatea.PullProgressMsg)
+	if !ok || progress.Model != "qwen2.5" {
+		t.Fatalf("expected qwen2.5 pulling, got %+v", progress)
+	}
+	next := update(progress)
+
+	// closing the chooser stops the pull, rather than blocking on the dropped Next
+	update(tea.KeyMsg{Type: tea.KeyEsc})
+	if chooser.IsPulling() {
+		t.Error("expected esc to stop the pull")
+	}
+	for i := 0; next != nil; i++ {
+		stopped := make(chan tea.Msg)
+		go func() { stopped <- next() }()
+		select {
+		case msg := <-stopped:
+			if progress, ok := msg.(ollamatea.PullProgressMsg); ok && i < 10 {
+				if update(progress) != nil || chooser.IsPulling() {
+					t.Fatal("expected the stopped pull's progress ignored")
+				}
+				next = progress.Next()
+			} else {
+				next = nil
+			}
+		case <-time.After(ollamateatest.DefaultTimeout):
+			t.Fatal("expected the stopped pull to end")
+		}
+	}
+}
+
+func TestModelChooserRefresh(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.AddModel("llama3.2", 1000)
+
+	chooser := ollamatea.NewModelChooser(srv.URL)
+	chooser.Accessible = true
+	chooser.RefreshInterval = 20 * time.Millisecond
+	update := func(msg tea.Msg) tea.Cmd {
+		var cmd tea.Cmd
+		chooser, cmd = chooser.Update(msg)
+		return cmd
+	}
+	update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	msg := ollamateatest.Run(t, update, chooser.Init(), ollamateatest.MatchType[ollamatea.FetchModelListResponseMsg](), 0)
+	cmd := update(msg)
+
+	// the list is refetched after the interval, showing models added meanwhile
+	srv.AddModel("qwen2.5", 2000)
+	listed := func(n int) ollamateatest.Matcher {
+		return func(msg tea.Msg) bool {
+			resp, ok := msg.(ollamatea.FetchModelListResponseMsg)
+			return ok && len(resp.Models) == n
+		}
+	}
+	update(ollamateatest.Run(t, update, cmd, listed(2), 0))
+	update(tea.KeyMsg{Type: tea.KeyDown})
+	if view := chooser.View(); !strings.Contains(view, "2 of 2: qwen2.5") {
+		t.Fatalf("expected the added model in the view %q", view)
+	}
+
+	// r refreshes at once, keeping the highlighted model
+	chooser.RefreshInterval = 0
+	ollamatea.DeleteModelCmd(srv.URL, "llama3.2")()
+	cmd = update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	update(ollamateatest.Run(t, update, cmd, listed(1), 0))
+	if model := chooser.HighlightedModel(); model == nil || model.Name != "qwen2.5" {
+		t.Errorf("expected qwen2.5 still highlighted, got %+v", model)
+	}
+	if view := chooser.View(); strings.Contains(view, "llama3.2") || !strings.Contains(view, "r refresh") {
+		t.Errorf("expected the deleted model gone from the view %q", view)
+	}
+}
+
+func TestModelChooserSort(t *testing.T) {
+	chooser := ollamatea.NewModelChooser("http://localhost:11434")
+	chooser.Accessible = true
+	now := time.Now()
+	chooser, _ = chooser.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	chooser, _ = chooser.Update(ollamatea.FetchModelListResponseMsg{
+		ID: chooser.ID(),
+		Models: []ollama.ListModelResponse{
+			{Name: "qwen2.5", Size: 2000, ModifiedAt: now.Add(-time.Hour)},
+			{Name: "Gemma2", Size: 3000, ModifiedAt: now.Add(-2 * time.Hour)},
+			{Name: "llama3.2", Size: 1000, ModifiedAt: now},
+		},
+	})
+	order := func() []string {
+		var names []string
+		for _, line := range strings.Split(chooser.View(), "\n") {
+			if _, item, ok := strings.Cut(line, " of 3: "); ok {
+				name, _, _ := strings.Cut(item, ",")
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	if got := order(); !slices.Equal(got, []string{"qwen2.5", "Gemma2", "llama3.2"}) {
+		t.Errorf("expected the server's order, got %q", got)
+	}
+
+	chooser.SetSort(ollamatea.ModelSortName)
+	if got := order(); !slices.Equal(got, []string{"Gemma2", "llama3.2", "qwen2.5"}) {
+		t.Errorf("expected sorting by name, got %q", got)
+	}
+	if model := chooser.HighlightedModel(); model == nil || model.Name != "qwen2.5" {
+		t.Errorf("expected qwen2.5 still highlighted, got %+v", model)
+	}
+
+	// s cycles to the next order
+	chooser, _ = chooser.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if got := order(); chooser.Sort() != ollamatea.ModelSortSize || !slices.Equal(got, []string{"Gemma2", "qwen2.5", "llama3.2"}) {
+		t.Errorf("expected sorting by size, got %v %q", chooser.Sort(), got)
+	}
+	if view := chooser.View(); !strings.Contains(view, ", by size\n") || !strings.Contains(view, "s sort") {
+		t.Errorf("expected the sort in the view %q", view)
+	}
+	chooser, _ = chooser.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if got := order(); !slices.Equal(got, []string{"llama3.2", "qwen2.5", "Gemma2"}) {
+		t.Errorf("expected sorting by modification time, got %q", got)
+	}
+	chooser, _ = chooser.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if got := order(); chooser.Sort() != ollamatea.ModelSortServer || !slices.Equal(got, []string{"qwen2.5", "Gemma2", "llama3.2"}) {
+		t.Errorf("expected s to cycle back to the server's order, got %v %q", chooser.Sort(), got)
+	}
+
+	// the server's order returns after any sorts
+	for _, sort := range []ollamatea.ModelSort{ollamatea.ModelSortName, ollamatea.ModelSortSize, ollamatea.ModelSortServer} {
+		chooser.SetSort(sort)
+	}
+	if got := order(); !slices.Equal(got, []string{"qwen2.5", "Gemma2", "llama3.2"}) {
+		t.Errorf("expected the server's order restored, got %q", got)
+	}
+	if view := chooser.View(); strings.Contains(view, ", by ") {
+		t.Errorf("expected no sort in the view %q", view)
+	}
+}
@@ -0,0 +1,153 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	ollama "github.com/ollama/ollama/api"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Model management
+//
+// CopyModelCmd, DeleteModelCmd, and ShowModelCmd manage the models on an
+// Ollama host, so that any application can offer model management from its
+// own UI.  Each returns a typed result message, or a ModelManageErrorMsg.
+
+// Type aliases in this package for convenience
+type (
+	ShowResponse = ollama.ShowResponse
+	ModelDetails = ollama.ModelDetails
+)
+
+// ModelManager is a Backend that manages its models.
+// It is optional; the Ollama API client [ollama.Client] satisfies it.
+type ModelManager interface {
+	Copy(ctx context.Context, req *ollama.CopyRequest) error
+	Delete(ctx context.Context, req *ollama.DeleteRequest) error
+	Show(ctx context.Context, req *ollama.ShowRequest) (*ShowResponse, error)
+}
+
+var _ ModelManager = (*ollama.Client)(nil)
+
+// ModelOp is a model management operation.
+type ModelOp string
+
+const (
+	ModelOpCopy   ModelOp = "copy"   // ModelOpCopy is CopyModelCmd
+	ModelOpDelete ModelOp = "delete" // ModelOpDelete is DeleteModelCmd
+	ModelOpShow   ModelOp = "show"   // ModelOpShow is ShowModelCmd
+)
+
+// ModelCopiedMsg is sent when a CopyModelCmd completes.
+type ModelCopiedMsg struct {
+	OllamaHost  string // Ollama Host which copied the model
+	Source      string // Source model
+	Destination string // Destination model
+}
+
+// ModelDeletedMsg is sent when a DeleteModelCmd completes.
+type ModelDeletedMsg struct {
+	OllamaHost string // Ollama Host which deleted the model
+	Model      string // Model which was deleted
+}
+
+// ModelShownMsg is sent when a ShowModelCmd completes.
+type ModelShownMsg struct {
+	OllamaHost string        // Ollama Host which showed the model
+	Model      string        // Model which was shown
+	Info       *ShowResponse // Info is the model's details, Modelfile, template, and parameters
+}
+
+// ModelManageErrorMsg is sent when a CopyModelCmd, DeleteModelCmd, or ShowModelCmd fails.
+type ModelManageErrorMsg struct {
+	OllamaHost string  // Ollama Host generating the error
+	Op         ModelOp // Op is the failed operation
+	Model      string  // Model of the operation; for copies, the Source
+	Error      error   // Error returned
+}
+
+// CopyModelCmd returns a command which copies the source model to destination
+// on the Ollama host.  It returns a [ModelCopiedMsg], or a [ModelManageErrorMsg]
+// if there is an error.
+func CopyModelCmd(ollamaHost string, source string, destination string) tea.Cmd {
+	return func() tea.Msg {
+		err := manageModel(nil, ollamaHost, ModelOpCopy, source, func(ctx context.Context, manager ModelManager) error {
+			return manager.Copy(ctx, &ollama.CopyRequest{Source: source, Destination: destination})
+		})
+		if err != nil {
+			return ModelManageErrorMsg{OllamaHost: ollamaHost, Op: ModelOpCopy, Model: source, Error: err}
+		}
+		return ModelCopiedMsg{OllamaHost: ollamaHost, Source: source, Destination: destination}
+	}
+}
+
+// DeleteModelCmd returns a command which deletes the model from the Ollama host.
+// It returns a [ModelDeletedMsg], or a [ModelManageErrorMsg] if there is an error.
+func DeleteModelCmd(ollamaHost string, model string) tea.Cmd {
+	return func() tea.Msg {
+		err := manageModel(nil, ollamaHost, ModelOpDelete, model, func(ctx context.Context, manager ModelManager) error {
+			return manager.Delete(ctx, &ollama.DeleteRequest{Model: model})
+		})
+		if err != nil {
+			return ModelManageErrorMsg{OllamaHost: ollamaHost, Op: ModelOpDelete, Model: model, Error: err}
+		}
+		return ModelDeletedMsg{OllamaHost: ollamaHost, Model: model}
+	}
+}
+
+// ShowModelCmd returns a command which fetches the information of the model on
+// the Ollama host.  It returns a [ModelShownMsg], or a [ModelManageErrorMsg] if
+// there is an error.
+func ShowModelCmd(ollamaHost string, model string) tea.Cmd {
+	return showModelCmd(nil, ollamaHost, model)
+}
+
+// showModelCmd implements ShowModelCmd with the backend, or the host's shared
+// Backend if it is nil
+func showModelCmd(backend Backend, ollamaHost string, model string) tea.Cmd {
+	return func() tea.Msg {
+		var info *ShowResponse
+		err := manageModel(backend, ollamaHost, ModelOpShow, model, func(ctx context.Context, manager ModelManager) (err error) {
+			info, err = manager.Show(ctx, &ollama.ShowRequest{Model: model})
+			return err
+		})
+		if err != nil {
+			return ModelManageErrorMsg{OllamaHost: ollamaHost, Op: ModelOpShow, Model: model, Error: err}
+		}
+		return ModelShownMsg{OllamaHost: ollamaHost, Model: model, Info: info}
+	}
+}
+
+// manageModel performs the model management op with the backend, or the
+// host's shared Backend if it is nil
+func manageModel(backend Backend, ollamaHost string, op ModelOp, model string, fn func(context.Context, ModelManager) error) error {
+	if backend == nil {
+		sharedBackend, err := SharedBackend(ollamaHost)
+		if err != nil {
+			logger().Error("model "+string(op)+" failed", "host", ollamaHost, "model", model, "error", err)
+			return err
+		}
+		backend = sharedBackend
+	}
+	manager, ok := backend.(ModelManager)
+	if !ok {
+		return fmt.Errorf("backend %T does not support model management", backend)
+	}
+
+	ctx, span := startSpan(context.Background(), "ollamatea.model."+string(op),
+		Attr("ollamatea.host", ollamaHost),
+		Attr("ollamatea.model", model))
+	defer span.End()
+	if err := fn(ctx, manager); err != nil {
+		err = ClassifyError(err, ollamaHost, model)
+		logger().Error("model "+string(op)+" failed", "host", ollamaHost, "model", model, "error", err)
+		span.RecordError(err)
+		return err
+	}
+	logger().Info("model "+string(op)+" done", "host", ollamaHost, "model", model)
+	return nil
+}
@@ -0,0 +1,130 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	ollama "github.com/ollama/ollama/api"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Model creation
+//
+// CreateModelCmd creates a model from a Modelfile, streaming its progress.
+// Each CreateProgressMsg must be answered with its Next command to receive
+// the following message, until the CreateDoneMsg:
+//
+//	case ollamatea.CreateProgressMsg:
+//		m.status = msg.Status
+//		return m, msg.Next()
+
+// ModelCreator is a Backend that creates models from a Modelfile.
+// It is optional; the Ollama API client [ollama.Client] satisfies it.
+type ModelCreator interface {
+	Create(ctx context.Context, req *ollama.CreateRequest, fn ollama.CreateProgressFunc) error
+}
+
+var _ ModelCreator = (*ollama.Client)(nil)
+
+// CreateProgressMsg is sent as a CreateModelCmd progresses.
+type CreateProgressMsg struct {
+	OllamaHost string // Ollama Host creating the model
+	Model      string // Model being created
+	Status     string // Status of the step, such as "writing manifest"
+	Digest     string // Digest of the layer being transferred, if any
+	Total      int64  // Total bytes of the layer, if any
+	Completed  int64  // Completed bytes of the layer, if any
+
+	updates <-chan tea.Msg
+}
+
+// Next returns the command which waits for the message following this one.
+func (msg CreateProgressMsg) Next() tea.Cmd {
+	return waitForProgress(msg.updates)
+}
+
+// Percent returns the completed fraction of the layer, from 0 to 1.
+// Returns 0 if the step has no Total.
+func (msg CreateProgressMsg) Percent() float64 {
+	if msg.Total <= 0 {
+		return 0
+	}
+	return float64(msg.Completed) / float64(msg.Total)
+}
+
+// CreateDoneMsg is sent when a CreateModelCmd completes.
+type CreateDoneMsg struct {
+	OllamaHost string // Ollama Host which created the model
+	Model      string // Model which was created
+	Error      error  // Error, if the creation failed
+}
+
+// CreateModelCmd returns a command which creates the model on the Ollama host
+// from the modelfile.  It returns a [CreateProgressMsg] for each step, whose
+// Next command must be run to continue, and finally a [CreateDoneMsg].
+func CreateModelCmd(ollamaHost string, model string, modelfile string) tea.Cmd {
+	return func() tea.Msg {
+		updates := make(chan tea.Msg)
+		go createModel(ollamaHost, model, modelfile, updates)
+		return <-updates
+	}
+}
+
+// waitForProgress returns a command which waits for the next creation or pull message
+func waitForProgress(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		if updates == nil {
+			return nil
+		}
+		return <-updates
+	}
+}
+
+// createModel performs the creation, sending each message on updates
+func createModel(ollamaHost string, model string, modelfile string, updates chan tea.Msg) {
+	done := CreateDoneMsg{OllamaHost: ollamaHost, Model: model}
+	defer func() {
+		updates <- done
+	}()
+
+	backend, err := SharedBackend(ollamaHost)
+	if err != nil {
+		logger().Error("model create failed", "host", ollamaHost, "model", model, "error", err)
+		done.Error = err
+		return
+	}
+	creator, ok := backend.(ModelCreator)
+	if !ok {
+		done.Error = fmt.Errorf("backend %T does not support creating models", backend)
+		return
+	}
+
+	ctx, span := startSpan(context.Background(), "ollamatea.model.create",
+		Attr("ollamatea.host", ollamaHost),
+		Attr("ollamatea.model", model))
+	defer span.End()
+	req := &ollama.CreateRequest{Model: model, Modelfile: modelfile}
+	err = creator.Create(ctx, req, func(resp ollama.ProgressResponse) error {
+		updates <- CreateProgressMsg{
+			OllamaHost: ollamaHost,
+			Model:      model,
+			Status:     resp.Status,
+			Digest:     resp.Digest,
+			Total:      resp.Total,
+			Completed:  resp.Completed,
+			updates:    updates,
+		}
+		return nil
+	})
+	if err != nil {
+		err = ClassifyError(err, ollamaHost, model)
+		logger().Error("model create failed", "host", ollamaHost, "model", model, "error", err)
+		span.RecordError(err)
+		done.Error = err
+		return
+	}
+	logger().Info("model create done", "host", ollamaHost, "model", model)
+}
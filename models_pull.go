@@ -0,0 +1,167 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	ollama "github.com/ollama/ollama/api"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Model pulling
+//
+// PullModelCmd downloads a model from the Ollama library, streaming its
+// progress like CreateModelCmd.  Each PullProgressMsg must be answered with its
+// Next command to receive the following message, until the PullDoneMsg:
+//
+//	case ollamatea.PullProgressMsg:
+//		m.progress = msg.Percent()
+//		return m, msg.Next()
+//
+// A ModelChooser pulls models itself; see ModelChooser.PullModelMsg.  It owns
+// the context of its pull, cancelling it if the chooser is closed or pulls
+// another model, so the download stops rather than waiting on a dropped Next.
+
+// ModelPuller is a Backend that pulls models from a registry.
+// It is optional; the Ollama API client [ollama.Client] satisfies it.
+type ModelPuller interface {
+	Pull(ctx context.Context, req *ollama.PullRequest, fn ollama.PullProgressFunc) error
+}
+
+var _ ModelPuller = (*ollama.Client)(nil)
+
+// PullProgressMsg is sent as a PullModelCmd progresses.
+type PullProgressMsg struct {
+	ID         int64  // ID of the ModelChooser pulling the model, or 0 for PullModelCmd
+	OllamaHost string // Ollama Host pulling the model
+	Model      string // Model being pulled
+	Status     string // Status of the step, such as "pulling manifest"
+	Digest     string // Digest of the layer being downloaded, if any
+	Total      int64  // Total bytes of the layer, if any
+	Completed  int64  // Completed bytes of the layer, if any
+
+	ctx     context.Context
+	updates <-chan tea.Msg
+}
+
+// Next returns the command which waits for the message following this one.
+func (msg PullProgressMsg) Next() tea.Cmd {
+	return waitForPull(msg.ctx, msg.updates)
+}
+
+// Percent returns the completed fraction of the layer, from 0 to 1.
+// Returns 0 if the step has no Total.
+func (msg PullProgressMsg) Percent() float64 {
+	if msg.Total <= 0 {
+		return 0
+	}
+	return float64(msg.Completed) / float64(msg.Total)
+}
+
+// PullDoneMsg is sent when a PullModelCmd completes.
+type PullDoneMsg struct {
+	ID         int64  // ID of the ModelChooser which pulled the model, or 0 for PullModelCmd
+	OllamaHost string // Ollama Host which pulled the model
+	Model      string // Model which was pulled
+	Error      error  // Error, if the pull failed
+}
+
+// PullModelCmd returns a command which pulls the model to the Ollama host.
+// It returns a [PullProgressMsg] for each step, whose Next command must be
+// run to continue, and finally a [PullDoneMsg].
+func PullModelCmd(ollamaHost string, model string) tea.Cmd {
+	return pullModelCmd(context.Background(), nil, ollamaHost, model, 0)
+}
+
+// pullModelCmd implements PullModelCmd with the backend, or the host's shared
+// Backend if it is nil, and the ID of its messages.  Once ctx is done, the pull
+// is stopped and no more messages are sent.
+func pullModelCmd(ctx context.Context, backend Backend, ollamaHost string, model string, id int64) tea.Cmd {
+	return func() tea.Msg {
+		updates := make(chan tea.Msg)
+		go pullModel(ctx, backend, ollamaHost, model, id, updates)
+		return waitForPull(ctx, updates)()
+	}
+}
+
+// waitForPull returns a command which waits for the next message on updates,
+// returning nil once ctx is done
+func waitForPull(ctx context.Context, updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		if updates == nil {
+			return nil
+		}
+		select {
+		case msg := <-updates:
+			return msg
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// pullModel performs the pull, sending each message on updates until ctx is done
+func pullModel(ctx context.Context, backend Backend, ollamaHost string, model string, id int64, updates chan tea.Msg) {
+	done := PullDoneMsg{ID: id, OllamaHost: ollamaHost, Model: model}
+	defer func() {
+		select {
+		case updates <- done:
+		case <-ctx.Done():
+		}
+	}()
+
+	if backend == nil {
+		sharedBackend, err := SharedBackend(ollamaHost)
+		if err != nil {
+			logger().Error("model pull failed", "host", ollamaHost, "model", model, "error", err)
+			done.Error = err
+			return
+		}
+		backend = sharedBackend
+	}
+	puller, ok := backend.(ModelPuller)
+	if !ok {
+		done.Error = fmt.Errorf("backend %T does not support pulling models", backend)
+		return
+	}
+
+	ctx, span := startSpan(ctx, "ollamatea.model.pull",
+		Attr("ollamatea.host", ollamaHost),
+		Attr("ollamatea.model", model))
+	defer span.End()
+	err := puller.Pull(ctx, &ollama.PullRequest{Model: model}, func(resp ollama.ProgressResponse) error {
+		msg := PullProgressMsg{
+			ID:         id,
+			OllamaHost: ollamaHost,
+			Model:      model,
+			Status:     resp.Status,
+			Digest:     resp.Digest,
+			Total:      resp.Total,
+			Completed:  resp.Completed,
+			ctx:        ctx,
+			updates:    updates,
+		}
+		select {
+		case updates <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil && ctx.Err() != nil {
+		logger().Info("model pull stopped", "host", ollamaHost, "model", model)
+		done.Error = ctx.Err()
+		return
+	}
+	if err != nil {
+		err = ClassifyError(err, ollamaHost, model)
+		logger().Error("model pull failed", "host", ollamaHost, "model", model, "error", err)
+		span.RecordError(err)
+		done.Error = err
+		return
+	}
+	logger().Info("model pull done", "host", ollamaHost, "model", model)
+}
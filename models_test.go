@@ -0,0 +1,81 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestModelManagementCmds(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.AddModel("llama3.2", 1000)
+
+	msg := ollamatea.CopyModelCmd(srv.URL, "llama3.2", "mine")()
+	if copied, ok := msg.(ollamatea.ModelCopiedMsg); !ok || copied.Destination != "mine" {
+		t.Fatalf("expected ModelCopiedMsg, got %#v", msg)
+	}
+	if !slices.Equal(srv.Models(), []string{"llama3.2", "mine"}) {
+		t.Errorf("unexpected models after copy: %v", srv.Models())
+	}
+
+	msg = ollamatea.ShowModelCmd(srv.URL, "mine")()
+	shown, ok := msg.(ollamatea.ModelShownMsg)
+	if !ok || shown.Info == nil || shown.Info.Modelfile == "" {
+		t.Fatalf("expected ModelShownMsg, got %#v", msg)
+	}
+
+	msg = ollamatea.DeleteModelCmd(srv.URL, "mine")()
+	if _, ok := msg.(ollamatea.ModelDeletedMsg); !ok {
+		t.Fatalf("expected ModelDeletedMsg, got %#v", msg)
+	}
+	if !slices.Equal(srv.Models(), []string{"llama3.2"}) {
+		t.Errorf("unexpected models after delete: %v", srv.Models())
+	}
+
+	msg = ollamatea.DeleteModelCmd(srv.URL, "mine")()
+	errMsg, ok := msg.(ollamatea.ModelManageErrorMsg)
+	if !ok || errMsg.Op != ollamatea.ModelOpDelete {
+		t.Fatalf("expected ModelManageErrorMsg, got %#v", msg)
+	}
+	if kind := ollamatea.ErrorKindOf(errMsg.Error); kind != ollamatea.ErrorKindModelNotFound {
+		t.Errorf("expected model not found, got %v", kind)
+	}
+}
+
+func TestCreateModelCmd(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	var statuses []string
+	cmd := ollamatea.CreateModelCmd(srv.URL, "mine", "FROM llama3.2\nSYSTEM You are terse.\n")
+	for cmd != nil {
+		switch msg := cmd().(type) {
+		case ollamatea.CreateProgressMsg:
+			statuses = append(statuses, msg.Status)
+			cmd = msg.Next()
+		case ollamatea.CreateDoneMsg:
+			if msg.Error != nil {
+				t.Fatalf("unexpected error: %v", msg.Error)
+			}
+			cmd = nil
+		default:
+			t.Fatalf("unexpected message %#v", msg)
+		}
+	}
+	if len(statuses) == 0 || statuses[len(statuses)-1] != "success" {
+		t.Errorf("unexpected progress: %v", statuses)
+	}
+	if !slices.Contains(srv.Models(), "mine") {
+		t.Errorf("expected the model to be created, got %v", srv.Models())
+	}
+
+	msg := ollamatea.CreateModelCmd(srv.URL, "bad", "SYSTEM no base\n")()
+	if done, ok := msg.(ollamatea.CreateDoneMsg); !ok || done.Error == nil {
+		t.Errorf("expected a failed CreateDoneMsg, got %#v", msg)
+	}
+}
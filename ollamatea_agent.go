@@ -0,0 +1,372 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea messages
+
+// StartAgentMsg starts a ToolAgent run with a new user prompt.
+type StartAgentMsg struct {
+	ID     int64  // ID is the agent ID to start
+	Prompt string // Prompt is the user message to continue the conversation with
+}
+
+// StopAgentMsg stops a ToolAgent run.
+type StopAgentMsg struct {
+	ID int64 // ID is the agent ID to stop
+}
+
+// AgentStepKind is the kind of an AgentStepMsg.
+type AgentStepKind int
+
+const (
+	AgentStepResponse   AgentStepKind = iota // partial assistant text
+	AgentStepToolCall                        // the model requested a tool call
+	AgentStepToolResult                      // a tool call completed
+)
+
+// String returns the name of the AgentStepKind
+func (k AgentStepKind) String() string {
+	switch k {
+	case AgentStepResponse:
+		return "response"
+	case AgentStepToolCall:
+		return "tool_call"
+	case AgentStepToolResult:
+		return "tool_result"
+	default:
+		return fmt.Sprintf("AgentStepKind(%d)", int(k))
+	}
+}
+
+// AgentStepMsg is the message generated for each step of a ToolAgent run:
+// streamed response text, tool calls, and their results.
+type AgentStepMsg struct {
+	ID        int64         // ID is the agent ID corresponding to the step
+	CreatedAt time.Time     // CreatedAt is the timestamp of the step
+	Step      int           // Step is the 1-based chat round within the run
+	Kind      AgentStepKind // Kind of step
+	Content   string        // Content is the response text or tool result
+	ToolCall  *ToolCall     // ToolCall is the tool call, for tool steps
+	Error     error         // Error is the tool's error, for failed tool results
+}
+
+// AgentDoneMsg is the message generated when a ToolAgent run is complete.
+type AgentDoneMsg struct {
+	ID        int64     // ID is the agent ID corresponding to the run
+	CreatedAt time.Time // CreatedAt is the timestamp of completion
+	Response  string    // Response is the final answer
+	Steps     int       // Steps is the number of chat rounds taken
+	Error     error     // Error is set if the run failed
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+const defaultAgentMaxSteps = 8
+
+// ToolAgent runs a chat conversation with tools, automatically dispatching
+// the model's tool calls through its ToolRegistry and continuing until the
+// model gives a final answer without tool calls.
+//
+// Like Session, ToolAgent methods take pointer receivers, and its Init
+// command must be dispatched for its messages to be delivered.
+type ToolAgent struct {
+	Host     string                 // Ollama Host -- really the service's URL
+	Model    string                 // Ollama LLM model; it must support tools
+	System   string                 // System prompt, sent as the first message
+	Options  map[string]interface{} // Options lists model-specific options
+	Backend  Backend                // Backend to chat with; if nil, SharedBackend(Host) is used.  It must be a Chatter.
+	Registry *ToolRegistry          // Registry of available tools
+	MaxSteps int                    // MaxSteps limits chat rounds per run (default: 8)
+	Memory   Memory                 // Memory selects the Messages sent with each request; if nil, all are sent
+
+	// AutoTitle, if true, generates a title for the conversation after the first
+	// successful run, delivered as a ConversationTitledMsg.
+	AutoTitle bool
+
+	Messages []Message // Messages is the conversation history, without the System prompt
+
+	// Private
+	id         int64
+	parentCtx  context.Context // parent of each run's context; see WithContext
+	cancelFunc context.CancelFunc
+	isRunning  bool
+	lastError  error
+	response   string
+	msgCh      chan tea.Msg
+	closed     chan struct{} // closed by Close to stop the message listener
+	titled     bool          // AutoTitle has been started
+}
+
+// NewToolAgent returns a new ToolAgent with the default values and the given registry.
+func NewToolAgent(registry *ToolRegistry) ToolAgent {
+	if registry == nil {
+		registry = NewToolRegistry()
+	}
+	return ToolAgent{
+		Host:     DefaultHost(),
+		Model:    DefaultModel(),
+		System:   DefaultSystemPrompt(),
+		Registry: registry,
+		MaxSteps: defaultAgentMaxSteps,
+		id:       nextSessionID(),
+		msgCh:    make(chan tea.Msg, 100),
+		closed:   make(chan struct{}),
+	}
+}
+
+// ID returns the unique ID of the ToolAgent
+func (a *ToolAgent) ID() int64 {
+	return a.id
+}
+
+// IsRunning returns true if the ToolAgent is currently running
+func (a *ToolAgent) IsRunning() bool {
+	return a.isRunning
+}
+
+// Response returns the final answer of the last run
+func (a *ToolAgent) Response() string {
+	return a.response
+}
+
+// Error returns the last error from the ToolAgent, if any
+func (a *ToolAgent) Error() error {
+	return a.lastError
+}
+
+// Close cancels any run and stops the ToolAgent's message listener,
+// so no goroutines outlive it.  A closed ToolAgent cannot run again.
+func (a *ToolAgent) Close() {
+	if a.cancelFunc != nil {
+		a.cancelFunc()
+		a.cancelFunc = nil
+	}
+	a.isRunning = false
+	if a.closed != nil {
+		select {
+		case <-a.closed:
+		default:
+			close(a.closed)
+		}
+	}
+}
+
+// WithContext sets the parent context of the ToolAgent's runs, returning the ToolAgent.
+// When ctx is done, such as at program shutdown, any run is cancelled.
+func (a *ToolAgent) WithContext(ctx context.Context) *ToolAgent {
+	a.parentCtx = ctx
+	return a
+}
+
+// parentContext returns the parent context of runs
+func (a *ToolAgent) parentContext() context.Context {
+	if a.parentCtx != nil {
+		return a.parentCtx
+	}
+	return context.Background()
+}
+
+// StartAgentMsg returns a StartAgentMsg for this ToolAgent with the prompt
+func (a *ToolAgent) StartAgentMsg(prompt string) tea.Msg {
+	return StartAgentMsg{ID: a.id, Prompt: prompt}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea interface
+
+// Init handles the initialization of a ToolAgent
+func (a *ToolAgent) Init() tea.Cmd {
+	return agentWaitForMsg(a.msgCh, a.closed)
+}
+
+// Update handles BubbleTea messages for the ToolAgent
+func (a *ToolAgent) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case StartAgentMsg:
+		if msg.ID != a.id {
+			return a, nil
+		}
+		if a.cancelFunc != nil {
+			a.cancelFunc()
+			a.cancelFunc = nil
+		}
+		var ctx context.Context
+		ctx, a.cancelFunc = context.WithCancel(a.parentContext())
+		a.isRunning = true
+		a.lastError = nil
+		a.response = ""
+		prompt := msg.Prompt
+		return a, func() tea.Msg {
+			closed := a.closed
+			doneMsg, _ := a.Run(ctx, prompt, func(stepMsg AgentStepMsg) {
+				select {
+				case a.msgCh <- stepMsg:
+				case <-closed:
+				}
+			})
+			select {
+			case a.msgCh <- doneMsg:
+			case <-closed:
+			}
+			return nil
+		}
+
+	case StopAgentMsg:
+		if msg.ID != a.id {
+			return a, nil
+		}
+		if a.cancelFunc != nil {
+			a.cancelFunc()
+			a.cancelFunc = nil
+		}
+		return a, nil
+
+	case AgentStepMsg:
+		if msg.ID != a.id {
+			return a, nil
+		}
+		return a, agentWaitForMsg(a.msgCh, a.closed)
+
+	case AgentDoneMsg:
+		if msg.ID != a.id {
+			return a, nil
+		}
+		a.isRunning = false
+		a.cancelFunc = nil
+		var titleCmd tea.Cmd
+		if a.AutoTitle && !a.titled && msg.Error == nil {
+			a.titled = true
+			id, backend, host, model, messages := a.id, a.Backend, a.Host, a.Model, slices.Clone(a.Messages)
+			titleCmd = func() tea.Msg {
+				return titleConversationMsg(id, backend, host, model, messages)
+			}
+		}
+		return a, tea.Batch(agentWaitForMsg(a.msgCh, a.closed), titleCmd)
+	}
+	return a, nil
+}
+
+// View renders the ToolAgent's view, either an error message or the last response.
+func (a *ToolAgent) View() string {
+	if a.lastError != nil {
+		return fmt.Sprintf("ERROR: %s", a.lastError.Error())
+	}
+	return a.response
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// Run performs a blocking agent run for the prompt, outside of BubbleTea.
+// The prompt and every assistant and tool message are appended to Messages.
+// If onStep is non-nil, it is invoked with each step.
+// Returns the final AgentDoneMsg, with Error also returned if the run failed.
+func (a *ToolAgent) Run(ctx context.Context, prompt string, onStep func(AgentStepMsg)) (AgentDoneMsg, error) {
+	done := func(steps int, err error) (AgentDoneMsg, error) {
+		a.lastError = err
+		return AgentDoneMsg{
+			ID:        a.id,
+			CreatedAt: time.Now(),
+			Response:  a.response,
+			Steps:     steps,
+			Error:     err,
+		}, err
+	}
+	emit := func(step AgentStepMsg) {
+		if onStep != nil {
+			step.ID, step.CreatedAt = a.id, time.Now()
+			onStep(step)
+		}
+	}
+
+	chatter, err := resolveChatter(a.Backend, a.Host)
+	if err != nil {
+		return done(0, err)
+	}
+	registry := a.Registry
+	if registry == nil {
+		registry = NewToolRegistry()
+	}
+	maxSteps := a.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultAgentMaxSteps
+	}
+
+	a.response = ""
+	a.Messages = append(a.Messages, Message{Role: "user", Content: prompt})
+	for step := 1; step <= maxSteps; step++ {
+		messages := a.Messages
+		if a.Memory != nil {
+			if messages, err = a.Memory.Select(ctx, a.Messages); err != nil {
+				return done(step, err)
+			}
+		}
+		req := &ChatRequest{
+			Model:    a.Model,
+			Messages: messages,
+			Tools:    registry.Tools(),
+			Options:  a.Options,
+		}
+		if a.System != "" {
+			req.Messages = append([]Message{{Role: "system", Content: a.System}}, messages...)
+		}
+
+		var sb strings.Builder
+		var toolCalls []ToolCall
+		err := chatter.Chat(ctx, req, func(resp ChatResponse) error {
+			if resp.Message.Content != "" {
+				sb.WriteString(resp.Message.Content)
+				emit(AgentStepMsg{Step: step, Kind: AgentStepResponse, Content: resp.Message.Content})
+			}
+			toolCalls = append(toolCalls, resp.Message.ToolCalls...)
+			return nil
+		})
+		if err != nil {
+			return done(step, ClassifyError(err, a.Host, a.Model))
+		}
+		a.Messages = append(a.Messages, Message{Role: "assistant", Content: sb.String(), ToolCalls: toolCalls})
+
+		if len(toolCalls) == 0 {
+			a.response = sb.String()
+			return done(step, nil)
+		}
+
+		// Dispatch tool calls; failures are reported to the model rather than ending the run
+		for i := range toolCalls {
+			call := &toolCalls[i]
+			emit(AgentStepMsg{Step: step, Kind: AgentStepToolCall, ToolCall: call})
+			result, err := registry.Dispatch(ctx, *call)
+			if err != nil {
+				result = fmt.Sprintf("error: %s", err.Error())
+			}
+			emit(AgentStepMsg{Step: step, Kind: AgentStepToolResult, Content: result, ToolCall: call, Error: err})
+			a.Messages = append(a.Messages, Message{Role: "tool", Content: result})
+			if ctx.Err() != nil {
+				return done(step, ctx.Err())
+			}
+		}
+	}
+	return done(maxSteps, fmt.Errorf("no final answer after %d steps", maxSteps))
+}
+
+// agentWaitForMsg is a command that waits for the agent's messages on the channel
+func agentWaitForMsg(sub chan tea.Msg, closed chan struct{}) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case msg := <-sub:
+			return msg
+		case <-closed:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Response backpressure
+//
+// Streamed responses are delivered to the Session's Update loop through a
+// buffered channel.  When the UI is busy and the buffer fills, the
+// BackpressurePolicy decides whether the stream waits, drops chunks, or
+// coalesces them into the next delivery.  Done responses are never dropped.
+//
+// Independently, a FlushInterval or FlushChunks batches chunks so fast models
+// do not trigger a full re-render for every token.
+
+// DefaultResponseBufferSize is the default capacity of a Session's response buffer.
+const DefaultResponseBufferSize = 100
+
+// BackpressurePolicy is what a Session does with a streamed chunk when its response buffer is full.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock waits for room in the buffer, pausing the stream.
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDrop discards the chunk, so its text is missing from the response.
+	BackpressureDrop
+	// BackpressureCoalesce holds the chunk and merges it into the next delivery.
+	BackpressureCoalesce
+)
+
+// String returns the name of the BackpressurePolicy.
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case BackpressureBlock:
+		return "block"
+	case BackpressureDrop:
+		return "drop"
+	case BackpressureCoalesce:
+		return "coalesce"
+	default:
+		return fmt.Sprintf("BackpressurePolicy(%d)", int(p))
+	}
+}
+
+// responseSender delivers one generation's responses to a Session's respCh,
+// batching them by the Session's FlushInterval and FlushChunks and applying its BackpressurePolicy
+type responseSender struct {
+	m        *Session
+	ctx      context.Context
+	seq      int // the Session's generation
+	ch       chan generateResponseMsg
+	policy   BackpressurePolicy
+	interval time.Duration
+	chunks   int           // FlushChunks
+	stop     chan struct{} // closed to stop the flush loop
+
+	mu        sync.Mutex
+	pending   *generateResponseMsg // batched or coalesced chunks awaiting delivery
+	lastFlush time.Time
+	dropped   int
+	coalesced int
+	sent      bool           // any response has been delivered
+	tokens    []TokenLogprob // of the next response, from setTokens
+}
+
+// newResponseSender returns a responseSender for the generation seq with the context.
+// close must be called when the generation ends.
+func (m *Session) newResponseSender(ctx context.Context, seq int) *responseSender {
+	r := &responseSender{
+		m:         m,
+		ctx:       ctx,
+		seq:       seq,
+		ch:        m.respCh,
+		policy:    m.Backpressure,
+		interval:  m.FlushInterval,
+		chunks:    m.FlushChunks,
+		stop:      make(chan struct{}),
+		lastFlush: time.Now(),
+	}
+	if r.interval > 0 {
+		go r.flushLoop()
+	}
+	return r
+}
+
+// send delivers the response, unless it is batched or ctx is done first
+func (r *responseSender) send(resp GenerateResponse) error {
+	msg := generateResponseMsg{
+		ID:         r.m.id,
+		Seq:        r.seq,
+		CreatedAt:  resp.CreatedAt,
+		Response:   resp.Response,
+		Done:       resp.Done,
+		DoneReason: resp.DoneReason,
+		Context:    resp.Context,
+		Metrics:    resp.Metrics,
+	}
+	if resp.Response != "" {
+		msg.Chunks = 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg.Tokens, r.tokens = r.tokens, nil
+	if r.pending != nil {
+		msg.Response = r.pending.Response + msg.Response
+		msg.Chunks += r.pending.Chunks
+		if r.pending.Tokens != nil {
+			msg.Tokens = append(r.pending.Tokens, msg.Tokens...)
+		}
+		r.pending = nil
+	}
+	if !msg.Done && r.batching(msg) {
+		r.pending = &msg // the flush loop delivers it if no chunk follows soon
+		return nil
+	}
+	return r.deliver(msg)
+}
+
+// setTokens sets the log probabilities of the next response's tokens
+func (r *responseSender) setTokens(tokens []TokenLogprob) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = tokens
+}
+
+// batching returns true if msg, which may batch several chunks, should wait for more
+func (r *responseSender) batching(msg generateResponseMsg) bool {
+	if r.interval <= 0 && r.chunks <= 0 {
+		return false
+	}
+	if r.interval > 0 && time.Since(r.lastFlush) >= r.interval {
+		return false
+	}
+	return r.chunks <= 0 || msg.Chunks < r.chunks
+}
+
+// deliver sends the message according to the policy; r.mu must be held
+func (r *responseSender) deliver(msg generateResponseMsg) error {
+	r.sent = true
+	if r.policy != BackpressureBlock && !msg.Done {
+		select {
+		case r.ch <- msg:
+			r.lastFlush = time.Now()
+		default:
+			if r.policy == BackpressureDrop {
+				r.dropped++
+			} else {
+				r.coalesced++
+				r.pending = &msg
+			}
+		}
+		return nil
+	}
+
+	select {
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	case r.ch <- msg:
+		r.lastFlush = time.Now()
+		return nil
+	}
+}
+
+// flushLoop delivers batched chunks which have waited a FlushInterval
+func (r *responseSender) flushLoop() {
+	ticker := time.NewTicker(max(r.interval/2, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			if r.pending != nil && time.Since(r.lastFlush) >= r.interval {
+				msg := *r.pending
+				r.pending = nil
+				r.deliver(msg)
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// close stops the sender and records the generation's backpressure metrics
+func (r *responseSender) close(model string) {
+	close(r.stop)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending != nil {
+		r.dropped++ // the stream ended without a Done response to carry it
+		r.pending = nil
+	}
+	if r.dropped == 0 && r.coalesced == 0 {
+		return
+	}
+	r.m.logger().Debug("generate backpressure", "session_id", r.m.id, "policy", r.policy.String(),
+		"dropped", r.dropped, "coalesced", r.coalesced)
+	observeChunks(r.m.Host, model, r.dropped, r.coalesced)
+}
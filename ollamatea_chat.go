@@ -0,0 +1,500 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea messages
+
+// StartChatMsg sends a user message to a ChatSession and starts its reply.
+type StartChatMsg struct {
+	ID     int64       // ID is the chat session ID to start
+	Prompt string      // Prompt is the user message; if empty, with no Images, the Messages are replied to as they are
+	Images []ImageData // Images are attached to the user message, if any
+}
+
+// StopChatMsg stops a ChatSession's reply.
+type StopChatMsg struct {
+	ID int64 // ID is the chat session ID to stop
+}
+
+// ChatResponseMsg is the message generated for each streamed part of a ChatSession's reply.
+// The information contained is only partial; see [ChatSession.Response] for the reply so far.
+type ChatResponseMsg struct {
+	ID        int64     // ID is the chat session ID corresponding to the reply
+	CreatedAt time.Time // CreatedAt is the timestamp of the response
+	Content   string    // Content is the reply text in this specific part
+}
+
+// ChatDoneMsg is the message generated when a ChatSession's reply is complete.
+type ChatDoneMsg struct {
+	ID         int64     // ID is the chat session ID corresponding to the reply
+	CreatedAt  time.Time // CreatedAt is the timestamp of the reply
+	Message    Message   // Message is the assistant's reply, with any ToolCalls, appended to the Messages
+	DoneReason string    // DoneReason is the reason the model stopped generating text
+	Metrics    Metrics   // Metrics are the durations and token counts of the reply
+	Error      error     // Error is why the reply failed, if it did; see [OllamaError]
+}
+
+// Stats returns the GenerateStats of the reply
+func (msg ChatDoneMsg) Stats() GenerateStats {
+	return GenerateStats{Metrics: msg.Metrics}
+}
+
+// ToolCallMsg is the message generated for each tool call requested by a
+// ChatSession's reply, following its ChatDoneMsg.  Answer each with
+// [ChatSession.SendToolResult].
+type ToolCallMsg struct {
+	ID       int64    // ID is the chat session ID corresponding to the reply
+	Index    int      // Index is the call's position among the reply's ToolCalls
+	ToolCall ToolCall // ToolCall is the requested call, with its arguments
+}
+
+// ToolResultMsg answers a ToolCallMsg with the tool's result.
+type ToolResultMsg struct {
+	ID       int64    // ID is the chat session ID to answer
+	ToolCall ToolCall // ToolCall is the call answered
+	Content  string   // Content is the tool's result
+	Error    error    // Error, if set, is why the tool failed; it is sent to the model instead of Content
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// ChatSession holds a multi-turn conversation using Ollama's /api/chat endpoint.
+// Unlike Session, which carries a Generate's opaque Context, it keeps the
+// conversation as Messages with roles, sending them all with each request.
+//
+// If Tools are set, the model may request tool calls, each delivered as a
+// ToolCallMsg.  Once every call of a reply is answered with SendToolResult,
+// the next reply starts.  To dispatch tool calls automatically, use a ToolAgent.
+//
+// Like Session, ChatSession methods take pointer receivers, and its Init
+// command must be dispatched for its messages to be delivered.
+type ChatSession struct {
+	Host    string                 // Ollama Host -- really the service's URL
+	Model   string                 // Ollama LLM model.  See https://ollama.com/library
+	System  string                 // System prompt, sent as the first message
+	Options map[string]interface{} // Options lists model-specific options
+	Backend Backend                // Backend to chat with; if nil, SharedBackend(Host) is used.  It must be a Chatter.
+	Memory  Memory                 // Memory selects the Messages sent with each request; if nil, all are sent
+	Tools   []Tool                 // Tools the model may call; see ToolRegistry.Tools
+
+	Messages []Message // Messages is the conversation history, without the System prompt
+
+	// ProgressInterval, if positive, is how often a GenerateProgressMsg, with the
+	// ChatSession's ID, is sent while replying; 0 sends none.
+	ProgressInterval time.Duration
+
+	// Private
+	id           int64
+	parentCtx    context.Context // parent of each reply's context; see WithContext
+	cancelFunc   context.CancelFunc
+	isGenerating bool
+	lastError    error
+	response     string        // reply so far
+	pendingCalls int           // tool calls of the last reply awaiting results
+	stats        GenerateStats // of the last completed reply
+	msgCh        chan tea.Msg  // delivers ChatResponseMsg and ChatDoneMsg to Update
+	closed       chan struct{} // closed by Close to stop the message listener
+
+	progressSeq   int       // counts replies, so stale progress ticks are ignored
+	progressing   bool      // progress ticks are running for progressSeq
+	progressStart time.Time // when the reply started
+	progressCount int       // tokens streamed so far
+}
+
+var _ SessionModel = (*ChatSession)(nil)
+
+// NewChatSession returns a new ChatSession with the default values and no history.
+func NewChatSession() ChatSession {
+	return ChatSession{
+		Host:   DefaultHost(),
+		Model:  DefaultModel(),
+		System: DefaultSystemPrompt(),
+		id:     nextSessionID(),
+		msgCh:  make(chan tea.Msg, DefaultResponseBufferSize),
+		closed: make(chan struct{}),
+	}
+}
+
+// ID returns the unique ID of the ChatSession
+func (c *ChatSession) ID() int64 {
+	return c.id
+}
+
+// IsGenerating returns true if the ChatSession is currently replying
+func (c *ChatSession) IsGenerating() bool {
+	return c.isGenerating
+}
+
+// Response returns the last reply, or the reply so far while generating
+func (c *ChatSession) Response() string {
+	return c.response
+}
+
+// Stats returns the token counts, durations, and rates of the last completed reply
+func (c *ChatSession) Stats() GenerateStats {
+	return c.stats
+}
+
+// Error returns the last error from the ChatSession, if any
+func (c *ChatSession) Error() error {
+	return c.lastError
+}
+
+// Reset clears the conversation, keeping the model, system prompt, and options.
+// It has no effect while generating.
+func (c *ChatSession) Reset() {
+	if c.isGenerating {
+		return
+	}
+	c.Messages = nil
+	c.response = ""
+	c.lastError = nil
+	c.pendingCalls = 0
+}
+
+// Close cancels any reply and stops the ChatSession's message listener,
+// so no goroutines outlive it.  A closed ChatSession cannot chat again.
+func (c *ChatSession) Close() {
+	if c.cancelFunc != nil {
+		c.cancelFunc()
+		c.cancelFunc = nil
+	}
+	c.isGenerating = false
+	if c.closed != nil {
+		select {
+		case <-c.closed:
+		default:
+			close(c.closed)
+		}
+	}
+}
+
+// WithContext sets the parent context of the ChatSession's replies, returning the ChatSession.
+// When ctx is done, such as at program shutdown, any reply is cancelled.
+func (c *ChatSession) WithContext(ctx context.Context) *ChatSession {
+	c.parentCtx = ctx
+	return c
+}
+
+// parentContext returns the parent context of replies
+func (c *ChatSession) parentContext() context.Context {
+	if c.parentCtx != nil {
+		return c.parentCtx
+	}
+	return context.Background()
+}
+
+// Send returns a command which sends the prompt, with any images, and starts the reply.
+func (c *ChatSession) Send(prompt string, images ...ImageData) tea.Cmd {
+	return Cmdize(StartChatMsg{ID: c.id, Prompt: prompt, Images: images})
+}
+
+// SendToolResult returns a command which answers the tool call with its result.
+// If err is set, it is sent to the model instead, so the model may recover.
+func (c *ChatSession) SendToolResult(call ToolCall, result string, err error) tea.Cmd {
+	return Cmdize(ToolResultMsg{ID: c.id, ToolCall: call, Content: result, Error: err})
+}
+
+// PendingToolCalls returns the number of the last reply's tool calls awaiting results.
+func (c *ChatSession) PendingToolCalls() int {
+	return c.pendingCalls
+}
+
+// AddToolResult appends a tool call's result to the Messages.  If err is set, it is
+// sent to the model instead.  Outside of BubbleTea, add a result for each of a reply's
+// ToolCalls, in order, then call Chat with an empty prompt to continue.
+func (c *ChatSession) AddToolResult(result string, err error) {
+	if err != nil {
+		result = fmt.Sprintf("error: %s", err.Error())
+	}
+	c.Messages = append(c.Messages, Message{Role: "tool", Content: result})
+	if c.pendingCalls > 0 {
+		c.pendingCalls--
+	}
+}
+
+// Start returns a command which starts a reply to the Messages as they are,
+// such as to retry after a failure.
+func (c *ChatSession) Start() tea.Cmd {
+	return Cmdize(StartChatMsg{ID: c.id})
+}
+
+// Stop returns a command which stops the ChatSession's reply, if any.
+func (c *ChatSession) Stop() tea.Cmd {
+	return Cmdize(StopChatMsg{ID: c.id})
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea interface
+
+// Init handles the initialization of a ChatSession
+func (c *ChatSession) Init() tea.Cmd {
+	return agentWaitForMsg(c.msgCh, c.closed)
+}
+
+// Update handles BubbleTea messages for the ChatSession.
+// A StartChatMsg is ignored while a reply is being generated; stop it first.
+func (c *ChatSession) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case StartChatMsg:
+		if msg.ID != c.id || c.isGenerating {
+			return c, nil
+		}
+		c.appendPrompt(msg.Prompt, msg.Images)
+		req := c.makeChatRequest()
+		var ctx context.Context
+		ctx, c.cancelFunc = context.WithCancel(c.parentContext())
+		c.isGenerating = true
+		c.lastError = nil
+		c.response = ""
+		msgCh, closed := c.msgCh, c.closed
+		return c, tea.Batch(c.startProgress(), func() tea.Msg {
+			doneMsg, _ := c.reply(ctx, req, func(respMsg ChatResponseMsg) {
+				select {
+				case msgCh <- respMsg:
+				case <-closed:
+				}
+			})
+			select {
+			case msgCh <- doneMsg:
+			case <-closed:
+			}
+			return nil
+		})
+
+	case StopChatMsg:
+		if msg.ID != c.id {
+			return c, nil
+		}
+		logger().Debug("chat stop requested", "session_id", c.id, "generating", c.isGenerating)
+		if c.cancelFunc != nil {
+			c.cancelFunc()
+			c.cancelFunc = nil
+		}
+		return c, nil
+
+	case ChatResponseMsg:
+		if msg.ID != c.id {
+			return c, nil
+		}
+		c.response += msg.Content
+		c.progressCount++
+		return c, agentWaitForMsg(c.msgCh, c.closed)
+
+	case progressTickMsg:
+		if msg.ID != c.id || msg.Seq != c.progressSeq || !c.progressing {
+			return c, nil
+		}
+		return c, tea.Batch(Cmdize(c.progressMsg()), c.progressTickCmd())
+
+	case ChatDoneMsg:
+		if msg.ID != c.id {
+			return c, nil
+		}
+		c.finishReply(msg)
+		cmds := []tea.Cmd{agentWaitForMsg(c.msgCh, c.closed)}
+		if msg.Error == nil {
+			var callCmds []tea.Cmd
+			for i, call := range msg.Message.ToolCalls {
+				callCmds = append(callCmds, Cmdize(ToolCallMsg{ID: c.id, Index: i, ToolCall: call}))
+			}
+			cmds = append(cmds, tea.Sequence(callCmds...))
+		}
+		return c, tea.Batch(cmds...)
+
+	case ToolResultMsg:
+		if msg.ID != c.id {
+			return c, nil
+		}
+		c.AddToolResult(msg.Content, msg.Error)
+		if c.pendingCalls == 0 && !c.isGenerating {
+			return c, c.Start() // every call is answered, so continue
+		}
+		return c, nil
+	}
+	return c, nil
+}
+
+// View renders the ChatSession's view, either an error message or the last reply.
+func (c *ChatSession) View() string {
+	if c.lastError != nil {
+		return fmt.Sprintf("ERROR: %s", c.lastError.Error())
+	}
+	return c.response
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// Chat sends the prompt, with any images, and performs a blocking reply, outside of BubbleTea.
+// If prompt is empty, with no images, the Messages are replied to as they are.
+// If onResponse is non-nil, it is invoked with each streamed part of the reply.
+// The reply is appended to the Messages; on failure, the prompt remains, so a
+// later reply retries it.  Returns the ChatDoneMsg, with Error also returned if it failed.
+func (c *ChatSession) Chat(ctx context.Context, prompt string, images []ImageData, onResponse func(ChatResponseMsg)) (ChatDoneMsg, error) {
+	c.appendPrompt(prompt, images)
+	c.response = ""
+	doneMsg, err := c.reply(ctx, c.makeChatRequest(), onResponse)
+	c.finishReply(doneMsg)
+	return doneMsg, err
+}
+
+// appendPrompt appends the user message, if any, to the Messages
+func (c *ChatSession) appendPrompt(prompt string, images []ImageData) {
+	if prompt != "" || len(images) != 0 {
+		c.Messages = append(c.Messages, Message{Role: "user", Content: prompt, Images: images})
+	}
+}
+
+// finishReply records the completed reply
+func (c *ChatSession) finishReply(doneMsg ChatDoneMsg) {
+	c.isGenerating = false
+	c.progressing = false
+	c.cancelFunc = nil
+	c.lastError = doneMsg.Error
+	c.pendingCalls = 0
+	if doneMsg.Error == nil {
+		c.Messages = append(c.Messages, doneMsg.Message)
+		c.response = doneMsg.Message.Content
+		c.pendingCalls = len(doneMsg.Message.ToolCalls)
+		c.stats = doneMsg.Stats()
+	}
+}
+
+// startProgress resets the progress counters for a new reply,
+// returning the command for its first progress tick, if any
+func (c *ChatSession) startProgress() tea.Cmd {
+	c.progressSeq++
+	c.progressStart = time.Now()
+	c.progressCount = 0
+	c.progressing = c.ProgressInterval > 0
+	return c.progressTickCmd()
+}
+
+// progressTickCmd returns a command delivering a progressTickMsg after ProgressInterval
+func (c *ChatSession) progressTickCmd() tea.Cmd {
+	if c.ProgressInterval <= 0 {
+		return nil
+	}
+	id, seq := c.id, c.progressSeq
+	return tea.Tick(c.ProgressInterval, func(time.Time) tea.Msg {
+		return progressTickMsg{ID: id, Seq: seq}
+	})
+}
+
+// progressMsg returns a GenerateProgressMsg for the reply so far
+func (c *ChatSession) progressMsg() GenerateProgressMsg {
+	elapsed := time.Since(c.progressStart)
+	return GenerateProgressMsg{
+		ID:           c.id,
+		TokensSoFar:  c.progressCount,
+		Elapsed:      elapsed,
+		TokensPerSec: tokenRate(c.progressCount, elapsed),
+	}
+}
+
+// makeChatRequest returns a ChatRequest for the ChatSession's history.
+// The Messages are copied, so the request may be used while they change.
+func (c *ChatSession) makeChatRequest() *ChatRequest {
+	return &ChatRequest{
+		Model:    c.Model,
+		Messages: slices.Clone(c.Messages),
+		Tools:    c.Tools,
+		Options:  c.Options,
+	}
+}
+
+// reply streams the model's reply to the request within a trace span, recording metrics
+// and logs.  The Memory, if any, selects the request's Messages, and the System prompt
+// is prepended.  It does not change the ChatSession, so may run outside of Update.
+func (c *ChatSession) reply(ctx context.Context, req *ChatRequest, onResponse func(ChatResponseMsg)) (ChatDoneMsg, error) {
+	fail := func(err error) (ChatDoneMsg, error) {
+		return ChatDoneMsg{ID: c.id, CreatedAt: time.Now(), DoneReason: err.Error(), Error: err}, err
+	}
+	chatter, err := resolveChatter(c.Backend, c.Host)
+	if err != nil {
+		logger().Error("chat backend failed", "session_id", c.id, "host", c.Host, "error", err)
+		return fail(err)
+	}
+	if c.Memory != nil {
+		if req.Messages, err = c.Memory.Select(ctx, req.Messages); err != nil {
+			return fail(err)
+		}
+	}
+	if c.System != "" {
+		req.Messages = append([]Message{{Role: "system", Content: c.System}}, req.Messages...)
+	}
+
+	log := logger().With("session_id", c.id, "host", c.Host, "model", req.Model)
+	log.Debug("chat start", "messages", len(req.Messages))
+	ctx, span := startSpan(ctx, "ollamatea.chat",
+		Attr("ollamatea.session_id", c.id),
+		Attr("ollamatea.host", c.Host),
+		Attr("ollamatea.model", req.Model))
+	defer span.End()
+
+	start := time.Now()
+	chunks := 0
+	sawDone := false
+	var sb strings.Builder
+	var toolCalls []ToolCall
+	doneMsg := ChatDoneMsg{ID: c.id}
+	err = chatter.Chat(ctx, req, func(resp ChatResponse) error {
+		if err := ctx.Err(); err != nil {
+			return err // stop promptly, even if the Backend does not
+		}
+		chunks++
+		sb.WriteString(resp.Message.Content)
+		toolCalls = append(toolCalls, resp.Message.ToolCalls...)
+		span.AddEvent("chunk", Attr("ollamatea.chunk_bytes", len(resp.Message.Content)))
+		if onResponse != nil && resp.Message.Content != "" {
+			onResponse(ChatResponseMsg{ID: c.id, CreatedAt: resp.CreatedAt, Content: resp.Message.Content})
+		}
+		if resp.Done {
+			sawDone = true
+			doneMsg.CreatedAt = resp.CreatedAt
+			doneMsg.DoneReason = resp.DoneReason
+			doneMsg.Metrics = resp.Metrics
+			span.SetAttributes(
+				Attr("ollamatea.chunks", chunks),
+				Attr("ollamatea.done_reason", resp.DoneReason),
+				Attr("ollamatea.tool_calls", len(toolCalls)),
+				Attr("ollamatea.prompt_eval_count", resp.PromptEvalCount),
+				Attr("ollamatea.eval_count", resp.EvalCount))
+		}
+		return nil
+	})
+	if err == nil && !sawDone && ctx.Err() != nil {
+		err = ctx.Err() // some Backends end a cancelled stream quietly
+	}
+	err = ClassifyError(err, c.Host, req.Model)
+	latency := time.Since(start)
+	switch {
+	case err == nil:
+		log.Info("chat done", "chunks", chunks, "tool_calls", len(toolCalls), "prompt_eval_count", doneMsg.Metrics.PromptEvalCount, "eval_count", doneMsg.Metrics.EvalCount, "latency", latency)
+	case ctx.Err() != nil:
+		log.Info("chat canceled", "chunks", chunks, "latency", latency, "error", err)
+	default:
+		log.Error("chat failed", "chunks", chunks, "latency", latency, "error", err)
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	observeMetrics("chat", c.Host, req.Model, latency, doneMsg.Metrics.PromptEvalCount, doneMsg.Metrics.EvalCount, err)
+	if err != nil {
+		return fail(err)
+	}
+
+	doneMsg.Message = Message{Role: "assistant", Content: sb.String(), ToolCalls: toolCalls}
+	return doneMsg, nil
+}
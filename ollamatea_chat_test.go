@@ -0,0 +1,113 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestChatPanelConversation(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	chat := ollamateatest.ScriptedChat(srv,
+		ollamateatest.ChatScript{Chunks: []string{"Paris"}},
+		ollamateatest.ChatScript{Chunks: []string{"About 2 million"}},
+	)
+	chat.Model = "llama3.2"
+
+	panel := ollamatea.NewChatPanelWithChat(chat)
+	panel.Accessible = true
+	update := func(msg tea.Msg) tea.Cmd {
+		return panel.UpdateInPlace(msg)
+	}
+	send := func(prompt string) {
+		msg := ollamateatest.Run(t, update, tea.Batch(panel.Init(), chat.Send(prompt)), ollamateatest.MatchChatDone(chat.ID()), 0)
+		update(msg)
+	}
+	send("What is the capital of France?")
+	send("How many people live there?")
+
+	view := panel.View()
+	want := "You: What is the capital of France?\n\nAssistant: Paris\n\n" +
+		"You: How many people live there?\n\nAssistant: About 2 million\n"
+	if !strings.Contains(view, want) {
+		t.Errorf("expected the conversation in the view %q", view)
+	}
+	if reqs := srv.ChatRequests(); len(reqs) != 2 || len(reqs[1].Messages) != 3 {
+		t.Errorf("expected the history sent with the second request, got %+v", reqs)
+	}
+}
+
+func TestChatPanelToolCalls(t *testing.T) {
+	chat := ollamatea.NewChatSession()
+	chat.Messages = []ollamatea.Message{
+		{Role: "user", Content: "weather in Paris?"},
+		{Role: "assistant", ToolCalls: []ollamatea.ToolCall{{Function: ollamatea.ToolCallFunction{
+			Name:      "get_weather",
+			Arguments: ollamatea.ToolCallFunctionArguments{"city": "Paris"},
+		}}}},
+		{Role: "tool", Content: "sunny"},
+		{Role: "assistant", Content: "It is sunny."},
+	}
+	panel := ollamatea.NewChatPanelWithChat(&chat)
+	panel.Accessible = true
+
+	view := panel.View()
+	want := "You: weather in Paris?\n\nTool call: get_weather {\"city\":\"Paris\"}\n\n" +
+		"Tool result: sunny\n\nAssistant: It is sunny.\n"
+	if !strings.Contains(view, want) {
+		t.Errorf("expected the tool call in the view %q", view)
+	}
+}
+
+func TestChatSessionWithContext(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	chat := ollamateatest.ScriptedChat(srv,
+		ollamateatest.ChatScript{Chunks: []string{"never", " done"}, Delay: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	chat.WithContext(ctx)
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := chat.Update(msg)
+		return cmd
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(chat.Init(), chat.Send("Hi")), ollamateatest.MatchChatDone(chat.ID()), 0)
+	if done := msg.(ollamatea.ChatDoneMsg); done.Error == nil {
+		t.Errorf("expected the reply cancelled with its parent context, got %+v", done)
+	}
+}
+
+func TestChatSessionProgress(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	chat := ollamateatest.ScriptedChat(srv, ollamateatest.ChatScript{
+		Chunks: []string{"one", " two", " three", " four"},
+		Delay:  20 * time.Millisecond,
+	})
+	chat.ProgressInterval = 30 * time.Millisecond
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := chat.Update(msg)
+		return cmd
+	}
+	match := ollamateatest.MatchAny(
+		ollamateatest.MatchType[ollamatea.GenerateProgressMsg](),
+		ollamateatest.MatchChatDone(chat.ID()))
+	msg := ollamateatest.Run(t, update, tea.Batch(chat.Init(), chat.Send("count")), match, 0)
+	progress, ok := msg.(ollamatea.GenerateProgressMsg)
+	if !ok {
+		t.Fatalf("expected a progress message before done, got %#v", msg)
+	}
+	if progress.ID != chat.ID() || progress.Elapsed < chat.ProgressInterval {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+}
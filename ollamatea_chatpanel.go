@@ -5,7 +5,9 @@
 package ollamatea
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/help"
@@ -15,6 +17,7 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
 const (
@@ -24,6 +27,12 @@ const (
 	defaultInputOnTop  = false
 )
 
+// thinkingStyle dims a Session's reasoning in the response view
+var thinkingStyle = lipgloss.NewStyle().Faint(true)
+
+// truncatedStyle dims the marker of a response truncated by MaxResponseBytes
+var truncatedStyle = lipgloss.NewStyle().Faint(true)
+
 ///////////////////////////////////////////////////////////////////////////////
 // ollamatea.ChatPanelKeyMap
 
@@ -94,8 +103,19 @@ func (m ChatPanelKeyMap) ShortHelp() []key.Binding {
 type ChatPanelModel struct {
 	Title      string // Title of the ChatPanelModel, if any
 	InputOnTop bool   // InputOnTop indicates whether the input box is at the top of screen
+	Accessible bool   // Accessible renders linear, plain text for screen readers (default: DefaultAccessible())
+
+	Session     *Session     // Session generates single responses; nil if Chat is set
+	Chat        *ChatSession // Chat, if set, holds a multi-turn conversation, shown in full
+	ShowHistory bool         // ShowHistory shows the Session's whole History, rather than its last Response
 
-	Session *Session
+	// ShowThinking shows the reasoning of a Session with SplitThinking, dimmed,
+	// above its Response; otherwise the reasoning is hidden.
+	ShowThinking bool
+
+	// Health, if set, is checked by the panel, which refuses to send prompts
+	// while the server is down.
+	Health *HealthMonitor
 
 	choosingModel bool
 
@@ -110,10 +130,41 @@ type ChatPanelModel struct {
 	spinner      spinner.Model  // spins while waiting for response
 	inputText    textarea.Model // prompt input
 	responseView viewport.Model // response view
+	responseWrap responseWrapper
+	thinkingWrap responseWrapper // of the Session's reasoning, if ShowThinking
 	modelChooser ModelChooser
+	progress     *GenerateProgressMsg // of the Session's generation, if any
 }
 
+// NewChatPanel returns a new ChatPanelModel with a copy of the Session.
+// Use [ChatPanelModel.Session] to access the copy.
 func NewChatPanel(session Session) ChatPanelModel {
+	return *NewChatPanelWithSession(&session)
+}
+
+// NewChatPanelWithSession returns a new ChatPanelModel sharing the Session,
+// so changes to either are seen by both.
+// If the Session's ProgressInterval is unset, it is set to DefaultProgressInterval.
+func NewChatPanelWithSession(session *Session) *ChatPanelModel {
+	if session.ProgressInterval == 0 {
+		session.ProgressInterval = DefaultProgressInterval
+	}
+	return newChatPanel(session, nil, session.Host, session.Backend)
+}
+
+// NewChatPanelWithChat returns a new ChatPanelModel for a multi-turn conversation,
+// sharing the ChatSession.  Each prompt is sent as a new user message, and the
+// whole conversation is shown.
+// If the ChatSession's ProgressInterval is unset, it is set to DefaultProgressInterval.
+func NewChatPanelWithChat(chat *ChatSession) *ChatPanelModel {
+	if chat.ProgressInterval == 0 {
+		chat.ProgressInterval = DefaultProgressInterval
+	}
+	return newChatPanel(nil, chat, chat.Host, chat.Backend)
+}
+
+// newChatPanel returns a new ChatPanelModel with either the Session or the ChatSession
+func newChatPanel(session *Session, chat *ChatSession, ollamaHost string, backend Backend) *ChatPanelModel {
 	width := defaultChatWidth
 	height := defaultChatHeight
 	inputHeight := defaultInputHeight
@@ -135,14 +186,16 @@ func NewChatPanel(session Session) ChatPanelModel {
 	inputText.KeyMap.InsertNewline.SetEnabled(false)
 
 	responseView := viewport.New(width, responseHeight)
-	responseView.SetContent(session.Response())
 
-	chooser := NewModelChooser(session.Host)
+	chooser := NewModelChooser(ollamaHost)
+	chooser.Backend = backend
 	chooser.FetchOnInit = false
 
-	m := ChatPanelModel{
+	m := &ChatPanelModel{
 		InputOnTop:    defaultInputOnTop,
-		Session:       &session,
+		Accessible:    DefaultAccessible(),
+		Session:       session,
+		Chat:          chat,
 		choosingModel: false,
 		KeyMap:        DefaultChatPanelKeyMap(),
 		showHelp:      true,
@@ -158,6 +211,7 @@ func NewChatPanel(session Session) ChatPanelModel {
 	m.SetWidth(width)
 	m.SetHeight(height)
 	m.SetInputHeight(inputHeight)
+	m.refreshResponse()
 	return m
 }
 
@@ -168,6 +222,7 @@ func (m *ChatPanelModel) SetWidth(w int) {
 	m.responseView.Width = w
 	m.help.Width = w
 	m.modelChooser.SetWidth(w)
+	m.refreshResponse()
 }
 
 // Width returns the width of the ChatPanelModel
@@ -209,7 +264,7 @@ func (m ChatPanelModel) Placeholder() string {
 }
 
 // SetPlaceholder sets the placeholder text for the input box
-func (m ChatPanelModel) SetPlaceholder(s string) {
+func (m *ChatPanelModel) SetPlaceholder(s string) {
 	m.inputText.Placeholder = s
 }
 
@@ -223,63 +278,126 @@ func (m *ChatPanelModel) SetShowHelp(showHelp bool) {
 	m.showHelp = showHelp
 }
 
+// Close closes the ChatPanelModel's Session or ChatSession, cancelling any generation.
+// Call it when discarding the panel, such as when a conversation ends in a
+// long-lived program.
+func (m *ChatPanelModel) Close() {
+	if m.Chat != nil {
+		m.Chat.Close()
+	} else {
+		m.Session.Close()
+	}
+}
+
+// sessionModel returns the ChatSession, if set, else the Session
+func (m *ChatPanelModel) sessionModel() SessionModel {
+	if m.Chat != nil {
+		return m.Chat
+	}
+	return m.Session.AsModel()
+}
+
+// modelName returns the model of the ChatSession or Session
+func (m *ChatPanelModel) modelName() string {
+	if m.Chat != nil {
+		return m.Chat.Model
+	}
+	return m.Session.Model
+}
+
+// setModelName sets the model of the ChatSession or Session
+func (m *ChatPanelModel) setModelName(model string) {
+	if m.Chat != nil {
+		m.Chat.Model = model
+	} else {
+		m.Session.Model = model
+	}
+}
+
 //////////////////////////////////////////////////////////////////////////////
 // BubbleTea handling
 
 // Init handles the initialization of an ChatPanelModel
 func (m ChatPanelModel) Init() tea.Cmd {
-	sessionCmd := m.Session.Init()
-	return tea.Batch(textarea.Blink, m.spinner.Tick, sessionCmd)
+	sessionCmd := m.sessionModel().Init()
+	var healthCmd tea.Cmd
+	if m.Health != nil {
+		healthCmd = m.Health.Init()
+	}
+	return tea.Batch(textarea.Blink, m.spinner.Tick, sessionCmd, healthCmd)
 }
 
 // Update handles BubbleTea messages for the ChatPanelModel
+// It copies the ChatPanelModel; see [ChatPanelModel.UpdateInPlace] to avoid that.
 func (m ChatPanelModel) Update(msg tea.Msg) (ChatPanelModel, tea.Cmd) {
+	cmd := m.UpdateInPlace(msg)
+	return m, cmd
+}
+
+// UpdateInPlace handles BubbleTea messages for the ChatPanelModel, updating it in place.
+// Use it with a *ChatPanelModel, such as from [NewChatPanelWithSession], to avoid
+// copying the ChatPanelModel and its sub-models on every message.
+func (m *ChatPanelModel) UpdateInPlace(msg tea.Msg) tea.Cmd {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.SetWidth(msg.Width)
 		m.SetHeight(msg.Height)
-		return m, nil
+		return nil
 	case tea.KeyMsg:
 		if m.choosingModel {
 			m.modelChooser, cmd = m.modelChooser.Update(msg)
-			return m, cmd
+			return cmd
 		}
-		return m, m.handleChattingKeyMsg(msg)
+		return m.handleChattingKeyMsg(msg)
 
 	case cursor.BlinkMsg:
 		// Textarea should also process cursor blinks.
 		m.inputText, cmd = m.inputText.Update(msg)
-		return m, cmd
+		return cmd
 
-	case GenerateResponseMsg:
+	case GenerateResponseMsg, ChatResponseMsg, ChatDoneMsg:
 		var cmds []tea.Cmd
-		_, cmd = m.Session.Update(msg)
+		_, cmd = m.sessionModel().Update(msg)
 		cmds = append(cmds, cmd)
-		m.responseView.SetContent(m.Session.Response())
+		m.refreshResponse()
 		m.responseView, cmd = m.responseView.Update(msg)
 		cmds = append(cmds, cmd)
-		return m, tea.Batch(cmds...)
+		return tea.Batch(cmds...)
+
+	case GenerateProgressMsg:
+		if msg.ID == m.sessionModel().ID() {
+			m.progress = &msg
+		}
+		return nil
+
+	case OllamaUpMsg, OllamaDownMsg, healthTickMsg:
+		if m.Health == nil {
+			return nil
+		}
+		_, cmd = m.Health.Update(msg)
+		return cmd
 
 	case ModelChooserAbortedMsg:
 		if msg.ID == m.modelChooser.ID() {
 			m.choosingModel = false
 		}
-		return m, nil
+		return nil
 
 	case ModelChooserSelectedMsg:
 		if msg.ID == m.modelChooser.ID() {
 			m.choosingModel = false
-			m.Session.Model = m.modelChooser.SelectedModel().Model
+			m.setModelName(m.modelChooser.SelectedModel().Model)
 		}
-		return m, nil
+		return nil
 
 	default:
 		var cmds []tea.Cmd
 		m.spinner, cmd = m.spinner.Update(msg)
 		cmds = append(cmds, cmd)
-		_, cmd = m.Session.Update(msg)
+		_, cmd = m.sessionModel().Update(msg)
 		cmds = append(cmds, cmd)
+		m.refreshResponse()
 		m.responseView, cmd = m.responseView.Update(msg)
 		cmds = append(cmds, cmd)
 		m.inputText, cmd = m.inputText.Update(msg)
@@ -287,18 +405,23 @@ func (m ChatPanelModel) Update(msg tea.Msg) (ChatPanelModel, tea.Cmd) {
 		m.modelChooser, cmd = m.modelChooser.Update(msg)
 		cmds = append(cmds, cmd)
 
-		return m, tea.Batch(cmds...)
+		return tea.Batch(cmds...)
 	}
 }
 
 // View renders the ChatPanelModel's view.
 func (m ChatPanelModel) View() string {
 	if m.choosingModel {
-		return m.modelChooser.View()
+		chooser := m.modelChooser
+		chooser.Accessible = chooser.Accessible || m.Accessible
+		return chooser.View()
+	}
+	if m.Accessible {
+		return m.accessibleView()
 	}
 	var respView string
-	if m.Session.IsGenerating() {
-		respView = m.spinner.View()
+	if m.sessionModel().IsGenerating() {
+		respView = m.spinner.View() + m.progressView()
 	}
 	respView += m.responseView.View()
 	var helpView string
@@ -326,13 +449,62 @@ func (m ChatPanelModel) View() string {
 	}
 }
 
+// accessibleView renders the panel as labelled lines, without spinners or box drawing
+func (m *ChatPanelModel) accessibleView() string {
+	var sb strings.Builder
+	if m.Title != "" {
+		sb.WriteString(m.Title + "\n")
+	}
+	sb.WriteString("Model: " + m.modelName() + "\n")
+	session := m.sessionModel()
+	switch {
+	case session.Error() != nil:
+		sb.WriteString("Status: error: " + session.Error().Error() + "\n")
+	case m.Health != nil && m.Health.IsDown():
+		sb.WriteString("Status: server unreachable\n")
+	case session.IsGenerating():
+		sb.WriteString("Status: generating" + m.progressView() + "\n")
+	default:
+		sb.WriteString("Status: ready\n")
+	}
+	if m.Chat != nil {
+		if transcript := m.chatTranscript(); transcript != "" {
+			sb.WriteString("Conversation:\n" + transcript + "\n")
+		}
+	} else {
+		if thinking := m.Session.Thinking(); m.ShowThinking && thinking != "" {
+			sb.WriteString("Thinking:\n" + thinking + "\n")
+		}
+		if truncated := m.Session.TruncatedBytes(); truncated > 0 {
+			sb.WriteString(fmt.Sprintf("Truncated: %d bytes\n", truncated))
+		}
+		if response := m.Session.Response(); response != "" {
+			sb.WriteString("Response:\n" + response + "\n")
+		}
+	}
+	sb.WriteString("Prompt: " + m.inputText.Value() + "\n")
+	if m.showHelp {
+		sb.WriteString(accessibleHelp(m.KeyMap.ShortHelp()) + "\n")
+	}
+	return sb.String()
+}
+
+// progressView renders the generation's progress, such as " 42 tokens, 12.3 tok/s, 3s", if known
+func (m *ChatPanelModel) progressView() string {
+	if m.progress == nil {
+		return ""
+	}
+	return fmt.Sprintf(" %d tokens, %.1f tok/s, %s", m.progress.TokensSoFar, m.progress.TokensPerSec,
+		m.progress.Elapsed.Truncate(time.Second))
+}
+
 func (m *ChatPanelModel) headerView() string {
 	return "─ " + m.Title + " " + strings.Repeat("─", m.width-len(m.Title)-3) + "\n"
 }
 
 func (m *ChatPanelModel) seperatorView() string {
-	modelLen := len(m.Session.Model)
-	return "┌" + strings.Repeat("─", m.width-modelLen-1) + m.Session.Model + "\n"
+	model := m.modelName()
+	return "┌" + strings.Repeat("─", m.width-len(model)-1) + model + "\n"
 }
 
 // handleChatting for when a user is in chat mode
@@ -358,6 +530,16 @@ func (m *ChatPanelModel) handleChattingKeyMsg(msg tea.Msg) tea.Cmd {
 			if v == "" {
 				// Don't send empty messages.
 				return nil
+			} else if m.Health != nil && m.Health.IsDown() {
+				// Don't send to an unreachable server
+				return nil
+			} else if m.Chat != nil {
+				if m.Chat.IsGenerating() {
+					return nil
+				}
+				m.inputText.Reset()
+				m.progress = nil
+				return m.Chat.Send(v)
 			} else if m.Session.Prompt == v {
 				// Don't repeat an unchanged prompt
 				return nil
@@ -365,12 +547,13 @@ func (m *ChatPanelModel) handleChattingKeyMsg(msg tea.Msg) tea.Cmd {
 
 			m.Session.Prompt = v
 			m.Session.ClearResponse()
-			m.responseView.SetContent("")
+			m.progress = nil
+			m.refreshResponse()
 			return m.Session.StartGenerateMsg
 
 		case key.Matches(msg, m.KeyMap.ChooseModel):
 			m.choosingModel = true
-			m.modelChooser.SetSelectionByName(m.Session.Model)
+			m.modelChooser.SetSelectionByName(m.modelName())
 			return Cmdize(m.modelChooser.FetchListMsg())
 
 		default:
@@ -418,3 +601,135 @@ func (m *ChatPanelModel) updateHeights() {
 
 	m.modelChooser.SetHeight(m.height)
 }
+
+// refreshResponse updates the response view with the Session's response, or the
+// ChatSession's conversation, if it changed
+func (m *ChatPanelModel) refreshResponse() {
+	text := ""
+	if m.Chat != nil {
+		text = m.chatTranscript()
+	} else if m.ShowHistory {
+		text = m.sessionTranscript()
+	} else {
+		text = m.Session.Response()
+	}
+	thinking := ""
+	if m.Chat == nil && !m.ShowHistory && m.ShowThinking {
+		thinking = m.Session.Thinking()
+	}
+	responseChanged := m.responseWrap.update(text, m.responseView.Width)
+	thinkingChanged := m.thinkingWrap.update(thinking, m.responseView.Width)
+	if responseChanged || thinkingChanged {
+		m.responseView.SetContent(m.responseContent())
+		if m.Chat != nil || m.ShowHistory || m.truncatedBytes() > 0 {
+			m.responseView.GotoBottom() // follow the conversation, or the tail of a truncated response
+		}
+	}
+}
+
+// responseContent returns the wrapped response, after the dimmed reasoning, if any.
+// A response truncated by the Session's MaxResponseBytes is marked as such.
+func (m *ChatPanelModel) responseContent() string {
+	response := strings.Join(m.responseWrap.lines, "\n")
+	if truncated := m.truncatedBytes(); truncated > 0 {
+		response = truncatedStyle.Render(fmt.Sprintf("… %d bytes truncated", truncated)) + "\n" + response
+	}
+	if m.thinkingWrap.text == "" {
+		return response
+	}
+	thinking := thinkingStyle.Render(strings.Join(m.thinkingWrap.lines, "\n"))
+	return thinking + "\n\n" + response
+}
+
+// truncatedBytes returns the bytes dropped from the front of the shown Session response
+func (m *ChatPanelModel) truncatedBytes() int64 {
+	if m.Chat != nil || m.ShowHistory {
+		return 0
+	}
+	return m.Session.TruncatedBytes()
+}
+
+// sessionTranscript renders the Session's History, with the generation in progress
+// and the last error, if any
+func (m *ChatPanelModel) sessionTranscript() string {
+	var parts []string
+	for _, turn := range m.Session.History() {
+		parts = append(parts, "You: "+turn.Prompt, "Assistant: "+turn.Response)
+	}
+	if m.Session.IsGenerating() {
+		parts = append(parts, "You: "+m.Session.Prompt, "Assistant: "+m.Session.Response())
+	} else if err := m.Session.Error(); err != nil {
+		parts = append(parts, "ERROR: "+err.Error())
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// chatTranscript renders the ChatSession's conversation, with the reply in progress
+// and the last error, if any
+func (m *ChatPanelModel) chatTranscript() string {
+	var parts []string
+	for _, message := range m.Chat.Messages {
+		switch message.Role {
+		case "user":
+			parts = append(parts, "You: "+message.Content)
+		case "assistant":
+			if message.Content != "" || len(message.ToolCalls) == 0 {
+				parts = append(parts, "Assistant: "+message.Content)
+			}
+			for _, call := range message.ToolCalls {
+				parts = append(parts, "Tool call: "+call.Function.Name+" "+call.Function.Arguments.String())
+			}
+		case "tool":
+			parts = append(parts, "Tool result: "+message.Content)
+		}
+	}
+	if m.Chat.IsGenerating() {
+		parts = append(parts, "Assistant: "+m.Chat.Response())
+	} else if err := m.Chat.Error(); err != nil {
+		parts = append(parts, "ERROR: "+err.Error())
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// responseWrapper
+
+// responseWrapper word-wraps a streaming response incrementally.
+// As chunks are appended, only the last unterminated line is re-wrapped,
+// rather than re-measuring the whole response on every chunk.
+type responseWrapper struct {
+	width     int      // width wrapped to; 0 is unwrapped
+	text      string   // text wrapped so far
+	lines     []string // wrapped lines of text
+	lastStart int      // index in lines where lastLine begins
+	lastLine  string   // last unterminated line of text, unwrapped
+}
+
+// update wraps text to width, returning true if the wrapped lines changed.
+// If text extends the previous text at the same width, only the new text is wrapped;
+// otherwise, such as when the response was cleared, replaced, or resized, it is wrapped anew.
+func (w *responseWrapper) update(text string, width int) bool {
+	if width == w.width && text == w.text {
+		return false
+	}
+	if width != w.width || !strings.HasPrefix(text, w.text) {
+		*w = responseWrapper{width: width}
+	}
+	parts := strings.Split(text[len(w.text):], "\n")
+	w.text = text
+	w.lastLine += parts[0]
+	w.lines = append(w.lines[:w.lastStart], w.wrap(w.lastLine)...)
+	for _, part := range parts[1:] {
+		w.lastStart, w.lastLine = len(w.lines), part
+		w.lines = append(w.lines, w.wrap(part)...)
+	}
+	return true
+}
+
+// wrap returns the wrapped lines of a single line
+func (w *responseWrapper) wrap(line string) []string {
+	if w.width <= 0 {
+		return []string{line}
+	}
+	return strings.Split(ansi.Wrap(line, w.width, ""), "\n")
+}
@@ -0,0 +1,23 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResponseWrapper(t *testing.T) {
+	var w responseWrapper
+	if !w.update("hello wor", 5) || !w.update("hello world", 5) || !slices.Equal(w.lines, []string{"hello", "world"}) {
+		t.Fatalf("unexpected lines %q", w.lines)
+	}
+	if w.update("hello world", 5) {
+		t.Error("expected no change for the same text")
+	}
+
+	// a replaced response of the same length is wrapped anew
+	if !w.update("goodbye all", 5) || !slices.Equal(w.lines, []string{"goodb", "ye", "all"}) {
+		t.Errorf("expected the replaced text wrapped, got %q", w.lines)
+	}
+}
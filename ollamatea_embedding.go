@@ -4,9 +4,10 @@ package ollamatea
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"net/url"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -44,6 +45,52 @@ type EmbedErrorMsg struct {
 	Error     error     // Error is the reason the model stopped generating text.
 }
 
+// EmbedProgressMsg is sent after each batch of an EmbedSession's inputs is
+// embedded, for progress indicators over long inputs; see [EmbedSession.BatchSize].
+// An unbatched embedding is a single batch.
+type EmbedProgressMsg struct {
+	ID        int64         // ID is the embedding session ID
+	Completed int           // Completed is the count of inputs embedded so far
+	Total     int           // Total is the count of inputs
+	Elapsed   time.Duration // Elapsed is the time since the embedding started
+}
+
+// EmbedDoneMsg is sent when an embedding completes, after its EmbedResponseMsg
+// or EmbedErrorMsg, mirroring GenerateDoneMsg.  A stopped embedding does not
+// complete; it ends with an EmbedStoppedMsg instead.
+type EmbedDoneMsg struct {
+	ID        int64          // ID is the embedding session ID
+	CreatedAt time.Time      // CreatedAt is the timestamp of the completion
+	Response  *EmbedResponse // Response is the complete response, or nil if it failed
+	Elapsed   time.Duration  // Elapsed is the time since the embedding started
+	Error     error          // Error is why the embedding failed, if it did; see [OllamaError]
+}
+
+// EmbedStoppedMsg is sent when a StopEmbedMsg stops an embedding in flight.
+// Its request is aborted, and no EmbedResponseMsg, EmbedErrorMsg, or
+// EmbedDoneMsg follows for it.
+type EmbedStoppedMsg struct {
+	ID        int64         // ID is the embedding session ID
+	Completed int           // Completed is the count of inputs embedded before it stopped
+	Total     int           // Total is the count of inputs
+	Elapsed   time.Duration // Elapsed is the time since the embedding started
+}
+
+// errEmbedStopped is the cause of the cancellation of a stopped or restarted
+// embedding, whose command then returns no message
+var errEmbedStopped = errors.New("embedding stopped")
+
+// embedBatchMsg is the private message returned by a batch's command.
+// Its handler collects the batch, then embeds the next or completes the embedding.
+type embedBatchMsg struct {
+	ID       int64          // ID is the embedding session ID
+	Seq      int            // Seq is the embedding the batch belongs to
+	Start    int            // Start is the index of the batch's first input
+	Response *EmbedResponse // Response is the batch's response, if it succeeded
+	Error    error          // Error is why the batch failed
+	TimedOut bool           // TimedOut is true if it exceeded the EmbedSession's Timeout
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 
 // EmbedSession holds the data for an OllamaTea Embed, both its request and response
@@ -58,7 +105,47 @@ type EmbedSession struct {
 	KeepAlive *time.Duration // KeepAlive controls how long the model will stay loaded in memory following this request.
 	Truncate  *bool          // Truncate the end of each input to fit within context length
 
+	Backend Backend // Backend to embed with; if nil, SharedBackend(Host) is used
+	Hooks   *Hooks  // Hooks to invoke when an embedding completes, if set
+
+	// Logger, if set, logs the EmbedSession's requests, their token counts,
+	// durations, and errors, rather than the Logger of SetLogger.
+	Logger *slog.Logger
+
+	// HTTPClient and Header, if set and Backend is not, make requests to Host with
+	// that client and those headers, such as an mTLS transport or bearer token.
+	HTTPClient *http.Client
+	Header     http.Header
+
+	// RateLimiter, if set, delays embeddings beyond its limits; it may be shared
+	// with other sessions.  A delayed embedding is announced with a QueuedMsg.
+	RateLimiter *RateLimiter
+
+	// Timeout, if positive, bounds each embedding, excluding time queued by the
+	// RateLimiter.  One exceeding it is announced with a TimeoutErrorMsg.
+	Timeout time.Duration
+
+	// Retry, if set, retries embeddings failing transiently; each retry is
+	// announced with a RetryingMsg.
+	Retry *RetryPolicy
+
+	// BatchSize, if positive, embeds an Input of more strings than it in
+	// sequential requests of BatchSize inputs, announcing each with an
+	// EmbedProgressMsg under BubbleTea; Embed batches alike.  Batches wait for
+	// the RateLimiter and are retried without a QueuedMsg or RetryingMsg.
+	BatchSize int
+
+	// Chunker, if set, splits a string Input into chunks, each embedded separately;
+	// see Chunks and ChunkEmbeddings.
+	Chunker *Chunker
+
+	// Normalize, if true, scales each embedding of the Response to unit length,
+	// so that their Dot product is their CosineSimilarity.  Embeddings are
+	// float32 from every Backend, as most similarity pipelines expect.
+	Normalize bool
+
 	// Private
+	parentCtx  context.Context // parent of each embedding's context; see WithContext
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 	id         int64 // Unique Session ID
@@ -66,6 +153,19 @@ type EmbedSession struct {
 
 	isEmbedding bool                  // Currently inferencing? Only one per session
 	response    *ollama.EmbedResponse // Ollama embed response
+	seq         int                   // counts embeddings, so stale batches are ignored
+	start       time.Time             // when the embedding started
+	batch       *embedBatch           // of a batched embedding in flight, if any
+	input       any                   // input of the embedding in flight, captured by Update
+	chunks      []Chunk               // chunks of the last embedding's Input, if chunked
+}
+
+// embedBatch collects the responses of a batched embedding
+type embedBatch struct {
+	inputs       []string
+	embeddings   [][]float32
+	completed    int
+	promptTokens int
 }
 
 // NewEmbedSession returns a new Session with the default values.
@@ -101,6 +201,122 @@ func WithModel(model string) EmbedOption {
 	}
 }
 
+// WithBackend is an EmbedOption to set the Backend field.
+func WithBackend(backend Backend) EmbedOption {
+	return func(s *EmbedSession) {
+		s.Backend = backend
+	}
+}
+
+// WithHTTPClient is an EmbedOption to set the HTTPClient field.
+func WithHTTPClient(client *http.Client) EmbedOption {
+	return func(s *EmbedSession) {
+		s.HTTPClient = client
+	}
+}
+
+// WithHeader is an EmbedOption to set a Header of requests.
+// For example, WithHeader("Authorization", "Bearer "+token).
+func WithHeader(key string, value string) EmbedOption {
+	return func(s *EmbedSession) {
+		if s.Header == nil {
+			s.Header = make(http.Header)
+		}
+		s.Header.Set(key, value)
+	}
+}
+
+// WithHeaders is an EmbedOption to set Headers of requests, replacing any
+// values already set for their keys, such as from a proxy's configuration.
+func WithHeaders(header http.Header) EmbedOption {
+	return func(s *EmbedSession) {
+		s.Header = mergeHeader(s.Header, header)
+	}
+}
+
+// WithHooks is an EmbedOption to set the Hooks field.
+func WithHooks(hooks *Hooks) EmbedOption {
+	return func(s *EmbedSession) {
+		s.Hooks = hooks
+	}
+}
+
+// WithLogger is an EmbedOption to set the Logger field.
+func WithLogger(logger *slog.Logger) EmbedOption {
+	return func(s *EmbedSession) {
+		s.Logger = logger
+	}
+}
+
+// WithRateLimiter is an EmbedOption to set the RateLimiter field.
+func WithRateLimiter(limiter *RateLimiter) EmbedOption {
+	return func(s *EmbedSession) {
+		s.RateLimiter = limiter
+	}
+}
+
+// WithContext is an EmbedOption to set the parent context of embeddings.
+// When ctx is done, such as at program shutdown, any embedding is cancelled.
+func WithContext(ctx context.Context) EmbedOption {
+	return func(s *EmbedSession) {
+		s.parentCtx = ctx
+	}
+}
+
+// WithTimeout is an EmbedOption to set the Timeout field.
+func WithTimeout(timeout time.Duration) EmbedOption {
+	return func(s *EmbedSession) {
+		s.Timeout = timeout
+	}
+}
+
+// WithRetry is an EmbedOption to set the Retry field.
+func WithRetry(policy *RetryPolicy) EmbedOption {
+	return func(s *EmbedSession) {
+		s.Retry = policy
+	}
+}
+
+// WithOptions is an EmbedOption to set model-specific options, such as
+// map[string]any{"num_ctx": 8192}.  They are merged into the Options field,
+// so it may be combined with typed options such as WithNumCtx.
+func WithOptions(options map[string]any) EmbedOption {
+	return func(s *EmbedSession) {
+		for key, value := range options {
+			s.Options = setOption(s.Options, key, value)
+		}
+	}
+}
+
+// WithNumCtx is an EmbedOption to set the context window size in tokens.
+// Inputs longer than it are truncated or rejected; see WithTruncate.
+func WithNumCtx(n int) EmbedOption {
+	return func(s *EmbedSession) {
+		s.Options = setOption(s.Options, OptionNumCtx, n)
+	}
+}
+
+// WithChunker is an EmbedOption to set the Chunker field.
+func WithChunker(chunker *Chunker) EmbedOption {
+	return func(s *EmbedSession) {
+		s.Chunker = chunker
+	}
+}
+
+// WithBatchSize is an EmbedOption to set the BatchSize field.
+func WithBatchSize(size int) EmbedOption {
+	return func(s *EmbedSession) {
+		s.BatchSize = size
+	}
+}
+
+// WithNormalize is an EmbedOption to set the Normalize field.
+func WithNormalize(normalize bool) EmbedOption {
+	return func(s *EmbedSession) {
+		s.Normalize = normalize
+	}
+}
+
 // WithInput is an EmbedOption to set the Input field.
 func WithInput(input any) EmbedOption {
 	return func(s *EmbedSession) {
@@ -119,6 +335,13 @@ func WithKeepAlive(d time.Duration) EmbedOption {
 	}
 }
 
+// WithID is an EmbedOption to set the ID, such as to a stable ID for tests or replays.
+func WithID(id int64) EmbedOption {
+	return func(s *EmbedSession) {
+		s.id = id
+	}
+}
+
 // WithTruncate is an EmbedOption to indicate truncation.
 func WithTruncate(trunc bool) EmbedOption {
 	return func(s *EmbedSession) {
@@ -131,6 +354,12 @@ func (s *EmbedSession) ID() int64 {
 	return s.id
 }
 
+// SetID sets the ID of the EmbedSession, such as to a stable ID for tests or replays.
+// The ID must be unique among EmbedSessions receiving the same messages.
+func (s *EmbedSession) SetID(id int64) {
+	s.id = id
+}
+
 // IsEmbedding returns whether the EmbedSession is currently embedding
 func (s *EmbedSession) IsEmbedding() bool {
 	return s.isEmbedding
@@ -141,6 +370,31 @@ func (s *EmbedSession) Response() *ollama.EmbedResponse {
 	return s.response
 }
 
+// Chunks returns the chunks of the last embedding's Input, if it was chunked by
+// the Chunker.  The Response's Embeddings are of the chunks, in the same order.
+func (s *EmbedSession) Chunks() []Chunk {
+	return s.chunks
+}
+
+// ChunkEmbedding is a Chunk with its embedding
+type ChunkEmbedding struct {
+	Chunk
+	Embedding []float32
+}
+
+// ChunkEmbeddings returns the chunks of the last embedding's Input with their
+// embeddings, or nil if the Input was not chunked or the embedding failed.
+func (s *EmbedSession) ChunkEmbeddings() []ChunkEmbedding {
+	if s.chunks == nil || s.response == nil || len(s.response.Embeddings) != len(s.chunks) {
+		return nil
+	}
+	embeddings := make([]ChunkEmbedding, len(s.chunks))
+	for i, chunk := range s.chunks {
+		embeddings[i] = ChunkEmbedding{Chunk: chunk, Embedding: s.response.Embeddings[i]}
+	}
+	return embeddings
+}
+
 // Error returns the last error, if any
 func (s *EmbedSession) Error() error {
 	return s.lastError
@@ -156,6 +410,18 @@ func (s *EmbedSession) ClearError() {
 	s.lastError = nil
 }
 
+// Close cancels any embedding in flight.  Call it when discarding an EmbedSession,
+// such as at program shutdown.
+func (s *EmbedSession) Close() {
+	if s.cancelFunc != nil {
+		s.cancelFunc()
+		s.cancelFunc = nil
+	}
+	s.ctx = nil
+	s.isEmbedding = false
+	s.batch = nil
+}
+
 // StartEmbedMsg returns a StartEmbedMsg for the EmbedSession
 func (s *EmbedSession) StartEmbedMsg() tea.Msg {
 	return StartEmbedMsg{ID: s.id}
@@ -194,29 +460,83 @@ func (m *EmbedSession) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.ctx = nil
 			m.isEmbedding = false
 		}
+		m.isEmbedding = true
+		parentCtx := m.parentCtx
+		if parentCtx == nil {
+			parentCtx = context.Background()
+		}
+		ctx, cancelCause := context.WithCancelCause(parentCtx)
+		m.ctx, m.cancelFunc = ctx, func() { cancelCause(errEmbedStopped) }
+		m.seq++
+		m.start = time.Now()
+		m.batch = nil
+		m.input, m.chunks = m.requestInput()
+		if inputs, ok := m.input.([]string); ok && m.BatchSize > 0 && len(inputs) > m.BatchSize {
+			m.batch = &embedBatch{inputs: inputs, embeddings: make([][]float32, len(inputs))}
+			return m, m.embedBatchCmd(0)
+		}
 		return m, m.startEmbeddingCmd()
 
 	case StopEmbedMsg:
 		if msg.ID != m.id {
 			return m, nil
 		}
+		m.logger().Debug("embed stop requested", "session_id", m.id, "embedding", m.isEmbedding)
 		if m.cancelFunc != nil {
 			m.cancelFunc()
 			m.cancelFunc = nil
 		}
+		var stoppedCmd tea.Cmd
+		if m.isEmbedding {
+			stopped := EmbedStoppedMsg{ID: m.id, Total: embedInputCount(m.input), Elapsed: m.elapsed()}
+			if m.batch != nil {
+				stopped.Completed = m.batch.completed
+			}
+			stoppedCmd = Cmdize(stopped)
+		}
 		m.ctx = nil
 		m.isEmbedding = false
-		return m, nil
+		m.batch = nil
+		return m, stoppedCmd
+
+	case QueuedMsg:
+		if msg.ID != m.id || !m.isEmbedding {
+			return m, nil
+		}
+		m.logger().Debug("embed queued", "session_id", m.id, "waiting", msg.Waiting)
+		return m, m.waitAndEmbedCmd()
+
+	case RetryingMsg:
+		if msg.ID != m.id || msg.Request != "embed" || !m.isEmbedding {
+			return m, nil
+		}
+		return m, m.retryEmbedCmd(msg)
+
+	case embedBatchMsg:
+		if msg.ID != m.id || msg.Seq != m.seq || m.batch == nil {
+			return m, nil
+		}
+		return m, m.collectBatch(msg)
 
 	case EmbedResponseMsg:
+		if msg.ID != m.id {
+			return m, nil
+		}
 		m.response = &msg.Response
 		m.lastError = nil
-		return m, nil
+		m.isEmbedding = false
+		m.batch = nil
+		return m, Cmdize(EmbedDoneMsg{ID: m.id, CreatedAt: msg.CreatedAt, Response: m.response, Elapsed: m.elapsed()})
 
 	case EmbedErrorMsg:
+		if msg.ID != m.id {
+			return m, nil
+		}
 		m.response = nil
 		m.lastError = msg.Error
-		return m, nil
+		m.isEmbedding = false
+		m.batch = nil
+		return m, Cmdize(EmbedDoneMsg{ID: m.id, CreatedAt: msg.CreatedAt, Elapsed: m.elapsed(), Error: msg.Error})
 	}
 	return m, nil
 }
@@ -233,45 +553,319 @@ func (m *EmbedSession) View() string {
 
 //////////////////////////////////////////////////////////////////////////////
 
-// startEmbeddingCmd is a tea.Msg wrapper for startEmbedding
+// elapsed returns the time since the embedding started, or 0 if none has
+func (s *EmbedSession) elapsed() time.Duration {
+	if s.start.IsZero() {
+		return 0
+	}
+	return time.Since(s.start)
+}
+
+// startEmbeddingCmd returns a command which starts the embedding set up by Update.
+// If the RateLimiter delays it, a QueuedMsg is returned instead.
 func (s *EmbedSession) startEmbeddingCmd() tea.Cmd {
+	ctx, input, start := s.ctx, s.input, s.start
 	return func() tea.Msg {
-		return s.startEmbedding()
+		if s.RateLimiter == nil {
+			return s.embed(ctx, input, start, 1)
+		}
+		release, ok := s.RateLimiter.TryAcquire()
+		if !ok {
+			return s.RateLimiter.queuedMsg(s.id)
+		}
+		defer release()
+		return s.embed(ctx, input, start, 1)
 	}
 }
 
-// startEmbedding starts embedding for a Session
-// Performs the actual Ollama /embed call
-func (s *EmbedSession) startEmbedding() tea.Msg {
-	if s.isEmbedding {
-		return nil
+// embedBatchCmd returns a command which embeds the batch of inputs from index start
+func (s *EmbedSession) embedBatchCmd(start int) tea.Cmd {
+	ctx, id, seq := s.ctx, s.id, s.seq
+	inputs := s.batch.inputs[start:min(start+s.BatchSize, len(s.batch.inputs))]
+	return func() tea.Msg {
+		resp, timedOut, err := s.embedAttempts(ctx, inputs)
+		if embedStopped(ctx) {
+			return nil
+		}
+		if err == nil && len(resp.Embeddings) != len(inputs) {
+			err = fmt.Errorf("embed returned %d embeddings for %d inputs", len(resp.Embeddings), len(inputs))
+		}
+		return embedBatchMsg{ID: id, Seq: seq, Start: start, Response: resp, Error: err, TimedOut: timedOut}
+	}
+}
+
+// collectBatch records the batch's response, returning the commands announcing
+// its progress and embedding the next batch, or completing the embedding
+func (s *EmbedSession) collectBatch(msg embedBatchMsg) tea.Cmd {
+	if msg.Error != nil {
+		id, timeout, errMsg := s.id, s.Timeout, makeEmbedErrorMsg(s.id, msg.Error)
+		return tea.Batch(
+			s.fireHooksCmd(nil, msg.Error),
+			func() tea.Msg {
+				if msg.TimedOut {
+					return timeoutMsg(id, timeout, msg.Error, errMsg)
+				}
+				return errMsg
+			})
+	}
+
+	b := s.batch
+	copy(b.embeddings[msg.Start:], msg.Response.Embeddings)
+	b.completed += len(msg.Response.Embeddings)
+	b.promptTokens += msg.Response.PromptEvalCount
+	progressCmd := Cmdize(EmbedProgressMsg{ID: s.id, Completed: b.completed, Total: len(b.inputs), Elapsed: s.elapsed()})
+	if b.completed < len(b.inputs) {
+		return tea.Batch(progressCmd, s.embedBatchCmd(b.completed))
 	}
-	s.isEmbedding = true
-	s.ctx, s.cancelFunc = context.WithCancel(context.Background())
 
-	ollamaURL, err := url.Parse(s.Host)
+	resp := &EmbedResponse{
+		Model:           msg.Response.Model,
+		Embeddings:      b.embeddings,
+		TotalDuration:   s.elapsed(),
+		PromptEvalCount: b.promptTokens,
+	}
+	return tea.Sequence(progressCmd, s.fireHooksCmd(resp, nil), Cmdize(makeEmbedResponseMsg(s.id, resp)))
+}
+
+// waitAndEmbedCmd returns a command which waits for the RateLimiter, then embeds
+func (s *EmbedSession) waitAndEmbedCmd() tea.Cmd {
+	ctx, input, start := s.ctx, s.input, s.start
+	return func() tea.Msg {
+		release, err := s.RateLimiter.Wait(ctx)
+		if err != nil {
+			s.logger().Info("embed canceled while queued", "session_id", s.id, "error", err)
+			if embedStopped(ctx) {
+				return nil
+			}
+			return makeEmbedErrorMsg(s.id, err)
+		}
+		defer release()
+		return s.embed(ctx, input, start, 1)
+	}
+}
+
+// retryEmbedCmd returns a command which waits out the RetryingMsg's Delay, then embeds again
+func (s *EmbedSession) retryEmbedCmd(msg RetryingMsg) tea.Cmd {
+	ctx, input, start := s.ctx, s.input, s.start
+	return func() tea.Msg {
+		if err := sleepContext(ctx, msg.Delay); err != nil {
+			if embedStopped(ctx) {
+				return nil
+			}
+			return makeEmbedErrorMsg(s.id, err)
+		}
+		if s.RateLimiter != nil {
+			release, err := s.RateLimiter.Wait(ctx)
+			if err != nil {
+				if embedStopped(ctx) {
+					return nil
+				}
+				return makeEmbedErrorMsg(s.id, err)
+			}
+			defer release()
+		}
+		return s.embed(ctx, input, start, msg.Attempt)
+	}
+}
+
+// embed performs attempt of the actual Ollama /embed call of the input, for the embedding started at start
+func (s *EmbedSession) embed(ctx context.Context, input any, start time.Time, attempt int) tea.Msg {
+	backend, err := resolveClientBackend(s.Backend, s.Host, s.HTTPClient, s.Header)
 	if err != nil {
-		s.lastError = err
-		s.isEmbedding = false
+		s.logger().Error("embed backend failed", "session_id", s.id, "host", s.Host, "error", err)
 		return makeEmbedErrorMsg(s.id, err)
 	}
 
-	ollamaClient := ollama.NewClient(ollamaURL, http.DefaultClient)
+	resp, timedOut, err := s.embedRequest(ctx, backend, input)
+	if embedStopped(ctx) {
+		return nil // its EmbedStoppedMsg was sent by Update
+	}
+	if ctx.Err() == nil {
+		if retryMsg, ok := s.Retry.retryingMsg(s.id, "embed", attempt, err); ok {
+			return retryMsg
+		}
+	}
+	s.Hooks.fireEmbed(s.makeEmbedEvent(resp, err))
+	if err != nil {
+		if timedOut {
+			return timeoutMsg(s.id, s.Timeout, err, makeEmbedErrorMsg(s.id, err))
+		}
+		return makeEmbedErrorMsg(s.id, err)
+	}
+
+	total := embedInputCount(input)
+	return tea.Sequence(
+		Cmdize(EmbedProgressMsg{ID: s.id, Completed: total, Total: total, Elapsed: time.Since(start)}),
+		Cmdize(makeEmbedResponseMsg(s.id, resp)))()
+}
+
+// embedRequest performs one Ollama /embed call of the input within a trace span, recording metrics and logs.
+// Returns whether the call exceeded the Session's Timeout.
+func (s *EmbedSession) embedRequest(ctx context.Context, backend Backend, input any) (*EmbedResponse, bool, error) {
 	req := &ollama.EmbedRequest{
-		Model: s.Model,
-		Input: s.Input,
-		// TODO KeepAlive: &ollama.Duration{},
-		// TODO Truncate:  new(bool),
-		Options: s.Options,
+		Model:    s.Model,
+		Input:    input,
+		Truncate: s.Truncate,
+		Options:  s.Options,
+	}
+	if s.KeepAlive != nil {
+		req.KeepAlive = &ollama.Duration{Duration: *s.KeepAlive}
 	}
 
-	resp, err := ollamaClient.Embed(s.ctx, req)
+	parentCtx := ctx
+	ctx, cancel := withRequestTimeout(ctx, s.Timeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "ollamatea.embed",
+		Attr("ollamatea.session_id", s.id),
+		Attr("ollamatea.host", s.Host),
+		Attr("ollamatea.model", s.Model))
+	log := s.logger().With("session_id", s.id, "host", s.Host, "model", s.Model)
+	log.Debug("embed start")
+	start := time.Now()
+	resp, err := backend.Embed(ctx, req)
+	err = ClassifyError(err, s.Host, s.Model)
+	promptTokens := 0
 	if err != nil {
+		if ctx.Err() != nil {
+			log.Info("embed canceled", "latency", time.Since(start), "error", err)
+		} else {
+			log.Error("embed failed", "latency", time.Since(start), "error", err)
+		}
+		span.RecordError(err)
+	} else {
+		log.Info("embed done", "embeddings", len(resp.Embeddings), "prompt_eval_count", resp.PromptEvalCount, "latency", time.Since(start))
+		promptTokens = resp.PromptEvalCount
+		if s.Normalize {
+			resp = normalizeEmbedResponse(resp)
+		}
+		span.SetAttributes(
+			Attr("ollamatea.embeddings", len(resp.Embeddings)),
+			Attr("ollamatea.prompt_eval_count", resp.PromptEvalCount))
+	}
+	span.End()
+	observeMetrics("embed", s.Host, s.Model, time.Since(start), promptTokens, 0, err)
+	return resp, err != nil && requestTimedOut(ctx, parentCtx), err
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// Embed performs a blocking embedding for the EmbedSession, outside of BubbleTea.
+// Returns the response, or an error if the embedding failed.
+// The EmbedSession's Response and Error are also updated.
+// If the EmbedSession has a RateLimiter, Embed first waits for it; the Timeout,
+// if any, applies after.  Transient failures are retried by the Retry policy, if any.
+// An Input of more strings than a positive BatchSize is embedded in batches.
+func (s *EmbedSession) Embed(ctx context.Context) (*EmbedResponse, error) {
+	var input any
+	input, s.chunks = s.requestInput()
+	resp, err := s.embedBatches(ctx, input)
+	s.Hooks.fireEmbed(s.makeEmbedEvent(resp, err))
+	if err != nil {
+		s.response = nil
 		s.lastError = err
-		return makeEmbedErrorMsg(s.id, err)
+		return nil, err
+	}
+	s.response = resp
+	s.lastError = nil
+	return resp, nil
+}
+
+// embedBatches embeds the input by embedAttempts, in sequential requests of
+// BatchSize inputs if it has more, concatenating their embeddings
+func (s *EmbedSession) embedBatches(ctx context.Context, input any) (*EmbedResponse, error) {
+	inputs, ok := input.([]string)
+	if !ok || s.BatchSize <= 0 || len(inputs) <= s.BatchSize {
+		resp, _, err := s.embedAttempts(ctx, input)
+		return resp, err
 	}
 
-	return makeEmbedResponseMsg(s.id, resp)
+	start := time.Now()
+	resp := &EmbedResponse{Embeddings: make([][]float32, 0, len(inputs))}
+	for i := 0; i < len(inputs); i += s.BatchSize {
+		batch := inputs[i:min(i+s.BatchSize, len(inputs))]
+		batchResp, _, err := s.embedAttempts(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(batchResp.Embeddings) != len(batch) {
+			return nil, fmt.Errorf("embed returned %d embeddings for %d inputs", len(batchResp.Embeddings), len(batch))
+		}
+		resp.Model = batchResp.Model
+		resp.Embeddings = append(resp.Embeddings, batchResp.Embeddings...)
+		resp.PromptEvalCount += batchResp.PromptEvalCount
+	}
+	resp.TotalDuration = time.Since(start)
+	return resp, nil
+}
+
+// embedAttempts embeds the input after waiting for the RateLimiter, if any,
+// retrying transient failures by the Retry policy, if any, without messages.
+// Returns whether the last attempt exceeded the Session's Timeout.
+func (s *EmbedSession) embedAttempts(ctx context.Context, input any) (*EmbedResponse, bool, error) {
+	backend, err := resolveClientBackend(s.Backend, s.Host, s.HTTPClient, s.Header)
+	if err != nil {
+		s.logger().Error("embed backend failed", "session_id", s.id, "host", s.Host, "error", err)
+		return nil, false, err
+	}
+	if s.RateLimiter != nil {
+		release, err := s.RateLimiter.Wait(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		defer release()
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, timedOut, err := s.embedRequest(ctx, backend, input)
+		retryMsg, ok := s.Retry.retryingMsg(s.id, "embed", attempt, err)
+		if !ok || ctx.Err() != nil {
+			return resp, timedOut, err
+		}
+		if err = sleepContext(ctx, retryMsg.Delay); err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// requestInput returns the input to request, and its chunks if the Chunker splits a string Input
+func (s *EmbedSession) requestInput() (any, []Chunk) {
+	text, ok := s.Input.(string)
+	if s.Chunker == nil || !ok {
+		return s.Input, nil
+	}
+	chunks := s.Chunker.Chunk(text)
+	inputs := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		inputs[i] = chunk.Text
+	}
+	return inputs, chunks
+}
+
+// embedStopped returns true if the embedding of ctx was stopped or restarted
+func embedStopped(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errEmbedStopped)
+}
+
+// normalizeEmbedResponse returns a copy of resp with its embeddings scaled to unit length
+func normalizeEmbedResponse(resp *EmbedResponse) *EmbedResponse {
+	normalized := *resp
+	normalized.Embeddings = make([][]float32, len(resp.Embeddings))
+	for i, embedding := range resp.Embeddings {
+		normalized.Embeddings[i] = Normalize(embedding)
+	}
+	return &normalized
+}
+
+// embedInputCount returns the count of inputs in an EmbedSession's Input
+func embedInputCount(input any) int {
+	switch input := input.(type) {
+	case nil:
+		return 0
+	case []string:
+		return len(input)
+	default:
+		return 1
+	}
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,188 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestEmbedSessionProgress(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	inputs := []string{"a", "b", "c", "d", "e"}
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithModel("all-minilm"),
+		ollamatea.WithInput(inputs),
+		ollamatea.WithBatchSize(2))
+	var progress []int
+	update := func(msg tea.Msg) tea.Cmd {
+		if msg, ok := msg.(ollamatea.EmbedProgressMsg); ok {
+			if msg.ID != s.ID() || msg.Total != len(inputs) {
+				t.Errorf("unexpected %+v", msg)
+			}
+			progress = append(progress, msg.Completed)
+		}
+		_, cmd := s.Update(msg)
+		return cmd
+	}
+	msg := ollamateatest.Run(t, update, s.StartEmbedCmd(), ollamateatest.MatchType[ollamatea.EmbedDoneMsg](), 0)
+	done := msg.(ollamatea.EmbedDoneMsg)
+	if done.Error != nil || done.Response == nil || len(done.Response.Embeddings) != len(inputs) {
+		t.Fatalf("unexpected %+v", done)
+	}
+	if !slices.Equal(progress, []int{2, 4, 5}) || len(srv.EmbedRequests()) != 3 {
+		t.Errorf("expected 3 batches, got progress %v", progress)
+	}
+	for i, input := range inputs {
+		if !slices.Equal(done.Response.Embeddings[i], ollamateatest.FakeEmbedding(input, ollamateatest.DefaultEmbeddingDimensions)) {
+			t.Errorf("unexpected embedding of %q", input)
+		}
+	}
+	if s.IsEmbedding() || s.Response() == nil {
+		t.Errorf("expected the embedding complete")
+	}
+
+	// a failed batch ends the embedding with an EmbedErrorMsg, then an EmbedDoneMsg
+	srv.QueueEmbed(
+		ollamateatest.EmbedScript{Embeddings: [][]float32{{1}, {2}}},
+		ollamateatest.EmbedScript{Status: 500, Error: "out of memory"})
+	progress = nil
+	msg = ollamateatest.Run(t, update, s.StartEmbedCmd(), ollamateatest.MatchType[ollamatea.EmbedDoneMsg](), 0)
+	if done := msg.(ollamatea.EmbedDoneMsg); done.Error == nil || done.Response != nil || s.Error() == nil {
+		t.Errorf("expected an error, got %+v", done)
+	}
+	if !slices.Equal(progress, []int{2}) {
+		t.Errorf("expected 1 batch before the error, got progress %v", progress)
+	}
+}
+
+func TestEmbedSessionEmbedBatches(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	inputs := []string{"a", "b", "c", "d", "e"}
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithModel("all-minilm"),
+		ollamatea.WithInput(inputs),
+		ollamatea.WithBatchSize(2))
+	resp, err := s.Embed(context.Background())
+	if err != nil || len(resp.Embeddings) != len(inputs) || s.Response() != resp {
+		t.Fatalf("unexpected response %+v, error %v", resp, err)
+	}
+	if reqs := srv.EmbedRequests(); len(reqs) != 3 {
+		t.Errorf("expected 3 batches, got %d requests", len(reqs))
+	}
+	for i, input := range inputs {
+		if !slices.Equal(resp.Embeddings[i], ollamateatest.FakeEmbedding(input, ollamateatest.DefaultEmbeddingDimensions)) {
+			t.Errorf("unexpected embedding of %q", input)
+		}
+	}
+}
+
+func TestEmbedSessionOptions(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithInput("hello"),
+		ollamatea.WithNumCtx(8192),
+		ollamatea.WithTruncate(false),
+		ollamatea.WithKeepAlive(time.Minute),
+		ollamatea.WithOptions(map[string]any{"num_thread": 4}))
+	if len(s.Options) != 2 || s.Options[ollamatea.OptionNumCtx] != 8192 {
+		t.Fatalf("unexpected options %v", s.Options)
+	}
+	if _, err := s.Embed(context.Background()); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	// The options are sent as JSON, so numbers arrive as float64
+	if opts := srv.EmbedRequests()[0].Options; opts["num_ctx"] != 8192.0 || opts["num_thread"] != 4.0 {
+		t.Errorf("unexpected request options %v", opts)
+	}
+	if req := srv.EmbedRequests()[0]; req.Truncate == nil || *req.Truncate || req.KeepAlive == nil || req.KeepAlive.Duration != time.Minute {
+		t.Errorf("expected truncation off and keep alive sent, got %+v", req)
+	}
+}
+
+func TestEmbedSessionNormalize(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.QueueEmbed(
+		ollamateatest.EmbedScript{Embeddings: [][]float32{{3, 4}, {0, 0}}},
+		ollamateatest.EmbedScript{Embeddings: [][]float32{{3, 4}}})
+
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithInput([]string{"a", "b"}),
+		ollamatea.WithNormalize(true))
+	resp, err := s.Embed(context.Background())
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if !slices.Equal(resp.Embeddings[0], []float32{0.6, 0.8}) || !slices.Equal(resp.Embeddings[1], []float32{0, 0}) {
+		t.Errorf("unexpected embeddings %v", resp.Embeddings)
+	}
+
+	s.Normalize = false
+	s.Input = "a"
+	if resp, _ := s.Embed(context.Background()); !slices.Equal(resp.Embeddings[0], []float32{3, 4}) {
+		t.Errorf("expected the embedding unnormalized, got %v", resp.Embeddings)
+	}
+}
+
+func TestEmbedSessionStop(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.QueueEmbed(ollamateatest.EmbedScript{Embeddings: [][]float32{{1}}, Delay: time.Minute})
+
+	s := ollamatea.NewEmbedSession(ollamatea.WithHost(srv.URL), ollamatea.WithInput("hello"))
+	var stopped []ollamatea.EmbedStoppedMsg
+	update := func(msg tea.Msg) tea.Cmd {
+		if msg, ok := msg.(ollamatea.EmbedStoppedMsg); ok {
+			stopped = append(stopped, msg)
+		}
+		_, cmd := s.Update(msg)
+		return cmd
+	}
+	// the stopped request's abort must not fail the embedding started after it
+	stop := tea.Tick(50*time.Millisecond, func(time.Time) tea.Msg { return ollamatea.StopEmbedMsg{ID: s.ID()} })
+	msg := ollamateatest.Run(t, update, tea.Sequence(s.StartEmbedCmd(), stop, s.StartEmbedCmd()),
+		ollamateatest.MatchType[ollamatea.EmbedDoneMsg](), 0)
+	if done := msg.(ollamatea.EmbedDoneMsg); done.Error != nil || s.Error() != nil {
+		t.Fatalf("expected the second embedding to succeed, got %+v", done)
+	}
+	if len(stopped) != 1 || stopped[0].ID != s.ID() || stopped[0].Total != 1 || stopped[0].Completed != 0 {
+		t.Errorf("expected one EmbedStoppedMsg, got %+v", stopped)
+	}
+}
+
+func TestEmbedSessionHTTPClientAndHeaders(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	recorder := &headerRecorder{}
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithInput("hello"),
+		ollamatea.WithHTTPClient(&http.Client{Transport: recorder}),
+		ollamatea.WithHeader("Authorization", "Bearer stale"),
+		ollamatea.WithHeaders(http.Header{"authorization": {"Bearer secret"}, "X-Tenant": {"acme"}}))
+	if _, err := s.Embed(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.auths) != 1 || recorder.auths[0] != "Bearer secret" || s.Header.Get("X-Tenant") != "acme" {
+		t.Errorf("expected one request with the bearer token, got %q", recorder.auths)
+	}
+}
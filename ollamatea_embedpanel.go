@@ -0,0 +1,397 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	defaultEmbedInputHeight = 3
+	embedPreviewValues      = 4 // count of an embedding's first values previewed
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// ollamatea.EmbedPanelKeyMap
+
+// EmbedPanelKeyMap is the all the [key.Binding] for the EmbedPanelModel
+type EmbedPanelKeyMap struct {
+	SendInput key.Binding
+	Stop      key.Binding
+}
+
+// DefaultEmbedPanelKeyMap returns a default set of keybindings for EmbedPanelModel
+func DefaultEmbedPanelKeyMap() EmbedPanelKeyMap {
+	return EmbedPanelKeyMap{
+		SendInput: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "embed"),
+		),
+		Stop: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "stop"),
+		),
+	}
+}
+
+// FullHelp returns bindings to show the full help view.
+// Implements bubble's [help.KeyMap] interface.
+func (m *EmbedPanelKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{m.ShortHelp()}
+}
+
+// ShortHelp returns bindings to show in the abbreviated help view. It's part
+// of the help.KeyMap interface.
+func (m EmbedPanelKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		m.SendInput,
+		m.Stop,
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// ollamatea.EmbedPanelModel
+
+// EmbedPanelModel holds a simple Panel TUI for embedding text with an
+// EmbedSession.  Text entered in its input box is embedded with a spinner
+// while waiting, then summarized by its model, vector count and dimensions,
+// the first values of its first vector, and latency.  It handles all of its
+// EmbedSession's messages, so pass it every message.
+type EmbedPanelModel struct {
+	Title      string // Title of the EmbedPanelModel, if any
+	Accessible bool   // Accessible renders linear, plain text for screen readers (default: DefaultAccessible())
+
+	Session *EmbedSession // Session embeds the input text
+
+	showHelp bool
+	help     help.Model
+	KeyMap   EmbedPanelKeyMap
+
+	width  int // width of the EmbedPanelModel
+	height int // height of the EmbedPanelModel
+
+	spinner   spinner.Model     // spins while embedding
+	inputText textarea.Model    // text to embed
+	progress  *EmbedProgressMsg // of the embedding in flight, if batched
+	done      *EmbedDoneMsg     // of the last completed embedding, if any
+	stopped   *EmbedStoppedMsg  // of the last stopped embedding, if any
+}
+
+// NewEmbedPanel returns a new EmbedPanelModel with a copy of the EmbedSession.
+// Use [EmbedPanelModel.Session] to access the copy.
+func NewEmbedPanel(session EmbedSession) EmbedPanelModel {
+	return *NewEmbedPanelWithSession(&session)
+}
+
+// NewEmbedPanelWithSession returns a new EmbedPanelModel sharing the EmbedSession,
+// so changes to either are seen by both.
+func NewEmbedPanelWithSession(session *EmbedSession) *EmbedPanelModel {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+
+	inputText := textarea.New()
+	inputText.Placeholder = "Enter text to embed here..."
+	inputText.Focus()
+	inputText.Prompt = "│ "
+	inputText.CharLimit = 1000
+	inputText.SetHeight(defaultEmbedInputHeight)
+	inputText.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	inputText.ShowLineNumbers = false
+	inputText.KeyMap.InsertNewline.SetEnabled(false)
+
+	m := &EmbedPanelModel{
+		Accessible: DefaultAccessible(),
+		Session:    session,
+		KeyMap:     DefaultEmbedPanelKeyMap(),
+		showHelp:   true,
+		help:       help.New(),
+		spinner:    s,
+		inputText:  inputText,
+	}
+	m.SetWidth(defaultChatWidth)
+	m.SetHeight(defaultChatHeight)
+	return m
+}
+
+// SetWidth sets the width of the EmbedPanelModel
+func (m *EmbedPanelModel) SetWidth(w int) {
+	m.width = w
+	m.inputText.SetWidth(w)
+	m.help.Width = w
+}
+
+// Width returns the width of the EmbedPanelModel
+func (m EmbedPanelModel) Width() int {
+	return m.width
+}
+
+// SetHeight sets the height of the EmbedPanelModel
+func (m *EmbedPanelModel) SetHeight(height int) {
+	m.height = height
+}
+
+// Height returns the height of the EmbedPanelModel
+func (m EmbedPanelModel) Height() int {
+	return m.height
+}
+
+// Placeholder gets the placeholder text for the input box
+func (m EmbedPanelModel) Placeholder() string {
+	return m.inputText.Placeholder
+}
+
+// SetPlaceholder sets the placeholder text for the input box
+func (m *EmbedPanelModel) SetPlaceholder(s string) {
+	m.inputText.Placeholder = s
+}
+
+// GetShowHelp gets the ShowHelp setting value.
+func (m EmbedPanelModel) GetShowHelp() bool {
+	return m.showHelp
+}
+
+// SetShowHelp sets whether to show help or not.
+func (m *EmbedPanelModel) SetShowHelp(showHelp bool) {
+	m.showHelp = showHelp
+}
+
+// Done returns the EmbedDoneMsg of the last completed embedding, if any.
+func (m EmbedPanelModel) Done() *EmbedDoneMsg {
+	return m.done
+}
+
+// Close closes the EmbedPanelModel's EmbedSession, cancelling any embedding.
+func (m *EmbedPanelModel) Close() {
+	m.Session.Close()
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea handling
+
+// Init handles the initialization of an EmbedPanelModel
+func (m EmbedPanelModel) Init() tea.Cmd {
+	return tea.Batch(textarea.Blink, m.spinner.Tick, m.Session.Init())
+}
+
+// Update handles BubbleTea messages for the EmbedPanelModel
+// It copies the EmbedPanelModel; see [EmbedPanelModel.UpdateInPlace] to avoid that.
+func (m EmbedPanelModel) Update(msg tea.Msg) (EmbedPanelModel, tea.Cmd) {
+	cmd := m.UpdateInPlace(msg)
+	return m, cmd
+}
+
+// UpdateInPlace handles BubbleTea messages for the EmbedPanelModel, updating it in place.
+func (m *EmbedPanelModel) UpdateInPlace(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.SetWidth(msg.Width)
+		m.SetHeight(msg.Height)
+		return nil
+
+	case tea.KeyMsg:
+		return m.handleKeyMsg(msg)
+
+	case cursor.BlinkMsg:
+		// Textarea should also process cursor blinks.
+		m.inputText, cmd = m.inputText.Update(msg)
+		return cmd
+
+	case EmbedProgressMsg:
+		if msg.ID == m.Session.ID() {
+			m.progress = &msg
+		}
+		return nil
+
+	case EmbedDoneMsg:
+		if msg.ID == m.Session.ID() {
+			m.done, m.progress = &msg, nil
+		}
+		return nil
+
+	case EmbedStoppedMsg:
+		if msg.ID == m.Session.ID() {
+			m.stopped, m.progress = &msg, nil
+		}
+		return nil
+
+	default:
+		var cmds []tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		cmds = append(cmds, cmd)
+		_, cmd = m.Session.Update(msg)
+		cmds = append(cmds, cmd)
+		m.inputText, cmd = m.inputText.Update(msg)
+		cmds = append(cmds, cmd)
+		return tea.Batch(cmds...)
+	}
+}
+
+// handleKeyMsg embeds the input, stops the embedding, or edits the input
+func (m *EmbedPanelModel) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, m.KeyMap.SendInput):
+		v := m.inputText.Value()
+		if strings.TrimSpace(v) == "" || m.Session.IsEmbedding() {
+			// Don't embed nothing, nor interrupt an embedding
+			return nil
+		}
+		m.Session.Input = v
+		m.done, m.stopped, m.progress = nil, nil, nil
+		return m.Session.StartEmbedCmd()
+
+	case key.Matches(msg, m.KeyMap.Stop):
+		if !m.Session.IsEmbedding() {
+			return nil
+		}
+		return Cmdize(StopEmbedMsg{ID: m.Session.ID()})
+
+	default:
+		// Send all other keypresses to the textarea.
+		var cmd tea.Cmd
+		m.inputText, cmd = m.inputText.Update(msg)
+		return cmd
+	}
+}
+
+// View renders the EmbedPanelModel's view.
+func (m EmbedPanelModel) View() string {
+	if m.Accessible {
+		return m.accessibleView()
+	}
+	var helpView string
+	if m.showHelp {
+		helpView = m.help.View(&m.KeyMap)
+	}
+	headerView := m.headerView()
+	separatorView := m.separatorView()
+	summaryHeight := m.height - lipgloss.Height(headerView) - lipgloss.Height(separatorView) -
+		m.inputText.Height() - lipgloss.Height(helpView)
+	summary := strings.Join(m.summaryLines(), "\n")
+	summaryView := lipgloss.NewStyle().Height(max(summaryHeight, 0)).MaxHeight(max(summaryHeight, 0)).Render(summary)
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		headerView,
+		summaryView,
+		separatorView,
+		m.inputText.View(),
+		helpView,
+	)
+}
+
+// summaryLines describes the embedding in flight, or the last one's outcome
+func (m *EmbedPanelModel) summaryLines() []string {
+	if m.done == nil || m.done.Error != nil {
+		return []string{m.statusView()}
+	}
+	resp := m.done.Response
+	model := resp.Model
+	if model == "" {
+		model = m.Session.Model
+	}
+	lines := []string{
+		"Model: " + model,
+		fmt.Sprintf("Vectors: %d × %d dimensions", len(resp.Embeddings), embeddingDimensions(resp.Embeddings)),
+		"Latency: " + m.done.Elapsed.Round(time.Millisecond).String(),
+	}
+	if resp.PromptEvalCount > 0 {
+		lines = append(lines, fmt.Sprintf("Tokens: %d", resp.PromptEvalCount))
+	}
+	if len(resp.Embeddings) > 0 {
+		lines = append(lines, "First: "+embeddingPreview(resp.Embeddings[0]))
+	}
+	return lines
+}
+
+// statusView describes an embedding in flight, stopped, or failed, or is empty if none is
+func (m *EmbedPanelModel) statusView() string {
+	switch {
+	case m.Session.IsEmbedding():
+		if m.Accessible {
+			return "embedding" + m.progressView()
+		}
+		return m.spinner.View() + "embedding" + m.progressView()
+	case m.stopped != nil:
+		return fmt.Sprintf("stopped after %d of %d inputs, %s", m.stopped.Completed, m.stopped.Total,
+			m.stopped.Elapsed.Round(time.Millisecond))
+	case m.done != nil && m.done.Error != nil:
+		return "error: " + m.done.Error.Error()
+	}
+	return ""
+}
+
+// accessibleView renders the panel as labelled lines, without spinners or box drawing
+func (m *EmbedPanelModel) accessibleView() string {
+	var sb strings.Builder
+	if m.Title != "" {
+		sb.WriteString(m.Title + "\n")
+	}
+	if m.done != nil && m.done.Error == nil {
+		sb.WriteString("Status: done\n")
+		for _, line := range m.summaryLines() {
+			sb.WriteString(line + "\n")
+		}
+	} else {
+		status := m.statusView()
+		if status == "" {
+			status = "ready"
+		}
+		sb.WriteString("Model: " + m.Session.Model + "\n")
+		sb.WriteString("Status: " + status + "\n")
+	}
+	sb.WriteString("Input: " + m.inputText.Value() + "\n")
+	if m.showHelp {
+		sb.WriteString(accessibleHelp(m.KeyMap.ShortHelp()) + "\n")
+	}
+	return sb.String()
+}
+
+// progressView renders the progress of a batched embedding, such as " 4 of 10 inputs, 3s", if known
+func (m *EmbedPanelModel) progressView() string {
+	if m.progress == nil {
+		return ""
+	}
+	return fmt.Sprintf(" %d of %d inputs, %s", m.progress.Completed, m.progress.Total,
+		m.progress.Elapsed.Truncate(time.Second))
+}
+
+func (m *EmbedPanelModel) headerView() string {
+	return "─ " + m.Title + " " + strings.Repeat("─", max(m.width-len(m.Title)-3, 0)) + "\n"
+}
+
+func (m *EmbedPanelModel) separatorView() string {
+	model := m.Session.Model
+	return "┌" + strings.Repeat("─", max(m.width-len(model)-1, 0)) + model + "\n"
+}
+
+// embeddingDimensions returns the length of the first embedding, or 0 if there are none
+func embeddingDimensions(embeddings [][]float32) int {
+	if len(embeddings) == 0 {
+		return 0
+	}
+	return len(embeddings[0])
+}
+
+// embeddingPreview renders the first values of the embedding, such as "[0.0123, -0.0456, …]"
+func embeddingPreview(embedding []float32) string {
+	values := make([]string, 0, embedPreviewValues+1)
+	for i, v := range embedding {
+		if i == embedPreviewValues {
+			values = append(values, "…")
+			break
+		}
+		values = append(values, fmt.Sprintf("%.4f", v))
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
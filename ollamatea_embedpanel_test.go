@@ -0,0 +1,47 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestEmbedPanel(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamatea.NewEmbedSession(ollamatea.WithHost(srv.URL), ollamatea.WithModel("all-minilm"))
+	panel := ollamatea.NewEmbedPanelWithSession(&session)
+	panel.Title = "Embed"
+	panel.Accessible = true
+	if cmd := panel.UpdateInPlace(tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil {
+		t.Error("expected no embedding of empty input")
+	}
+
+	panel.UpdateInPlace(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("the sky is blue")})
+	cmd := panel.UpdateInPlace(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("expected enter to start embedding")
+	}
+	msg := ollamateatest.Run(t, panel.UpdateInPlace, cmd, ollamateatest.MatchType[ollamatea.EmbedDoneMsg](), 0)
+	panel.UpdateInPlace(msg)
+
+	if reqs := srv.EmbedRequests(); len(reqs) != 1 || reqs[0].Input != "the sky is blue" {
+		t.Errorf("unexpected requests %+v", reqs)
+	}
+	if done := panel.Done(); done == nil || done.Error != nil {
+		t.Fatalf("unexpected %+v", done)
+	}
+	view := panel.View()
+	for _, want := range []string{"Embed\n", "Status: done\n", "Model: all-minilm\n", "Vectors: 1 × 8 dimensions\n",
+		"Latency: ", "Tokens: 4\n", "First: [", ", …]\n", "Input: the sky is blue\n"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in the view %q", want, view)
+		}
+	}
+}
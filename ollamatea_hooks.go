@@ -0,0 +1,206 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Completion hooks
+
+// GenerateEvent describes a completed generation.
+// It is passed to [Hooks.OnGenerate] and is the JSON payload of generate webhooks.
+type GenerateEvent struct {
+	Event      string    `json:"event"` // Event is always "generate"
+	SessionID  int64     `json:"session_id"`
+	Host       string    `json:"host"`
+	Model      string    `json:"model"`
+	System     string    `json:"system,omitempty"`
+	Prompt     string    `json:"prompt"`
+	Suffix     string    `json:"suffix,omitempty"`
+	Response   string    `json:"response"`
+	DoneReason string    `json:"done_reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Metrics    Metrics   `json:"metrics"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// EmbedEvent describes a completed embedding.
+// It is passed to [Hooks.OnEmbed] and is the JSON payload of embed webhooks.
+// The embeddings themselves are not included.
+type EmbedEvent struct {
+	Event           string    `json:"event"` // Event is always "embed"
+	SessionID       int64     `json:"session_id"`
+	Host            string    `json:"host"`
+	Model           string    `json:"model"`
+	Input           any       `json:"input"`
+	Count           int       `json:"count"`      // Count is the number of embeddings
+	Dimensions      int       `json:"dimensions"` // Dimensions is the length of each embedding
+	PromptEvalCount int       `json:"prompt_eval_count,omitempty"`
+	TotalDuration   int64     `json:"total_duration,omitempty"` // nanoseconds
+	CreatedAt       time.Time `json:"created_at"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Hooks are invoked when a Session generation or EmbedSession embedding completes,
+// successfully or not.  They allow logging and analytics integrations without
+// modifying BubbleTea Update loops.
+//
+// Callbacks run in a BubbleTea command goroutine (or the caller's goroutine for
+// [Session.Generate]), so they must not modify models directly.
+type Hooks struct {
+	OnGenerate func(GenerateEvent) // OnGenerate is called when a generation completes
+	OnEmbed    func(EmbedEvent)    // OnEmbed is called when an embedding completes
+
+	// WebhookURL, if set, receives a JSON POST of each GenerateEvent and EmbedEvent.
+	WebhookURL    string
+	WebhookHeader http.Header   // WebhookHeader holds extra request headers, such as Authorization
+	HTTPClient    *http.Client  // HTTPClient is used for webhooks (default: DefaultHTTPClient())
+	Timeout       time.Duration // Timeout for each webhook request (default: 10s)
+	OnError       func(error)   // OnError is called with webhook failures, if set
+}
+
+const defaultHooksTimeout = 10 * time.Second
+
+// fireToken calls the Session's OnToken callback, if set, with a streamed response
+func (s *Session) fireToken(msg GenerateResponseMsg) {
+	if s.OnToken != nil && msg.Response != "" {
+		s.OnToken(msg)
+	}
+}
+
+// fireDone calls the Session's OnDone callback, or OnError if the generation failed
+func (s *Session) fireDone(msg GenerateDoneMsg) {
+	switch {
+	case msg.Error != nil && s.OnError != nil:
+		s.OnError(msg.Error)
+	case msg.Error == nil && s.OnDone != nil:
+		s.OnDone(msg)
+	}
+}
+
+// NewWebhookHooks returns Hooks which POST events to the given URL.
+func NewWebhookHooks(webhookURL string) *Hooks {
+	return &Hooks{WebhookURL: webhookURL}
+}
+
+// fireGenerate invokes the generate callback and webhook
+func (h *Hooks) fireGenerate(ev GenerateEvent) {
+	if h == nil {
+		return
+	}
+	ev.Event = "generate"
+	if h.OnGenerate != nil {
+		h.OnGenerate(ev)
+	}
+	h.postWebhook(ev)
+}
+
+// fireEmbed invokes the embed callback and webhook
+func (h *Hooks) fireEmbed(ev EmbedEvent) {
+	if h == nil {
+		return
+	}
+	ev.Event = "embed"
+	if h.OnEmbed != nil {
+		h.OnEmbed(ev)
+	}
+	h.postWebhook(ev)
+}
+
+// postWebhook POSTs the payload to the WebhookURL, if set
+func (h *Hooks) postWebhook(payload any) {
+	if h.WebhookURL == "" {
+		return
+	}
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHooksTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := doHTTPRequest(ctx, h.HTTPClient, http.MethodPost, h.WebhookURL, "", payload, "*/*")
+	if err == nil {
+		resp.Body.Close()
+	}
+	if err != nil && h.OnError != nil {
+		h.OnError(fmt.Errorf("webhook: %w", err))
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// makeGenerateEvent returns a GenerateEvent for the Session's current request
+func (m *Session) makeGenerateEvent(doneMsg GenerateDoneMsg, err error) GenerateEvent {
+	ev := GenerateEvent{
+		SessionID:  m.id,
+		Host:       m.Host,
+		Model:      m.Model,
+		System:     m.System,
+		Prompt:     m.Prompt,
+		Suffix:     m.Suffix,
+		Response:   doneMsg.Response,
+		DoneReason: doneMsg.DoneReason,
+		CreatedAt:  doneMsg.CreatedAt,
+		Metrics:    doneMsg.Metrics,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+		ev.DoneReason = ""
+	}
+	return ev
+}
+
+// fireHooksCmd returns a command invoking the Session's Hooks, or nil if it has none
+func (m *Session) fireHooksCmd(doneMsg GenerateDoneMsg) tea.Cmd {
+	if m.Hooks == nil {
+		return nil
+	}
+	hooks, ev := m.Hooks, m.makeGenerateEvent(doneMsg, doneMsg.Error)
+	return func() tea.Msg {
+		hooks.fireGenerate(ev)
+		return nil
+	}
+}
+
+// fireHooksCmd returns a command invoking the EmbedSession's Hooks, or nil if it has none
+func (s *EmbedSession) fireHooksCmd(resp *EmbedResponse, err error) tea.Cmd {
+	if s.Hooks == nil {
+		return nil
+	}
+	hooks, ev := s.Hooks, s.makeEmbedEvent(resp, err)
+	return func() tea.Msg {
+		hooks.fireEmbed(ev)
+		return nil
+	}
+}
+
+// makeEmbedEvent returns an EmbedEvent for the EmbedSession's current request
+func (s *EmbedSession) makeEmbedEvent(resp *EmbedResponse, err error) EmbedEvent {
+	ev := EmbedEvent{
+		SessionID: s.id,
+		Host:      s.Host,
+		Model:     s.Model,
+		Input:     s.Input,
+		CreatedAt: time.Now(),
+	}
+	if resp != nil {
+		ev.Count = len(resp.Embeddings)
+		if ev.Count > 0 {
+			ev.Dimensions = len(resp.Embeddings[0])
+		}
+		ev.PromptEvalCount = resp.PromptEvalCount
+		ev.TotalDuration = int64(resp.TotalDuration)
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	return ev
+}
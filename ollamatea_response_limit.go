@@ -0,0 +1,144 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Response size limits
+//
+// A Session accumulates its response as it streams, in a strings.Builder so
+// long generations are not copied on every chunk.  Setting
+// Session.MaxResponseBytes bounds that memory for long-running TUIs, with
+// the ResponseLimitPolicy deciding what happens to text beyond the limit.
+
+// ErrResponseLimit is the error of a generation stopped by [ResponseLimitStop].
+var ErrResponseLimit = errors.New("response size limit exceeded")
+
+// ResponseLimitPolicy is what a Session does when its response exceeds MaxResponseBytes.
+type ResponseLimitPolicy int
+
+const (
+	// ResponseLimitTruncate drops the oldest text from the front of the response.
+	ResponseLimitTruncate ResponseLimitPolicy = iota
+	// ResponseLimitSpill moves the oldest text from the front of the response to a temp file.
+	ResponseLimitSpill
+	// ResponseLimitStop keeps the first MaxResponseBytes and stops the generation with ErrResponseLimit.
+	ResponseLimitStop
+)
+
+// String returns the name of the ResponseLimitPolicy.
+func (p ResponseLimitPolicy) String() string {
+	switch p {
+	case ResponseLimitTruncate:
+		return "truncate"
+	case ResponseLimitSpill:
+		return "spill"
+	case ResponseLimitStop:
+		return "stop"
+	default:
+		return fmt.Sprintf("ResponseLimitPolicy(%d)", int(p))
+	}
+}
+
+// TruncatedBytes returns how many bytes have been dropped or spilled from the
+// front of the response since it was last cleared.
+func (s *Session) TruncatedBytes() int64 {
+	return s.truncatedBytes
+}
+
+// SpillPath returns the path of the temp file holding text spilled by
+// [ResponseLimitSpill], or "" if there is none.  The file is removed when the
+// response is cleared, such as by ClearResponse or a new Generate, or the
+// Session is closed; copy it first to keep it.
+func (s *Session) SpillPath() string {
+	if s.spillFile == nil {
+		return ""
+	}
+	return s.spillFile.Name()
+}
+
+// appendResponse appends text to the response, enforcing MaxResponseBytes.
+// Returns ErrResponseLimit if the generation should stop.
+func (s *Session) appendResponse(text string) error {
+	if s.response == nil {
+		s.response = &strings.Builder{}
+	}
+	s.response.WriteString(text)
+	maxBytes := s.MaxResponseBytes
+	if maxBytes <= 0 || s.response.Len() <= maxBytes {
+		return nil
+	}
+
+	response := s.response.String()
+	if s.ResponseLimit == ResponseLimitStop {
+		end := maxBytes
+		for end > 0 && !utf8.RuneStart(response[end]) {
+			end--
+		}
+		s.setResponse(response[:end])
+		return ErrResponseLimit
+	}
+
+	// Drop down to 3/4 of the limit, so we are not copying on every chunk
+	cut := len(response) - (maxBytes - maxBytes/4)
+	for cut < len(response) && !utf8.RuneStart(response[cut]) {
+		cut++
+	}
+	if s.ResponseLimit == ResponseLimitSpill {
+		if err := s.spill(response[:cut]); err != nil {
+			s.logger().Warn("response spill failed, truncating", "session_id", s.id, "error", err)
+		}
+	}
+	s.setResponse(response[cut:])
+	s.truncatedBytes += int64(cut)
+	return nil
+}
+
+// setResponse replaces the response with text, in a new builder so that
+// strings returned by Response before are unchanged
+func (s *Session) setResponse(text string) {
+	s.response = &strings.Builder{}
+	s.response.Grow(max(s.MaxResponseBytes, len(text)))
+	s.response.WriteString(text)
+}
+
+// spill appends text to the spill file, creating it if needed
+func (s *Session) spill(text string) error {
+	if s.spillFile == nil {
+		f, err := os.CreateTemp("", "ollamatea-response-*.txt")
+		if err != nil {
+			return err
+		}
+		s.spillFile = f
+		s.logger().Debug("response spill file created", "session_id", s.id, "path", f.Name())
+	}
+	_, err := s.spillFile.WriteString(text)
+	return err
+}
+
+// resetResponse clears the response and its truncation state
+func (s *Session) resetResponse() {
+	s.response = nil
+	s.truncatedBytes = 0
+	s.resetThinking()
+	s.removeSpill()
+}
+
+// removeSpill closes and removes the spill file, if any
+func (s *Session) removeSpill() {
+	if s.spillFile == nil {
+		return
+	}
+	s.spillFile.Close()
+	if err := os.Remove(s.spillFile.Name()); err != nil {
+		s.logger().Warn("response spill file removal failed", "session_id", s.id, "error", err)
+	}
+	s.spillFile = nil
+}
@@ -0,0 +1,71 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSessionAppendResponse(t *testing.T) {
+	s := NewSession()
+	for range 1000 {
+		s.appendResponse("token ")
+	}
+	before := s.Response()
+	if before != strings.Repeat("token ", 1000) {
+		t.Fatalf("unexpected response of %d bytes", len(before))
+	}
+
+	s.MaxResponseBytes = 100
+	s.appendResponse("end")
+	if response := s.Response(); len(response) > 100 || !strings.HasSuffix(response, "token end") {
+		t.Errorf("expected a truncated response, got %q", response)
+	}
+	if before != strings.Repeat("token ", 1000) || s.TruncatedBytes() == 0 {
+		t.Error("expected the earlier response to be unchanged by truncation")
+	}
+
+	s.ClearResponse()
+	if s.Response() != "" || s.TruncatedBytes() != 0 {
+		t.Errorf("expected a cleared response, got %q", s.Response())
+	}
+}
+
+func TestSessionSpillRemoved(t *testing.T) {
+	s := NewSession()
+	s.MaxResponseBytes = 100
+	s.ResponseLimit = ResponseLimitSpill
+	spill := func() string {
+		for range 100 {
+			s.appendResponse("token ")
+		}
+		path := s.SpillPath()
+		if _, err := os.Stat(path); path == "" || err != nil {
+			t.Fatalf("expected a spill file, got %q: %v", path, err)
+		}
+		return path
+	}
+
+	path := spill()
+	s.ClearResponse()
+	if _, err := os.Stat(path); !os.IsNotExist(err) || s.SpillPath() != "" {
+		t.Errorf("expected %s removed when cleared, got %v", path, err)
+	}
+	path = spill()
+	s.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected %s removed when closed, got %v", path, err)
+	}
+}
+
+func BenchmarkSessionAppendResponse(b *testing.B) {
+	for range b.N {
+		s := NewSession()
+		for range 10000 {
+			s.appendResponse("token ")
+			_ = s.Response()
+		}
+	}
+}
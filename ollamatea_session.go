@@ -4,10 +4,14 @@ package ollamatea
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
-	"net/url"
-	"sync/atomic"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -29,6 +33,7 @@ type StopGenerateMsg struct {
 // Its handler dispatches the public GenerateResponseMsg and GenerateDoneMsg messages
 type generateResponseMsg struct {
 	ID        int64     // ID is the generation session ID corresponding to the Response
+	Seq       int       // Seq is the generation of the Response, so a restarted one ignores stale chunks
 	CreatedAt time.Time // CreatedAt is the timestamp of the response.
 	Response  string    // Response is the textual response itself.
 
@@ -37,6 +42,18 @@ type generateResponseMsg struct {
 	// Context is an encoding of the conversation used in this response; this
 	// can be sent in the next request to keep a conversational memory.
 	Context []int
+	Metrics Metrics        // Metrics of the generation, set when Done
+	Chunks  int            // Chunks is the count of streamed chunks of text in Response
+	Tokens  []TokenLogprob // Tokens are the log probabilities of Response's tokens, if requested
+}
+
+// generateFailedMsg is the private message returned by a failed generation's command.
+// Its handler records the failure, then dispatches the public GenerateDoneMsg.
+type generateFailedMsg struct {
+	ID       int64 // ID is the generation session ID
+	Seq      int   // Seq is the generation that failed
+	Error    error // Error is why it failed
+	TimedOut bool  // TimedOut is true if it exceeded the Session's Timeout
 }
 
 // GenerateResponseMsg is the message generated each time there is a reply from Ollama.
@@ -50,6 +67,10 @@ type GenerateResponseMsg struct {
 	// Response is the textual response in this specific call.
 	// Use [GenerateDoneMsg] or [Session.Response()] for fuller responses.
 	Response string
+
+	// Tokens are the tokens of Response with their log probabilities, if the
+	// Session's Logprobs is set and its Backend reports them.
+	Tokens []TokenLogprob
 }
 
 // GenerateDoneMsg is the message generated when the generation is complete.
@@ -58,11 +79,61 @@ type GenerateResponseMsg struct {
 type GenerateDoneMsg struct {
 	ID         int64     // ID is the generation session ID corresponding to the Response
 	Response   string    // Full resposne from the Ollama generation
+	Thinking   string    // Thinking is the generation's reasoning, if the Session's SplitThinking is set
 	CreatedAt  time.Time // CreatedAt is the timestamp of the response.
 	DoneReason string    // DoneReason is the reason the model stopped generating text.
 	// Context is an encoding of the conversation used in this response; this
 	// can be sent in the next request to keep a conversational memory.
 	Context []int
+	Metrics Metrics // Metrics are the durations and token counts of the generation
+	Error   error   // Error is why the generation failed, if it did; see [OllamaError]
+}
+
+// GenerateProgressMsg is sent every [Session.ProgressInterval] while a Session
+// generates, or every [ChatSession.ProgressInterval] while a ChatSession replies,
+// for live speed and elapsed-time indicators, such as "generating… 12s".
+// TokensSoFar counts the streamed chunks, which Ollama sends one token at a time.
+type GenerateProgressMsg struct {
+	ID           int64         // ID is the generation session ID
+	TokensSoFar  int           // TokensSoFar is the count of tokens streamed so far
+	Elapsed      time.Duration // Elapsed is the time since the generation started, including model loading
+	TokensPerSec float64       // TokensPerSec is TokensSoFar over Elapsed, or 0 if unknown
+}
+
+// progressTickMsg is the private message prompting a GenerateProgressMsg
+type progressTickMsg struct {
+	ID  int64 // ID is the generation session ID
+	Seq int   // Seq is the generation it was scheduled for
+}
+
+// Stats returns the GenerateStats of the generation
+func (msg GenerateDoneMsg) Stats() GenerateStats {
+	return GenerateStats{Metrics: msg.Metrics}
+}
+
+// GenerateStats are the token counts and durations reported for a generation,
+// with rates for display in status bars.  Durations are zero when the backend
+// does not report them, such as for cached responses.
+type GenerateStats struct {
+	Metrics // TotalDuration, LoadDuration, PromptEvalCount, EvalCount, and more
+}
+
+// TokensPerSecond returns the rate of generated tokens, or 0 if unknown
+func (s GenerateStats) TokensPerSecond() float64 {
+	return tokenRate(s.EvalCount, s.EvalDuration)
+}
+
+// PromptTokensPerSecond returns the rate of evaluated prompt tokens, or 0 if unknown
+func (s GenerateStats) PromptTokensPerSecond() float64 {
+	return tokenRate(s.PromptEvalCount, s.PromptEvalDuration)
+}
+
+// tokenRate returns tokens per second over the duration, or 0 if unknown
+func tokenRate(tokens int, d time.Duration) float64 {
+	if tokens <= 0 || d <= 0 {
+		return 0
+	}
+	return float64(tokens) / d.Seconds()
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -72,16 +143,62 @@ type GenerateDoneMsg struct {
 var lastSessionID int64
 
 func nextSessionID() int64 {
-	return atomic.AddInt64(&lastSessionID, 1)
+	return allocateID(&lastSessionID)
 }
 
-// Type alias in this package for convenience
-type ImageData = ollama.ImageData
+// Type aliases in this package for convenience
+type (
+	ImageData = ollama.ImageData
+	Metrics   = ollama.Metrics
+)
+
+//////////////////////////////////////////////////////////////////////////////
+
+// SessionModel is the generation component interface, implemented by [Session.AsModel].
+// Applications may accept a SessionModel so their Update logic can be unit tested
+// with a scripted fake, such as ollamateatest.FakeSession, instead of a model.
+type SessionModel interface {
+	tea.Model
+
+	ID() int64          // ID returns the unique ID of the session's messages
+	Start() tea.Cmd     // Start returns a command which starts a generation
+	Stop() tea.Cmd      // Stop returns a command which stops the generation, if any
+	IsGenerating() bool // IsGenerating returns true while generating
+	Response() string   // Response returns the response so far
+	Error() error       // Error returns the last error, if any
+}
+
+var _ SessionModel = sessionTeaModel{}
+
+// AsModel returns the Session as a tea.Model and SessionModel, whose Update
+// returns a tea.Model, such as for ScopeByID or code written before
+// Session.Update returned a *Session.  It shares the Session's state.
+func (m *Session) AsModel() SessionModel {
+	return sessionTeaModel{m}
+}
+
+// sessionTeaModel adapts a *Session to the tea.Model interface
+type sessionTeaModel struct {
+	*Session
+}
+
+// Update handles BubbleTea messages for the Session, returning the adapter
+func (a sessionTeaModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	_, cmd := a.Session.Update(msg)
+	return a, cmd
+}
 
 //////////////////////////////////////////////////////////////////////////////
 
 // Session holds the data for an OllamaTea Generate, both its request and built response
 // See https://github.com/ollama/ollama/blob/main/api/types.go#L42
+//
+// A Session is not safe for concurrent use.  Its state changes only in Update,
+// and in its methods called from the same goroutine, such as the tea.Program's;
+// the commands it returns never modify it, but deliver messages to Update.
+// The request is captured when a generation starts, but the other fields, such
+// as Backend and Timeout, are read by its commands, so change them only while
+// the Session is not generating.
 type Session struct {
 	Host     string // Ollama Host -- really the service's URL
 	Model    string // Ollama LLM model.  See https://ollama.com/library
@@ -94,17 +211,126 @@ type Session struct {
 	Images  []ImageData            // List of base64-encoded images
 	Options map[string]interface{} // Options lists model-specific options
 
+	// Format constrains the response; "json" requests a JSON value, decodable with
+	// StructuredCmd.  JSON schemas need Ollama 0.5 and a newer API client than this
+	// module's, so describe the wanted fields in the Prompt instead.
+	Format string
+
+	// Raw sends the Prompt as is, without the model's template, for fully formed
+	// prompts such as hand-built fill-in-the-middle tokens.  System, Template, and
+	// Context are not sent with a Raw request.
+	Raw bool
+
+	// Logprobs requests the log probability of each generated token, delivered in
+	// GenerateResponseMsg.Tokens, with TopLogprobs alternatives for each.  Only
+	// Backends implementing LogprobsGenerator report them; Ollama's does not.
+	Logprobs    bool
+	TopLogprobs int
+
+	Backend Backend // Backend to generate with; if nil, SharedBackend(Host) is used
+	Hooks   *Hooks  // Hooks to invoke when a generation completes, if set
+
+	// Logger, if set, logs the Session's requests, their token counts, durations,
+	// and errors, rather than the Logger of SetLogger.
+	Logger *slog.Logger
+
+	// OnToken, OnDone, and OnError, if set, are called with each streamed
+	// response, each successful GenerateDoneMsg, and each failure, by Generate
+	// and by Update, so the Session can be used without a tea.Program.
+	OnToken func(GenerateResponseMsg)
+	OnDone  func(GenerateDoneMsg)
+	OnError func(error)
+
+	// HTTPClient and Header, if set and Backend is not, make requests to Host with
+	// that client and those headers, such as an mTLS transport or bearer token.
+	HTTPClient *http.Client
+	Header     http.Header
+
+	// Timeout, if positive, bounds each generation, excluding time queued by the
+	// RateLimiter.  One exceeding it is announced with a TimeoutErrorMsg.
+	Timeout time.Duration
+
+	// Retry, if set, retries generations failing transiently before any response
+	// streams; each retry is announced with a RetryingMsg.
+	Retry *RetryPolicy
+
+	// Cache, if set, is consulted before deterministic generations
+	// (temperature 0 or a fixed seed); a hit is delivered as an immediate Done response.
+	Cache ResponseCache
+
+	// RateLimiter, if set, delays generations beyond its limits; it may be shared
+	// with other sessions.  A delayed generation is announced with a QueuedMsg.
+	RateLimiter *RateLimiter
+
+	// MaxResponseBytes, if positive, bounds the response retained by the Session;
+	// ResponseLimit is the policy for text beyond it.
+	MaxResponseBytes int
+	ResponseLimit    ResponseLimitPolicy
+
+	// ResponseBufferSize is the capacity of the buffer delivering streamed responses
+	// to Update, applied by Init; Backpressure is the policy when it is full.
+	ResponseBufferSize int
+	Backpressure       BackpressurePolicy
+
+	// FlushInterval, if positive, batches streamed chunks into one GenerateResponseMsg
+	// per interval (50ms suits most UIs); 0 delivers each chunk as it arrives.
+	// FlushChunks, if positive, also delivers a batch once it has that many chunks,
+	// or alone, batches by count without regard to time.
+	FlushInterval time.Duration
+	FlushChunks   int
+
+	// ProgressInterval, if positive, is how often a GenerateProgressMsg is sent
+	// while generating; 0 sends none.  ChatPanelModel uses DefaultProgressInterval.
+	ProgressInterval time.Duration
+
+	// SplitThinking, if true, parses <think>…</think> reasoning out of the
+	// response, delivering it as GenerateThinkingMsg; see Thinking.
+	SplitThinking bool
+
+	// AutoContext, if true, sets Context to that returned by each successful
+	// generation, carrying on the conversation without copying it by hand.
+	AutoContext bool
+
+	// DryRun, if true, answers each StartGenerateMsg with a RequestPreviewMsg
+	// of the request which would be sent, rather than generating.
+	DryRun bool
+
+	// AutoTitle, if true, generates a title for the conversation after the first
+	// successful generation, delivered as a ConversationTitledMsg.
+	AutoTitle bool
+
 	// Private
-	ctx        context.Context
-	cancelFunc context.CancelFunc
-	id         int64 // Unique Session ID
-	lastError  error // Last error
+	parentCtx   context.Context // parent of each generation's context; see WithContext
+	ctx         context.Context
+	cancelFunc  context.CancelFunc
+	cancelCause context.CancelCauseFunc // cancels ctx with a cause, such as ErrResponseLimit
+	id          int64                   // Unique Session ID
+	lastError   error                   // Last error
+
+	isGenerating   bool                     // Currently inferencing? Only one per session
+	genSeq         int                      // counts generations, so a stale failure does not end the current one
+	request        *ollama.GenerateRequest  // of the generation in flight, captured by Update
+	respCh         chan generateResponseMsg // Channel for responses message dispatch
+	closed         chan struct{}            // closed by Close to stop the response listener
+	response       *strings.Builder         // Ollama response, if any; see appendResponse
+	truncatedBytes int64                    // bytes dropped from the front of response
+	spillFile      *os.File                 // holds text spilled from response, if any
+	titled         bool                     // AutoTitle has been started
+	streamWriter   io.Writer                // receives streamed text, if set; see StreamTo
+	stats          GenerateStats            // of the last completed generation
+	history        []Turn                   // of successful generations; see History
+	thinking       *strings.Builder         // reasoning, if SplitThinking; see Thinking
+	thinkParser    thinkParser              // splits the streaming response, if SplitThinking
 
-	isGenerating bool                     // Currently inferencing? Only one per session
-	respCh       chan generateResponseMsg // Channel for responses message dispatch
-	response     string                   // Ollama response
+	progressSeq   int       // counts generations, so stale progress ticks are ignored
+	progressing   bool      // progress ticks are running for progressSeq
+	progressStart time.Time // when the generation started
+	progressCount int       // tokens streamed so far
 }
 
+// DefaultProgressInterval is the ProgressInterval used by ChatPanelModel.
+const DefaultProgressInterval = time.Second
+
 // NewSession returns a new Session with the default values.
 func NewSession() Session {
 	return Session{
@@ -114,7 +340,10 @@ func NewSession() Session {
 		System:       DefaultSystemPrompt(),
 		id:           nextSessionID(),
 		isGenerating: false,
-		respCh:       make(chan generateResponseMsg, 100),
+
+		ResponseBufferSize: DefaultResponseBufferSize,
+		respCh:             make(chan generateResponseMsg, DefaultResponseBufferSize),
+		closed:             make(chan struct{}),
 	}
 }
 
@@ -123,6 +352,12 @@ func (s *Session) ID() int64 {
 	return s.id
 }
 
+// SetID sets the ID of the Session, such as to a stable ID for tests or replays.
+// The ID must be unique among Sessions receiving the same messages.
+func (s *Session) SetID(id int64) {
+	s.id = id
+}
+
 // ISGenerating returns true if the Session is currently generating
 func (s *Session) IsGenerating() bool {
 	return s.isGenerating
@@ -130,7 +365,10 @@ func (s *Session) IsGenerating() bool {
 
 // Response returns the last generation from the Session
 func (s *Session) Response() string {
-	return s.response
+	if s.response == nil {
+		return ""
+	}
+	return s.response.String()
 }
 
 // Error returns the last error from the Session, if any
@@ -138,9 +376,15 @@ func (s *Session) Error() error {
 	return s.lastError
 }
 
+// Stats returns the token counts, durations, and rates of the Session's last
+// completed generation.  They are zero until a generation completes.
+func (s *Session) Stats() GenerateStats {
+	return s.stats
+}
+
 // ClearResponse clears the last response from the Session
 func (s *Session) ClearResponse() {
-	s.response = ""
+	s.resetResponse()
 }
 
 // ClearError clears the last error from the Session
@@ -148,27 +392,123 @@ func (s *Session) ClearError() {
 	s.lastError = nil
 }
 
+// Close cancels any generation and stops the Session's response listener,
+// so no goroutines outlive it.  Call it when discarding a Session, such as
+// when a conversation ends in a long-lived program.  The Response is kept,
+// but a closed Session cannot generate again, and its SpillPath is removed.
+func (s *Session) Close() {
+	if s.cancelFunc != nil {
+		s.cancelFunc()
+		s.cancelFunc = nil
+	}
+	s.isGenerating = false
+	s.ctx = nil
+	if s.closed != nil {
+		select {
+		case <-s.closed:
+		default:
+			close(s.closed)
+		}
+	}
+	s.removeSpill()
+}
+
+// WithContext sets the parent context of the Session's generations, returning the Session.
+// When ctx is done, such as at program shutdown, any generation is cancelled.
+func (s *Session) WithContext(ctx context.Context) *Session {
+	s.parentCtx = ctx
+	return s
+}
+
+// WithHTTPClient sets the HTTPClient of the Session's requests, returning the Session.
+func (s *Session) WithHTTPClient(client *http.Client) *Session {
+	s.HTTPClient = client
+	return s
+}
+
+// WithHeader sets a Header of the Session's requests, returning the Session.
+// For example, WithHeader("Authorization", "Bearer "+token).
+func (s *Session) WithHeader(key string, value string) *Session {
+	if s.Header == nil {
+		s.Header = make(http.Header)
+	}
+	s.Header.Set(key, value)
+	return s
+}
+
+// WithHeaders sets Headers of the Session's requests, replacing any values
+// already set for their keys, returning the Session.
+func (s *Session) WithHeaders(header http.Header) *Session {
+	s.Header = mergeHeader(s.Header, header)
+	return s
+}
+
+// mergeHeader returns dst with the values of src, replacing those of the same keys.
+// dst is allocated if nil.
+func mergeHeader(dst http.Header, src http.Header) http.Header {
+	if dst == nil {
+		dst = make(http.Header, len(src))
+	}
+	for key, values := range src {
+		dst[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+	}
+	return dst
+}
+
+// parentContext returns the parent context of generations
+func (s *Session) parentContext() context.Context {
+	if s.parentCtx != nil {
+		return s.parentCtx
+	}
+	return context.Background()
+}
+
+// newGenerateContext sets up the context of a new generation
+func (s *Session) newGenerateContext() {
+	ctx, cancelCause := context.WithCancelCause(s.parentContext())
+	s.ctx, s.cancelCause = ctx, cancelCause
+	s.cancelFunc = func() { cancelCause(context.Canceled) }
+}
+
 // StartGenerateMsg returns a StartGenerateMsg for this Session ID
 func (s *Session) StartGenerateMsg() tea.Msg {
 	return StartGenerateMsg{ID: s.id}
 }
 
+// Start returns a command which starts a generation of the Session.
+func (s *Session) Start() tea.Cmd {
+	return s.StartGenerateMsg
+}
+
+// Stop returns a command which stops the Session's generation, if any.
+func (s *Session) Stop() tea.Cmd {
+	return Cmdize(StopGenerateMsg{ID: s.id})
+}
+
 //////////////////////////////////////////////////////////////////////////////
 // BubbleTea interface
 
 // Init handles the initialization of an Session
 func (m *Session) Init() tea.Cmd {
-	return generateWaitForResponse(m.respCh) // start the response listener
+	if m.ResponseBufferSize > 0 && m.ResponseBufferSize != cap(m.respCh) {
+		m.respCh = make(chan generateResponseMsg, m.ResponseBufferSize)
+	}
+	return m.waitForResponse() // start the response listener
 }
 
 // Update handles BubbleTea messages for the Session
 // This is for starting/stopping/updating generation.
-func (m *Session) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+// Like ModelChooser and ChatPanelModel, it returns its own type; use AsModel
+// where a tea.Model is needed.
+func (m *Session) Update(msg tea.Msg) (*Session, tea.Cmd) {
 	switch msg := msg.(type) {
 	case StartGenerateMsg:
 		if msg.ID != m.id {
 			return m, nil
 		}
+		if m.DryRun {
+			return m, Cmdize(m.previewMsg())
+		}
 		if m.isGenerating {
 			// Cancel current inference
 			if m.cancelFunc != nil {
@@ -179,53 +519,131 @@ func (m *Session) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.isGenerating = false
 			// TODO: done message send?
 		}
-		return m, m.startGeneratingCmd()
+		m.thinkParser = thinkParser{}
+		m.isGenerating = true
+		m.newGenerateContext()
+		m.genSeq++
+		m.request = m.makeGenerateRequest()
+		return m, tea.Batch(m.startGeneratingCmd(), m.startProgress())
 
 	case StopGenerateMsg:
 		if msg.ID != m.id {
 			return m, nil
 		}
+		m.logger().Debug("generate stop requested", "session_id", m.id, "generating", m.isGenerating)
 		if m.cancelFunc != nil {
 			m.cancelFunc()
 			m.cancelFunc = nil
 		}
 		m.ctx = nil
 		m.isGenerating = false
+		m.progressing = false
 		// TODO: done message send?
 		return m, nil
 
+	case progressTickMsg:
+		if msg.ID != m.id || msg.Seq != m.progressSeq || !m.progressing {
+			return m, nil
+		}
+		return m, tea.Batch(Cmdize(m.progressMsg()), m.progressTickCmd())
+
+	case GenerateDoneMsg:
+		if msg.ID == m.id {
+			m.progressing = false // such as a failed generation's
+			m.fireDone(msg)
+		}
+		return m, nil
+
+	case generateFailedMsg:
+		if msg.ID != m.id {
+			return m, nil
+		}
+		if msg.Seq != m.genSeq {
+			return m, nil // such as a restarted generation's, which was cancelled
+		}
+		m.lastError = msg.Error
+		m.isGenerating = false
+		return m, m.failedCmd(msg)
+
+	case QueuedMsg:
+		if msg.ID != m.id || msg.Seq != m.genSeq || !m.isGenerating {
+			return m, nil
+		}
+		m.logger().Debug("generate queued", "session_id", m.id, "waiting", msg.Waiting)
+		return m, m.waitAndGenerateCmd()
+
+	case RetryingMsg:
+		if msg.ID != m.id || msg.Request != "generate" || msg.Seq != m.genSeq || !m.isGenerating {
+			return m, nil
+		}
+		return m, m.retryGenerateCmd(msg)
+
 	case generateResponseMsg:
 		if msg.ID != m.id {
 			return m, nil
 		}
-		// TODO: string builder
-		m.response = m.response + msg.Response
+		if msg.Seq != m.genSeq {
+			return m, m.waitForResponse() // a cancelled generation's, left in the buffer
+		}
+		text := msg.Response
+		var thinkCmd tea.Cmd
+		if m.SplitThinking {
+			var thinking string
+			text, thinking = m.splitThinking(text, msg.Done)
+			if thinking != "" {
+				thinkCmd = Cmdize(GenerateThinkingMsg{ID: m.id, CreatedAt: msg.CreatedAt, Thinking: thinking})
+			}
+		}
+		limitErr := m.appendResponse(text)
+		m.progressCount += msg.Chunks
 
 		respMsg := GenerateResponseMsg{
 			ID:        m.id,
 			CreatedAt: msg.CreatedAt,
-			Response:  msg.Response,
+			Response:  text,
+			Tokens:    msg.Tokens,
+		}
+		m.fireToken(respMsg)
+
+		if limitErr != nil && !msg.Done {
+			// the generation's command sends GenerateDoneMsg once cancelled
+			if m.cancelCause != nil {
+				m.cancelCause(limitErr)
+			}
+			return m, tea.Batch(thinkCmd, Cmdize(respMsg), m.waitForResponse())
 		}
 
 		if !msg.Done {
-			return m, tea.Batch(Cmdize(respMsg), generateWaitForResponse(m.respCh))
+			return m, tea.Batch(thinkCmd, Cmdize(respMsg), m.waitForResponse())
 		}
 
 		// We are done generating
 		m.isGenerating = false
+		m.progressing = false
 		doneMsg := GenerateDoneMsg{
 			ID:         m.id,
 			CreatedAt:  msg.CreatedAt,
 			DoneReason: msg.DoneReason,
-			Response:   m.response,
+			Response:   m.Response(),
+			Thinking:   m.Thinking(),
 			Context:    msg.Context,
+			Metrics:    msg.Metrics,
 		}
+		m.stats = doneMsg.Stats()
+		m.appendDoneTurn(doneMsg)
+		m.carryContext(doneMsg)
 
-		return m, tea.Sequence(
+		cmd := tea.Sequence(
+			thinkCmd,
 			Cmdize(respMsg),
 			Cmdize(doneMsg),
-			generateWaitForResponse(m.respCh),
+			m.fireHooksCmd(doneMsg),
+			m.waitForResponse(),
 		)
+		if titleCmd := m.autoTitleCmd(); titleCmd != nil {
+			cmd = tea.Batch(cmd, titleCmd)
+		}
+		return m, cmd
 	}
 	return m, nil
 }
@@ -242,30 +660,136 @@ func (m *Session) View() string {
 
 //////////////////////////////////////////////////////////////////////////////
 
-// startGeneratingCmd is a tea.Msg wrapper for startGenerating
+// startProgress resets the progress counters for a new generation,
+// returning the command for its first progress tick, if any
+func (m *Session) startProgress() tea.Cmd {
+	m.progressSeq++
+	m.progressStart = time.Now()
+	m.progressCount = 0
+	m.progressing = m.ProgressInterval > 0
+	return m.progressTickCmd()
+}
+
+// progressTickCmd returns a command delivering a progressTickMsg after ProgressInterval
+func (m *Session) progressTickCmd() tea.Cmd {
+	if m.ProgressInterval <= 0 {
+		return nil
+	}
+	id, seq := m.id, m.progressSeq
+	return tea.Tick(m.ProgressInterval, func(time.Time) tea.Msg {
+		return progressTickMsg{ID: id, Seq: seq}
+	})
+}
+
+// progressMsg returns a GenerateProgressMsg for the generation so far
+func (m *Session) progressMsg() GenerateProgressMsg {
+	elapsed := time.Since(m.progressStart)
+	return GenerateProgressMsg{
+		ID:           m.id,
+		TokensSoFar:  m.progressCount,
+		Elapsed:      elapsed,
+		TokensPerSec: tokenRate(m.progressCount, elapsed),
+	}
+}
+
+// startGeneratingCmd returns a command which starts the generation captured by Update.
+// If the RateLimiter delays it, a QueuedMsg is returned instead.
 func (m *Session) startGeneratingCmd() tea.Cmd {
+	ctx, req, seq := m.ctx, m.request, m.genSeq
 	return func() tea.Msg {
-		return m.startGenerating()
+		if m.RateLimiter == nil {
+			return m.generate(ctx, req, seq, 1)
+		}
+		release, ok := m.RateLimiter.TryAcquire()
+		if !ok {
+			queued := m.RateLimiter.queuedMsg(m.id)
+			queued.Seq = seq
+			return queued
+		}
+		defer release()
+		return m.generate(ctx, req, seq, 1)
 	}
 }
 
-// startGenerating starts generation for a Session
-// Performs the actual Ollama /generate call
-func (m *Session) startGenerating() tea.Msg {
-	if m.isGenerating {
-		return nil
+// waitAndGenerateCmd returns a command which waits for the RateLimiter, then generates
+func (m *Session) waitAndGenerateCmd() tea.Cmd {
+	ctx, req, seq := m.ctx, m.request, m.genSeq
+	return func() tea.Msg {
+		release, err := m.RateLimiter.Wait(ctx)
+		if err != nil {
+			m.logger().Info("generate canceled while queued", "session_id", m.id, "error", err)
+			return generateFailedMsg{ID: m.id, Seq: seq, Error: err}
+		}
+		defer release()
+		return m.generate(ctx, req, seq, 1)
 	}
-	m.isGenerating = true
-	m.ctx, m.cancelFunc = context.WithCancel(context.Background())
+}
 
-	ollamaURL, err := url.Parse(m.Host)
+// retryGenerateCmd returns a command which waits out the RetryingMsg's Delay, then generates again
+func (m *Session) retryGenerateCmd(msg RetryingMsg) tea.Cmd {
+	ctx, req, seq := m.ctx, m.request, m.genSeq
+	return func() tea.Msg {
+		if err := sleepContext(ctx, msg.Delay); err != nil {
+			return generateFailedMsg{ID: m.id, Seq: seq, Error: err}
+		}
+		if m.RateLimiter != nil {
+			release, err := m.RateLimiter.Wait(ctx)
+			if err != nil {
+				return generateFailedMsg{ID: m.id, Seq: seq, Error: err}
+			}
+			defer release()
+		}
+		return m.generate(ctx, req, seq, msg.Attempt)
+	}
+}
+
+// failedCmd returns the command dispatching a failed generation's GenerateDoneMsg,
+// preceded by a TimeoutErrorMsg if it timed out, and invoking the Hooks
+func (m *Session) failedCmd(msg generateFailedMsg) tea.Cmd {
+	id, timeout := m.id, m.Timeout
+	doneMsg := makeGenerateDoneErrorMsg(id, msg.Error)
+	return tea.Batch(
+		m.fireHooksCmd(doneMsg),
+		func() tea.Msg {
+			if msg.TimedOut {
+				return timeoutMsg(id, timeout, msg.Error, doneMsg)
+			}
+			return doneMsg
+		})
+}
+
+// generate performs attempt of the actual Ollama /generate call, streaming to respCh.
+// It reads, but does not modify, the Session; its failure is returned as a generateFailedMsg.
+func (m *Session) generate(ctx context.Context, req *ollama.GenerateRequest, seq int, attempt int) tea.Msg {
+	backend, err := resolveClientBackend(m.Backend, m.Host, m.HTTPClient, m.Header)
 	if err != nil {
-		m.lastError = err
-		m.isGenerating = false
-		return Cmdize(makeGenerateDoneErrorMsg(m.id, err))
+		m.logger().Error("generate backend failed", "session_id", m.id, "host", m.Host, "error", err)
+		return generateFailedMsg{ID: m.id, Seq: seq, Error: err}
 	}
 
-	ollamaClient := ollama.NewClient(ollamaURL, http.DefaultClient)
+	reqCtx, cancel := withRequestTimeout(ctx, m.Timeout)
+	defer cancel()
+	sender := m.newResponseSender(reqCtx, seq)
+	backend = m.withLogprobs(backend, sender.setTokens)
+	err = m.instrumentedGenerate(reqCtx, backend, req, sender.send)
+	sender.close(req.Model)
+	if err != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, ErrResponseLimit) {
+			err = cause
+		}
+		if !sender.sent && ctx.Err() == nil {
+			if retryMsg, ok := m.Retry.retryingMsg(m.id, "generate", attempt, err); ok {
+				retryMsg.Seq = seq
+				return retryMsg
+			}
+		}
+		return generateFailedMsg{ID: m.id, Seq: seq, Error: err, TimedOut: requestTimedOut(reqCtx, ctx)}
+	}
+	return nil
+}
+
+// makeGenerateRequest returns an Ollama GenerateRequest from the Session's fields
+func (m *Session) makeGenerateRequest() *ollama.GenerateRequest {
 	req := &ollama.GenerateRequest{
 		Model:    m.Model,
 		Prompt:   m.Prompt,
@@ -275,43 +799,236 @@ func (m *Session) startGenerating() tea.Msg {
 		Context:  m.Context,
 		Options:  m.Options,
 		Images:   m.Images,
+		Format:   m.Format,
+		Raw:      m.Raw,
+	}
+	if m.Raw {
+		// Ollama rejects raw requests with any of these
+		req.System, req.Template, req.Context = "", "", nil
 	}
+	return req
+}
 
-	respFunc := func(resp ollama.GenerateResponse) error {
-		m.respCh <- generateResponseMsg{
-			ID:         m.id,
-			CreatedAt:  resp.CreatedAt,
-			Response:   resp.Response,
-			Done:       resp.Done,
-			DoneReason: resp.DoneReason,
-			Context:    resp.Context,
+// instrumentedGenerate performs the Backend generation within a trace span, recording metrics and logs.
+// The Session's Cache, if any, is consulted first and updated after.
+func (m *Session) instrumentedGenerate(ctx context.Context, backend Backend, req *ollama.GenerateRequest, fn ollama.GenerateResponseFunc) error {
+	log := m.logger().With("session_id", m.id, "host", m.Host, "model", req.Model)
+	if m.streamWriter != nil {
+		fn = teeResponses(m.streamWriter, fn)
+	}
+
+	var cacheKey string
+	var cacheable bool
+	if m.Cache != nil {
+		cacheKey, cacheable = ResponseCacheKey(m.Host, req)
+	}
+	if cacheable {
+		if cached, ok := m.Cache.Get(cacheKey); ok {
+			log.Debug("generate cache hit", "cache_key", cacheKey)
+			return fn(ollama.GenerateResponse{
+				Model:      req.Model,
+				CreatedAt:  time.Now(),
+				Response:   cached.Response,
+				Done:       true,
+				DoneReason: cached.DoneReason,
+				Context:    cached.Context,
+				Metrics:    cached.Metrics,
+			})
 		}
-		return nil
 	}
 
-	err = ollamaClient.Generate(m.ctx, req, respFunc)
+	log.Debug("generate start", "prompt_bytes", len(req.Prompt), "images", len(req.Images))
+
+	ctx, span := startSpan(ctx, "ollamatea.generate",
+		Attr("ollamatea.session_id", m.id),
+		Attr("ollamatea.host", m.Host),
+		Attr("ollamatea.model", req.Model))
+	defer span.End()
+
+	start := time.Now()
+	chunks, promptTokens, evalTokens := 0, 0, 0
+	sawDone := false
+	var sb strings.Builder
+	var doneResp *ollama.GenerateResponse
+	err := backend.Generate(ctx, req, func(resp ollama.GenerateResponse) error {
+		if err := ctx.Err(); err != nil {
+			return err // stop promptly, even if the Backend does not
+		}
+		chunks++
+		if cacheable {
+			sb.WriteString(resp.Response)
+			if resp.Done {
+				doneResp = &resp
+			}
+		}
+		span.AddEvent("chunk", Attr("ollamatea.chunk_bytes", len(resp.Response)))
+		if resp.Done {
+			sawDone = true
+			promptTokens, evalTokens = resp.PromptEvalCount, resp.EvalCount
+			span.SetAttributes(
+				Attr("ollamatea.chunks", chunks),
+				Attr("ollamatea.done_reason", resp.DoneReason),
+				Attr("ollamatea.prompt_eval_count", resp.PromptEvalCount),
+				Attr("ollamatea.eval_count", resp.EvalCount))
+		}
+		return fn(resp)
+	})
+	if err == nil && !sawDone && ctx.Err() != nil {
+		err = ctx.Err() // some Backends end a cancelled stream quietly
+	}
+	err = ClassifyError(err, m.Host, req.Model)
+	latency := time.Since(start)
+	switch {
+	case err == nil:
+		log.Info("generate done", "chunks", chunks, "prompt_eval_count", promptTokens, "eval_count", evalTokens, "latency", latency)
+	case ctx.Err() != nil:
+		log.Info("generate canceled", "chunks", chunks, "latency", latency, "error", err)
+	default:
+		log.Error("generate failed", "chunks", chunks, "latency", latency, "error", err)
+	}
 	if err != nil {
-		m.lastError = err
-		return Cmdize(makeGenerateDoneErrorMsg(m.id, err))
+		span.RecordError(err)
+	}
+	observeMetrics("generate", m.Host, req.Model, latency, promptTokens, evalTokens, err)
+
+	if err == nil && doneResp != nil {
+		cacheErr := m.Cache.Set(cacheKey, CachedResponse{
+			Response:   sb.String(),
+			DoneReason: doneResp.DoneReason,
+			Context:    doneResp.Context,
+			Metrics:    doneResp.Metrics,
+			CreatedAt:  time.Now(),
+		})
+		if cacheErr != nil {
+			log.Warn("generate cache store failed", "error", cacheErr)
+		}
+	}
+	return err
+}
+
+// autoTitleCmd returns a command titling the first exchange, if AutoTitle is set and it has not been titled
+func (m *Session) autoTitleCmd() tea.Cmd {
+	if !m.AutoTitle || m.titled || m.Prompt == "" || m.Response() == "" {
+		return nil
+	}
+	m.titled = true
+	id, backend, host, model := m.id, m.Backend, m.Host, m.Model
+	messages := []Message{{Role: "user", Content: m.Prompt}, {Role: "assistant", Content: m.Response()}}
+	return func() tea.Msg {
+		return titleConversationMsg(id, backend, host, model, messages)
 	}
-	return nil
 }
 
-func makeGenerateDoneErrorMsg(id int64, err error) tea.Msg {
+func makeGenerateDoneErrorMsg(id int64, err error) GenerateDoneMsg {
 	return GenerateDoneMsg{
 		ID:         id,
 		Response:   "",
 		CreatedAt:  time.Now(),
 		DoneReason: err.Error(),
 		Context:    nil,
+		Error:      err,
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// Generate performs a blocking generation for the Session, outside of BubbleTea.
+// If onResponse is non-nil, it is invoked with each streamed partial response.
+// Returns the final GenerateDoneMsg, or an error if the generation failed.
+// The Session's Response and Error are also updated.
+// If the Session has a RateLimiter, Generate first waits for it; the Timeout,
+// if any, applies after.  Transient failures are retried by the Retry policy, if any.
+func (m *Session) Generate(ctx context.Context, onResponse func(GenerateResponseMsg)) (GenerateDoneMsg, error) {
+	backend, err := resolveClientBackend(m.Backend, m.Host, m.HTTPClient, m.Header)
+	if err != nil {
+		m.logger().Error("generate backend failed", "session_id", m.id, "host", m.Host, "error", err)
+		m.lastError = err
+		return GenerateDoneMsg{}, err
+	}
+	if m.RateLimiter != nil {
+		release, err := m.RateLimiter.Wait(ctx)
+		if err != nil {
+			m.lastError = err
+			return GenerateDoneMsg{}, err
+		}
+		defer release()
 	}
+
+	m.resetResponse()
+	var doneMsg GenerateDoneMsg
+	var tokens []TokenLogprob
+	backend = m.withLogprobs(backend, func(t []TokenLogprob) { tokens = t })
+	respFunc := func(resp ollama.GenerateResponse) error {
+		text := resp.Response
+		if m.SplitThinking {
+			text, _ = m.splitThinking(text, resp.Done)
+		}
+		limitErr := m.appendResponse(text)
+		respMsg := GenerateResponseMsg{
+			ID:        m.id,
+			CreatedAt: resp.CreatedAt,
+			Response:  text,
+			Tokens:    tokens,
+		}
+		if onResponse != nil {
+			onResponse(respMsg)
+		}
+		m.fireToken(respMsg)
+		tokens = nil
+		if resp.Done {
+			doneMsg = GenerateDoneMsg{
+				ID:         m.id,
+				Response:   m.Response(),
+				Thinking:   m.Thinking(),
+				CreatedAt:  resp.CreatedAt,
+				DoneReason: resp.DoneReason,
+				Context:    resp.Context,
+				Metrics:    resp.Metrics,
+			}
+			return nil
+		}
+		return limitErr
+	}
+
+	for attempt := 1; ; attempt++ {
+		reqCtx, cancel := withRequestTimeout(ctx, m.Timeout)
+		err = m.instrumentedGenerate(reqCtx, backend, m.makeGenerateRequest(), respFunc)
+		cancel()
+		retryMsg, ok := m.Retry.retryingMsg(m.id, "generate", attempt, err)
+		if !ok || m.Response() != "" || ctx.Err() != nil {
+			break
+		}
+		if err = sleepContext(ctx, retryMsg.Delay); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		m.lastError = err
+		m.Hooks.fireGenerate(m.makeGenerateEvent(GenerateDoneMsg{Response: m.Response(), CreatedAt: time.Now()}, err))
+		m.fireDone(GenerateDoneMsg{ID: m.id, CreatedAt: time.Now(), Error: err})
+		return GenerateDoneMsg{}, err
+	}
+	m.lastError = nil
+	m.stats = doneMsg.Stats()
+	m.appendDoneTurn(doneMsg)
+	m.carryContext(doneMsg)
+	m.Hooks.fireGenerate(m.makeGenerateEvent(doneMsg, nil))
+	m.fireDone(doneMsg)
+	return doneMsg, nil
 }
 
 //////////////////////////////////////////////////////////////////////////////
 
-// generateWaitForResponse is a command that waits for the responses on the channel
-func generateWaitForResponse(sub chan generateResponseMsg) tea.Cmd {
+// waitForResponse is a command that waits for the responses on the channel,
+// until the Session is closed
+func (m *Session) waitForResponse() tea.Cmd {
+	sub, closed := m.respCh, m.closed
 	return func() tea.Msg {
-		return generateResponseMsg(<-sub)
+		select {
+		case msg := <-sub:
+			return msg
+		case <-closed:
+			return nil
+		}
 	}
 }
@@ -0,0 +1,434 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSessionRaw(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Chunks: []string{"return a + b"}})
+	session.System = "You are a helpful assistant."
+	session.Context = []int{1, 2, 3}
+	session.Prompt = "<|fim_prefix|>def add(a, b):\n    <|fim_suffix|>\n<|fim_middle|>"
+	session.Raw = true
+	if _, err := session.Generate(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected one request, got %d", len(reqs))
+	}
+	if req := reqs[0]; !req.Raw || req.Prompt != session.Prompt || req.System != "" || req.Context != nil {
+		t.Errorf("expected a raw request with only the prompt, got %#v", req)
+	}
+}
+
+func TestSessionStats(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{
+		Chunks: []string{"one", " two", " three"},
+		Delay:  5 * time.Millisecond,
+	})
+	if stats := session.Stats(); stats.EvalCount != 0 || stats.TokensPerSecond() != 0 {
+		t.Errorf("expected no stats before generating, got %+v", stats)
+	}
+	doneMsg, err := session.Generate(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := session.Stats()
+	if stats != doneMsg.Stats() || stats.EvalCount != 3 || stats.TotalDuration <= 0 || stats.TokensPerSecond() <= 0 {
+		t.Errorf("expected the done message's stats, got %+v", stats)
+	}
+
+	stats = ollamatea.GenerateStats{Metrics: ollamatea.Metrics{
+		PromptEvalCount: 10, PromptEvalDuration: 100 * time.Millisecond,
+		EvalCount: 50, EvalDuration: 2 * time.Second,
+	}}
+	if stats.TokensPerSecond() != 25 || stats.PromptTokensPerSecond() != 100 {
+		t.Errorf("unexpected rates %v and %v", stats.TokensPerSecond(), stats.PromptTokensPerSecond())
+	}
+}
+
+func TestSessionProgress(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{
+		Chunks: []string{"one", " two", " three", " four"},
+		Delay:  20 * time.Millisecond,
+	})
+	session.ProgressInterval = 30 * time.Millisecond
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	match := ollamateatest.MatchAny(
+		ollamateatest.MatchType[ollamatea.GenerateProgressMsg](),
+		ollamateatest.MatchGenerateDone(session.ID()))
+	msg := ollamateatest.Run(t, update, tea.Batch(session.Init(), session.Start()), match, 0)
+	progress, ok := msg.(ollamatea.GenerateProgressMsg)
+	if !ok {
+		t.Fatalf("expected a progress message before done, got %#v", msg)
+	}
+	if progress.ID != session.ID() || progress.Elapsed < session.ProgressInterval {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+	if progress.TokensSoFar > 0 && progress.TokensPerSec <= 0 {
+		t.Errorf("expected a rate with %d tokens, got %+v", progress.TokensSoFar, progress)
+	}
+}
+
+func TestSessionTimeout(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	slow := ollamateatest.GenerateScript{Chunks: []string{"too", " slow"}, Delay: 200 * time.Millisecond}
+	session := ollamateatest.ScriptedSession(srv, slow, slow)
+	session.Timeout = 20 * time.Millisecond
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(session.Init(), session.Start()),
+		ollamateatest.MatchType[ollamatea.TimeoutErrorMsg](), 0)
+	timeout := msg.(ollamatea.TimeoutErrorMsg)
+	if timeout.ID != session.ID() || timeout.Timeout != session.Timeout {
+		t.Errorf("unexpected timeout message %+v", timeout)
+	}
+	if kind := ollamatea.ErrorKindOf(timeout.Error); kind != ollamatea.ErrorKindTimeout {
+		t.Errorf("expected a timeout error, got %v: %v", kind, timeout.Error)
+	}
+
+	if _, err := session.Generate(context.Background(), nil); ollamatea.ErrorKindOf(err) != ollamatea.ErrorKindTimeout {
+		t.Errorf("expected Generate to time out, got %v", err)
+	}
+}
+
+func TestSessionRetry(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	unavailable := ollamateatest.GenerateScript{Status: http.StatusServiceUnavailable, Error: "server busy"}
+	ok := ollamateatest.GenerateScript{Chunks: []string{"recovered"}}
+	session := ollamateatest.ScriptedSession(srv, unavailable, ok, unavailable, unavailable)
+	session.Retry = &ollamatea.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+
+	var retries []ollamatea.RetryingMsg
+	update := func(msg tea.Msg) tea.Cmd {
+		if retry, ok := msg.(ollamatea.RetryingMsg); ok {
+			retries = append(retries, retry)
+		}
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(session.Init(), session.Start()),
+		ollamateatest.MatchGenerateDone(session.ID()), 0)
+	if done := msg.(ollamatea.GenerateDoneMsg); done.Error != nil || done.Response != "recovered" {
+		t.Errorf("expected the retry to succeed, got %+v", done)
+	}
+	if len(retries) != 1 || retries[0].Attempt != 2 || retries[0].MaxAttempts != 2 || retries[0].Request != "generate" {
+		t.Errorf("expected one retry announced, got %+v", retries)
+	}
+
+	_, err := session.Generate(context.Background(), nil)
+	if ollamatea.ErrorKindOf(err) != ollamatea.ErrorKindServerError {
+		t.Errorf("expected a server error after the last attempt, got %v", err)
+	}
+	if reqs := srv.GenerateRequests(); len(reqs) != 4 {
+		t.Errorf("expected 4 requests, got %d", len(reqs))
+	}
+}
+
+// headerRecorder is an http.RoundTripper recording the Authorization header of each request
+type headerRecorder struct {
+	auths []string
+}
+
+func (r *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.auths = append(r.auths, req.Header.Get("Authorization"))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestSessionHTTPClientAndHeader(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	recorder := &headerRecorder{}
+	session := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Chunks: []string{"ok"}})
+	session.WithHTTPClient(&http.Client{Transport: recorder}).WithHeader("Authorization", "Bearer secret")
+	if _, err := session.Generate(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.auths) != 1 || recorder.auths[0] != "Bearer secret" {
+		t.Errorf("expected one request with the bearer token, got %q", recorder.auths)
+	}
+}
+
+func TestSessionFlushChunks(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Chunks: []string{"a", "b", "c", "d"}})
+	session.FlushChunks = 2
+	var responses []string
+	update := func(msg tea.Msg) tea.Cmd {
+		if resp, ok := msg.(ollamatea.GenerateResponseMsg); ok {
+			responses = append(responses, resp.Response)
+		}
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	ollamateatest.Run(t, update, tea.Batch(session.Init(), session.Start()),
+		ollamateatest.MatchGenerateDone(session.ID()), 0)
+	if strings.Join(responses, "|") != "ab|cd" {
+		t.Errorf("expected chunks batched in pairs, got %q", responses)
+	}
+}
+
+func TestSessionHistory(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"Hello!"}, Context: []int{1}},
+		ollamateatest.GenerateScript{Chunks: []string{"Fine."}, Context: []int{1, 2}})
+	for _, prompt := range []string{"Hi", "How are you?"} {
+		session.Prompt = prompt
+		if _, err := session.Generate(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history := session.History()
+	if len(history) != 2 || history[1].Prompt != "How are you?" || history[1].Response != "Fine." || len(history[1].Context) != 2 {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	if c := ollamatea.ConversationFromSession(session); len(c.Messages) != 4 || c.Messages[2].Content != "How are you?" {
+		t.Errorf("expected the conversation of the history, got %+v", c.Messages)
+	}
+
+	session.TruncateHistory(1)
+	if history := session.History(); len(history) != 1 || history[0].Prompt != "How are you?" {
+		t.Errorf("expected only the last turn, got %+v", history)
+	}
+	session.AppendTurn(ollamatea.Turn{Prompt: "Bye", Response: "Goodbye!"})
+	session.TruncateHistory(0)
+	if len(session.History()) != 0 {
+		t.Errorf("expected no history, got %+v", session.History())
+	}
+}
+
+func TestSessionAutoContext(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"Hello!"}, Context: []int{1, 2}},
+		ollamateatest.GenerateScript{Chunks: []string{"Fine."}, Context: []int{1, 2, 3, 4}})
+	session.AutoContext = true
+	session.Prompt = "Hi"
+	if _, err := session.Generate(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Context) != 2 {
+		t.Fatalf("expected the returned Context carried over, got %v", session.Context)
+	}
+
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	session.Prompt = "How are you?"
+	ollamateatest.Run(t, update, tea.Batch(session.Init(), session.Start()), ollamateatest.MatchGenerateDone(session.ID()), 0)
+	if reqs := srv.GenerateRequests(); len(reqs) != 2 || len(reqs[1].Context) != 2 {
+		t.Errorf("expected the carried Context sent, got %+v", reqs)
+	}
+	if len(session.Context) != 4 {
+		t.Errorf("expected the second Context carried over, got %v", session.Context)
+	}
+}
+
+func TestSessionSplitThinking(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"<think>", "Two plus", " two</th", "ink>", "Four"}})
+	session.SplitThinking = true
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	var thinking strings.Builder
+	match := func(msg tea.Msg) bool {
+		switch msg := msg.(type) {
+		case ollamatea.GenerateThinkingMsg:
+			thinking.WriteString(msg.Thinking)
+		case ollamatea.GenerateResponseMsg:
+			if strings.Contains(msg.Response, "think") {
+				t.Errorf("unexpected reasoning in response %q", msg.Response)
+			}
+		}
+		return ollamateatest.MatchGenerateDone(session.ID())(msg)
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(session.Init(), session.Start()), match, 0)
+	done := msg.(ollamatea.GenerateDoneMsg)
+	if done.Response != "Four" || done.Thinking != "Two plus two" || session.Thinking() != "Two plus two" {
+		t.Errorf("unexpected done %+v", done)
+	}
+	if thinking.String() != "Two plus two" {
+		t.Errorf("unexpected thinking messages %q", thinking.String())
+	}
+}
+
+func TestSessionCallbacks(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"Hel", "lo"}},
+		ollamateatest.GenerateScript{Status: http.StatusNotFound, Error: "model not found"})
+	var tokens []string
+	var done []ollamatea.GenerateDoneMsg
+	var errs []error
+	session.OnToken = func(msg ollamatea.GenerateResponseMsg) { tokens = append(tokens, msg.Response) }
+	session.OnDone = func(msg ollamatea.GenerateDoneMsg) { done = append(done, msg) }
+	session.OnError = func(err error) { errs = append(errs, err) }
+
+	if _, err := session.Generate(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tokens, "|") != "Hel|lo" || len(done) != 1 || done[0].Response != "Hello" || len(errs) != 0 {
+		t.Errorf("unexpected callbacks: tokens %q, done %+v, errors %v", tokens, done, errs)
+	}
+	if _, err := session.Generate(context.Background(), nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(done) != 1 || len(errs) != 1 {
+		t.Errorf("expected one error callback, got done %+v, errors %v", done, errs)
+	}
+}
+
+func TestSessionDryRun(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv)
+	session.Prompt = "Why is the sky blue?"
+	session.System = "Be brief."
+	session.SetTemperature(0.2)
+	session.DryRun = true
+
+	req := session.BuildRequest()
+	req.Options["temperature"] = 1.0
+	if session.Options["temperature"] != 0.2 {
+		t.Errorf("expected BuildRequest to copy the options, got %v", session.Options)
+	}
+
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(session.Init(), session.Start()), ollamateatest.MatchType[ollamatea.RequestPreviewMsg](), 0)
+	preview := msg.(ollamatea.RequestPreviewMsg)
+	if preview.ID != session.ID() || preview.Host != srv.URL {
+		t.Errorf("unexpected preview %+v", preview)
+	}
+	if r := preview.Request; r.Prompt != session.Prompt || r.System != session.System || r.Options["temperature"] != 0.2 {
+		t.Errorf("unexpected request %+v", r)
+	}
+	if session.IsGenerating() || len(srv.GenerateRequests()) != 0 {
+		t.Errorf("expected no generation in a dry run")
+	}
+}
+
+func TestSessionAsModel(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	s := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Chunks: []string{"Hello"}})
+	if next, _ := s.Update(nil); next != s {
+		t.Errorf("expected Update to return the Session")
+	}
+
+	var model tea.Model = s.AsModel()
+	update := func(msg tea.Msg) tea.Cmd {
+		var cmd tea.Cmd
+		model, cmd = model.Update(msg)
+		return cmd
+	}
+	ollamateatest.Run(t, update, tea.Batch(model.Init(), s.Start()), ollamateatest.MatchGenerateDone(s.ID()), 0)
+	if s.Response() != "Hello" {
+		t.Errorf("expected the adapter to share the Session state, got %q", s.Response())
+	}
+}
+
+func TestSessionStateChangesInUpdate(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	s := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Status: http.StatusInternalServerError})
+	var delivered int
+	update := func(msg tea.Msg) tea.Cmd {
+		if _, ok := ollamatea.MsgID(msg); ok {
+			delivered++
+		}
+		// the commands deliver the failure to Update, rather than recording it themselves
+		if delivered == 2 && (s.Error() != nil || !s.IsGenerating()) {
+			t.Errorf("expected the Session unchanged before Update, got %v", s.Error())
+		}
+		_, cmd := s.Update(msg)
+		return cmd
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(s.Init(), s.Start()), ollamateatest.MatchGenerateDone(s.ID()), 0)
+	if msg.(ollamatea.GenerateDoneMsg).Error == nil || s.Error() == nil || s.IsGenerating() {
+		t.Errorf("expected the failure recorded, got %v", s.Error())
+	}
+}
+
+func TestSessionRestart(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	s := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"old ", "old ", "old ", "old ", "old"}},
+		ollamateatest.GenerateScript{Chunks: []string{"new"}})
+	var failures int
+	s.OnError = func(error) { failures++ }
+	restarted := false
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := s.Update(msg)
+		if s.Response() != "" && !restarted {
+			// restart upon the first chunk, cancelling the first generation with chunks still buffered
+			restarted = true
+			s.ClearResponse()
+			_, start := s.Update(s.StartGenerateMsg())
+			return tea.Batch(cmd, start)
+		}
+		return cmd
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(s.Init(), s.Start()), ollamateatest.MatchGenerateDone(s.ID()), 0)
+	s.Update(msg)
+	done := msg.(ollamatea.GenerateDoneMsg)
+	if done.Error != nil || s.Error() != nil || failures != 0 {
+		t.Errorf("expected the cancelled generation's failure ignored, got %v", done.Error)
+	}
+	if done.Response != "new" || s.Response() != "new" {
+		t.Errorf("expected the cancelled generation's chunks ignored, got %q", done.Response)
+	}
+}
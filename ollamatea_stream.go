@@ -0,0 +1,222 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	ollama "github.com/ollama/ollama/api"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// External stream sources
+
+// StreamSource is an external stream of generated tokens, such as a relay of
+// another program's generation, read over Server-Sent Events (http:// or
+// https:// URLs) or WebSocket (ws:// or wss:// URLs).
+//
+// Each event or message may be an Ollama generate or chat response, an
+// OpenAI-compatible or llama.cpp stream chunk, or plain text.  A "[DONE]"
+// message or the end of the stream completes the generation.
+//
+// Use [Session.StartStreamCmd] to display a StreamSource's generation in a
+// Session, and so in a ChatPanelModel, as if the Session had started it.
+type StreamSource struct {
+	URL        string       // URL of the stream
+	Header     http.Header  // Header holds extra request headers, such as Authorization
+	HTTPClient *http.Client // HTTPClient is used for SSE requests (default: DefaultHTTPClient())
+}
+
+// NewStreamSource returns a new StreamSource for the URL.
+func NewStreamSource(url string) *StreamSource {
+	return &StreamSource{
+		URL:        url,
+		Header:     make(http.Header),
+		HTTPClient: DefaultHTTPClient(),
+	}
+}
+
+// Read performs a blocking read of the stream, invoking fn for each response.
+// The last response passed to fn has Done set, even if the stream did not say so.
+func (s *StreamSource) Read(ctx context.Context, fn GenerateResponseFunc) error {
+	sawDone := false
+	handle := func(data string) error {
+		if sawDone {
+			return nil
+		}
+		resp := parseStreamPayload(data)
+		sawDone = resp.Done
+		return fn(resp)
+	}
+
+	var err error
+	if isWebSocketURL(s.URL) {
+		err = s.readWebSocket(ctx, handle)
+	} else {
+		err = s.readSSE(ctx, handle)
+	}
+	if err != nil {
+		return err
+	}
+	if !sawDone {
+		return fn(GenerateResponse{CreatedAt: time.Now(), Done: true, DoneReason: "stop"})
+	}
+	return nil
+}
+
+// readSSE reads a Server-Sent Events stream
+func (s *StreamSource) readSSE(ctx context.Context, fn func(data string) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return err
+	}
+	for k, vs := range s.Header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = DefaultHTTPClient()
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		respBody, _ := io.ReadAll(resp.Body)
+		return ollama.StatusError{StatusCode: resp.StatusCode, Status: resp.Status, ErrorMessage: string(respBody)}
+	}
+	return scanServerSentEvents(resp.Body, fn)
+}
+
+// readWebSocket reads a WebSocket stream, one payload per message
+func (s *StreamSource) readWebSocket(ctx context.Context, fn func(data string) error) error {
+	conn, err := dialWebSocket(ctx, s.URL, s.Header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// unblock reads on cancellation
+	stop := context.AfterFunc(ctx, func() { conn.conn.Close() })
+	defer stop()
+
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		data := string(message)
+		if strings.TrimSpace(data) == "[DONE]" {
+			return nil
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+}
+
+// streamPayload is the union of the stream formats understood by StreamSource
+type streamPayload struct {
+	// Ollama generate and chat
+	Response   *string    `json:"response"`
+	Message    *Message   `json:"message"`
+	Done       bool       `json:"done"`
+	DoneReason string     `json:"done_reason"`
+	CreatedAt  *time.Time `json:"created_at"`
+	Model      string     `json:"model"`
+	Context    []int      `json:"context"`
+	// llama.cpp
+	Content *string `json:"content"`
+	Stop    bool    `json:"stop"`
+	// OpenAI-compatible
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		Text         string  `json:"text"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// parseStreamPayload converts one stream payload to a GenerateResponse.
+// Payloads that are not JSON objects are treated as plain text.
+func parseStreamPayload(data string) GenerateResponse {
+	resp := GenerateResponse{CreatedAt: time.Now()}
+	var p streamPayload
+	if !strings.HasPrefix(strings.TrimSpace(data), "{") || json.Unmarshal([]byte(data), &p) != nil {
+		resp.Response = data
+		return resp
+	}
+
+	resp.Model = p.Model
+	if p.CreatedAt != nil {
+		resp.CreatedAt = *p.CreatedAt
+	}
+	switch {
+	case p.Response != nil:
+		resp.Response = *p.Response
+	case p.Message != nil:
+		resp.Response = p.Message.Content
+	case p.Content != nil:
+		resp.Response = *p.Content
+	}
+	resp.Done = p.Done || p.Stop
+	resp.DoneReason = p.DoneReason
+	resp.Context = p.Context
+	for _, choice := range p.Choices {
+		resp.Response += choice.Delta.Content + choice.Text
+		if choice.FinishReason != nil {
+			resp.Done = true
+			resp.DoneReason = *choice.FinishReason
+		}
+	}
+	if resp.Done && resp.DoneReason == "" {
+		resp.DoneReason = "stop"
+	}
+	return resp
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// StartStreamCmd returns a command which reads the StreamSource into the Session,
+// as though the Session were generating: its Response is cleared and built up,
+// and GenerateResponseMsg and GenerateDoneMsg are sent with the Session's ID.
+// A StopGenerateMsg for the Session stops reading.
+// Like Update, it changes the Session's state, so call it from the same goroutine;
+// it returns nil if the Session is already generating.
+func (m *Session) StartStreamCmd(source *StreamSource) tea.Cmd {
+	if m.isGenerating {
+		return nil
+	}
+	m.isGenerating = true
+	m.resetResponse()
+	m.newGenerateContext()
+	m.genSeq++
+
+	id, ctx, seq, model := m.id, m.ctx, m.genSeq, m.Model
+	return func() tea.Msg {
+		sender := m.newResponseSender(ctx, seq)
+		err := source.Read(ctx, sender.send)
+		sender.close(model)
+		if err != nil {
+			return generateFailedMsg{ID: id, Seq: seq, Error: err}
+		}
+		return nil
+	}
+}
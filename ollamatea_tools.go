@@ -0,0 +1,112 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	ollama "github.com/ollama/ollama/api"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+
+// Type aliases in this package for convenience
+type (
+	Tool                      = ollama.Tool
+	ToolFunction              = ollama.ToolFunction
+	ToolCall                  = ollama.ToolCall
+	ToolCallFunction          = ollama.ToolCallFunction
+	ToolCallFunctionArguments = ollama.ToolCallFunctionArguments
+)
+
+// ToolHandlerFunc handles a tool call with the model-supplied arguments.
+// The returned string is sent back to the model as the tool's result.
+type ToolHandlerFunc func(ctx context.Context, args ToolCallFunctionArguments) (string, error)
+
+// ParseToolSchema parses a JSON tool function schema, as in the Ollama API:
+//
+//	{"description": "...", "parameters": {"type": "object", "properties": {...}, "required": [...]}}
+func ParseToolSchema(jsonSchema string) (ToolFunction, error) {
+	var schema ToolFunction
+	if err := json.Unmarshal([]byte(jsonSchema), &schema); err != nil {
+		return schema, fmt.Errorf("bad tool schema: %w", err)
+	}
+	return schema, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// ToolRegistry
+
+// ToolRegistry holds the tools available to a model and dispatches its tool calls.
+// It is safe for concurrent use.
+type ToolRegistry struct {
+	mu       sync.RWMutex
+	names    []string // registration order, for stable Tools()
+	schemas  map[string]ToolFunction
+	handlers map[string]ToolHandlerFunc
+}
+
+// NewToolRegistry returns a new, empty ToolRegistry.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		schemas:  make(map[string]ToolFunction),
+		handlers: make(map[string]ToolHandlerFunc),
+	}
+}
+
+// Register adds a tool with the given name, schema, and handler.
+// The schema's Name is set to name.  Registering an existing name replaces it.
+func (r *ToolRegistry) Register(name string, schema ToolFunction, handler ToolHandlerFunc) error {
+	if name == "" {
+		return fmt.Errorf("tool name is empty")
+	}
+	if handler == nil {
+		return fmt.Errorf("tool '%s' has no handler", name)
+	}
+	schema.Name = name
+	if schema.Parameters.Type == "" {
+		schema.Parameters.Type = "object"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.handlers[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.schemas[name] = schema
+	r.handlers[name] = handler
+	return nil
+}
+
+// Len returns the number of registered tools.
+func (r *ToolRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.names)
+}
+
+// Tools returns the registered tools, in registration order, for a ChatRequest.
+func (r *ToolRegistry) Tools() ollama.Tools {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make(ollama.Tools, 0, len(r.names))
+	for _, name := range r.names {
+		tools = append(tools, Tool{Type: "function", Function: r.schemas[name]})
+	}
+	return tools
+}
+
+// Dispatch invokes the handler for the tool call and returns its result.
+// Returns an error if the tool is not registered or its handler fails.
+func (r *ToolRegistry) Dispatch(ctx context.Context, call ToolCall) (string, error) {
+	r.mu.RLock()
+	handler, ok := r.handlers[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown tool '%s'", call.Function.Name)
+	}
+	return handler(ctx, call.Function.Arguments)
+}
@@ -0,0 +1,47 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"fmt"
+	"io"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Streaming to an io.Writer
+//
+// A CLI often just wants generated text on stdout, a file, or a socket.
+// StreamTo tees a Session's streamed text to an io.Writer as it arrives,
+// for both BubbleTea generations and Session.Generate, so the caller need
+// not translate messages itself.
+
+// StreamTo tees each streamed response chunk to w as it arrives, until it is
+// called again; a nil w stops streaming.  If w has a Flush method, as a
+// bufio.Writer does, it is flushed after each chunk.  A failed write ends the
+// generation with the error.  Set it before generating, as it is read from
+// the generation's goroutine.
+func (m *Session) StreamTo(w io.Writer) {
+	m.streamWriter = w
+}
+
+// flusher is a Writer which buffers, such as a bufio.Writer
+type flusher interface {
+	Flush() error
+}
+
+// teeResponses returns fn, first writing each response's text to w
+func teeResponses(w io.Writer, fn GenerateResponseFunc) GenerateResponseFunc {
+	return func(resp GenerateResponse) error {
+		if resp.Response != "" {
+			if _, err := io.WriteString(w, resp.Response); err != nil {
+				return fmt.Errorf("stream writer: %w", err)
+			}
+			if f, ok := w.(flusher); ok {
+				if err := f.Flush(); err != nil {
+					return fmt.Errorf("stream writer: %w", err)
+				}
+			}
+		}
+		return fn(resp)
+	}
+}
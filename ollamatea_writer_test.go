@@ -0,0 +1,49 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestSessionStreamTo(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"Hello", ", ", "world"}},
+		ollamateatest.GenerateScript{Chunks: []string{"again"}},
+		ollamateatest.GenerateScript{Chunks: []string{"lost"}},
+	)
+	var sb strings.Builder
+	session.StreamTo(&sb)
+	if _, err := session.Generate(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "Hello, world" {
+		t.Errorf("unexpected streamed text %q", sb.String())
+	}
+
+	// BubbleTea generations are streamed too
+	ollamateatest.WaitForGenerateDone(t, session, 0)
+	if sb.String() != "Hello, worldagain" {
+		t.Errorf("unexpected streamed text %q", sb.String())
+	}
+
+	session.StreamTo(failingWriter{})
+	if _, err := session.Generate(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "broken pipe") {
+		t.Errorf("expected the write error, got %v", err)
+	}
+}
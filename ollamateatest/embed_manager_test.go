@@ -0,0 +1,81 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamateatest_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func corpus(n int) []string {
+	inputs := make([]string, n)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("document %d", i)
+	}
+	return inputs
+}
+
+func TestEmbedManager(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	mgr := ollamatea.NewEmbedManager(srv.URL, "all-minilm")
+	mgr.Workers, mgr.BatchSize, mgr.MaxInflight = 3, 7, 2
+	inputs := corpus(100)
+
+	var progress []int
+	embeddings, err := mgr.Embed(context.Background(), inputs, func(done int, total int) {
+		progress = append(progress, done)
+	})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	for i, input := range inputs {
+		if !slices.Equal(embeddings[i], ollamateatest.FakeEmbedding(input, ollamateatest.DefaultEmbeddingDimensions)) {
+			t.Fatalf("embedding %d out of order", i)
+		}
+	}
+	if reqs := srv.EmbedRequests(); len(reqs) != 15 {
+		t.Errorf("expected 15 batched requests, got %d", len(reqs))
+	}
+	if len(progress) != 15 || progress[len(progress)-1] != len(inputs) {
+		t.Errorf("unexpected progress %v", progress)
+	}
+}
+
+func TestEmbedManagerError(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.QueueEmbed(ollamateatest.EmbedScript{Status: 500, Error: "out of memory"})
+
+	mgr := ollamatea.NewEmbedManager(srv.URL, "all-minilm")
+	mgr.Workers = 1
+	if _, err := mgr.Embed(context.Background(), corpus(100), nil); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func BenchmarkEmbedManager(b *testing.B) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	inputs := corpus(1000)
+
+	for _, workers := range []int{1, 4, 16} {
+		for _, batchSize := range []int{1, 32, 256} {
+			b.Run(fmt.Sprintf("workers=%d/batch=%d", workers, batchSize), func(b *testing.B) {
+				mgr := ollamatea.NewEmbedManager(srv.URL, "all-minilm")
+				mgr.Workers, mgr.BatchSize = workers, batchSize
+				for range b.N {
+					if _, err := mgr.Embed(context.Background(), inputs, nil); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamateatest
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// FakeSession
+
+// FakeSession is an [ollamatea.SessionModel] which streams canned Chunks
+// without any server, for unit testing Update logic.  Like a Session, it sends
+// a GenerateResponseMsg per chunk and then a GenerateDoneMsg; if Err is set,
+// the GenerateDoneMsg carries it as its DoneReason and Error returns it.
+type FakeSession struct {
+	Chunks     []string      // Chunks are the responses streamed by each generation
+	Delay      time.Duration // Delay is waited before each chunk
+	DoneReason string        // DoneReason of the GenerateDoneMsg; defaults to "stop"
+	Context    []int         // Context of the GenerateDoneMsg
+	Err        error         // Err, if set, fails each generation after its Chunks
+
+	id           int64
+	generation   int // incremented by each Start and Stop, to ignore stale chunks
+	isGenerating bool
+	response     string
+	lastError    error
+}
+
+// fakeChunkMsg delivers the next chunk of a FakeSession generation
+type fakeChunkMsg struct {
+	ID         int64
+	Generation int
+	Index      int
+}
+
+// FakeSession IDs count down from -1, so they never collide with Session IDs.
+var lastFakeSessionID int64
+
+// NewFakeSession returns a new FakeSession streaming the chunks.
+func NewFakeSession(chunks ...string) *FakeSession {
+	return &FakeSession{
+		Chunks: chunks,
+		id:     atomic.AddInt64(&lastFakeSessionID, -1),
+	}
+}
+
+var _ ollamatea.SessionModel = (*FakeSession)(nil)
+
+// ID returns the unique ID of the FakeSession
+func (f *FakeSession) ID() int64 { return f.id }
+
+// Start returns a command which starts a generation of the FakeSession.
+func (f *FakeSession) Start() tea.Cmd {
+	return ollamatea.Cmdize(ollamatea.StartGenerateMsg{ID: f.id})
+}
+
+// Stop returns a command which stops the FakeSession's generation, if any.
+func (f *FakeSession) Stop() tea.Cmd {
+	return ollamatea.Cmdize(ollamatea.StopGenerateMsg{ID: f.id})
+}
+
+// IsGenerating returns true while the FakeSession is generating
+func (f *FakeSession) IsGenerating() bool { return f.isGenerating }
+
+// Response returns the response so far
+func (f *FakeSession) Response() string { return f.response }
+
+// Error returns the error of the last generation, if any
+func (f *FakeSession) Error() error { return f.lastError }
+
+// Init handles the initialization of a FakeSession
+func (f *FakeSession) Init() tea.Cmd { return nil }
+
+// Update handles the FakeSession's start, stop, and chunk messages
+func (f *FakeSession) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ollamatea.StartGenerateMsg:
+		if msg.ID != f.id {
+			return f, nil
+		}
+		f.generation++
+		f.isGenerating, f.response, f.lastError = true, "", nil
+		return f, f.chunkCmd(0)
+
+	case ollamatea.StopGenerateMsg:
+		if msg.ID != f.id {
+			return f, nil
+		}
+		f.generation++
+		f.isGenerating = false
+		return f, nil
+
+	case fakeChunkMsg:
+		if msg.ID != f.id || msg.Generation != f.generation {
+			return f, nil
+		}
+		if msg.Index < len(f.Chunks) {
+			chunk := f.Chunks[msg.Index]
+			f.response += chunk
+			respMsg := ollamatea.GenerateResponseMsg{ID: f.id, CreatedAt: time.Now(), Response: chunk}
+			return f, tea.Batch(ollamatea.Cmdize(respMsg), f.chunkCmd(msg.Index+1))
+		}
+
+		f.isGenerating = false
+		doneMsg := ollamatea.GenerateDoneMsg{
+			ID:         f.id,
+			Response:   f.response,
+			CreatedAt:  time.Now(),
+			DoneReason: f.DoneReason,
+			Context:    f.Context,
+		}
+		if f.Err != nil {
+			f.lastError = f.Err
+			doneMsg.DoneReason = f.Err.Error()
+		} else if doneMsg.DoneReason == "" {
+			doneMsg.DoneReason = "stop"
+		}
+		return f, ollamatea.Cmdize(doneMsg)
+	}
+	return f, nil
+}
+
+// View renders the FakeSession's response
+func (f *FakeSession) View() string { return f.response }
+
+// chunkCmd returns a command delivering the chunk at index after Delay
+func (f *FakeSession) chunkCmd(index int) tea.Cmd {
+	msg := fakeChunkMsg{ID: f.id, Generation: f.generation, Index: index}
+	if f.Delay > 0 {
+		return tea.Tick(f.Delay, func(time.Time) tea.Msg { return msg })
+	}
+	return ollamatea.Cmdize(msg)
+}
@@ -0,0 +1,33 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamateatest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestFakeSession(t *testing.T) {
+	var session ollamatea.SessionModel = ollamateatest.NewFakeSession("Hello", ", world")
+	update := ollamateatest.UpdateFunc(session)
+	msg := ollamateatest.Run(t, update, session.Start(), ollamateatest.MatchGenerateDone(session.ID()), 0)
+	done := msg.(ollamatea.GenerateDoneMsg)
+	if done.Response != "Hello, world" || done.DoneReason != "stop" || session.IsGenerating() {
+		t.Errorf("unexpected done %+v", done)
+	}
+}
+
+func TestFakeSessionError(t *testing.T) {
+	fake := ollamateatest.NewFakeSession("partial")
+	fake.Err = errors.New("model crashed")
+	msg := ollamateatest.Run(t, ollamateatest.UpdateFunc(fake), fake.Start(), ollamateatest.MatchGenerateDone(fake.ID()), 0)
+	if done := msg.(ollamatea.GenerateDoneMsg); done.DoneReason != "model crashed" || fake.Error() != fake.Err {
+		t.Errorf("unexpected done %+v, error %v", done, fake.Error())
+	}
+	if fake.Response() != "partial" {
+		t.Errorf("unexpected response %q", fake.Response())
+	}
+}
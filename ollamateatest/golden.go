@@ -0,0 +1,178 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamateatest
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // decode JPEG goldens
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Golden images
+//
+// Rendered images vary slightly across platforms, as fonts are rasterized and
+// anti-aliased differently, so byte-for-byte comparisons are brittle.
+// AssertGoldenImage compares pixels within a tolerance instead.  Run tests with
+// OLLAMATEA_UPDATE_GOLDEN=1 to regenerate the golden files from the output.
+
+// UpdateGoldenEnv is the environment variable which, when set, makes
+// AssertGoldenImage write golden files rather than compare with them.
+const UpdateGoldenEnv = "OLLAMATEA_UPDATE_GOLDEN"
+
+// ImageDiffOptions are the tolerances of an image comparison.
+type ImageDiffOptions struct {
+	// Tolerance is the largest difference in any 8-bit color channel
+	// for which two pixels are considered the same.
+	Tolerance uint8
+	// MaxDiffRatio is the fraction of pixels which may differ.
+	MaxDiffRatio float64
+	// MaxSizeDelta is the difference in width or height, in pixels, which is
+	// allowed for varying font metrics; only the overlapping area is compared.
+	MaxSizeDelta int
+}
+
+// DefaultImageDiffOptions returns tolerances suited to rendered terminal text.
+func DefaultImageDiffOptions() ImageDiffOptions {
+	return ImageDiffOptions{
+		Tolerance:    32,
+		MaxDiffRatio: 0.01,
+		MaxSizeDelta: 0,
+	}
+}
+
+// ImageDiff is the result of comparing two images.
+type ImageDiff struct {
+	WantBounds image.Rectangle // WantBounds are the bounds of the expected image
+	GotBounds  image.Rectangle // GotBounds are the bounds of the actual image
+	DiffPixels int             // DiffPixels is the number of compared pixels which differ
+	Pixels     int             // Pixels is the number of pixels compared
+	Diff       *image.RGBA     // Diff shows differing pixels in red over a faded copy of the actual image
+}
+
+// Ratio returns the fraction of compared pixels which differ.
+func (d ImageDiff) Ratio() float64 {
+	if d.Pixels == 0 {
+		return 0
+	}
+	return float64(d.DiffPixels) / float64(d.Pixels)
+}
+
+// Within returns true if the difference is within the tolerances of opts.
+func (d ImageDiff) Within(opts ImageDiffOptions) bool {
+	dw := d.WantBounds.Dx() - d.GotBounds.Dx()
+	dh := d.WantBounds.Dy() - d.GotBounds.Dy()
+	if abs(dw) > opts.MaxSizeDelta || abs(dh) > opts.MaxSizeDelta {
+		return false
+	}
+	return d.Ratio() <= opts.MaxDiffRatio
+}
+
+// String describes the difference.
+func (d ImageDiff) String() string {
+	return fmt.Sprintf("%d of %d pixels differ (%.2f%%); want %dx%d, got %dx%d",
+		d.DiffPixels, d.Pixels, 100*d.Ratio(),
+		d.WantBounds.Dx(), d.WantBounds.Dy(), d.GotBounds.Dx(), d.GotBounds.Dy())
+}
+
+// CompareImages compares the overlapping area of want and got, pixel by pixel,
+// counting pixels whose channels differ by more than opts.Tolerance.
+func CompareImages(want image.Image, got image.Image, opts ImageDiffOptions) ImageDiff {
+	wb, gb := want.Bounds(), got.Bounds()
+	w, h := min(wb.Dx(), gb.Dx()), min(wb.Dy(), gb.Dy())
+	diff := ImageDiff{
+		WantBounds: wb,
+		GotBounds:  gb,
+		Pixels:     w * h,
+		Diff:       image.NewRGBA(image.Rect(0, 0, w, h)),
+	}
+	tolerance := uint32(opts.Tolerance) << 8 // RGBA channels are 16-bit
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			wr, wg, wbl, wa := want.At(wb.Min.X+x, wb.Min.Y+y).RGBA()
+			gr, gg, gbl, ga := got.At(gb.Min.X+x, gb.Min.Y+y).RGBA()
+			if absDiff(wr, gr) > tolerance || absDiff(wg, gg) > tolerance ||
+				absDiff(wbl, gbl) > tolerance || absDiff(wa, ga) > tolerance {
+				diff.DiffPixels++
+				diff.Diff.Set(x, y, color.RGBA{R: 0xff, A: 0xff})
+			} else {
+				gray := uint8((gr + gg + gbl) / 3 >> 8)
+				diff.Diff.Set(x, y, color.RGBA{R: gray / 4, G: gray / 4, B: gray / 4, A: 0xff})
+			}
+		}
+	}
+	return diff
+}
+
+// AssertGoldenImage compares the encoded image got with the golden image file
+// at goldenPath, failing t if they differ beyond opts.  On failure, the actual
+// image and a diff image are written beside the golden file, with ".actual"
+// and ".diff" before its extension.  If the UpdateGoldenEnv environment
+// variable is set, got is written to goldenPath instead.
+func AssertGoldenImage(t testing.TB, goldenPath string, got []byte, opts ImageDiffOptions) {
+	t.Helper()
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+			t.Fatalf("failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+			t.Fatalf("failed to update golden image: %v", err)
+		}
+		t.Logf("updated golden image %s", goldenPath)
+		return
+	}
+
+	wantBytes, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("failed to read golden image, set %s=1 to create it: %v", UpdateGoldenEnv, err)
+	}
+	wantImg, _, err := image.Decode(bytes.NewReader(wantBytes))
+	if err != nil {
+		t.Fatalf("failed to decode golden image %s: %v", goldenPath, err)
+	}
+	gotImg, _, err := image.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("failed to decode image: %v", err)
+	}
+
+	diff := CompareImages(wantImg, gotImg, opts)
+	if diff.Within(opts) {
+		return
+	}
+	ext := filepath.Ext(goldenPath)
+	base := strings.TrimSuffix(goldenPath, ext)
+	actualPath, diffPath := base+".actual"+ext, base+".diff.png"
+	if err := os.WriteFile(actualPath, got, 0o644); err != nil {
+		t.Logf("failed to write actual image: %v", err)
+	}
+	var diffBuf bytes.Buffer
+	if err := png.Encode(&diffBuf, diff.Diff); err == nil {
+		if err := os.WriteFile(diffPath, diffBuf.Bytes(), 0o644); err != nil {
+			t.Logf("failed to write diff image: %v", err)
+		}
+	}
+	t.Errorf("image differs from golden %s: %s; see %s and %s", goldenPath, diff, actualPath, diffPath)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
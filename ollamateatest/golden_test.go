@@ -0,0 +1,54 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamateatest_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func filledImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCompareImages(t *testing.T) {
+	opts := ollamateatest.DefaultImageDiffOptions()
+	want := filledImage(100, 100, color.RGBA{R: 100, G: 100, B: 100, A: 255})
+
+	// slight color variance is within tolerance
+	got := filledImage(100, 100, color.RGBA{R: 110, G: 95, B: 100, A: 255})
+	if diff := ollamateatest.CompareImages(want, got, opts); diff.DiffPixels != 0 || !diff.Within(opts) {
+		t.Errorf("expected equal images, got %s", diff)
+	}
+
+	// a few differing pixels are within MaxDiffRatio, many are not
+	got.Set(0, 0, color.White)
+	if diff := ollamateatest.CompareImages(want, got, opts); diff.DiffPixels != 1 || !diff.Within(opts) {
+		t.Errorf("expected 1 tolerated pixel, got %s", diff)
+	}
+	for x := 0; x < 100; x++ {
+		got.Set(x, 50, color.White)
+	}
+	if diff := ollamateatest.CompareImages(want, got, opts); diff.Within(opts) {
+		t.Errorf("expected a failing diff, got %s", diff)
+	}
+
+	// sizes must match unless MaxSizeDelta allows
+	smaller := filledImage(98, 100, color.RGBA{R: 100, G: 100, B: 100, A: 255})
+	if diff := ollamateatest.CompareImages(want, smaller, opts); diff.Within(opts) {
+		t.Errorf("expected a size mismatch, got %s", diff)
+	}
+	opts.MaxSizeDelta = 2
+	if diff := ollamateatest.CompareImages(want, smaller, opts); !diff.Within(opts) {
+		t.Errorf("expected a tolerated size mismatch, got %s", diff)
+	}
+}
@@ -0,0 +1,257 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamateatest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Program harness
+//
+// Run drives a model's commands and messages like a BubbleTea program, but
+// without a terminal, so tests can wait for OllamaTea messages directly:
+//
+//	session := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Chunks: []string{"Hi"}})
+//	done := ollamateatest.WaitForGenerateDone(t, session, time.Second)
+//
+// For full programs under [teatest], OutputContains is a condition for teatest.WaitFor.
+//
+// Run is not built on teatest, which drives a whole tea.Model and waits on
+// its rendered output; Run waits on messages, and drives bare update
+// functions such as a Session's.  It executes commands as BubbleTea does,
+// which for tea.Sequence means unpacking its unexported message by
+// reflection; Run fails the test, rather than misbehaving, if a BubbleTea
+// upgrade changes that message.
+//
+// [teatest]: https://pkg.go.dev/github.com/charmbracelet/x/exp/teatest
+
+// DefaultTimeout is the timeout used by the harness when none is given.
+const DefaultTimeout = 5 * time.Second
+
+// Matcher reports whether a message is the one being waited for.
+type Matcher func(msg tea.Msg) bool
+
+// MatchType returns a Matcher for messages of type T.
+func MatchType[T tea.Msg]() Matcher {
+	return func(msg tea.Msg) bool {
+		_, ok := msg.(T)
+		return ok
+	}
+}
+
+// MatchAny returns a Matcher for messages matched by any of the matchers.
+func MatchAny(matchers ...Matcher) Matcher {
+	return func(msg tea.Msg) bool {
+		for _, match := range matchers {
+			if match(msg) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchGenerateResponse returns a Matcher for a GenerateResponseMsg of the session ID; 0 matches any.
+func MatchGenerateResponse(id int64) Matcher {
+	return func(msg tea.Msg) bool {
+		resp, ok := msg.(ollamatea.GenerateResponseMsg)
+		return ok && (id == 0 || resp.ID == id)
+	}
+}
+
+// MatchGenerateDone returns a Matcher for a GenerateDoneMsg of the session ID; 0 matches any.
+func MatchGenerateDone(id int64) Matcher {
+	return func(msg tea.Msg) bool {
+		done, ok := msg.(ollamatea.GenerateDoneMsg)
+		return ok && (id == 0 || done.ID == id)
+	}
+}
+
+// MatchChatDone returns a Matcher for a ChatDoneMsg of the chat session ID; 0 matches any.
+func MatchChatDone(id int64) Matcher {
+	return func(msg tea.Msg) bool {
+		done, ok := msg.(ollamatea.ChatDoneMsg)
+		return ok && (id == 0 || done.ID == id)
+	}
+}
+
+// MatchEmbedDone returns a Matcher for an EmbedResponseMsg or EmbedErrorMsg of the session ID; 0 matches any.
+func MatchEmbedDone(id int64) Matcher {
+	return func(msg tea.Msg) bool {
+		switch msg := msg.(type) {
+		case ollamatea.EmbedResponseMsg:
+			return id == 0 || msg.ID == id
+		case ollamatea.EmbedErrorMsg:
+			return id == 0 || msg.ID == id
+		}
+		return false
+	}
+}
+
+// UpdateFunc adapts a tea.Model to the update function taken by Run,
+// keeping the latest model returned by its Update.
+func UpdateFunc(model tea.Model) func(tea.Msg) tea.Cmd {
+	return func(msg tea.Msg) tea.Cmd {
+		var cmd tea.Cmd
+		model, cmd = model.Update(msg)
+		return cmd
+	}
+}
+
+// ScriptedSession returns a new Session generating from srv, with the scripts queued.
+func ScriptedSession(srv *Server, scripts ...GenerateScript) *ollamatea.Session {
+	srv.QueueGenerate(scripts...)
+	session := ollamatea.NewSession()
+	session.Host = srv.URL
+	return &session
+}
+
+// ScriptedChat returns a new ChatSession chatting with srv, with the scripts queued.
+func ScriptedChat(srv *Server, scripts ...ChatScript) *ollamatea.ChatSession {
+	srv.QueueChat(scripts...)
+	chat := ollamatea.NewChatSession()
+	chat.Host = srv.URL
+	return &chat
+}
+
+// Run executes cmd, passing each resulting message to update and executing
+// the command it returns, until a message satisfies match.  Batched and
+// sequenced commands are executed as BubbleTea would, so batched messages may
+// arrive in any order.  It returns the matching message, or fails the test if
+// timeout passes first; a timeout of 0 is DefaultTimeout.
+//
+// The matching message is returned without being passed to update, so a test
+// may inspect the state before it; pass it to update before asserting on the
+// final state, as WaitForChatDone does.
+func Run(t testing.TB, update func(tea.Msg) tea.Cmd, cmd tea.Cmd, match Matcher, timeout time.Duration) tea.Msg {
+	t.Helper()
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	msgs := make(chan tea.Msg)
+	exec := func(cmds ...tea.Cmd) { // runs cmds in order, sending their messages
+		go func() {
+			for _, cmd := range cmds {
+				if cmd == nil {
+					continue
+				}
+				if msg := cmd(); msg != nil {
+					select {
+					case msgs <- msg:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+	exec(cmd)
+
+	for {
+		select {
+		case msg := <-msgs:
+			if cmds, ok := batchedCmds(msg); ok {
+				for _, cmd := range cmds {
+					exec(cmd)
+				}
+				continue
+			}
+			if cmds, ok, err := sequencedCmds(msg); err != nil {
+				t.Fatal(err)
+				return nil
+			} else if ok {
+				exec(cmds...)
+				continue
+			}
+			if match(msg) {
+				return msg
+			}
+			exec(update(msg))
+		case <-ctx.Done():
+			t.Fatalf("timed out after %v waiting for message", timeout)
+			return nil
+		}
+	}
+}
+
+// WaitForGenerateDone starts a generation of the Session and runs it until done,
+// returning its GenerateDoneMsg.  A timeout of 0 is DefaultTimeout.
+func WaitForGenerateDone(t testing.TB, session *ollamatea.Session, timeout time.Duration) ollamatea.GenerateDoneMsg {
+	t.Helper()
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	cmd := tea.Batch(session.Init(), session.StartGenerateMsg)
+	msg, _ := Run(t, update, cmd, MatchGenerateDone(session.ID()), timeout).(ollamatea.GenerateDoneMsg)
+	return msg
+}
+
+// WaitForChatDone sends the prompt to the ChatSession and runs it until the reply
+// is done, returning its ChatDoneMsg, which is also passed to the ChatSession's Update.
+// A timeout of 0 is DefaultTimeout.
+func WaitForChatDone(t testing.TB, chat *ollamatea.ChatSession, prompt string, timeout time.Duration) ollamatea.ChatDoneMsg {
+	t.Helper()
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := chat.Update(msg)
+		return cmd
+	}
+	cmd := tea.Batch(chat.Init(), chat.Send(prompt))
+	msg, _ := Run(t, update, cmd, MatchChatDone(chat.ID()), timeout).(ollamatea.ChatDoneMsg)
+	chat.Update(msg)
+	return msg
+}
+
+// OutputContains returns a condition for teatest.WaitFor which is true
+// once the program's output contains all of the strs.
+func OutputContains(strs ...string) func(out []byte) bool {
+	return func(out []byte) bool {
+		for _, s := range strs {
+			if !bytes.Contains(out, []byte(s)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// batchedCmds returns the commands of a tea.BatchMsg
+func batchedCmds(msg tea.Msg) ([]tea.Cmd, bool) {
+	cmds, ok := msg.(tea.BatchMsg)
+	return cmds, ok
+}
+
+var cmdSliceType = reflect.TypeOf([]tea.Cmd(nil))
+
+// sequenceMsgType is the type of tea.Sequence's message, unexported by BubbleTea
+var sequenceMsgType = reflect.TypeOf(tea.Sequence(noopCmd, noopCmd)())
+
+// noopCmd is a command without a message
+func noopCmd() tea.Msg { return nil }
+
+// sequencedCmds returns the commands of tea.Sequence's message.  It returns an
+// error if the message is no longer a slice of commands.
+func sequencedCmds(msg tea.Msg) ([]tea.Cmd, bool, error) {
+	v := reflect.ValueOf(msg)
+	if !v.IsValid() || v.Type() != sequenceMsgType {
+		return nil, false, nil
+	}
+	if !v.Type().ConvertibleTo(cmdSliceType) {
+		return nil, false, fmt.Errorf("ollamateatest: unsupported tea.Sequence message %s, from a newer BubbleTea?", v.Type())
+	}
+	return v.Convert(cmdSliceType).Interface().([]tea.Cmd), true, nil
+}
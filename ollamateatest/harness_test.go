@@ -0,0 +1,170 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamateatest_test
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestWaitForGenerateDone(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Chunks: []string{"Hello", ", world"}})
+	done := ollamateatest.WaitForGenerateDone(t, session, 0)
+	if done.Response != "Hello, world" || session.Response() != "Hello, world" {
+		t.Errorf("unexpected response %q", done.Response)
+	}
+}
+
+func TestWaitForChatDone(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	chat := ollamateatest.ScriptedChat(srv, ollamateatest.ChatScript{Chunks: []string{"Hi", " there"}})
+	done := ollamateatest.WaitForChatDone(t, chat, "hello", 0)
+	if done.Message.Content != "Hi there" || chat.Response() != "Hi there" || chat.IsGenerating() {
+		t.Errorf("unexpected reply %q", done.Message.Content)
+	}
+	if len(chat.Messages) != 2 || chat.Messages[0].Content != "hello" || chat.Messages[1].Role != "assistant" {
+		t.Errorf("unexpected history %+v", chat.Messages)
+	}
+}
+
+func TestChatToolCalls(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	call := ollamatea.ToolCall{Function: ollamatea.ToolCallFunction{
+		Name:      "get_weather",
+		Arguments: ollamatea.ToolCallFunctionArguments{"city": "Paris"},
+	}}
+	chat := ollamateatest.ScriptedChat(srv,
+		ollamateatest.ChatScript{ToolCalls: []ollamatea.ToolCall{call}},
+		ollamateatest.ChatScript{Chunks: []string{"It is sunny in Paris."}},
+	)
+	chat.Tools = []ollamatea.Tool{{Type: "function", Function: ollamatea.ToolFunction{Name: "get_weather"}}}
+
+	var calls []ollamatea.ToolCallMsg
+	update := func(msg tea.Msg) tea.Cmd {
+		if callMsg, ok := msg.(ollamatea.ToolCallMsg); ok {
+			calls = append(calls, callMsg)
+			return chat.SendToolResult(callMsg.ToolCall, "sunny", nil)
+		}
+		_, cmd := chat.Update(msg)
+		return cmd
+	}
+	dones := 0
+	match := func(msg tea.Msg) bool {
+		if _, ok := msg.(ollamatea.ChatDoneMsg); ok {
+			dones++
+			return dones == 2
+		}
+		return false
+	}
+	// the first ChatDoneMsg is not matched, so it is passed to update
+	msg := ollamateatest.Run(t, update, tea.Batch(chat.Init(), chat.Send("weather in Paris?")), match, 0)
+	chat.Update(msg)
+
+	if len(calls) != 1 || calls[0].ToolCall.Function.Name != "get_weather" {
+		t.Fatalf("unexpected tool calls %+v", calls)
+	}
+	roles := []string{}
+	for _, message := range chat.Messages {
+		roles = append(roles, message.Role)
+	}
+	if strings.Join(roles, ",") != "user,assistant,tool,assistant" || chat.Response() != "It is sunny in Paris." {
+		t.Errorf("unexpected history %v, response %q", roles, chat.Response())
+	}
+	if reqs := srv.ChatRequests(); len(reqs) != 2 || len(reqs[0].Tools) != 1 || reqs[1].Messages[2].Content != "sunny" {
+		t.Errorf("unexpected requests %+v", reqs)
+	}
+	if chat.PendingToolCalls() != 0 {
+		t.Errorf("expected no pending tool calls, got %d", chat.PendingToolCalls())
+	}
+}
+
+func TestRunMatchesResponses(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Chunks: []string{"a", "b", "c"}})
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	var chunks []string
+	match := func(msg tea.Msg) bool {
+		if resp, ok := msg.(ollamatea.GenerateResponseMsg); ok {
+			chunks = append(chunks, resp.Response)
+		}
+		return len(chunks) == 3
+	}
+	cmd := tea.Batch(session.Init(), tea.Sequence(session.StartGenerateMsg))
+	ollamateatest.Run(t, update, cmd, match, 0)
+	if len(chunks) != 3 || session.Response() != "abc" {
+		t.Errorf("expected 3 chunks, got %v", chunks)
+	}
+}
+
+func TestSessionCloseStopsGoroutines(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	before := runtime.NumGoroutine()
+	session := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{
+		Chunks: []string{"a", "b", "c", "d"},
+		Delay:  50 * time.Millisecond,
+	})
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	cmd := tea.Batch(session.Init(), session.Start())
+	ollamateatest.Run(t, update, cmd, ollamateatest.MatchGenerateResponse(session.ID()), 0)
+
+	// the listener returns nil once closed, and the generation is cancelled
+	listener := session.Init()
+	session.Close()
+	if msg := listener(); msg != nil {
+		t.Errorf("expected nil from closed listener, got %T", msg)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		ollamatea.DefaultHTTPClient().CloseIdleConnections() // keep-alive connections have goroutines too
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("goroutines leaked: %d before, %d after Close", before, n)
+	}
+}
+
+// TestRunSequence locks down Run's unpacking of tea.Sequence, which depends on
+// BubbleTea's unexported message type, and that the match is not updated.
+func TestRunSequence(t *testing.T) {
+	type stepMsg int
+	step := func(n int) tea.Cmd {
+		return func() tea.Msg { return stepMsg(n) }
+	}
+	var updated []stepMsg
+	update := func(msg tea.Msg) tea.Cmd {
+		if step, ok := msg.(stepMsg); ok {
+			updated = append(updated, step)
+		} else {
+			t.Errorf("unexpected %T passed to update", msg)
+		}
+		return nil
+	}
+	match := func(msg tea.Msg) bool { return msg == stepMsg(3) }
+	msg := ollamateatest.Run(t, update, tea.Sequence(step(1), step(2), step(3)), match, time.Second)
+	if msg != stepMsg(3) || len(updated) != 2 || updated[0] != 1 || updated[1] != 2 {
+		t.Errorf("expected steps 1 and 2 updated in order before matching 3, got %v then %v", updated, msg)
+	}
+}
@@ -0,0 +1,608 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+// Package ollamateatest provides a fake Ollama server for tests.
+//
+// The Server implements /api/generate, /api/chat, /api/embed, /api/tags,
+// /api/version, and model management (/api/copy, /api/create, /api/delete, /api/pull, /api/show) with
+// scripted, streaming responses, so applications using OllamaTea can write
+// integration tests without a real model:
+//
+//	srv := ollamateatest.NewServer()
+//	defer srv.Close()
+//	srv.QueueGenerate(ollamateatest.GenerateScript{Chunks: []string{"Hello", ", world"}})
+//
+//	session := ollamatea.NewSession()
+//	session.Host = srv.URL
+package ollamateatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	ollama "github.com/ollama/ollama/api"
+)
+
+// DefaultEmbeddingDimensions is the length of the default embeddings.
+const DefaultEmbeddingDimensions = 8
+
+// DefaultServerVersion is the version reported by /api/version, unless set with SetVersion.
+const DefaultServerVersion = "0.5.0"
+
+// GenerateScript is a scripted response to a generate request.
+type GenerateScript struct {
+	Chunks     []string      // Chunks are streamed in order, the last with Done set
+	Delay      time.Duration // Delay is slept before each chunk
+	DoneReason string        // DoneReason of the final chunk; defaults to "stop"
+	Context    []int         // Context of the final chunk
+	Status     int           // Status, if set, fails the request with this HTTP status
+	Error      string        // Error, if set, is sent after the Chunks, or with Status
+}
+
+// ChatScript is a scripted response to a chat request.
+type ChatScript struct {
+	Chunks     []string          // Chunks of assistant content are streamed in order, the last with Done set
+	ToolCalls  []ollama.ToolCall // ToolCalls are requested with the final chunk
+	Delay      time.Duration     // Delay is slept before each chunk
+	DoneReason string            // DoneReason of the final chunk; defaults to "stop"
+	Status     int               // Status, if set, fails the request with this HTTP status
+	Error      string            // Error, if set, is sent after the Chunks, or with Status
+}
+
+// EmbedScript is a scripted response to an embed request.
+type EmbedScript struct {
+	Embeddings [][]float32   // Embeddings to respond with
+	Delay      time.Duration // Delay is slept before responding
+	Status     int           // Status, if set, fails the request with this HTTP status
+	Error      string        // Error message sent with Status
+}
+
+// Server is a fake Ollama server.  Responses are taken from the queued scripts;
+// when none are queued, generate echoes the prompt, chat echoes the last
+// message, and embed returns
+// deterministic embeddings derived from each input.
+type Server struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	models          []ollama.ListModelResponse
+	version         string
+	generateScripts []GenerateScript
+	chatScripts     []ChatScript
+	embedScripts    []EmbedScript
+	generateReqs    []ollama.GenerateRequest
+	chatReqs        []ollama.ChatRequest
+	embedReqs       []ollama.EmbedRequest
+}
+
+// NewServer starts and returns a new Server.  The caller should Close it.
+func NewServer() *Server {
+	s := &Server{version: DefaultServerVersion}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleHeartbeat)
+	mux.HandleFunc("/api/generate", s.handleGenerate)
+	mux.HandleFunc("/api/chat", s.handleChat)
+	mux.HandleFunc("/api/embed", s.handleEmbed)
+	mux.HandleFunc("/api/tags", s.handleTags)
+	mux.HandleFunc("/api/version", s.handleVersion)
+	mux.HandleFunc("/api/copy", s.handleCopy)
+	mux.HandleFunc("/api/create", s.handleCreate)
+	mux.HandleFunc("/api/delete", s.handleDelete)
+	mux.HandleFunc("/api/pull", s.handlePull)
+	mux.HandleFunc("/api/show", s.handleShow)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// AddModel adds a model to the /api/tags listing.
+func (s *Server) AddModel(name string, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models = append(s.models, ollama.ListModelResponse{
+		Name:       name,
+		Model:      name,
+		ModifiedAt: time.Now(),
+		Size:       size,
+		Digest:     fmt.Sprintf("%x", fnvHash(name)),
+	})
+}
+
+// Models returns the names of the models in the /api/tags listing.
+func (s *Server) Models() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, model := range s.models {
+		names = append(names, model.Name)
+	}
+	return names
+}
+
+// SetVersion sets the version reported by /api/version.
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// QueueGenerate queues scripted responses for the next generate requests.
+func (s *Server) QueueGenerate(scripts ...GenerateScript) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generateScripts = append(s.generateScripts, scripts...)
+}
+
+// QueueChat queues scripted responses for the next chat requests.
+func (s *Server) QueueChat(scripts ...ChatScript) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chatScripts = append(s.chatScripts, scripts...)
+}
+
+// QueueEmbed queues scripted responses for the next embed requests.
+func (s *Server) QueueEmbed(scripts ...EmbedScript) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.embedScripts = append(s.embedScripts, scripts...)
+}
+
+// GenerateRequests returns the generate requests received so far.
+func (s *Server) GenerateRequests() []ollama.GenerateRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ollama.GenerateRequest(nil), s.generateReqs...)
+}
+
+// ChatRequests returns the chat requests received so far.
+func (s *Server) ChatRequests() []ollama.ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ollama.ChatRequest(nil), s.chatReqs...)
+}
+
+// EmbedRequests returns the embed requests received so far.
+func (s *Server) EmbedRequests() []ollama.EmbedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ollama.EmbedRequest(nil), s.embedReqs...)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// handleHeartbeat answers the client's Heartbeat, like Ollama
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprint(w, "Ollama is running")
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	var req ollama.GenerateRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	s.generateReqs = append(s.generateReqs, req)
+	script := GenerateScript{Chunks: []string{req.Prompt}}
+	if len(s.generateScripts) > 0 {
+		script = s.generateScripts[0]
+		s.generateScripts = s.generateScripts[1:]
+	}
+	s.mu.Unlock()
+
+	if script.Status != 0 {
+		writeError(w, script.Status, script.Error)
+		return
+	}
+	doneReason := script.DoneReason
+	if doneReason == "" {
+		doneReason = "stop"
+	}
+
+	start := time.Now()
+	makeResp := func(text string, done bool) ollama.GenerateResponse {
+		resp := ollama.GenerateResponse{
+			Model:     req.Model,
+			CreatedAt: time.Now(),
+			Response:  text,
+			Done:      done,
+		}
+		if done {
+			resp.DoneReason = doneReason
+			resp.Context = script.Context
+			elapsed := time.Since(start)
+			resp.Metrics = ollama.Metrics{
+				TotalDuration:   elapsed,
+				PromptEvalCount: len(strings.Fields(req.Prompt)),
+				EvalCount:       len(script.Chunks),
+				EvalDuration:    elapsed,
+			}
+		}
+		return resp
+	}
+
+	if req.Stream != nil && !*req.Stream {
+		for range script.Chunks {
+			time.Sleep(script.Delay)
+		}
+		if script.Error != "" {
+			writeError(w, http.StatusInternalServerError, script.Error)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(makeResp(strings.Join(script.Chunks, ""), true))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for i, chunk := range script.Chunks {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(script.Delay):
+		}
+		done := i == len(script.Chunks)-1 && script.Error == ""
+		enc.Encode(makeResp(chunk, done))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if script.Error != "" {
+		enc.Encode(map[string]string{"error": script.Error})
+	} else if len(script.Chunks) == 0 {
+		enc.Encode(makeResp("", true))
+	}
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req ollama.ChatRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	s.chatReqs = append(s.chatReqs, req)
+	var script ChatScript
+	if len(s.chatScripts) > 0 {
+		script = s.chatScripts[0]
+		s.chatScripts = s.chatScripts[1:]
+	} else if len(req.Messages) > 0 {
+		script.Chunks = []string{req.Messages[len(req.Messages)-1].Content}
+	}
+	s.mu.Unlock()
+
+	if script.Status != 0 {
+		writeError(w, script.Status, script.Error)
+		return
+	}
+	doneReason := script.DoneReason
+	if doneReason == "" {
+		doneReason = "stop"
+	}
+
+	start := time.Now()
+	makeResp := func(content string, done bool) ollama.ChatResponse {
+		resp := ollama.ChatResponse{
+			Model:     req.Model,
+			CreatedAt: time.Now(),
+			Message:   ollama.Message{Role: "assistant", Content: content},
+			Done:      done,
+		}
+		if done {
+			resp.Message.ToolCalls = script.ToolCalls
+			resp.DoneReason = doneReason
+			elapsed := time.Since(start)
+			resp.Metrics = ollama.Metrics{
+				TotalDuration:   elapsed,
+				PromptEvalCount: len(req.Messages),
+				EvalCount:       len(script.Chunks),
+				EvalDuration:    elapsed,
+			}
+		}
+		return resp
+	}
+
+	if req.Stream != nil && !*req.Stream {
+		for range script.Chunks {
+			time.Sleep(script.Delay)
+		}
+		if script.Error != "" {
+			writeError(w, http.StatusInternalServerError, script.Error)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(makeResp(strings.Join(script.Chunks, ""), true))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for i, chunk := range script.Chunks {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(script.Delay):
+		}
+		done := i == len(script.Chunks)-1 && script.Error == ""
+		enc.Encode(makeResp(chunk, done))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if script.Error != "" {
+		enc.Encode(map[string]string{"error": script.Error})
+	} else if len(script.Chunks) == 0 {
+		enc.Encode(makeResp("", true))
+	}
+}
+
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	var req ollama.EmbedRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	s.embedReqs = append(s.embedReqs, req)
+	var script *EmbedScript
+	if len(s.embedScripts) > 0 {
+		script = &s.embedScripts[0]
+		s.embedScripts = s.embedScripts[1:]
+	}
+	s.mu.Unlock()
+
+	resp := ollama.EmbedResponse{Model: req.Model}
+	if script != nil {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(script.Delay):
+		}
+		if script.Status != 0 {
+			writeError(w, script.Status, script.Error)
+			return
+		}
+		resp.Embeddings = script.Embeddings
+	} else {
+		inputs, err := embedInputs(req.Input)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, input := range inputs {
+			resp.Embeddings = append(resp.Embeddings, FakeEmbedding(input, DefaultEmbeddingDimensions))
+			resp.PromptEvalCount += len(strings.Fields(input))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	resp := ollama.ListResponse{Models: append([]ollama.ListModelResponse{}, s.models...)}
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleVersion answers /api/version
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	version := s.version
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"version": version})
+}
+
+func (s *Server) handleCopy(w http.ResponseWriter, r *http.Request) {
+	var req ollama.CopyRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.findModel(req.Source)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("model %q not found", req.Source))
+		return
+	}
+	model := s.models[i]
+	model.Name, model.Model, model.ModifiedAt = req.Destination, req.Destination, time.Now()
+	s.models = append(s.models, model)
+}
+
+// handleCreate streams progress like Ollama, then adds the model;
+// a Modelfile without a FROM line fails, as with Ollama
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req ollama.CreateRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if !strings.Contains(strings.ToUpper(req.Modelfile), "FROM ") {
+		writeError(w, http.StatusBadRequest, "no FROM line for the model was specified")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for _, status := range []string{"reading model metadata", "creating system layer", "writing manifest", "success"} {
+		enc.Encode(ollama.ProgressResponse{Status: status})
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	s.AddModel(req.Model, int64(len(req.Modelfile)))
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	var req ollama.DeleteRequest
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.findModel(req.Model)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("model '%s' not found", req.Model))
+		return
+	}
+	s.models = append(s.models[:i], s.models[i+1:]...)
+}
+
+// handlePull streams download progress like Ollama, then adds the model;
+// every model is available to pull
+func (s *Server) handlePull(w http.ResponseWriter, r *http.Request) {
+	var req ollama.PullRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	send := func(resp ollama.ProgressResponse) {
+		enc.Encode(resp)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	digest := fmt.Sprintf("sha256:%016x", fnvHash(req.Model))
+	const total = 1000
+	send(ollama.ProgressResponse{Status: "pulling manifest"})
+	for completed := int64(0); completed <= total; completed += total / 4 {
+		send(ollama.ProgressResponse{Status: "pulling " + digest[7:19], Digest: digest, Total: total, Completed: completed})
+	}
+	for _, status := range []string{"verifying sha256 digest", "writing manifest", "success"} {
+		send(ollama.ProgressResponse{Status: status})
+	}
+	s.mu.Lock()
+	found := s.findModel(req.Model) >= 0
+	s.mu.Unlock()
+	if !found {
+		s.AddModel(req.Model, total)
+	}
+}
+
+func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
+	var req ollama.ShowRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	i := s.findModel(req.Model)
+	var model ollama.ListModelResponse
+	if i >= 0 {
+		model = s.models[i]
+	}
+	s.mu.Unlock()
+	if i < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("model '%s' not found", req.Model))
+		return
+	}
+	resp := ollama.ShowResponse{
+		Modelfile:  fmt.Sprintf("FROM %s\n", model.Name),
+		Template:   "{{ .Prompt }}",
+		Details:    model.Details,
+		ModifiedAt: model.ModifiedAt,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// findModel returns the index of the named model, or -1; s.mu must be held
+func (s *Server) findModel(name string) int {
+	for i, model := range s.models {
+		if model.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// FakeEmbedding returns a deterministic, unit-length embedding of the input,
+// as the Server returns by default.
+func FakeEmbedding(input string, dimensions int) []float32 {
+	embedding := make([]float32, dimensions)
+	var norm float64
+	for i := range embedding {
+		v := float64(fnvHash(fmt.Sprintf("%d:%s", i, input))%2000)/1000 - 1
+		embedding[i] = float32(v)
+		norm += v * v
+	}
+	if norm > 0 {
+		scale := 1 / math.Sqrt(norm)
+		for i := range embedding {
+			embedding[i] *= float32(scale)
+		}
+	}
+	return embedding
+}
+
+// embedInputs returns the embed request Input as strings
+func embedInputs(input any) ([]string, error) {
+	switch input := input.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{input}, nil
+	case []any:
+		inputs := make([]string, 0, len(input))
+		for _, v := range input {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid input type %T", v)
+			}
+			inputs = append(inputs, str)
+		}
+		return inputs, nil
+	default:
+		return nil, fmt.Errorf("invalid input type %T", input)
+	}
+}
+
+// decodeRequest decodes the JSON request body, writing an error response on failure
+func decodeRequest(w http.ResponseWriter, r *http.Request, req any) bool {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// writeError writes an Ollama-style JSON error response
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func fnvHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
@@ -0,0 +1,135 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamateatest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	ollama "github.com/ollama/ollama/api"
+)
+
+func TestServerGenerate(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.QueueGenerate(ollamateatest.GenerateScript{Chunks: []string{"Hello", ", ", "world"}})
+
+	session := ollamatea.NewSession()
+	session.Host = srv.URL
+	session.Prompt = "say hello"
+
+	var chunks []string
+	done, err := session.Generate(context.Background(), func(msg ollamatea.GenerateResponseMsg) {
+		chunks = append(chunks, msg.Response)
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if done.Response != "Hello, world" || len(chunks) != 3 || done.DoneReason != "stop" {
+		t.Errorf("unexpected response %q in %d chunks, done reason %q", done.Response, len(chunks), done.DoneReason)
+	}
+	if reqs := srv.GenerateRequests(); len(reqs) != 1 || reqs[0].Prompt != "say hello" {
+		t.Errorf("unexpected requests %+v", reqs)
+	}
+
+	// unscripted requests echo the prompt
+	done, err = session.Generate(context.Background(), nil)
+	if err != nil || done.Response != "say hello" {
+		t.Errorf("unexpected echo %q, err %v", done.Response, err)
+	}
+}
+
+func TestServerGenerateError(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.QueueGenerate(
+		ollamateatest.GenerateScript{Status: 404, Error: "model not found"},
+		ollamateatest.GenerateScript{Chunks: []string{"partial"}, Error: "out of memory"},
+	)
+
+	session := ollamatea.NewSession()
+	session.Host = srv.URL
+	if _, err := session.Generate(context.Background(), nil); err == nil {
+		t.Error("expected status error")
+	}
+	if _, err := session.Generate(context.Background(), nil); err == nil {
+		t.Error("expected stream error")
+	}
+}
+
+func TestServerChat(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.QueueChat(
+		ollamateatest.ChatScript{Chunks: []string{"Hello", ", world"}},
+		ollamateatest.ChatScript{Status: 500, Error: "out of memory"},
+	)
+
+	chat := ollamatea.NewChatSession()
+	chat.Host = srv.URL
+	chat.System = "be brief"
+
+	var chunks []string
+	done, err := chat.Chat(context.Background(), "say hello", nil, func(msg ollamatea.ChatResponseMsg) {
+		chunks = append(chunks, msg.Content)
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if done.Message.Content != "Hello, world" || len(chunks) != 2 || done.DoneReason != "stop" {
+		t.Errorf("unexpected reply %q in %d chunks, done reason %q", done.Message.Content, len(chunks), done.DoneReason)
+	}
+
+	// a failed reply keeps the prompt, so it may be retried
+	if _, err := chat.Chat(context.Background(), "again", nil, nil); err == nil {
+		t.Error("expected status error")
+	}
+	if len(chat.Messages) != 3 || chat.Messages[2].Content != "again" {
+		t.Errorf("unexpected history after failure %+v", chat.Messages)
+	}
+
+	// unscripted requests echo the last message
+	done, err = chat.Chat(context.Background(), "", nil, nil)
+	if err != nil || done.Message.Content != "again" || len(chat.Messages) != 4 {
+		t.Errorf("unexpected retry %q, err %v", done.Message.Content, err)
+	}
+
+	reqs := srv.ChatRequests()
+	if len(reqs) != 3 || len(reqs[2].Messages) != 4 || reqs[2].Messages[0].Role != "system" {
+		t.Errorf("unexpected requests %+v", reqs)
+	}
+}
+
+func TestServerEmbedAndList(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.AddModel("llama3.2", 1234)
+
+	backend, err := ollamatea.NewBackend(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := backend.Embed(context.Background(), &ollama.EmbedRequest{Model: "nomic", Input: []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(resp.Embeddings) != 2 || len(resp.Embeddings[0]) != ollamateatest.DefaultEmbeddingDimensions {
+		t.Errorf("unexpected embeddings %v", resp.Embeddings)
+	}
+	want := ollamateatest.FakeEmbedding("a", ollamateatest.DefaultEmbeddingDimensions)
+	for i := range want {
+		if resp.Embeddings[0][i] != want[i] {
+			t.Fatalf("embedding not deterministic: %v != %v", resp.Embeddings[0], want)
+		}
+	}
+
+	list, err := backend.List(context.Background())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list.Models) != 1 || list.Models[0].Name != "llama3.2" {
+		t.Errorf("unexpected models %+v", list.Models)
+	}
+}
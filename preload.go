@@ -0,0 +1,102 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	ollama "github.com/ollama/ollama/api"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Model preloading
+//
+// Ollama loads a model on its first request, which can take many seconds.
+// PreloadModel loads it ahead of time, such as while the user is still
+// typing their first prompt, by generating with an empty prompt.
+// UnloadModel frees it, by generating with a keep_alive of 0.
+
+// ModelWarmMsg is sent when a PreloadModel completes and the model is loaded.
+type ModelWarmMsg struct {
+	OllamaHost   string        // Ollama Host which loaded the model
+	Model        string        // Model which was loaded
+	LoadDuration time.Duration // LoadDuration is how long loading took; 0 if already loaded
+}
+
+// ModelUnloadedMsg is sent when an UnloadModel completes and the model is unloaded.
+type ModelUnloadedMsg struct {
+	OllamaHost string // Ollama Host which unloaded the model
+	Model      string // Model which was unloaded
+}
+
+// ModelLoadErrorMsg is sent when a PreloadModel or UnloadModel fails.
+type ModelLoadErrorMsg struct {
+	OllamaHost string // Ollama Host generating the error
+	Model      string // Model being loaded or unloaded
+	Unloading  bool   // Unloading is true if the error is from UnloadModel
+	Error      error  // Error returned
+}
+
+// PreloadModel returns a command which loads the model on the Ollama host,
+// keeping it loaded for the server's default keep-alive.  It returns a
+// [ModelWarmMsg], or a [ModelLoadErrorMsg] if there is an error.
+func PreloadModel(ollamaHost string, model string) tea.Cmd {
+	return func() tea.Msg {
+		start := time.Now()
+		resp, err := loadModel(ollamaHost, model, nil)
+		if err != nil {
+			logger().Error("preload failed", "host", ollamaHost, "model", model, "error", err)
+			return ModelLoadErrorMsg{OllamaHost: ollamaHost, Model: model, Error: err}
+		}
+		logger().Info("preload done", "host", ollamaHost, "model", model,
+			"load_duration", resp.LoadDuration, "latency", time.Since(start))
+		return ModelWarmMsg{OllamaHost: ollamaHost, Model: model, LoadDuration: resp.LoadDuration}
+	}
+}
+
+// UnloadModel returns a command which unloads the model from the Ollama host,
+// freeing its memory.  It returns a [ModelUnloadedMsg], or a [ModelLoadErrorMsg]
+// if there is an error.
+func UnloadModel(ollamaHost string, model string) tea.Cmd {
+	return func() tea.Msg {
+		_, err := loadModel(ollamaHost, model, &ollama.Duration{Duration: 0})
+		if err != nil {
+			logger().Error("unload failed", "host", ollamaHost, "model", model, "error", err)
+			return ModelLoadErrorMsg{OllamaHost: ollamaHost, Model: model, Unloading: true, Error: err}
+		}
+		logger().Info("unload done", "host", ollamaHost, "model", model)
+		return ModelUnloadedMsg{OllamaHost: ollamaHost, Model: model}
+	}
+}
+
+// loadModel performs an empty generation with the keepAlive, returning its final response
+func loadModel(ollamaHost string, model string, keepAlive *ollama.Duration) (*GenerateResponse, error) {
+	backend, err := SharedBackend(ollamaHost)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := backend.(*ollama.Client); !ok {
+		return nil, fmt.Errorf("backend %T does not support loading models", backend)
+	}
+
+	ctx, span := startSpan(context.Background(), "ollamatea.load",
+		Attr("ollamatea.host", ollamaHost),
+		Attr("ollamatea.model", model),
+		Attr("ollamatea.unload", keepAlive != nil))
+	defer span.End()
+
+	var final GenerateResponse
+	err = backend.Generate(ctx, &GenerateRequest{Model: model, KeepAlive: keepAlive}, func(resp GenerateResponse) error {
+		final = resp
+		return nil
+	})
+	if err != nil {
+		err = ClassifyError(err, ollamaHost, model)
+		span.RecordError(err)
+		return nil, err
+	}
+	return &final, nil
+}
@@ -0,0 +1,356 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/NimbleMarkets/ntcharts/canvas"
+	"github.com/NimbleMarkets/ntcharts/linechart"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Embedding projection
+//
+// Embeddings have hundreds of dimensions.  ProjectPCA flattens them onto the
+// plane of their two principal components, the directions in which they vary
+// most, so that similar records plot near each other.  A ProjectionModel
+// renders the projection of a VectorStore's records as a scatter chart, and
+// sends a ProjectionSelectedMsg as the user moves between points.
+
+// pcaIterations bounds the power iterations finding each principal component
+const pcaIterations = 100
+
+// ProjectPCA projects the vectors onto their first two principal components,
+// returning the X and Y of each.  Vectors must have the same length.  A
+// component the vectors do not vary in, such as with fewer than three
+// vectors, projects to 0.
+func ProjectPCA[T Float](vectors [][]T) [][2]float64 {
+	points := make([][2]float64, len(vectors))
+	if len(vectors) == 0 {
+		return points
+	}
+	dimensions := len(vectors[0])
+
+	// center the vectors on their mean
+	mean := make([]float64, dimensions)
+	for _, v := range vectors {
+		checkLengths(vectors[0], v)
+		for i, x := range v {
+			mean[i] += float64(x)
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(len(vectors))
+	}
+	centered := make([][]float64, len(vectors))
+	for j, v := range vectors {
+		centered[j] = make([]float64, dimensions)
+		for i, x := range v {
+			centered[j][i] = float64(x) - mean[i]
+		}
+	}
+
+	var components [][]float64
+	for c := 0; c < 2; c++ {
+		component := principalComponent(centered, components)
+		for j, v := range centered {
+			points[j][c] = Dot(v, component)
+		}
+		components = append(components, component)
+	}
+	return points
+}
+
+// principalComponent returns the unit direction of greatest variance of the
+// centered rows orthogonal to the previous components, by power iteration, or
+// a zero vector if there is no variance
+func principalComponent(rows [][]float64, previous [][]float64) []float64 {
+	dimensions := len(rows[0])
+	v := make([]float64, dimensions)
+	for i := range v { // a deterministic start, unlikely to be orthogonal to the component
+		v[i] = 1 / float64(i+1+len(previous))
+	}
+	orthogonalize(v, previous)
+	for iter := 0; iter < pcaIterations; iter++ {
+		// multiply by the covariance matrix, rowsᵀ·rows, without forming it
+		next := make([]float64, dimensions)
+		for _, row := range rows {
+			projection := Dot(row, v)
+			for i, x := range row {
+				next[i] += projection * x
+			}
+		}
+		orthogonalize(next, previous)
+		norm := Norm(next)
+		if norm < 1e-12 {
+			return make([]float64, dimensions)
+		}
+		for i := range next {
+			next[i] /= norm
+		}
+		converged := math.Abs(math.Abs(Dot(next, v))-1) < 1e-12
+		v = next
+		if converged {
+			break
+		}
+	}
+
+	// fix the sign, so the projection does not flip between runs
+	largest := 0
+	for i := range v {
+		if math.Abs(v[i]) > math.Abs(v[largest]) {
+			largest = i
+		}
+	}
+	if v[largest] < 0 {
+		for i := range v {
+			v[i] = -v[i]
+		}
+	}
+	return v
+}
+
+// orthogonalize removes the components of v along the unit vectors of previous
+func orthogonalize(v []float64, previous [][]float64) {
+	for _, p := range previous {
+		projection := Dot(v, p)
+		for i := range v {
+			v[i] -= projection * p[i]
+		}
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// ollamatea.ProjectionModel
+
+// Internal ProjectionModel ID management. Ensures that messages are received
+// only by components that sent them.
+var lastProjectionID int64
+
+// ProjectionSelectedMsg is sent when the user moves the ProjectionModel's
+// selection to a point.
+type ProjectionSelectedMsg struct {
+	ID     int64        // ID of the ProjectionModel
+	Index  int          // Index of the record in the ProjectionModel's Records
+	Record VectorRecord // Record selected
+	X, Y   float64      // X and Y of its projection
+}
+
+var (
+	projectionPointStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+	projectionSelectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+)
+
+// projectionKeyBindings are the keys moving the selection
+var projectionKeyBindings = []key.Binding{
+	key.NewBinding(key.WithKeys("left", "h"), key.WithHelp("←/h", "left")),
+	key.NewBinding(key.WithKeys("right", "l"), key.WithHelp("→/l", "right")),
+	key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+	key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+}
+
+// ProjectionModel is a Terminal UX plotting records' embeddings, projected
+// to 2D with ProjectPCA, as a scatter chart.  The arrow keys, or h, j, k,
+// and l, move the selection to the nearest point in their direction, sending
+// a ProjectionSelectedMsg.  Below the chart, the selected record's Text is shown.
+type ProjectionModel struct {
+	Accessible bool // Accessible renders linear, plain text for screen readers (default: DefaultAccessible())
+
+	id       int64
+	records  []VectorRecord
+	points   [][2]float64
+	selected int
+	width    int
+	height   int
+}
+
+// NewProjectionModel returns a new ProjectionModel of the records, such as a VectorStore's Records.
+func NewProjectionModel(records []VectorRecord) ProjectionModel {
+	m := ProjectionModel{
+		Accessible: DefaultAccessible(),
+		id:         allocateID(&lastProjectionID),
+		width:      60,
+		height:     20,
+	}
+	m.SetRecords(records)
+	return m
+}
+
+// ID returns the ProjectionModel's unique ID.
+func (m ProjectionModel) ID() int64 {
+	return m.id
+}
+
+// SetID sets the ProjectionModel's ID, such as to a stable ID for tests or replays.
+func (m *ProjectionModel) SetID(id int64) {
+	m.id = id
+}
+
+// Records returns the records plotted.
+func (m ProjectionModel) Records() []VectorRecord {
+	return m.records
+}
+
+// SetRecords plots the records, projecting their embeddings, and selects the first.
+// The records' embeddings must have the same dimensions.
+func (m *ProjectionModel) SetRecords(records []VectorRecord) {
+	vectors := make([][]float32, len(records))
+	for i, record := range records {
+		vectors[i] = record.Embedding
+	}
+	m.records = records
+	m.points = ProjectPCA(vectors)
+	m.selected = 0
+}
+
+// Points returns the projection of each record, as X and Y.
+func (m ProjectionModel) Points() [][2]float64 {
+	return m.points
+}
+
+// Selected returns the index of the selected record, or -1 if there are none.
+func (m ProjectionModel) Selected() int {
+	if len(m.records) == 0 {
+		return -1
+	}
+	return m.selected
+}
+
+// SetSize sets the width and height of the ProjectionModel, including its caption line.
+func (m *ProjectionModel) SetSize(width int, height int) {
+	m.width, m.height = width, height
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea interface
+
+// Init handles the initialization of the ProjectionModel.  Currently does nothing.
+func (m ProjectionModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles BubbleTea messages for the ProjectionModel, moving the selection.
+func (m ProjectionModel) Update(msg tea.Msg) (ProjectionModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.SetSize(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		var dx, dy float64
+		switch {
+		case key.Matches(msg, projectionKeyBindings[0]):
+			dx = -1
+		case key.Matches(msg, projectionKeyBindings[1]):
+			dx = 1
+		case key.Matches(msg, projectionKeyBindings[2]):
+			dy = 1
+		case key.Matches(msg, projectionKeyBindings[3]):
+			dy = -1
+		default:
+			return m, nil
+		}
+		if next, ok := m.nearestInDirection(dx, dy); ok {
+			m.selected = next
+			return m, Cmdize(m.selectedMsg())
+		}
+	}
+	return m, nil
+}
+
+// nearestInDirection returns the index of the point nearest the selected one
+// in the direction (dx, dy), favoring those closest to its line, and whether there is one
+func (m ProjectionModel) nearestInDirection(dx float64, dy float64) (int, bool) {
+	if len(m.points) == 0 {
+		return 0, false
+	}
+	from := m.points[m.selected]
+	best, bestScore := -1, math.Inf(1)
+	for i, p := range m.points {
+		along := (p[0]-from[0])*dx + (p[1]-from[1])*dy
+		across := math.Abs((p[0]-from[0])*dy - (p[1]-from[1])*dx)
+		if i == m.selected || along <= 0 {
+			continue
+		}
+		if score := along + 2*across; score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best, best >= 0
+}
+
+// selectedMsg returns the ProjectionSelectedMsg of the selected record
+func (m ProjectionModel) selectedMsg() ProjectionSelectedMsg {
+	p := m.points[m.selected]
+	return ProjectionSelectedMsg{ID: m.id, Index: m.selected, Record: m.records[m.selected], X: p[0], Y: p[1]}
+}
+
+// View renders the scatter chart, with the selected record's Text below it.
+func (m ProjectionModel) View() string {
+	if m.Accessible {
+		return m.accessibleView()
+	}
+	if len(m.points) == 0 {
+		return "<empty>"
+	}
+	minX, maxX, minY, maxY := projectionBounds(m.points)
+	chart := linechart.New(m.width, max(m.height-1, 3), minX, maxX, minY, maxY)
+	chart.DrawXYAxisAndLabel()
+	for i, p := range m.points {
+		if i != m.selected {
+			chart.DrawRuneWithStyle(canvas.Float64Point{X: p[0], Y: p[1]}, '•', projectionPointStyle)
+		}
+	}
+	p := m.points[m.selected]
+	chart.DrawRuneWithStyle(canvas.Float64Point{X: p[0], Y: p[1]}, '◉', projectionSelectedStyle)
+	return lipgloss.JoinVertical(lipgloss.Left, chart.View(), m.caption())
+}
+
+// caption describes the selected record on one line
+func (m ProjectionModel) caption() string {
+	record := m.records[m.selected]
+	text := strings.Join(strings.Fields(record.Text), " ")
+	caption := fmt.Sprintf("%d/%d %s", m.selected+1, len(m.records), text)
+	if m.width > 1 && len([]rune(caption)) > m.width {
+		caption = string([]rune(caption)[:m.width-1]) + "…"
+	}
+	return caption
+}
+
+// accessibleView renders the points as a numbered list, without the chart
+func (m ProjectionModel) accessibleView() string {
+	if len(m.points) == 0 {
+		return "No records to plot.\n"
+	}
+	var sb strings.Builder
+	for i, p := range m.points {
+		fmt.Fprintf(&sb, "%d of %d: (%.3f, %.3f) %s", i+1, len(m.points), p[0], p[1], strings.Join(strings.Fields(m.records[i].Text), " "))
+		if i == m.selected {
+			sb.WriteString(" (current)")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(accessibleHelp(projectionKeyBindings) + "\n")
+	return sb.String()
+}
+
+// projectionBounds returns the ranges of the points, padded so no point is on an edge
+func projectionBounds(points [][2]float64) (minX, maxX, minY, maxY float64) {
+	minX, maxX, minY, maxY = math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		minX, maxX = min(minX, p[0]), max(maxX, p[0])
+		minY, maxY = min(minY, p[1]), max(maxY, p[1])
+	}
+	padX, padY := (maxX-minX)*0.05, (maxY-minY)*0.05
+	if padX == 0 {
+		padX = 1
+	}
+	if padY == 0 {
+		padY = 1
+	}
+	return minX - padX, maxX + padX, minY - padY, maxY + padY
+}
@@ -0,0 +1,57 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestProjectPCA(t *testing.T) {
+	// points along the line x = y, with a little variation along z
+	points := ollamatea.ProjectPCA([][]float32{{0, 0, 0}, {1, 1, 0}, {2, 2, 0.1}, {3, 3, 0}, {4, 4, -0.1}})
+	for i := 1; i < len(points); i++ {
+		if points[i][0] <= points[i-1][0] {
+			t.Errorf("expected the first component along the line, got %v", points)
+		}
+	}
+	if length := points[4][0] - points[0][0]; length < 5.6 || length > 5.7 {
+		t.Errorf("expected the line's length of about 5.66 preserved, got %v", length)
+	}
+	if points := ollamatea.ProjectPCA([][]float64{{1, 2}}); points[0] != [2]float64{0, 0} {
+		t.Errorf("expected a single vector at the origin, got %v", points)
+	}
+}
+
+func TestProjectionModel(t *testing.T) {
+	m := ollamatea.NewProjectionModel([]ollamatea.VectorRecord{
+		{ID: "west", Text: "west", Embedding: []float32{-2, 0}},
+		{ID: "center", Text: "center", Embedding: []float32{0, 0}},
+		{ID: "east", Text: "east", Embedding: []float32{2, 0}},
+		{ID: "north", Text: "north", Embedding: []float32{0, 1}},
+	})
+	m.Accessible = true
+	var selected []string
+	for _, keyType := range []tea.KeyType{tea.KeyRight, tea.KeyUp, tea.KeyUp, tea.KeyRight} {
+		var cmd tea.Cmd
+		m, cmd = m.Update(tea.KeyMsg{Type: keyType})
+		if cmd == nil {
+			selected = append(selected, "-")
+			continue
+		}
+		msg := cmd().(ollamatea.ProjectionSelectedMsg)
+		if msg.ID != m.ID() || msg.Index != m.Selected() {
+			t.Errorf("unexpected %+v", msg)
+		}
+		selected = append(selected, msg.Record.ID)
+	}
+	if strings.Join(selected, ",") != "center,north,-,east" {
+		t.Errorf("unexpected selections %v", selected)
+	}
+	if view := m.View(); !strings.Contains(view, "3 of 4: ") || !strings.Contains(view, "east (current)") {
+		t.Errorf("unexpected view:\n%s", view)
+	}
+}
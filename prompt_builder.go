@@ -0,0 +1,195 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Prompt building
+//
+// A PromptBuilder assembles a system prompt and prompt from their parts, so
+// that applications lay out instructions, context documents, and user input
+// consistently.  With a Budget, context documents are truncated or dropped
+// to fit, rather than silently overflowing the model's context:
+//
+//	prompt, err := ollamatea.NewPromptBuilder().
+//		System("You answer questions about the documents.").
+//		Context(docs...).
+//		UserInput(question).
+//		Constraints("Cite the document numbers you used.").
+//		Budget(4000, model).
+//		Build()
+//	prompt.Apply(&session)
+
+// ErrPromptTooLong is returned by PromptBuilder.Build when the system prompt,
+// constraints, and user input alone exceed the Budget.
+var ErrPromptTooLong = errors.New("prompt exceeds the token budget")
+
+// promptTruncatedMarker ends a context document truncated to fit the budget
+const promptTruncatedMarker = "\n[truncated]"
+
+// PromptBuilder assembles a system prompt and prompt.  Its methods append to
+// the builder and return it, for chaining.  The zero value is ready to use.
+type PromptBuilder struct {
+	system      []string
+	docs        []string
+	userInput   string
+	constraints []string
+	maxTokens   int
+	model       string
+}
+
+// BuiltPrompt is the result of PromptBuilder.Build.
+type BuiltPrompt struct {
+	System      string // System prompt
+	Prompt      string // Prompt, with the context documents and user input
+	Tokens      int    // Tokens is the estimated size of System and Prompt
+	Truncated   bool   // Truncated is true if a context document was shortened to fit
+	DroppedDocs int    // DroppedDocs is the number of context documents omitted to fit
+}
+
+// Apply sets the Session's System and Prompt.
+func (p BuiltPrompt) Apply(session *Session) {
+	session.System = p.System
+	session.Prompt = p.Prompt
+}
+
+// NewPromptBuilder returns a new, empty PromptBuilder.
+func NewPromptBuilder() *PromptBuilder {
+	return &PromptBuilder{}
+}
+
+// System appends paragraphs to the system prompt.
+func (b *PromptBuilder) System(parts ...string) *PromptBuilder {
+	b.system = append(b.system, parts...)
+	return b
+}
+
+// Context appends documents for the model to draw upon.  Documents are
+// numbered in the prompt, so constraints may refer to them.
+func (b *PromptBuilder) Context(docs ...string) *PromptBuilder {
+	b.docs = append(b.docs, docs...)
+	return b
+}
+
+// UserInput sets the user's request, which ends the prompt.
+func (b *PromptBuilder) UserInput(input string) *PromptBuilder {
+	b.userInput = input
+	return b
+}
+
+// Constraints appends rules for the response, listed in the system prompt.
+func (b *PromptBuilder) Constraints(rules ...string) *PromptBuilder {
+	b.constraints = append(b.constraints, rules...)
+	return b
+}
+
+// Budget limits the built prompt to maxTokens estimated tokens for the model;
+// see [EstimateTokens].  A maxTokens of 0 means no limit.
+func (b *PromptBuilder) Budget(maxTokens int, model string) *PromptBuilder {
+	b.maxTokens, b.model = maxTokens, model
+	return b
+}
+
+// Build renders the system prompt and prompt.  Context documents which do not
+// fit the Budget are truncated, then dropped, from the last.  Returns
+// ErrPromptTooLong if the other parts alone exceed the Budget.
+func (b *PromptBuilder) Build() (BuiltPrompt, error) {
+	var system strings.Builder
+	for _, part := range b.system {
+		if part = strings.TrimSpace(part); part != "" {
+			appendParagraph(&system, part)
+		}
+	}
+	if len(b.constraints) > 0 {
+		var rules strings.Builder
+		rules.WriteString("Follow these rules:")
+		for _, rule := range b.constraints {
+			fmt.Fprintf(&rules, "\n- %s", strings.TrimSpace(rule))
+		}
+		appendParagraph(&system, rules.String())
+	}
+	built := BuiltPrompt{System: system.String()}
+	userInput := strings.TrimSpace(b.userInput)
+
+	// fit the documents within what the required parts leave of the budget
+	docs := b.docs
+	if b.maxTokens > 0 {
+		required := EstimateTokens(built.System, b.model) + EstimateTokens(userInput, b.model)
+		if userInput != "" {
+			required += EstimateTokens("\n\n", b.model) // separating it from the documents
+		}
+		if required > b.maxTokens {
+			return built, fmt.Errorf("%w: %d tokens without context, budget %d", ErrPromptTooLong, required, b.maxTokens)
+		}
+		docs, built.Truncated, built.DroppedDocs = fitDocuments(docs, b.maxTokens-required, b.model)
+	}
+
+	var prompt strings.Builder
+	for i, doc := range docs {
+		appendParagraph(&prompt, renderDocument(i, doc))
+	}
+	if userInput != "" {
+		appendParagraph(&prompt, userInput)
+	}
+	built.Prompt = prompt.String()
+	built.Tokens = EstimateTokens(built.System, b.model) + EstimateTokens(built.Prompt, b.model)
+	return built, nil
+}
+
+// appendParagraph appends s to sb, separated from any previous paragraph by a blank line
+func appendParagraph(sb *strings.Builder, s string) {
+	if sb.Len() > 0 {
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString(s)
+}
+
+// renderDocument renders the i'th context document within numbered delimiters
+func renderDocument(i int, doc string) string {
+	return fmt.Sprintf("<document %d>\n%s\n</document %d>", i+1, strings.TrimSpace(doc), i+1)
+}
+
+// fitDocuments returns the docs within budget tokens, truncating the last which fits partially
+func fitDocuments(docs []string, budget int, model string) (fitted []string, truncated bool, dropped int) {
+	for i, doc := range docs {
+		// the delimiters, and the separator from the previous paragraph, around a one-token placeholder
+		overhead := EstimateTokens(renderDocument(i, "x")+"\n\n", model) - 1
+		remaining := budget - overhead
+		tokens := EstimateTokens(strings.TrimSpace(doc), model)
+		if tokens <= remaining {
+			fitted = append(fitted, doc)
+			budget -= tokens + overhead
+			continue
+		}
+		if partial := truncateToTokens(doc, remaining-EstimateTokens(promptTruncatedMarker, model), model); partial != "" {
+			fitted = append(fitted, partial+promptTruncatedMarker)
+			truncated = true
+			i++
+		}
+		return fitted, truncated, len(docs) - i
+	}
+	return fitted, false, 0
+}
+
+// truncateToTokens returns the longest prefix of text, ending at a word, within maxTokens
+func truncateToTokens(text string, maxTokens int, model string) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	words := strings.SplitAfter(text, " ")
+	lo, hi := 0, len(words) // the prefix of lo words fits
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if EstimateTokens(strings.Join(words[:mid], ""), model) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return strings.TrimSpace(strings.Join(words[:lo], ""))
+}
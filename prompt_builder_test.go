@@ -0,0 +1,69 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func TestPromptBuilder(t *testing.T) {
+	prompt, err := ollamatea.NewPromptBuilder().
+		System("You answer questions.").
+		Context("The sky is blue.", "Grass is green.").
+		UserInput("What color is the sky?").
+		Constraints("Answer in one word.", "Cite the document.").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSystem := "You answer questions.\n\nFollow these rules:\n- Answer in one word.\n- Cite the document."
+	if prompt.System != wantSystem {
+		t.Errorf("unexpected system %q", prompt.System)
+	}
+	wantPrompt := "<document 1>\nThe sky is blue.\n</document 1>\n\n<document 2>\nGrass is green.\n</document 2>\n\nWhat color is the sky?"
+	if prompt.Prompt != wantPrompt {
+		t.Errorf("unexpected prompt %q", prompt.Prompt)
+	}
+
+	session := ollamatea.NewSession()
+	prompt.Apply(&session)
+	if session.System != prompt.System || session.Prompt != prompt.Prompt {
+		t.Error("Apply should set the session's prompts")
+	}
+}
+
+func TestPromptBuilderBudget(t *testing.T) {
+	doc := strings.Repeat("lorem ipsum dolor ", 50)
+	prompt, err := ollamatea.NewPromptBuilder().
+		System("Summarize.").
+		Context(doc, doc, doc).
+		UserInput("Go.").
+		Budget(200, "").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prompt.Truncated || prompt.DroppedDocs != 1 || prompt.Tokens > 200 {
+		t.Errorf("unexpected fit: truncated=%v dropped=%d tokens=%d", prompt.Truncated, prompt.DroppedDocs, prompt.Tokens)
+	}
+	if !strings.Contains(prompt.Prompt, "[truncated]") || !strings.HasSuffix(prompt.Prompt, "Go.") {
+		t.Errorf("unexpected prompt %q", prompt.Prompt)
+	}
+
+	for budget := 20; budget < 600; budget += 23 {
+		prompt, err := ollamatea.NewPromptBuilder().System("Summarize.").Context(doc, "a short one", doc).
+			UserInput("Go.").Budget(budget, "").Build()
+		if err != nil || prompt.Tokens > budget {
+			t.Errorf("budget %d: got %d tokens, error %v", budget, prompt.Tokens, err)
+		}
+	}
+
+	_, err = ollamatea.NewPromptBuilder().UserInput(doc).Budget(10, "").Build()
+	if !errors.Is(err, ollamatea.ErrPromptTooLong) {
+		t.Errorf("expected ErrPromptTooLong, got %v", err)
+	}
+}
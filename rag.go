@@ -0,0 +1,244 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Retrieval-augmented generation
+//
+// A RAGSession answers questions from a VectorStore: it embeds the question,
+// retrieves the most similar records, and generates an answer from a prompt
+// built with them as context documents.  The retrieved records are sent in a
+// RetrievedChunksMsg before the answer streams, so they may be shown as sources:
+//
+//	rag := ollamatea.NewRAGSession(store, ollamatea.WithModel("nomic-embed-text"))
+//	rag.Model = "llama3.2"
+//	...
+//	return m, rag.Ask(question)
+//	...
+//	case ollamatea.RetrievedChunksMsg:
+//	    m.sources = msg.Results
+//	case ollamatea.GenerateDoneMsg:
+//	    m.answer = msg.Response
+
+// DefaultRAGInstructions is the system prompt of a RAGSession without Instructions.
+const DefaultRAGInstructions = "Answer the question using only the documents provided.  " +
+	"If they do not contain the answer, say that you do not know."
+
+// AskMsg starts a RAGSession answering the Question.
+type AskMsg struct {
+	ID       int64  // ID is the RAGSession ID
+	Question string // Question to answer
+}
+
+// RetrievedChunksMsg is sent when a RAGSession has retrieved the records for
+// a question, before its answer streams.  If retrieval failed, a GenerateDoneMsg
+// with the Error follows it.
+type RetrievedChunksMsg struct {
+	ID       int64          // ID is the RAGSession ID
+	Question string         // Question being answered
+	Results  []SearchResult // Results retrieved, most similar first
+	Error    error          // Error, if embedding the question or searching failed
+}
+
+// ragRetrievedMsg is the private message returned by a RAGSession's search command.
+// Its handler builds the prompt and starts the generation.
+type ragRetrievedMsg struct {
+	ID      int64          // ID is the RAGSession ID
+	Seq     int            // Seq is the question the results are for
+	Results []SearchResult // Results of the search
+	Error   error          // Error, if the search failed
+}
+
+// RAGSession is a Session which answers questions with context retrieved from
+// a Store.  Its Embedder must embed with the model the Store's embeddings were
+// made with; the Session's Model generates the answers.
+//
+// Its Update passes messages to the Embedder and the Session, and moves each
+// question from one stage to the next.  Like Session, its methods take pointer receivers.
+type RAGSession struct {
+	Session
+
+	Embedder EmbedSession  // Embedder embeds the questions; its Chunker should be nil
+	Store    *VectorStore  // Store to retrieve from
+	Search   SearchOptions // Search options of the retrieval, such as K and MinScore
+
+	// Instructions is the system prompt (default: DefaultRAGInstructions).
+	Instructions string
+
+	// ContextTokens, if positive, is the token budget of the prompt; retrieved
+	// records which do not fit are truncated, then dropped, from the least similar.
+	ContextTokens int
+
+	// Private
+	question   string         // question being answered
+	results    []SearchResult // results retrieved for the question
+	askSeq     int            // counts questions, so stale retrievals are ignored
+	retrieving bool           // embedding or searching the question?
+}
+
+var _ SessionModel = (*RAGSession)(nil)
+
+// NewRAGSession returns a new RAGSession retrieving from the store, whose
+// Embedder is configured with the opts, such as WithModel.
+func NewRAGSession(store *VectorStore, opts ...EmbedOption) RAGSession {
+	return RAGSession{
+		Session:  NewSession(),
+		Embedder: NewEmbedSession(opts...),
+		Store:    store,
+	}
+}
+
+// Ask returns a command which starts answering the question.
+func (r *RAGSession) Ask(question string) tea.Cmd {
+	return Cmdize(AskMsg{ID: r.ID(), Question: question})
+}
+
+// Question returns the question last asked.
+func (r *RAGSession) Question() string {
+	return r.question
+}
+
+// Results returns the records retrieved for the question last asked.
+func (r *RAGSession) Results() []SearchResult {
+	return r.results
+}
+
+// IsGenerating returns true while answering, including while retrieving.
+func (r *RAGSession) IsGenerating() bool {
+	return r.retrieving || r.Session.IsGenerating()
+}
+
+// Update handles BubbleTea messages for the RAGSession, sending a
+// RetrievedChunksMsg before the generation of each answer.
+func (r *RAGSession) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case AskMsg:
+		if msg.ID != r.ID() {
+			return r, nil
+		}
+		r.question, r.results = msg.Question, nil
+		r.askSeq++
+		if r.Store == nil {
+			return r, r.retrievalFailedCmd(errors.New("RAG session has no vector store"))
+		}
+		r.retrieving = true
+		r.Embedder.Input = msg.Question
+		_, cmd := r.Embedder.Update(StartEmbedMsg{ID: r.Embedder.ID()})
+		return r, cmd
+
+	case StopGenerateMsg:
+		if msg.ID == r.ID() && r.retrieving {
+			r.retrieving = false
+			r.Embedder.Update(StopEmbedMsg{ID: r.Embedder.ID()})
+		}
+
+	case EmbedDoneMsg:
+		if msg.ID != r.Embedder.ID() || !r.retrieving {
+			break
+		}
+		if msg.Error != nil {
+			r.retrieving = false
+			return r, r.retrievalFailedCmd(fmt.Errorf("failed to embed question: %w", msg.Error))
+		}
+		if msg.Response == nil || len(msg.Response.Embeddings) == 0 {
+			r.retrieving = false
+			return r, r.retrievalFailedCmd(errors.New("failed to embed question: no embedding"))
+		}
+		return r, r.retrieveCmd(msg.Response.Embeddings[0])
+
+	case ragRetrievedMsg:
+		if msg.ID != r.ID() || msg.Seq != r.askSeq || !r.retrieving {
+			return r, nil
+		}
+		r.retrieving = false
+		if msg.Error != nil {
+			return r, r.retrievalFailedCmd(msg.Error)
+		}
+		r.results = msg.Results
+		built, err := r.buildPrompt(r.question, r.results)
+		if err != nil {
+			return r, r.retrievalFailedCmd(err)
+		}
+		built.Apply(&r.Session)
+		_, cmd := r.Session.Update(StartGenerateMsg{ID: r.ID()})
+		retrieved := RetrievedChunksMsg{ID: r.ID(), Question: r.question, Results: r.results}
+		return r, tea.Sequence(Cmdize(retrieved), cmd)
+	}
+
+	_, embedCmd := r.Embedder.Update(msg)
+	_, cmd := r.Session.Update(msg)
+	return r, tea.Batch(embedCmd, cmd)
+}
+
+// Answer performs a blocking retrieval and generation for the question,
+// outside of BubbleTea.  Returns the records retrieved and the GenerateDoneMsg,
+// with Error also returned if it failed.
+func (r *RAGSession) Answer(ctx context.Context, question string) ([]SearchResult, GenerateDoneMsg, error) {
+	r.question, r.results = question, nil
+	if r.Store == nil {
+		err := errors.New("RAG session has no vector store")
+		return nil, makeGenerateDoneErrorMsg(r.ID(), err), err
+	}
+	r.Embedder.Input = question
+	resp, err := r.Embedder.Embed(ctx)
+	if err == nil && len(resp.Embeddings) == 0 {
+		err = errors.New("no embedding")
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to embed question: %w", err)
+		return nil, makeGenerateDoneErrorMsg(r.ID(), err), err
+	}
+	if r.results, err = r.Store.Search(resp.Embeddings[0], r.Search); err != nil {
+		return nil, makeGenerateDoneErrorMsg(r.ID(), err), err
+	}
+	built, err := r.buildPrompt(question, r.results)
+	if err != nil {
+		return r.results, makeGenerateDoneErrorMsg(r.ID(), err), err
+	}
+	built.Apply(&r.Session)
+	doneMsg, err := r.Generate(ctx, nil)
+	return r.results, doneMsg, err
+}
+
+// retrieveCmd returns a command which searches the Store for the question's embedding
+func (r *RAGSession) retrieveCmd(query []float32) tea.Cmd {
+	id, seq, store, opts := r.ID(), r.askSeq, r.Store, r.Search
+	return func() tea.Msg {
+		results, err := store.Search(query, opts)
+		return ragRetrievedMsg{ID: id, Seq: seq, Results: results, Error: err}
+	}
+}
+
+// retrievalFailedCmd returns the command reporting a failed retrieval with a
+// RetrievedChunksMsg, then failing the generation with its GenerateDoneMsg
+func (r *RAGSession) retrievalFailedCmd(err error) tea.Cmd {
+	_, failedCmd := r.Session.Update(generateFailedMsg{ID: r.ID(), Seq: r.genSeq, Error: err})
+	retrieved := RetrievedChunksMsg{ID: r.ID(), Question: r.question, Error: err}
+	return tea.Sequence(Cmdize(retrieved), failedCmd)
+}
+
+// buildPrompt returns the prompt answering the question from the results
+func (r *RAGSession) buildPrompt(question string, results []SearchResult) (BuiltPrompt, error) {
+	instructions := r.Instructions
+	if instructions == "" {
+		instructions = DefaultRAGInstructions
+	}
+	docs := make([]string, len(results))
+	for i, result := range results {
+		docs[i] = result.Text
+	}
+	return NewPromptBuilder().
+		System(instructions).
+		Context(docs...).
+		UserInput(question).
+		Budget(r.ContextTokens, r.Model).
+		Build()
+}
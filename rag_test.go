@@ -0,0 +1,66 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestRAGSession(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	store := ollamatea.NewVectorStore()
+	for _, text := range []string{"The sky is blue.", "Grass is green.", "Snow is white."} {
+		store.Add(ollamatea.VectorRecord{Text: text, Embedding: ollamateatest.FakeEmbedding(text, ollamateatest.DefaultEmbeddingDimensions)})
+	}
+	rag := ollamatea.NewRAGSession(store, ollamatea.WithHost(srv.URL), ollamatea.WithModel("all-minilm"))
+	rag.Host = srv.URL
+	rag.Search.K = 1
+	srv.QueueGenerate(ollamateatest.GenerateScript{Chunks: []string{"Green", "."}})
+
+	var retrieved []ollamatea.RetrievedChunksMsg
+	update := func(msg tea.Msg) tea.Cmd {
+		if msg, ok := msg.(ollamatea.RetrievedChunksMsg); ok {
+			retrieved = append(retrieved, msg)
+		}
+		_, cmd := rag.Update(msg)
+		return cmd
+	}
+	msg := ollamateatest.Run(t, update, tea.Batch(rag.Init(), rag.Ask("Grass is green.")),
+		ollamateatest.MatchGenerateDone(rag.ID()), 0)
+	if done := msg.(ollamatea.GenerateDoneMsg); done.Error != nil || done.Response != "Green." {
+		t.Fatalf("unexpected %+v", done)
+	}
+	if len(retrieved) != 1 || retrieved[0].Error != nil || len(retrieved[0].Results) != 1 ||
+		retrieved[0].Results[0].Text != "Grass is green." || retrieved[0].Question != "Grass is green." {
+		t.Fatalf("expected the retrieved chunks before the answer, got %+v", retrieved)
+	}
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 1 || reqs[0].System != ollamatea.DefaultRAGInstructions ||
+		!strings.Contains(reqs[0].Prompt, "<document 1>\nGrass is green.\n</document 1>") {
+		t.Errorf("unexpected request %+v", reqs)
+	}
+
+	// a failed embedding is reported by both messages
+	srv.QueueEmbed(ollamateatest.EmbedScript{Status: 500, Error: "out of memory"})
+	retrieved = nil
+	msg = ollamateatest.Run(t, update, rag.Ask("Why?"), ollamateatest.MatchGenerateDone(rag.ID()), 0)
+	if done := msg.(ollamatea.GenerateDoneMsg); done.Error == nil || rag.Error() == nil || rag.IsGenerating() {
+		t.Errorf("expected an error, got %+v", done)
+	}
+	if len(retrieved) != 1 || retrieved[0].Error == nil {
+		t.Errorf("expected a RetrievedChunksMsg with the error, got %+v", retrieved)
+	}
+
+	results, done, err := rag.Answer(context.Background(), "Snow is white.")
+	if err != nil || len(results) != 1 || results[0].Text != "Snow is white." || !strings.Contains(done.Response, "Snow is white.") {
+		t.Errorf("unexpected answer %+v %+v, error %v", results, done, err)
+	}
+}
@@ -0,0 +1,154 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Rate limiting
+//
+// A RateLimiter may be shared by many Sessions and EmbedSessions to protect
+// a shared server from bursty TUIs.  It limits both the requests started per
+// minute and the requests in flight.  A BubbleTea request which must wait is
+// announced with a QueuedMsg, then started when the RateLimiter allows.
+
+// QueuedMsg is the message generated when a Session or EmbedSession request
+// is delayed by its RateLimiter.  The request starts once the RateLimiter
+// allows it, or is abandoned if the session is stopped first.
+type QueuedMsg struct {
+	ID        int64     // ID is the session ID of the delayed request
+	CreatedAt time.Time // CreatedAt is when the request was queued
+	Waiting   int       // Waiting is the number of requests already queued ahead of it
+	Seq       int       // Seq is the Session's generation which was queued, so a restarted one ignores it
+}
+
+// RateLimiter limits requests per minute and concurrent requests.
+// It is safe for concurrent use.
+type RateLimiter struct {
+	requestsPerMinute int
+	maxConcurrent     int
+
+	mu      sync.Mutex
+	starts  []time.Time   // request start times within the last minute
+	active  int           // requests in flight
+	waiting int           // requests blocked in Wait
+	changed chan struct{} // closed and replaced when a request is released
+}
+
+// NewRateLimiter returns a new RateLimiter allowing requestsPerMinute request
+// starts in any minute, with at most maxConcurrent in flight.  A limit of 0 is unlimited.
+func NewRateLimiter(requestsPerMinute int, maxConcurrent int) *RateLimiter {
+	return &RateLimiter{
+		requestsPerMinute: max(requestsPerMinute, 0),
+		maxConcurrent:     max(maxConcurrent, 0),
+		changed:           make(chan struct{}),
+	}
+}
+
+// TryAcquire starts a request if the limits allow it now.
+// If ok, release must be called when the request completes.
+func (r *RateLimiter) TryAcquire() (release func(), ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if delay, _ := r.delay(time.Now()); delay != 0 {
+		return nil, false
+	}
+	return r.acquire(time.Now()), true
+}
+
+// Wait blocks until the limits allow a request to start, or ctx is done.
+// On success, release must be called when the request completes.
+func (r *RateLimiter) Wait(ctx context.Context) (release func(), err error) {
+	r.mu.Lock()
+	r.waiting++
+	defer func() {
+		r.mu.Lock()
+		r.waiting--
+		r.mu.Unlock()
+	}()
+	for {
+		now := time.Now()
+		delay, changed := r.delay(now)
+		if delay == 0 {
+			release = r.acquire(now)
+			r.mu.Unlock()
+			return release, nil
+		}
+		r.mu.Unlock()
+
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if delay > 0 {
+			timer = time.NewTimer(delay)
+			timerC = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		r.mu.Lock()
+	}
+}
+
+// Stats returns the number of requests in flight and waiting.
+func (r *RateLimiter) Stats() (active int, waiting int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active, r.waiting
+}
+
+// delay returns how long until a request may start: 0 if now, or -1 if until
+// the returned channel is closed by a release.  r.mu must be held.
+func (r *RateLimiter) delay(now time.Time) (time.Duration, chan struct{}) {
+	if r.maxConcurrent > 0 && r.active >= r.maxConcurrent {
+		return -1, r.changed
+	}
+	if r.requestsPerMinute > 0 {
+		// drop starts older than a minute
+		cutoff := now.Add(-time.Minute)
+		i := 0
+		for i < len(r.starts) && !r.starts[i].After(cutoff) {
+			i++
+		}
+		r.starts = r.starts[i:]
+		if len(r.starts) >= r.requestsPerMinute {
+			return r.starts[0].Sub(cutoff), r.changed
+		}
+	}
+	return 0, r.changed
+}
+
+// acquire records a request start, returning its release function.  r.mu must be held.
+func (r *RateLimiter) acquire(now time.Time) func() {
+	if r.requestsPerMinute > 0 {
+		r.starts = append(r.starts, now)
+	}
+	r.active++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.active--
+			close(r.changed)
+			r.changed = make(chan struct{})
+		})
+	}
+}
+
+// queuedMsg returns a QueuedMsg for the session, counting the requests waiting ahead of it
+func (r *RateLimiter) queuedMsg(id int64) QueuedMsg {
+	_, waiting := r.Stats()
+	return QueuedMsg{ID: id, CreatedAt: time.Now(), Waiting: waiting}
+}
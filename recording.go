@@ -0,0 +1,250 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Record and replay
+//
+// A RecordingBackend wraps a real Backend, saving each generate stream,
+// embedding, and model listing to a fixture file in a directory.  A
+// ReplayBackend serves those fixtures for matching requests, with their
+// original timing or accelerated, for deterministic demos, tests, and
+// offline development.
+
+// ErrNoRecording is returned by a ReplayBackend for a request with no fixture.
+var ErrNoRecording = errors.New("no recording for request")
+
+// Recording is a recorded request and its responses, as stored in a fixture file.
+type Recording struct {
+	Kind     string          `json:"kind"` // Kind is "generate", "embed", or "list"
+	Request  json.RawMessage `json:"request,omitempty"`
+	Chunks   []RecordedChunk `json:"chunks,omitempty"`   // Chunks of a generate stream
+	Embed    *EmbedResponse  `json:"embed,omitempty"`    // Embed response
+	List     *ListResponse   `json:"list,omitempty"`     // List response
+	Latency  time.Duration   `json:"latency"`            // Latency of an embed or list response
+	Error    string          `json:"error,omitempty"`    // Error, if the request failed
+	Recorded time.Time       `json:"recorded,omitempty"` // Recorded is when the recording was made
+}
+
+// RecordedChunk is a generate response chunk and its offset from the start of the request.
+type RecordedChunk struct {
+	Offset   time.Duration    `json:"offset"`
+	Response GenerateResponse `json:"response"`
+}
+
+// recordingKey returns the fixture key of a request
+func recordingKey(kind string, req any) (string, json.RawMessage, error) {
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(append([]byte(kind+"\n"), reqJSON...))
+	return kind + "-" + hex.EncodeToString(sum[:8]), reqJSON, nil
+}
+
+// recordingPath returns the fixture file path for the key
+func recordingPath(dir string, key string) string {
+	return filepath.Join(dir, key+".json")
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// RecordingBackend is a Backend which records the requests and responses of
+// another Backend to fixture files in Dir, one per distinct request.
+type RecordingBackend struct {
+	Backend Backend // Backend performs the requests
+	Dir     string  // Dir holds the fixture files
+
+	mu sync.Mutex
+}
+
+// NewRecordingBackend returns a RecordingBackend which records backend to dir,
+// creating dir if needed.
+func NewRecordingBackend(backend Backend, dir string) (*RecordingBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording dir: %w", err)
+	}
+	return &RecordingBackend{Backend: backend, Dir: dir}, nil
+}
+
+// Generate performs and records the generation.
+func (r *RecordingBackend) Generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error {
+	start := time.Now()
+	rec := Recording{Kind: "generate"}
+	err := r.Backend.Generate(ctx, req, func(resp GenerateResponse) error {
+		rec.Chunks = append(rec.Chunks, RecordedChunk{Offset: time.Since(start), Response: resp})
+		return fn(resp)
+	})
+	if ctx.Err() != nil {
+		return err // partial streams from cancellation are not worth replaying
+	}
+	return errors.Join(err, r.save(req, rec, err))
+}
+
+// Embed performs and records the embedding.
+func (r *RecordingBackend) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	start := time.Now()
+	resp, err := r.Backend.Embed(ctx, req)
+	if ctx.Err() != nil {
+		return resp, err
+	}
+	rec := Recording{Kind: "embed", Embed: resp, Latency: time.Since(start)}
+	return resp, errors.Join(err, r.save(req, rec, err))
+}
+
+// List performs and records the model listing.
+func (r *RecordingBackend) List(ctx context.Context) (*ListResponse, error) {
+	start := time.Now()
+	resp, err := r.Backend.List(ctx)
+	if ctx.Err() != nil {
+		return resp, err
+	}
+	rec := Recording{Kind: "list", List: resp, Latency: time.Since(start)}
+	return resp, errors.Join(err, r.save(nil, rec, err))
+}
+
+// save writes the Recording of the request to its fixture file
+func (r *RecordingBackend) save(req any, rec Recording, reqErr error) error {
+	key, reqJSON, err := recordingKey(rec.Kind, req)
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %w", rec.Kind, err)
+	}
+	if req != nil {
+		rec.Request = reqJSON
+	}
+	if reqErr != nil {
+		rec.Error = reqErr.Error()
+	}
+	rec.Recorded = time.Now()
+	bts, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %w", rec.Kind, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.WriteFile(recordingPath(r.Dir, key), bts, 0644); err != nil {
+		return fmt.Errorf("failed to record %s: %w", rec.Kind, err)
+	}
+	logger().Debug("recorded", "kind", rec.Kind, "key", key, "chunks", len(rec.Chunks))
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// ReplayBackend is a Backend which replays the fixture files in Dir made by a
+// RecordingBackend.  Requests with no fixture fail with ErrNoRecording.
+type ReplayBackend struct {
+	Dir string // Dir holds the fixture files
+
+	// Speed scales the recorded timing: 2 replays twice as fast.
+	// 0 or 1 replays with the original timing; a negative Speed replays without delays.
+	Speed float64
+}
+
+// NewReplayBackend returns a ReplayBackend for the fixtures in dir at the given Speed.
+func NewReplayBackend(dir string, speed float64) *ReplayBackend {
+	return &ReplayBackend{Dir: dir, Speed: speed}
+}
+
+// Generate replays the recorded generation stream.
+func (r *ReplayBackend) Generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error {
+	rec, err := r.load("generate", req)
+	if err != nil {
+		return err
+	}
+	var last time.Duration
+	for _, chunk := range rec.Chunks {
+		if err := r.sleep(ctx, chunk.Offset-last); err != nil {
+			return err
+		}
+		last = chunk.Offset
+		if err := fn(chunk.Response); err != nil {
+			return err
+		}
+	}
+	return recordedError(rec)
+}
+
+// Embed replays the recorded embedding.
+func (r *ReplayBackend) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	rec, err := r.load("embed", req)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.sleep(ctx, rec.Latency); err != nil {
+		return nil, err
+	}
+	return rec.Embed, recordedError(rec)
+}
+
+// List replays the recorded model listing.
+func (r *ReplayBackend) List(ctx context.Context) (*ListResponse, error) {
+	rec, err := r.load("list", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.sleep(ctx, rec.Latency); err != nil {
+		return nil, err
+	}
+	return rec.List, recordedError(rec)
+}
+
+// load reads the Recording for the request
+func (r *ReplayBackend) load(kind string, req any) (*Recording, error) {
+	key, _, err := recordingKey(kind, req)
+	if err != nil {
+		return nil, err
+	}
+	bts, err := os.ReadFile(recordingPath(r.Dir, key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNoRecording, key)
+		}
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(bts, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse recording %s: %w", key, err)
+	}
+	return &rec, nil
+}
+
+// sleep waits for the recorded duration d, scaled by Speed, or until ctx is done
+func (r *ReplayBackend) sleep(ctx context.Context, d time.Duration) error {
+	if r.Speed < 0 || d <= 0 {
+		return ctx.Err()
+	}
+	if r.Speed > 0 {
+		d = time.Duration(float64(d) / r.Speed)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// recordedError returns the recorded error, if any
+func recordedError(rec *Recording) error {
+	if rec.Error == "" {
+		return nil
+	}
+	return errors.New(rec.Error)
+}
@@ -0,0 +1,116 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Retries
+//
+// A Session, EmbedSession, or ModelChooser with a RetryPolicy retries
+// requests which fail transiently, such as while Ollama is restarting,
+// rather than failing on the first refused connection.  Each retry is
+// announced with a RetryingMsg, so UIs can show "retrying (2/3)…".
+// A generation is only retried if none of its response has streamed.
+
+// RetryingMsg is sent when a failed request will be retried after Delay.
+type RetryingMsg struct {
+	ID          int64         // ID is the session or ModelChooser ID of the request
+	Request     string        // Request is "generate", "embed", or "list"
+	Attempt     int           // Attempt is the number of the upcoming attempt, from 2
+	MaxAttempts int           // MaxAttempts is the RetryPolicy's MaxAttempts
+	Delay       time.Duration // Delay is the wait before the attempt
+	Error       error         // Error is the failure being retried
+	Seq         int           // Seq is the Session's generation being retried, so a restarted one ignores it
+}
+
+// RetryPolicy is when and how often to retry failed requests.
+type RetryPolicy struct {
+	MaxAttempts int           // MaxAttempts is the most attempts, including the first; 1 or less never retries
+	Backoff     time.Duration // Backoff is the delay before the first retry (default: 500ms)
+	MaxBackoff  time.Duration // MaxBackoff caps the delay (default: 10s)
+	Multiplier  float64       // Multiplier grows the delay after each retry (default: 2)
+
+	// Retryable classifies the errors to retry; if nil, IsRetryable is used.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy of 3 attempts, with the default backoff.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     500 * time.Millisecond,
+		MaxBackoff:  10 * time.Second,
+		Multiplier:  2,
+	}
+}
+
+// IsRetryable returns true if err is a transient failure worth retrying:
+// an OllamaError of ErrorKindConnectionRefused, ErrorKindTimeout,
+// ErrorKindRateLimited, or ErrorKindServerError.
+func IsRetryable(err error) bool {
+	switch ErrorKindOf(err) {
+	case ErrorKindConnectionRefused, ErrorKindTimeout, ErrorKindRateLimited, ErrorKindServerError:
+		return true
+	}
+	return false
+}
+
+// Delay returns the delay before the given attempt, from 2.
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	delay, maxDelay, multiplier := p.Backoff, p.MaxBackoff, p.Multiplier
+	if delay <= 0 {
+		delay = 500 * time.Millisecond
+	}
+	if maxDelay <= 0 {
+		maxDelay = 10 * time.Second
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	for i := 2; i < attempt && delay < maxDelay; i++ {
+		delay = time.Duration(float64(delay) * multiplier)
+	}
+	return min(delay, maxDelay)
+}
+
+// retryingMsg returns the RetryingMsg for retrying the failed attempt with err,
+// or false if the policy, which may be nil, does not allow another attempt.
+func (p *RetryPolicy) retryingMsg(id int64, request string, attempt int, err error) (RetryingMsg, bool) {
+	if p == nil || err == nil || attempt >= p.MaxAttempts {
+		return RetryingMsg{}, false
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	if !retryable(err) {
+		return RetryingMsg{}, false
+	}
+	msg := RetryingMsg{
+		ID:          id,
+		Request:     request,
+		Attempt:     attempt + 1,
+		MaxAttempts: p.MaxAttempts,
+		Delay:       p.Delay(attempt + 1),
+		Error:       err,
+	}
+	logger().Info(request+" retrying", "id", id, "attempt", msg.Attempt, "max_attempts", msg.MaxAttempts,
+		"delay", msg.Delay, "error", err)
+	return msg, true
+}
+
+// sleepContext waits for d, returning early with ctx's error if it is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
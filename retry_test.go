@@ -0,0 +1,37 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 5, Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	for attempt, want := range map[int]time.Duration{2: 100 * time.Millisecond, 3: 200 * time.Millisecond, 4: 300 * time.Millisecond, 5: 300 * time.Millisecond} {
+		if got := p.Delay(attempt); got != want {
+			t.Errorf("attempt %d: expected delay %v, got %v", attempt, want, got)
+		}
+	}
+}
+
+func TestRetryPolicyRetryingMsg(t *testing.T) {
+	refused := ClassifyError(errors.New("dial tcp: connection refused"), "http://localhost:11434", "")
+	p := DefaultRetryPolicy()
+	if msg, ok := p.retryingMsg(7, "list", 1, refused); !ok || msg.ID != 7 || msg.Attempt != 2 || msg.Delay != p.Backoff {
+		t.Errorf("expected a retry of attempt 1, got %+v %v", msg, ok)
+	}
+	if _, ok := p.retryingMsg(7, "list", p.MaxAttempts, refused); ok {
+		t.Error("expected no retry after the last attempt")
+	}
+	if _, ok := p.retryingMsg(7, "list", 1, context.Canceled); ok {
+		t.Error("expected no retry of a cancellation")
+	}
+	var nilPolicy *RetryPolicy
+	if _, ok := nilPolicy.retryingMsg(7, "list", 1, refused); ok {
+		t.Error("expected no retry without a policy")
+	}
+}
@@ -0,0 +1,158 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Message routing
+//
+// OllamaTea messages carry the ID of the component they belong to.  An
+// application composing several components can route with RouteMsg, or wrap
+// each component with ScopeByID, rather than checking IDs in every branch:
+//
+//	if ollamatea.RouteMsg(msg, m.session.ID()) {
+//		_, cmd = m.session.Update(msg)
+//	}
+
+// MsgID returns the component ID carried by an OllamaTea message.
+// Returns false if msg is not one, such as a tea.KeyMsg.
+func MsgID(msg tea.Msg) (int64, bool) {
+	switch msg := msg.(type) {
+	case StartGenerateMsg:
+		return msg.ID, true
+	case StopGenerateMsg:
+		return msg.ID, true
+	case generateResponseMsg:
+		return msg.ID, true
+	case generateFailedMsg:
+		return msg.ID, true
+	case GenerateResponseMsg:
+		return msg.ID, true
+	case GenerateDoneMsg:
+		return msg.ID, true
+	case GenerateThinkingMsg:
+		return msg.ID, true
+	case RequestPreviewMsg:
+		return msg.ID, true
+	case GenerateProgressMsg:
+		return msg.ID, true
+	case progressTickMsg:
+		return msg.ID, true
+	case TimeoutErrorMsg:
+		return msg.ID, true
+	case RetryingMsg:
+		return msg.ID, true
+	case QueuedMsg:
+		return msg.ID, true
+	case StartEmbedMsg:
+		return msg.ID, true
+	case StopEmbedMsg:
+		return msg.ID, true
+	case EmbedResponseMsg:
+		return msg.ID, true
+	case EmbedErrorMsg:
+		return msg.ID, true
+	case EmbedProgressMsg:
+		return msg.ID, true
+	case EmbedDoneMsg:
+		return msg.ID, true
+	case EmbedStoppedMsg:
+		return msg.ID, true
+	case embedBatchMsg:
+		return msg.ID, true
+	case StartAgentMsg:
+		return msg.ID, true
+	case StopAgentMsg:
+		return msg.ID, true
+	case AgentStepMsg:
+		return msg.ID, true
+	case AgentDoneMsg:
+		return msg.ID, true
+	case fetchListMsg:
+		return msg.ID, true
+	case modelChooserRefreshMsg:
+		return msg.ID, true
+	case FetchModelListResponseMsg:
+		return msg.ID, true
+	case FetchModelListErrorMsg:
+		return msg.ID, true
+	case ModelChooserSelectedMsg:
+		return msg.ID, true
+	case ModelChooserAbortedMsg:
+		return msg.ID, true
+	case pullModelMsg:
+		return msg.ID, true
+	case PullProgressMsg:
+		return msg.ID, true
+	case PullDoneMsg:
+		return msg.ID, true
+	case ProjectionSelectedMsg:
+		return msg.ID, true
+	case CompletionDoneMsg:
+		return msg.ID, true
+	case AskMsg:
+		return msg.ID, true
+	case RetrievedChunksMsg:
+		return msg.ID, true
+	case ragRetrievedMsg:
+		return msg.ID, true
+	case StartPoolGenerateMsg:
+		return msg.ID, true
+	case PoolAssignedMsg:
+		return msg.ID, true
+	case OllamaUpMsg:
+		return msg.ID, true
+	case OllamaDownMsg:
+		return msg.ID, true
+	case healthTickMsg:
+		return msg.ID, true
+	case structuredMsg: // GenerateStructuredMsg of any type
+		return msg.structuredID(), true
+	}
+	return 0, false
+}
+
+// RouteMsg returns true if msg should be delivered to a component with one of
+// the ids: either msg carries one of them, or it carries no ID at all, as
+// with key presses and window sizes.
+func RouteMsg(msg tea.Msg, ids ...int64) bool {
+	id, ok := MsgID(msg)
+	return !ok || slices.Contains(ids, id)
+}
+
+// ScopeByID wraps model so that its Update receives only the messages which
+// RouteMsg would deliver to the ids; all others are dropped.
+func ScopeByID(model tea.Model, ids ...int64) tea.Model {
+	return scopedModel{model: model, ids: ids}
+}
+
+// scopedModel is the tea.Model returned by ScopeByID
+type scopedModel struct {
+	model tea.Model
+	ids   []int64
+}
+
+// Init initializes the wrapped model
+func (s scopedModel) Init() tea.Cmd {
+	return s.model.Init()
+}
+
+// Update passes messages for the scope's ids to the wrapped model
+func (s scopedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if !RouteMsg(msg, s.ids...) {
+		return s, nil
+	}
+	var cmd tea.Cmd
+	s.model, cmd = s.model.Update(msg)
+	return s, cmd
+}
+
+// View renders the wrapped model
+func (s scopedModel) View() string {
+	return s.model.View()
+}
@@ -0,0 +1,43 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// countingModel counts the messages it is updated with
+type countingModel struct{ count *int }
+
+func (m countingModel) Init() tea.Cmd                       { return nil }
+func (m countingModel) Update(tea.Msg) (tea.Model, tea.Cmd) { *m.count++; return m, nil }
+func (m countingModel) View() string                        { return "" }
+
+func TestRouteMsg(t *testing.T) {
+	if !ollamatea.RouteMsg(tea.KeyMsg{}, 1) {
+		t.Error("messages without an ID should be routed to everyone")
+	}
+	if !ollamatea.RouteMsg(ollamatea.GenerateDoneMsg{ID: 2}, 1, 2) {
+		t.Error("message should be routed to its ID")
+	}
+	if ollamatea.RouteMsg(ollamatea.EmbedErrorMsg{ID: 3}, 1, 2) {
+		t.Error("message should not be routed to other IDs")
+	}
+
+	var count int
+	scoped := ollamatea.ScopeByID(countingModel{&count}, 5)
+	for _, msg := range []tea.Msg{
+		tea.WindowSizeMsg{},
+		ollamatea.GenerateResponseMsg{ID: 5},
+		ollamatea.GenerateResponseMsg{ID: 6},
+		ollamatea.ModelChooserAbortedMsg{ID: 6},
+	} {
+		scoped, _ = scoped.Update(msg)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 scoped messages, got %d", count)
+	}
+}
@@ -0,0 +1,72 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"slices"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Session history
+//
+// A Session keeps only its last Response, carrying a conversation forward in
+// its opaque Context.  So that UIs and tools can render and export a whole
+// conversation, each successful generation is also recorded as a Turn.
+
+// Turn is one prompt and response of a Session's conversation.
+type Turn struct {
+	Prompt    string    `json:"prompt"`
+	Response  string    `json:"response"`
+	Context   []int     `json:"context,omitempty"` // Context returned by the generation
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// History returns a copy of the Session's turns, oldest first.
+func (s *Session) History() []Turn {
+	return slices.Clone(s.history)
+}
+
+// AppendTurn appends a turn to the Session's history, such as one restored
+// from elsewhere.  Successful generations are appended automatically.
+func (s *Session) AppendTurn(turn Turn) {
+	s.history = append(s.history, turn)
+}
+
+// TruncateHistory keeps only the last n turns of the Session's history;
+// 0 clears it.  The Session's Context is unchanged.
+func (s *Session) TruncateHistory(n int) {
+	if n <= 0 {
+		s.history = nil
+	} else if len(s.history) > n {
+		s.history = slices.Clone(s.history[len(s.history)-n:])
+	}
+}
+
+// appendDoneTurn appends the turn of a successful generation
+func (s *Session) appendDoneTurn(msg GenerateDoneMsg) {
+	s.AppendTurn(Turn{
+		Prompt:    s.Prompt,
+		Response:  msg.Response,
+		Context:   msg.Context,
+		CreatedAt: msg.CreatedAt,
+	})
+}
+
+// carryContext sets the Context to that of a successful generation, if AutoContext is set
+func (s *Session) carryContext(msg GenerateDoneMsg) {
+	if s.AutoContext && msg.Context != nil {
+		s.Context = msg.Context
+	}
+}
+
+// turnMessages returns the turns as user and assistant Messages
+func turnMessages(turns []Turn) []Message {
+	messages := make([]Message, 0, 2*len(turns))
+	for _, turn := range turns {
+		messages = append(messages,
+			Message{Role: "user", Content: turn.Prompt},
+			Message{Role: "assistant", Content: turn.Response})
+	}
+	return messages
+}
@@ -0,0 +1,196 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Session managers
+//
+// A SessionManager owns several Sessions generating in parallel, such as the
+// panes of a dashboard.  Pass all messages to its Update, which routes each
+// to the Session of its ID, and read the aggregate state from the manager:
+//
+//	m.sessions = ollamatea.NewSessionManager()
+//	cmd := m.sessions.Add(&session)
+//	...
+//	case ollamatea.GenerateDoneMsg:
+//	    m.status = fmt.Sprintf("%d running", m.sessions.Generating())
+
+// SessionManager routes messages to the Sessions it owns and aggregates their state.
+// Like Session, its methods take pointer receivers.
+type SessionManager struct {
+	// Private
+	sessions    []*Session
+	generations int           // completed generations
+	failures    int           // failed generations
+	totals      GenerateStats // summed Metrics of completed generations
+}
+
+var _ tea.Model = (*SessionManager)(nil)
+
+// NewSessionManager returns a new SessionManager with no Sessions.
+func NewSessionManager() SessionManager {
+	return SessionManager{}
+}
+
+// Add adds the Session to the manager, returning its Init command, which
+// must be dispatched if the manager's Init already was.
+func (m *SessionManager) Add(s *Session) tea.Cmd {
+	if m.Session(s.ID()) == nil {
+		m.sessions = append(m.sessions, s)
+	}
+	return s.Init()
+}
+
+// Remove closes the Session with the ID and removes it from the manager.
+// Returns false if there is no such Session.
+func (m *SessionManager) Remove(id int64) bool {
+	idx := slices.IndexFunc(m.sessions, func(s *Session) bool { return s.ID() == id })
+	if idx < 0 {
+		return false
+	}
+	m.sessions[idx].Close()
+	m.sessions = slices.Delete(m.sessions, idx, idx+1)
+	return true
+}
+
+// Session returns the Session with the ID, or nil if there is none
+func (m *SessionManager) Session(id int64) *Session {
+	for _, s := range m.sessions {
+		if s.ID() == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Sessions returns the manager's Sessions, in the order added
+func (m *SessionManager) Sessions() []*Session {
+	return m.sessions
+}
+
+// IDs returns the IDs of the manager's Sessions, such as for RouteMsg
+func (m *SessionManager) IDs() []int64 {
+	ids := make([]int64, len(m.sessions))
+	for i, s := range m.sessions {
+		ids[i] = s.ID()
+	}
+	return ids
+}
+
+// Len returns the number of Sessions
+func (m *SessionManager) Len() int {
+	return len(m.sessions)
+}
+
+// Generating returns the number of Sessions generating
+func (m *SessionManager) Generating() int {
+	count := 0
+	for _, s := range m.sessions {
+		if s.IsGenerating() {
+			count++
+		}
+	}
+	return count
+}
+
+// IsGenerating returns true while any Session is generating
+func (m *SessionManager) IsGenerating() bool {
+	return m.Generating() > 0
+}
+
+// Generations returns the number of generations completed, including failures
+func (m *SessionManager) Generations() int {
+	return m.generations
+}
+
+// Failures returns the number of generations which failed
+func (m *SessionManager) Failures() int {
+	return m.failures
+}
+
+// Totals returns the summed durations and token counts of the completed generations
+func (m *SessionManager) Totals() GenerateStats {
+	return m.totals
+}
+
+// StartAll returns a command which starts a generation in each Session.
+func (m *SessionManager) StartAll() tea.Cmd {
+	cmds := make([]tea.Cmd, len(m.sessions))
+	for i, s := range m.sessions {
+		cmds[i] = s.Start()
+	}
+	return tea.Batch(cmds...)
+}
+
+// StopAll returns a command which stops each Session's generation.
+func (m *SessionManager) StopAll() tea.Cmd {
+	cmds := make([]tea.Cmd, len(m.sessions))
+	for i, s := range m.sessions {
+		cmds[i] = s.Stop()
+	}
+	return tea.Batch(cmds...)
+}
+
+// Close closes all of the Sessions.
+func (m *SessionManager) Close() {
+	for _, s := range m.sessions {
+		s.Close()
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea interface
+
+// Init starts the response listeners of the Sessions
+func (m *SessionManager) Init() tea.Cmd {
+	cmds := make([]tea.Cmd, len(m.sessions))
+	for i, s := range m.sessions {
+		cmds[i] = s.Init()
+	}
+	return tea.Batch(cmds...)
+}
+
+// Update routes the message to the Session of its ID, recording completed generations
+func (m *SessionManager) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	id, ok := MsgID(msg)
+	if !ok {
+		return m, nil
+	}
+	s := m.Session(id)
+	if s == nil {
+		return m, nil
+	}
+	if doneMsg, ok := msg.(GenerateDoneMsg); ok {
+		m.recordDone(doneMsg)
+	}
+	_, cmd := s.Update(msg)
+	return m, cmd
+}
+
+// View renders a summary of the Sessions
+func (m *SessionManager) View() string {
+	return fmt.Sprintf("%d/%d generating, %d done, %d failed", m.Generating(), len(m.sessions), m.generations, m.failures)
+}
+
+// recordDone adds the completed generation to the totals
+func (m *SessionManager) recordDone(doneMsg GenerateDoneMsg) {
+	m.generations++
+	if doneMsg.Error != nil {
+		m.failures++
+		return
+	}
+	t := &m.totals.Metrics
+	t.TotalDuration += doneMsg.Metrics.TotalDuration
+	t.LoadDuration += doneMsg.Metrics.LoadDuration
+	t.PromptEvalCount += doneMsg.Metrics.PromptEvalCount
+	t.PromptEvalDuration += doneMsg.Metrics.PromptEvalDuration
+	t.EvalCount += doneMsg.Metrics.EvalCount
+	t.EvalDuration += doneMsg.Metrics.EvalDuration
+}
@@ -0,0 +1,60 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSessionManager(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	first := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"left"}},
+		ollamateatest.GenerateScript{Chunks: []string{"right"}})
+	second := ollamatea.NewSession()
+	second.Host = srv.URL
+
+	manager := ollamatea.NewSessionManager()
+	manager.Add(first)
+	manager.Add(&second)
+	if manager.Len() != 2 || manager.Session(second.ID()) != &second {
+		t.Fatalf("unexpected sessions %v", manager.IDs())
+	}
+
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := manager.Update(msg)
+		return cmd
+	}
+	var done []ollamatea.GenerateDoneMsg
+	match := func(msg tea.Msg) bool {
+		if doneMsg, ok := msg.(ollamatea.GenerateDoneMsg); ok {
+			done = append(done, doneMsg)
+			manager.Update(msg) // record it, as the matched message is not passed to update
+		}
+		return len(done) == 2
+	}
+	ollamateatest.Run(t, update, tea.Batch(manager.Init(), manager.StartAll()), match, 0)
+
+	if manager.IsGenerating() {
+		t.Errorf("expected no generations, got %d", manager.Generating())
+	}
+	if manager.Generations() != 2 || manager.Failures() != 0 {
+		t.Errorf("expected 2 generations without failure, got %d and %d", manager.Generations(), manager.Failures())
+	}
+	if totals := manager.Totals(); totals.EvalCount != done[0].Metrics.EvalCount+done[1].Metrics.EvalCount {
+		t.Errorf("expected summed totals, got %+v", totals)
+	}
+	if first.Response() == "" || second.Response() == "" || first.Response() == second.Response() {
+		t.Errorf("expected each session's own response, got %q and %q", first.Response(), second.Response())
+	}
+
+	if !manager.Remove(first.ID()) || manager.Remove(first.ID()) || manager.Len() != 1 {
+		t.Errorf("expected the first session removed once, got %v", manager.IDs())
+	}
+}
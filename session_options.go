@@ -0,0 +1,83 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+//////////////////////////////////////////////////////////////////////////////
+// Typed generation options
+//
+// Ollama's model options are a loosely typed map, so a misspelled key or a
+// string where a number belongs is silently ignored.  These setters populate
+// the Options map with the correct keys and types, and chain:
+//
+//	session.SetTemperature(0.2).SetNumPredict(256).SetStop([]string{"\n\n"})
+//
+// See https://github.com/ollama/ollama/blob/main/docs/modelfile.md#valid-parameters-and-values
+
+// Option keys of the typed setters
+const (
+	OptionTemperature = "temperature"
+	OptionTopP        = "top_p"
+	OptionTopK        = "top_k"
+	OptionNumPredict  = "num_predict"
+	OptionStop        = "stop"
+	OptionSeed        = "seed"
+	OptionNumCtx      = "num_ctx"
+)
+
+// SetTemperature sets the sampling temperature option, returning the Session.
+// Higher values are more creative; 0 is deterministic.
+func (s *Session) SetTemperature(temperature float64) *Session {
+	s.Options = setOption(s.Options, OptionTemperature, temperature)
+	return s
+}
+
+// SetTopP sets the nucleus sampling option, returning the Session.
+// Only tokens within the top cumulative probability p are sampled.
+func (s *Session) SetTopP(p float64) *Session {
+	s.Options = setOption(s.Options, OptionTopP, p)
+	return s
+}
+
+// SetTopK sets the top-k sampling option, returning the Session.
+// Only the k most likely tokens are sampled.
+func (s *Session) SetTopK(k int) *Session {
+	s.Options = setOption(s.Options, OptionTopK, k)
+	return s
+}
+
+// SetNumPredict sets the maximum number of tokens to generate, returning the Session.
+// -1 is unlimited.
+func (s *Session) SetNumPredict(n int) *Session {
+	s.Options = setOption(s.Options, OptionNumPredict, n)
+	return s
+}
+
+// SetStop sets the stop sequences option, returning the Session.
+// Generation stops before any of them.
+func (s *Session) SetStop(stop []string) *Session {
+	s.Options = setOption(s.Options, OptionStop, stop)
+	return s
+}
+
+// SetSeed sets the random seed option, returning the Session.
+// With a fixed seed, the same prompt generates the same response.
+func (s *Session) SetSeed(seed int) *Session {
+	s.Options = setOption(s.Options, OptionSeed, seed)
+	return s
+}
+
+// SetNumCtx sets the context window size in tokens, returning the Session.
+// Ollama's default is small; long prompts need more.
+func (s *Session) SetNumCtx(n int) *Session {
+	s.Options = setOption(s.Options, OptionNumCtx, n)
+	return s
+}
+
+// setOption returns options with the key set to value, allocating it if nil
+func setOption(options map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	if options == nil {
+		options = make(map[string]interface{})
+	}
+	options[key] = value
+	return options
+}
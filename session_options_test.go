@@ -0,0 +1,39 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestSessionOptionSetters(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	s := ollamateatest.ScriptedSession(srv, ollamateatest.GenerateScript{Chunks: []string{"ok"}})
+	s.SetTemperature(0.2).SetTopP(0.9).SetTopK(40).SetNumPredict(64).SetStop([]string{"\n\n"}).SetSeed(7)
+	if len(s.Options) != 6 || s.Options[ollamatea.OptionTemperature] != 0.2 || s.Options[ollamatea.OptionSeed] != 7 {
+		t.Fatalf("unexpected options %v", s.Options)
+	}
+	if _, err := ollamatea.GenerateSync(context.Background(), s); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	// The options are sent as JSON, so numbers arrive as float64
+	opts := reqs[0].Options
+	if opts["temperature"] != 0.2 || opts["top_p"] != 0.9 || opts["top_k"] != 40.0 || opts["num_predict"] != 64.0 || opts["seed"] != 7.0 {
+		t.Errorf("unexpected request options %v", opts)
+	}
+	if stop, ok := opts["stop"].([]interface{}); !ok || !slices.Equal(stop, []interface{}{"\n\n"}) {
+		t.Errorf("unexpected stop option %v", opts["stop"])
+	}
+}
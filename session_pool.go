@@ -0,0 +1,281 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Session pools
+//
+// A SessionPool spreads generations across several Ollama hosts, such as a
+// few GPU boxes, behind one ID.  It holds a number of Sessions for each host;
+// each prompt sent to the pool is routed to an idle Session by the
+// pool's PoolStrategy, or queued until one is free.  Pass all messages to
+// the pool's Update; the generation's own messages carry its Session's ID,
+// announced beforehand with a PoolAssignedMsg.
+
+// PoolStrategy selects the Session of a SessionPool's next generation.
+type PoolStrategy int
+
+const (
+	// PoolRoundRobin rotates through the hosts.
+	PoolRoundRobin PoolStrategy = iota
+	// PoolLeastBusy picks the host with the fewest generations in flight.
+	PoolLeastBusy
+)
+
+// String returns the name of the PoolStrategy.
+func (s PoolStrategy) String() string {
+	switch s {
+	case PoolRoundRobin:
+		return "round-robin"
+	case PoolLeastBusy:
+		return "least-busy"
+	default:
+		return fmt.Sprintf("PoolStrategy(%d)", int(s))
+	}
+}
+
+// StartPoolGenerateMsg starts a generation of the prompt in a SessionPool.
+type StartPoolGenerateMsg struct {
+	ID     int64  // ID is the pool ID to start
+	Prompt string // Prompt is the generation's Prompt
+}
+
+// PoolAssignedMsg is sent when a SessionPool routes a generation to one of its
+// Sessions, before the Session's generation messages.
+type PoolAssignedMsg struct {
+	ID        int64  // ID is the pool ID
+	SessionID int64  // SessionID is the ID of the Session generating
+	Host      string // Host is the Session's Host
+	Prompt    string // Prompt is the generation's Prompt
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// SessionPool routes generations across Sessions of several hosts.
+// Like Session, its methods take pointer receivers, and its Init command
+// must be dispatched for its Sessions' messages to be delivered.
+type SessionPool struct {
+	Strategy PoolStrategy // Strategy selects the Session of each generation
+
+	// Private
+	id       int64
+	sessions []*Session     // interleaved by host, so rotating through them rotates hosts
+	assigned map[int64]bool // Sessions with a generation in flight, by ID
+	pending  []string       // prompts awaiting an idle Session
+	next     int            // index of the next Session to try, for PoolRoundRobin
+}
+
+var _ tea.Model = (*SessionPool)(nil)
+
+// NewSessionPool returns a new SessionPool with sessionsPerHost Sessions for
+// each of the hosts, or the DefaultHost() if none.  The Sessions have the
+// default values; set their Model and other fields with Configure.
+func NewSessionPool(sessionsPerHost int, hosts ...string) SessionPool {
+	if len(hosts) == 0 {
+		hosts = []string{DefaultHost()}
+	}
+	sessionsPerHost = max(sessionsPerHost, 1)
+	p := SessionPool{
+		id:       nextSessionID(),
+		assigned: make(map[int64]bool),
+	}
+	for range sessionsPerHost {
+		for _, host := range hosts {
+			s := NewSession()
+			s.Host = host
+			p.sessions = append(p.sessions, &s)
+		}
+	}
+	return p
+}
+
+// ID returns the unique ID of the SessionPool
+func (p *SessionPool) ID() int64 {
+	return p.id
+}
+
+// Sessions returns the pool's Sessions
+func (p *SessionPool) Sessions() []*Session {
+	return p.sessions
+}
+
+// Session returns the pool's Session with the ID, or nil if there is none
+func (p *SessionPool) Session(id int64) *Session {
+	for _, s := range p.sessions {
+		if s.ID() == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Configure invokes fn with each of the pool's Sessions, such as to set their
+// Model, System prompt, and Options.  Their Host should be left as is.
+func (p *SessionPool) Configure(fn func(s *Session)) {
+	for _, s := range p.sessions {
+		fn(s)
+	}
+}
+
+// Busy returns the number of generations in flight
+func (p *SessionPool) Busy() int {
+	return len(p.assigned)
+}
+
+// Pending returns the number of prompts waiting for an idle Session
+func (p *SessionPool) Pending() int {
+	return len(p.pending)
+}
+
+// IsGenerating returns true while any generation is in flight or waiting
+func (p *SessionPool) IsGenerating() bool {
+	return len(p.assigned) > 0 || len(p.pending) > 0
+}
+
+// Close closes all of the pool's Sessions and discards waiting prompts.
+func (p *SessionPool) Close() {
+	for _, s := range p.sessions {
+		s.Close()
+	}
+	clear(p.assigned)
+	p.pending = nil
+}
+
+// Send returns a command which starts a generation of the prompt in the pool.
+func (p *SessionPool) Send(prompt string) tea.Cmd {
+	return Cmdize(StartPoolGenerateMsg{ID: p.id, Prompt: prompt})
+}
+
+// Stop returns a command which stops all of the pool's generations,
+// discarding waiting prompts.
+func (p *SessionPool) Stop() tea.Cmd {
+	return Cmdize(StopGenerateMsg{ID: p.id})
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea interface
+
+// Init starts the response listeners of the pool's Sessions
+func (p *SessionPool) Init() tea.Cmd {
+	cmds := make([]tea.Cmd, 0, len(p.sessions))
+	for _, s := range p.sessions {
+		cmds = append(cmds, s.Init())
+	}
+	return tea.Batch(cmds...)
+}
+
+// Update handles BubbleTea messages for the SessionPool, passing those of
+// its Sessions to them.
+func (p *SessionPool) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case StartPoolGenerateMsg:
+		if msg.ID != p.id {
+			return p, nil
+		}
+		return p, p.dispatch(msg.Prompt)
+
+	case StopGenerateMsg:
+		if msg.ID != p.id {
+			break
+		}
+		logger().Debug("pool stop requested", "pool_id", p.id, "busy", len(p.assigned), "pending", len(p.pending))
+		p.pending = nil
+		cmds := make([]tea.Cmd, 0, len(p.assigned))
+		for _, s := range p.sessions {
+			if p.assigned[s.ID()] {
+				cmds = append(cmds, s.Stop())
+			}
+		}
+		clear(p.assigned)
+		return p, tea.Batch(cmds...)
+	}
+
+	id, ok := MsgID(msg)
+	if !ok {
+		return p, nil
+	}
+	s := p.Session(id)
+	if s == nil {
+		return p, nil
+	}
+	_, cmd := s.Update(msg)
+	if _, ok := msg.(GenerateDoneMsg); ok && p.assigned[id] {
+		delete(p.assigned, id)
+		if len(p.pending) > 0 {
+			prompt := p.pending[0]
+			p.pending = p.pending[1:]
+			cmd = tea.Batch(cmd, p.dispatch(prompt))
+		}
+	}
+	return p, cmd
+}
+
+// View renders the hosts and their generations in flight
+func (p *SessionPool) View() string {
+	var sb strings.Builder
+	for _, s := range p.sessions {
+		state := "idle"
+		if p.assigned[s.ID()] {
+			state = "generating"
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", s.Host, state)
+	}
+	if len(p.pending) > 0 {
+		fmt.Fprintf(&sb, "%d waiting\n", len(p.pending))
+	}
+	return sb.String()
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// dispatch starts the prompt on the Session selected by the Strategy,
+// or queues it if all are busy
+func (p *SessionPool) dispatch(prompt string) tea.Cmd {
+	s := p.selectSession()
+	if s == nil {
+		p.pending = append(p.pending, prompt)
+		return Cmdize(QueuedMsg{ID: p.id, CreatedAt: time.Now(), Waiting: len(p.pending) - 1})
+	}
+	p.assigned[s.ID()] = true
+	s.Prompt = prompt
+	logger().Debug("pool generate assigned", "pool_id", p.id, "session_id", s.ID(), "host", s.Host, "strategy", p.Strategy.String())
+	assigned := PoolAssignedMsg{ID: p.id, SessionID: s.ID(), Host: s.Host, Prompt: prompt}
+	return tea.Sequence(Cmdize(assigned), s.Start())
+}
+
+// selectSession returns the idle Session for the next generation, or nil if all are busy
+func (p *SessionPool) selectSession() *Session {
+	if p.Strategy == PoolLeastBusy {
+		busy := make(map[string]int)
+		for _, s := range p.sessions {
+			if p.assigned[s.ID()] {
+				busy[s.Host]++
+			}
+		}
+		var best *Session
+		for _, s := range p.sessions {
+			if !p.assigned[s.ID()] && (best == nil || busy[s.Host] < busy[best.Host]) {
+				best = s
+			}
+		}
+		return best
+	}
+
+	n := len(p.sessions)
+	for i := range n {
+		idx := (p.next + i) % n
+		if s := p.sessions[idx]; !p.assigned[s.ID()] {
+			p.next = (idx + 1) % n
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSessionPool(t *testing.T) {
+	gpu1, gpu2 := ollamateatest.NewServer(), ollamateatest.NewServer()
+	defer gpu1.Close()
+	defer gpu2.Close()
+	gpu1.QueueGenerate(ollamateatest.GenerateScript{Chunks: []string{"one"}}, ollamateatest.GenerateScript{Chunks: []string{"three"}})
+	gpu2.QueueGenerate(ollamateatest.GenerateScript{Chunks: []string{"two"}, Delay: 100 * time.Millisecond})
+
+	pool := ollamatea.NewSessionPool(1, gpu1.URL, gpu2.URL)
+	pool.Configure(func(s *ollamatea.Session) { s.Model = "llama3.2" })
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := pool.Update(msg)
+		return cmd
+	}
+
+	var queued int
+	hosts := make(map[string]int)
+	done := make(map[string]bool)
+	var last tea.Msg
+	match := func(msg tea.Msg) bool {
+		switch msg := msg.(type) {
+		case ollamatea.QueuedMsg:
+			queued++
+		case ollamatea.PoolAssignedMsg:
+			hosts[msg.Host]++
+		case ollamatea.GenerateDoneMsg:
+			if msg.Error != nil {
+				t.Errorf("unexpected error %v", msg.Error)
+			}
+			done[msg.Response] = true
+			last = msg
+		}
+		return len(done) == 3
+	}
+	ollamateatest.Run(t, update, tea.Batch(pool.Init(), tea.Sequence(pool.Send("a"), pool.Send("b"), pool.Send("c"))), match, 0)
+
+	if queued != 1 {
+		t.Errorf("expected the third prompt queued, got %d queued", queued)
+	}
+	if hosts[gpu1.URL] != 2 || hosts[gpu2.URL] != 1 {
+		t.Errorf("expected round-robin assignment, got %v", hosts)
+	}
+	if len(gpu1.GenerateRequests()) != 2 || len(gpu2.GenerateRequests()) != 1 {
+		t.Errorf("unexpected requests %d %d", len(gpu1.GenerateRequests()), len(gpu2.GenerateRequests()))
+	}
+	pool.Update(last) // the matched message is not passed to update
+	if pool.IsGenerating() {
+		t.Errorf("expected the pool idle, busy %d pending %d", pool.Busy(), pool.Pending())
+	}
+}
@@ -0,0 +1,46 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Request previews
+//
+// Prompt engineers want to see exactly what a Session sends: the final
+// prompt, system prompt, template, and options.  BuildRequest returns the
+// request a generation would send, and a Session with DryRun set answers
+// each StartGenerateMsg with a RequestPreviewMsg instead of generating.
+
+// RequestPreviewMsg is sent instead of generating when a Session's DryRun is set.
+type RequestPreviewMsg struct {
+	ID        int64           // ID is the generation session ID
+	CreatedAt time.Time       // CreatedAt is when the request was built
+	Host      string          // Host is the Ollama Host the request would be sent to
+	Request   GenerateRequest // Request is the request which would be sent
+}
+
+// BuildRequest returns the request a generation of the Session would send,
+// as the Session is now.  Its Options, Context, and Images are copies, so
+// changing them does not change the Session.
+func (s *Session) BuildRequest() *GenerateRequest {
+	req := s.makeGenerateRequest()
+	req.Options = maps.Clone(req.Options)
+	req.Context = slices.Clone(req.Context)
+	req.Images = slices.Clone(req.Images)
+	return req
+}
+
+// previewMsg returns a RequestPreviewMsg of the Session's request
+func (s *Session) previewMsg() RequestPreviewMsg {
+	return RequestPreviewMsg{
+		ID:        s.id,
+		CreatedAt: time.Now(),
+		Host:      s.Host,
+		Request:   *s.BuildRequest(),
+	}
+}
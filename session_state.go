@@ -0,0 +1,98 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Session state
+//
+// A Session's request fields and accumulated Response may be saved as JSON
+// and restored, so a TUI can persist a conversation across restarts:
+//
+//	data, err := json.Marshal(&session)
+//	...
+//	session := ollamatea.NewSession()
+//	err = json.Unmarshal(data, &session)
+//
+// Runtime state, such as the Backend, Hooks, and any generation in flight,
+// is not saved.  For conversations across Sessions, ChatSessions, and
+// ToolAgents, see ConversationStore.
+
+// SessionState is the serializable state of a Session.
+type SessionState struct {
+	Host     string                 `json:"host,omitempty"`
+	Model    string                 `json:"model"`
+	System   string                 `json:"system,omitempty"`
+	Template string                 `json:"template,omitempty"`
+	Context  []int                  `json:"context,omitempty"`
+	Prompt   string                 `json:"prompt,omitempty"`
+	Suffix   string                 `json:"suffix,omitempty"`
+	Images   []ImageData            `json:"images,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+	Format   string                 `json:"format,omitempty"`
+	Raw      bool                   `json:"raw,omitempty"`
+	Response string                 `json:"response,omitempty"` // Response is the last generation
+	History  []Turn                 `json:"history,omitempty"`  // History of the conversation
+}
+
+// State returns the serializable state of the Session, including its History.
+func (s *Session) State() SessionState {
+	return SessionState{
+		Host:     s.Host,
+		Model:    s.Model,
+		System:   s.System,
+		Template: s.Template,
+		Context:  slices.Clone(s.Context),
+		Prompt:   s.Prompt,
+		Suffix:   s.Suffix,
+		Images:   slices.Clone(s.Images),
+		Options:  s.Options,
+		Format:   s.Format,
+		Raw:      s.Raw,
+		Response: s.Response(),
+		History:  s.History(),
+	}
+}
+
+// RestoreState sets the Session's fields and Response from the state.
+// Any generation in flight should be stopped first.
+func (s *Session) RestoreState(state SessionState) {
+	s.Host = state.Host
+	s.Model = state.Model
+	s.System = state.System
+	s.Template = state.Template
+	s.Context = slices.Clone(state.Context)
+	s.Prompt = state.Prompt
+	s.Suffix = state.Suffix
+	s.Images = slices.Clone(state.Images)
+	s.Options = state.Options
+	s.Format = state.Format
+	s.Raw = state.Raw
+	s.history = slices.Clone(state.History)
+	s.resetResponse()
+	if state.Response != "" {
+		s.response = &strings.Builder{}
+		s.response.WriteString(state.Response)
+	}
+}
+
+// MarshalJSON returns the Session's SessionState as JSON.
+func (s *Session) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.State())
+}
+
+// UnmarshalJSON restores the Session from a SessionState in JSON.
+// Unmarshal into a Session from NewSession, so it has an ID and can generate.
+func (s *Session) UnmarshalJSON(data []byte) error {
+	var state SessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.RestoreState(state)
+	return nil
+}
@@ -0,0 +1,39 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func TestSessionJSON(t *testing.T) {
+	session := ollamatea.NewSession()
+	session.Host = "http://gpu-box:11434"
+	session.Model = "llama3.2"
+	session.Prompt = "Why is the sky blue?"
+	session.Context = []int{1, 2, 3}
+	session.Options = map[string]interface{}{"temperature": 0.5}
+	session.RestoreState(ollamatea.SessionState{
+		Host: session.Host, Model: session.Model, Prompt: session.Prompt,
+		Context: session.Context, Options: session.Options, Response: "Rayleigh scattering.",
+	})
+
+	data, err := json.Marshal(&session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := ollamatea.NewSession()
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored.State(), session.State()) {
+		t.Errorf("expected %+v, got %+v", session.State(), restored.State())
+	}
+	if restored.Response() != "Rayleigh scattering." || restored.ID() == session.ID() {
+		t.Errorf("expected the response restored with a new ID, got %q", restored.Response())
+	}
+}
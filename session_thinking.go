@@ -0,0 +1,104 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"strings"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Reasoning
+//
+// Reasoning models, such as deepseek-r1 and qwq, think aloud between <think>
+// and </think> tags before answering.  A Session with SplitThinking set parses
+// that reasoning out of its response as it streams: the reasoning is delivered
+// as GenerateThinkingMsg and kept in Thinking(), while GenerateResponseMsg,
+// GenerateDoneMsg, and Response() hold only the answer.
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// GenerateThinkingMsg is the message generated for each streamed part of a
+// generation's reasoning, when the Session's SplitThinking is set.
+// To check the reasoning so far, see [Session.Thinking].
+type GenerateThinkingMsg struct {
+	ID        int64     // ID is the generation session ID corresponding to the reasoning
+	CreatedAt time.Time // CreatedAt is the timestamp of the response
+	Thinking  string    // Thinking is the reasoning text in this specific part
+}
+
+// Thinking returns the reasoning of the generation so far, if SplitThinking is set
+func (s *Session) Thinking() string {
+	if s.thinking == nil {
+		return ""
+	}
+	return s.thinking.String()
+}
+
+// splitThinking returns the answer and reasoning parts of streamed text,
+// appending the reasoning to Thinking().  If done, any text held back as a
+// possible partial tag is released.
+func (s *Session) splitThinking(text string, done bool) (answer string, thinking string) {
+	answer, thinking = s.thinkParser.feed(text, done)
+	if thinking != "" {
+		if s.thinking == nil {
+			s.thinking = &strings.Builder{}
+		}
+		s.thinking.WriteString(thinking)
+	}
+	return answer, thinking
+}
+
+// resetThinking clears the reasoning for a new generation
+func (s *Session) resetThinking() {
+	s.thinking = nil
+	s.thinkParser = thinkParser{}
+}
+
+// thinkParser splits streamed text into answer and reasoning by the think tags,
+// which may be split across chunks
+type thinkParser struct {
+	inThink bool   // within a <think> section
+	held    string // text which may begin a tag, awaiting the next chunk
+}
+
+// feed parses the next chunk of text, returning its answer and reasoning parts
+func (p *thinkParser) feed(text string, done bool) (answer string, thinking string) {
+	text = p.held + text
+	p.held = ""
+	var answerSB, thinkingSB strings.Builder
+	for text != "" {
+		tag := thinkOpenTag
+		out := &answerSB
+		if p.inThink {
+			tag, out = thinkCloseTag, &thinkingSB
+		}
+		if idx := strings.Index(text, tag); idx >= 0 {
+			out.WriteString(text[:idx])
+			text = text[idx+len(tag):]
+			p.inThink = !p.inThink
+			continue
+		}
+		keep := 0
+		if !done {
+			keep = partialTagSuffix(text, tag)
+		}
+		out.WriteString(text[:len(text)-keep])
+		p.held = text[len(text)-keep:]
+		break
+	}
+	return answerSB.String(), thinkingSB.String()
+}
+
+// partialTagSuffix returns the length of the longest suffix of text which is a proper prefix of tag
+func partialTagSuffix(text string, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,22 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import "testing"
+
+func TestThinkParser(t *testing.T) {
+	var p thinkParser
+	var answer, thinking string
+	for _, chunk := range []string{"<thi", "nk>Let me see", " <", "/think>\n", "It is <b>42</b>", " <"} {
+		a, th := p.feed(chunk, false)
+		answer, thinking = answer+a, thinking+th
+	}
+	a, th := p.feed("", true)
+	answer, thinking = answer+a, thinking+th
+	if thinking != "Let me see " {
+		t.Errorf("unexpected thinking %q", thinking)
+	}
+	if answer != "\nIt is <b>42</b> <" {
+		t.Errorf("unexpected answer %q", answer)
+	}
+}
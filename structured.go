@@ -0,0 +1,76 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Structured output
+//
+// A Session with Format "json" generates a JSON value, which StructuredCmd
+// decodes into a Go type once the generation is done:
+//
+//	case ollamatea.GenerateDoneMsg:
+//	    return m, ollamatea.StructuredCmd[Recipe](msg)
+//	case ollamatea.GenerateStructuredMsg[Recipe]:
+//	    m.recipe = msg.Value
+
+// GenerateStructuredMsg is sent with the decoded response of a generation.
+type GenerateStructuredMsg[T any] struct {
+	ID       int64  // ID is the generation session ID corresponding to the Response
+	Value    T      // Value is the decoded Response; the zero value on error
+	Response string // Response is the full text of the generation
+	Error    error  // Error is why the generation or decoding failed, if either did
+}
+
+// structuredMsg is implemented by each GenerateStructuredMsg, for MsgID
+type structuredMsg interface {
+	structuredID() int64
+}
+
+// structuredID returns the ID of the message
+func (m GenerateStructuredMsg[T]) structuredID() int64 {
+	return m.ID
+}
+
+// StructuredCmd returns a command which decodes the response of the done
+// generation into a T, returning a [GenerateStructuredMsg].
+func StructuredCmd[T any](msg GenerateDoneMsg) tea.Cmd {
+	return func() tea.Msg {
+		return ParseStructured[T](msg)
+	}
+}
+
+// ParseStructured decodes the response of the done generation into a T.
+// A generation error is passed through without decoding.
+func ParseStructured[T any](msg GenerateDoneMsg) GenerateStructuredMsg[T] {
+	structured := GenerateStructuredMsg[T]{ID: msg.ID, Response: msg.Response, Error: msg.Error}
+	if msg.Error != nil {
+		return structured
+	}
+	if err := json.Unmarshal([]byte(trimCodeFence(msg.Response)), &structured.Value); err != nil {
+		structured.Error = fmt.Errorf("failed to decode structured response: %w", err)
+		logger().Warn("structured response decode failed", "id", msg.ID, "error", err)
+	}
+	return structured
+}
+
+// trimCodeFence trims whitespace and a surrounding Markdown code fence, which
+// models often add to JSON when not constrained by Format.
+func trimCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") || len(text) < 6 {
+		return text
+	}
+	text = strings.TrimSuffix(text[3:], "```")
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+		text = text[newline+1:] // drop the info string, such as "json"
+	}
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,68 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type testRecipe struct {
+	Name    string   `json:"name"`
+	Minutes int      `json:"minutes"`
+	Steps   []string `json:"steps"`
+}
+
+func TestSessionStructured(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{`{"name": "Toast", `, `"minutes": 3, "steps": ["slice", "toast"]}`}},
+	)
+	session.Prompt = "A recipe for toast, as JSON with name, minutes, and steps."
+	session.Format = "json"
+	update := func(msg tea.Msg) tea.Cmd {
+		if done, ok := msg.(ollamatea.GenerateDoneMsg); ok {
+			return ollamatea.StructuredCmd[testRecipe](done)
+		}
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	cmd := tea.Batch(session.Init(), tea.Sequence(session.StartGenerateMsg))
+	msg := ollamateatest.Run(t, update, cmd, ollamateatest.MatchType[ollamatea.GenerateStructuredMsg[testRecipe]](), 0)
+	structured := msg.(ollamatea.GenerateStructuredMsg[testRecipe])
+	if id, ok := ollamatea.MsgID(structured); structured.Error != nil || !ok || id != session.ID() {
+		t.Fatalf("unexpected structured message %#v", structured)
+	}
+	if recipe := structured.Value; recipe.Name != "Toast" || recipe.Minutes != 3 || len(recipe.Steps) != 2 {
+		t.Errorf("unexpected recipe %#v", recipe)
+	}
+
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 1 || reqs[0].Format != "json" {
+		t.Errorf("expected a json format request, got %#v", reqs)
+	}
+}
+
+func TestParseStructured(t *testing.T) {
+	fenced := ollamatea.ParseStructured[testRecipe](ollamatea.GenerateDoneMsg{ID: 3, Response: "```json\n{\"name\": \"Tea\"}\n```\n"})
+	if fenced.Error != nil || fenced.ID != 3 || fenced.Value.Name != "Tea" {
+		t.Errorf("expected the fenced JSON decoded, got %#v", fenced)
+	}
+
+	invalid := ollamatea.ParseStructured[testRecipe](ollamatea.GenerateDoneMsg{Response: "Here is your recipe!"})
+	if invalid.Error == nil || invalid.Response != "Here is your recipe!" {
+		t.Errorf("expected a decode error, got %#v", invalid)
+	}
+
+	genErr := errors.New("boom")
+	failed := ollamatea.ParseStructured[testRecipe](ollamatea.GenerateDoneMsg{Response: "{}", Error: genErr})
+	if !errors.Is(failed.Error, genErr) {
+		t.Errorf("expected the generation error, got %#v", failed)
+	}
+}
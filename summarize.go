@@ -0,0 +1,311 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Map-reduce summarization
+//
+// Summarize condenses text longer than a model's context: it splits the text
+// into chunks, summarizes the chunks concurrently (the map), and merges their
+// summaries (the reduce).  Summaries too long to merge at once are themselves
+// chunked and condensed until they fit.
+
+const (
+	// DefaultSummarizeChunkTokens is the estimated size of each chunk, in tokens.
+	DefaultSummarizeChunkTokens = 2000
+	// DefaultSummarizeConcurrency is the number of chunks summarized at once.
+	DefaultSummarizeConcurrency = 4
+	// DefaultSummarizeChunkPrompt is the system prompt for summarizing each chunk.
+	DefaultSummarizeChunkPrompt = "Summarize the following excerpt of a longer document concisely, " +
+		"keeping the key facts, names, numbers, and events.  Reply with only the summary."
+	// DefaultSummarizeMergePrompt is the system prompt for merging chunk summaries.
+	DefaultSummarizeMergePrompt = "The following are summaries of consecutive parts of one document.  " +
+		"Combine them into a single concise summary of the whole document.  Reply with only the summary."
+)
+
+// maxSummarizeLevels bounds the rounds of condensing summaries, in case a model does not shorten them
+const maxSummarizeLevels = 8
+
+// SummarizeStage is the stage of a Summarize in progress.
+type SummarizeStage string
+
+const (
+	SummarizeStageMap    SummarizeStage = "map"    // SummarizeStageMap is summarizing the chunks of the text
+	SummarizeStageReduce SummarizeStage = "reduce" // SummarizeStageReduce is merging summaries
+)
+
+// SummarizeProgress reports the progress of a Summarize.
+type SummarizeProgress struct {
+	Stage SummarizeStage // Stage in progress
+	Level int            // Level is 0 for the map, and counts reduce rounds after it
+	Done  int            // Done is the number of generations completed in this Level
+	Total int            // Total is the number of generations in this Level
+}
+
+// SummarizeOptions configure a Summarize.  Zero values take the defaults.
+type SummarizeOptions struct {
+	Host        string                 // Host of the Ollama server (default: DefaultHost())
+	Model       string                 // Model to summarize with (default: DefaultModel())
+	Backend     Backend                // Backend to generate with; if nil, SharedBackend(Host) is used
+	Options     map[string]interface{} // Options lists model-specific options
+	ChunkTokens int                    // ChunkTokens is the estimated size of each chunk (default: DefaultSummarizeChunkTokens)
+	Concurrency int                    // Concurrency is the number of chunks summarized at once (default: DefaultSummarizeConcurrency)
+	ChunkPrompt string                 // ChunkPrompt is the system prompt for each chunk (default: DefaultSummarizeChunkPrompt)
+	MergePrompt string                 // MergePrompt is the system prompt for merging (default: DefaultSummarizeMergePrompt)
+
+	// RateLimiter, if set, delays generations beyond its limits
+	RateLimiter *RateLimiter
+
+	// OnProgress, if set, is called as each generation completes.
+	// It is called from the generating goroutines, one at a time.
+	OnProgress func(SummarizeProgress)
+}
+
+// withDefaults returns the options with defaults for zero values
+func (o SummarizeOptions) withDefaults() SummarizeOptions {
+	if o.Host == "" {
+		o.Host = DefaultHost()
+	}
+	if o.Model == "" {
+		o.Model = DefaultModel()
+	}
+	if o.ChunkTokens <= 0 {
+		o.ChunkTokens = DefaultSummarizeChunkTokens
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultSummarizeConcurrency
+	}
+	if o.ChunkPrompt == "" {
+		o.ChunkPrompt = DefaultSummarizeChunkPrompt
+	}
+	if o.MergePrompt == "" {
+		o.MergePrompt = DefaultSummarizeMergePrompt
+	}
+	return o
+}
+
+// Summarize performs a blocking map-reduce summarization of text.
+// Text which fits in one chunk is summarized with a single generation.
+func Summarize(ctx context.Context, text string, opts SummarizeOptions) (string, error) {
+	opts = opts.withDefaults()
+	chunks := ChunkText(text, opts.ChunkTokens, opts.Model)
+	if len(chunks) == 0 {
+		return "", nil
+	}
+
+	ctx, span := startSpan(ctx, "ollamatea.summarize",
+		Attr("ollamatea.host", opts.Host),
+		Attr("ollamatea.model", opts.Model),
+		Attr("ollamatea.chunks", len(chunks)))
+	defer span.End()
+
+	summaries, err := summarizeLevel(ctx, chunks, opts.ChunkPrompt, SummarizeStageMap, 0, opts)
+	for level := 1; err == nil && len(summaries) > 1; level++ {
+		if level > maxSummarizeLevels {
+			err = fmt.Errorf("summaries did not converge after %d rounds", maxSummarizeLevels)
+			break
+		}
+		// merge in one generation if they fit, otherwise condense them in chunks
+		chunks = ChunkText(strings.Join(summaries, "\n\n"), opts.ChunkTokens, opts.Model)
+		if len(chunks) > 1 && len(chunks) >= len(summaries) {
+			// chunking did not group any summaries; merge pairs instead
+			chunks = pairSummaries(summaries)
+		}
+		summaries, err = summarizeLevel(ctx, chunks, opts.MergePrompt, SummarizeStageReduce, level, opts)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	return summaries[0], nil
+}
+
+// summarizeLevel summarizes each chunk concurrently, returning the summaries in order
+func summarizeLevel(ctx context.Context, chunks []string, system string, stage SummarizeStage, level int, opts SummarizeOptions) ([]string, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	summaries := make([]string, len(chunks))
+	sem := make(chan struct{}, opts.Concurrency)
+	var mu sync.Mutex // guards done and OnProgress
+	done := 0
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			summary, err := summarizeChunk(ctx, chunk, system, opts)
+			if err != nil {
+				cancel(err)
+				return
+			}
+			summaries[i] = summary
+
+			mu.Lock()
+			done++
+			if opts.OnProgress != nil {
+				opts.OnProgress(SummarizeProgress{Stage: stage, Level: level, Done: done, Total: len(chunks)})
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// summarizeChunk summarizes one chunk with a Session
+func summarizeChunk(ctx context.Context, chunk string, system string, opts SummarizeOptions) (string, error) {
+	session := NewSession()
+	session.Host = opts.Host
+	session.Model = opts.Model
+	session.Backend = opts.Backend
+	session.Options = opts.Options
+	session.RateLimiter = opts.RateLimiter
+	session.System = system
+	session.Prompt = chunk
+	done, err := session.Generate(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(done.Response), nil
+}
+
+// pairSummaries joins consecutive pairs of summaries
+func pairSummaries(summaries []string) []string {
+	var pairs []string
+	for i := 0; i < len(summaries); i += 2 {
+		if i+1 < len(summaries) {
+			pairs = append(pairs, summaries[i]+"\n\n"+summaries[i+1])
+		} else {
+			pairs = append(pairs, summaries[i])
+		}
+	}
+	return pairs
+}
+
+// ChunkText splits text into chunks of about maxTokens estimated tokens for
+// the model, preferring to break between paragraphs, then lines, then words.
+func ChunkText(text string, maxTokens int, model string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if maxTokens <= 0 {
+		maxTokens = DefaultSummarizeChunkTokens
+	}
+	var chunks []string
+	var current strings.Builder
+	currentTokens := 0
+	add := func(piece string, sep string) {
+		tokens := EstimateTokens(piece, model)
+		if current.Len() > 0 {
+			tokens += EstimateTokens(sep, model)
+		}
+		if currentTokens > 0 && currentTokens+tokens > maxTokens {
+			chunks = append(chunks, strings.TrimSpace(current.String()))
+			current.Reset()
+			currentTokens = 0
+			tokens = EstimateTokens(piece, model)
+		}
+		if current.Len() > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(piece)
+		currentTokens += tokens
+	}
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if EstimateTokens(paragraph, model) <= maxTokens {
+			add(paragraph, "\n\n")
+			continue
+		}
+		for _, line := range strings.Split(paragraph, "\n") {
+			if EstimateTokens(line, model) <= maxTokens {
+				add(line, "\n")
+				continue
+			}
+			for _, word := range strings.Fields(line) {
+				add(word, " ")
+			}
+		}
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, strings.TrimSpace(current.String()))
+	}
+	return chunks
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea messages
+
+// SummarizeProgressMsg is sent as a SummarizeCmd progresses.
+// Its Next command must be run to receive the following message.
+type SummarizeProgressMsg struct {
+	ID       int64 // ID given to SummarizeCmd
+	Progress SummarizeProgress
+
+	updates <-chan tea.Msg
+}
+
+// Next returns the command which waits for the message following this one.
+func (msg SummarizeProgressMsg) Next() tea.Cmd {
+	updates := msg.updates
+	return func() tea.Msg {
+		if updates == nil {
+			return nil
+		}
+		return <-updates
+	}
+}
+
+// SummarizeDoneMsg is sent when a SummarizeCmd completes.
+type SummarizeDoneMsg struct {
+	ID      int64  // ID given to SummarizeCmd
+	Summary string // Summary of the text
+	Error   error  // Error, if the summarization failed
+}
+
+// SummarizeCmd returns a command which summarizes text like [Summarize].
+// It returns a [SummarizeProgressMsg] as each generation completes, whose
+// Next command must be run to continue, and finally a [SummarizeDoneMsg].
+// The id is an arbitrary identifier to correlate the messages.
+func SummarizeCmd(ctx context.Context, id int64, text string, opts SummarizeOptions) tea.Cmd {
+	return func() tea.Msg {
+		updates := make(chan tea.Msg)
+		onProgress := opts.OnProgress
+		opts.OnProgress = func(progress SummarizeProgress) {
+			if onProgress != nil {
+				onProgress(progress)
+			}
+			updates <- SummarizeProgressMsg{ID: id, Progress: progress, updates: updates}
+		}
+		go func() {
+			summary, err := Summarize(ctx, text, opts)
+			if err != nil {
+				logger().Error("summarize failed", "id", id, "error", err)
+			}
+			updates <- SummarizeDoneMsg{ID: id, Summary: summary, Error: err}
+		}()
+		return <-updates
+	}
+}
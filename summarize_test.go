@@ -0,0 +1,78 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestChunkText(t *testing.T) {
+	var paragraphs []string
+	for i := range 20 {
+		paragraphs = append(paragraphs, fmt.Sprintf("Paragraph %d has a few words in it.", i))
+	}
+	text := strings.Join(paragraphs, "\n\n")
+	chunks := ollamatea.ChunkText(text, 40, "")
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if tokens := ollamatea.EstimateTokens(chunk, ""); tokens > 40 {
+			t.Errorf("chunk of %d tokens exceeds the limit: %q", tokens, chunk)
+		}
+	}
+	if strings.Join(chunks, "\n\n") != text {
+		t.Error("chunks should reassemble to the text")
+	}
+	if chunks := ollamatea.ChunkText("  ", 40, ""); chunks != nil {
+		t.Errorf("expected no chunks of blank text, got %v", chunks)
+	}
+}
+
+func TestSummarizeCmd(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	for i := range 4 {
+		srv.QueueGenerate(ollamateatest.GenerateScript{Chunks: []string{fmt.Sprintf("summary %d", i)}})
+	}
+	srv.QueueGenerate(ollamateatest.GenerateScript{Chunks: []string{"the whole story"}})
+
+	var paragraphs []string
+	for i := range 4 {
+		paragraphs = append(paragraphs, strings.Repeat(fmt.Sprintf("Part %d of the story. ", i), 8))
+	}
+	opts := ollamatea.SummarizeOptions{Host: srv.URL, Model: "llama3.2", ChunkTokens: 50, Concurrency: 2}
+
+	var progress []ollamatea.SummarizeProgress
+	cmd := ollamatea.SummarizeCmd(context.Background(), 9, strings.Join(paragraphs, "\n\n"), opts)
+	var done ollamatea.SummarizeDoneMsg
+	for cmd != nil {
+		switch msg := cmd().(type) {
+		case ollamatea.SummarizeProgressMsg:
+			progress = append(progress, msg.Progress)
+			cmd = msg.Next()
+		case ollamatea.SummarizeDoneMsg:
+			done, cmd = msg, nil
+		default:
+			t.Fatalf("unexpected message %#v", msg)
+		}
+	}
+	if done.Error != nil || done.ID != 9 || done.Summary != "the whole story" {
+		t.Fatalf("unexpected done message %#v", done)
+	}
+	if len(progress) != 5 || progress[3].Stage != ollamatea.SummarizeStageMap || progress[3].Done != 4 ||
+		progress[4].Stage != ollamatea.SummarizeStageReduce {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 5 || reqs[0].System != ollamatea.DefaultSummarizeChunkPrompt || reqs[4].System != ollamatea.DefaultSummarizeMergePrompt {
+		t.Errorf("unexpected requests %+v", reqs)
+	}
+}
@@ -0,0 +1,49 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Request timeouts
+//
+// A hung server would otherwise stall a Session or EmbedSession forever.
+// Their Timeout bounds each request, apart from any time queued by a
+// RateLimiter.  A request which exceeds it fails with an OllamaError of
+// ErrorKindTimeout, announced first by a TimeoutErrorMsg.
+
+// TimeoutErrorMsg is sent when a request exceeds its Session's or EmbedSession's Timeout.
+// It is followed by the usual GenerateDoneMsg or EmbedErrorMsg with the Error.
+type TimeoutErrorMsg struct {
+	ID      int64         // ID is the session ID of the request
+	Timeout time.Duration // Timeout is the deadline which was exceeded
+	Error   error         // Error is the request's error, of ErrorKindTimeout
+}
+
+// withRequestTimeout returns a context for one request, with the timeout if positive
+func withRequestTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// requestTimedOut returns true if the request context ended by its own deadline,
+// rather than by its parent ending
+func requestTimedOut(reqCtx context.Context, parent context.Context) bool {
+	return errors.Is(reqCtx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+}
+
+// timeoutMsg returns a message sequencing a TimeoutErrorMsg before msg
+func timeoutMsg(id int64, timeout time.Duration, err error, msg tea.Msg) tea.Msg {
+	return tea.Sequence(
+		Cmdize(TimeoutErrorMsg{ID: id, Timeout: timeout, Error: err}),
+		Cmdize(msg),
+	)()
+}
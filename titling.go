@@ -0,0 +1,128 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Conversation titling
+//
+// A conversation can be titled with a short background generation, for
+// display in tabs and conversation lists.  Set AutoTitle on a Session or
+// ToolAgent to title its conversation once the first exchange completes,
+// or use TitleConversationCmd directly.
+
+// DefaultTitlePrompt is the instruction for generating conversation titles.
+const DefaultTitlePrompt = "Summarize this conversation in 5 words or fewer, as a title. " +
+	"Reply with only the title, without quotes or punctuation."
+
+// maxTitleLength bounds the length of generated titles, in runes
+const maxTitleLength = 60
+
+// maxTitleMessageLength bounds each message's content in the titling transcript, in runes
+const maxTitleMessageLength = 1000
+
+// ConversationTitledMsg is sent when a conversation title has been generated.
+type ConversationTitledMsg struct {
+	ID    int64  // ID of the Session or ToolAgent whose conversation was titled
+	Title string // Title of the conversation; empty on error
+	Error error  // Error, if titling failed
+}
+
+// TitleConversationCmd returns a command which generates a title for the
+// messages with the model on the Ollama host.  It returns a
+// [ConversationTitledMsg] with the given component ID.
+func TitleConversationCmd(id int64, ollamaHost string, model string, messages []Message) tea.Cmd {
+	messages = slices.Clone(messages)
+	return func() tea.Msg {
+		return titleConversationMsg(id, nil, ollamaHost, model, messages)
+	}
+}
+
+// titleConversationMsg generates a title, returning a ConversationTitledMsg
+func titleConversationMsg(id int64, backend Backend, ollamaHost string, model string, messages []Message) tea.Msg {
+	backend, err := resolveBackend(backend, ollamaHost)
+	if err == nil {
+		var title string
+		title, err = TitleConversation(context.Background(), backend, model, messages)
+		if err == nil {
+			return ConversationTitledMsg{ID: id, Title: title}
+		}
+		err = ClassifyError(err, ollamaHost, model)
+	}
+	logger().Warn("conversation title failed", "id", id, "host", ollamaHost, "model", model, "error", err)
+	return ConversationTitledMsg{ID: id, Error: err}
+}
+
+// TitleConversation performs a blocking generation of a title for the
+// messages with the model on the backend.
+func TitleConversation(ctx context.Context, backend Backend, model string, messages []Message) (string, error) {
+	var transcript strings.Builder
+	for _, message := range messages {
+		if message.Content == "" || (message.Role != "user" && message.Role != "assistant") {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", message.Role, truncateRunes(message.Content, maxTitleMessageLength))
+	}
+	if transcript.Len() == 0 {
+		return "", fmt.Errorf("no messages to title")
+	}
+
+	ctx, span := startSpan(ctx, "ollamatea.title", Attr("ollamatea.model", model))
+	defer span.End()
+	start := time.Now()
+	var sb strings.Builder
+	req := &GenerateRequest{
+		Model:   model,
+		System:  DefaultTitlePrompt,
+		Prompt:  transcript.String(),
+		Options: map[string]interface{}{"temperature": 0.2, "num_predict": 24},
+	}
+	err := backend.Generate(ctx, req, func(resp GenerateResponse) error {
+		sb.WriteString(resp.Response)
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	title := cleanTitle(sb.String())
+	if title == "" {
+		err = fmt.Errorf("model returned an empty title")
+		span.RecordError(err)
+		return "", err
+	}
+	logger().Debug("conversation titled", "model", model, "title", title, "latency", time.Since(start))
+	return title, nil
+}
+
+// cleanTitle trims a generated title to its first line, without quotes,
+// a "Title:" prefix, or trailing punctuation
+func cleanTitle(title string) string {
+	title = strings.TrimSpace(title)
+	title, _, _ = strings.Cut(title, "\n")
+	title = strings.TrimSpace(title)
+	if prefix, rest, ok := strings.Cut(title, ":"); ok && strings.EqualFold(strings.TrimSpace(prefix), "title") {
+		title = strings.TrimSpace(rest)
+	}
+	title = strings.Trim(title, "\"'`*#“”‘’ ")
+	title = strings.TrimRight(title, ".!?,;: ")
+	return truncateRunes(title, maxTitleLength)
+}
+
+// truncateRunes returns s limited to n runes
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n]))
+}
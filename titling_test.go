@@ -0,0 +1,46 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSessionAutoTitle(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	session := ollamateatest.ScriptedSession(srv,
+		ollamateatest.GenerateScript{Chunks: []string{"Paris is the capital."}},
+		ollamateatest.GenerateScript{Chunks: []string{"Title: \"French Capital", " Question.\"\nextra"}},
+	)
+	session.Prompt = "What is the capital of France?"
+	session.AutoTitle = true
+	update := func(msg tea.Msg) tea.Cmd {
+		_, cmd := session.Update(msg)
+		return cmd
+	}
+	cmd := tea.Batch(session.Init(), tea.Sequence(session.StartGenerateMsg))
+	msg := ollamateatest.Run(t, update, cmd, ollamateatest.MatchType[ollamatea.ConversationTitledMsg](), 0)
+	titled := msg.(ollamatea.ConversationTitledMsg)
+	if titled.Error != nil || titled.ID != session.ID() || titled.Title != "French Capital Question" {
+		t.Errorf("unexpected title message %#v", titled)
+	}
+
+	reqs := srv.GenerateRequests()
+	if len(reqs) != 2 || reqs[1].System != ollamatea.DefaultTitlePrompt {
+		t.Fatalf("expected a titling request, got %#v", reqs)
+	}
+}
+
+func TestTitleConversationCmdError(t *testing.T) {
+	msg := ollamatea.TitleConversationCmd(7, "simulate://", "simulated", nil)()
+	titled, ok := msg.(ollamatea.ConversationTitledMsg)
+	if !ok || titled.ID != 7 || titled.Error == nil {
+		t.Errorf("expected an error for an empty conversation, got %#v", msg)
+	}
+}
@@ -0,0 +1,134 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Token counting
+//
+// Context windows are measured in tokens, but tokenizers differ by model and
+// most servers do not expose them.  EstimateTokens approximates a modern BPE
+// tokenizer without a server; CountTokens uses a Backend's tokenizer when it
+// is a Tokenizer, falling back to the estimate otherwise.
+
+// Tokenizer is a Backend that tokenizes text with the model's tokenizer.
+// It is optional; the [LlamaCppBackend] satisfies it.
+type Tokenizer interface {
+	Tokenize(ctx context.Context, model string, text string) ([]int, error)
+}
+
+var _ Tokenizer = (*LlamaCppBackend)(nil)
+
+// messageTokenOverhead approximates the chat template tokens around each message
+const messageTokenOverhead = 4
+
+// smallVocabModels are model families with ~32k-token vocabularies, which
+// split text into roughly 15% more tokens than newer tokenizers
+var smallVocabModels = []string{"llama2", "codellama", "mistral", "mixtral", "vicuna", "orca-mini"}
+
+// EstimateTokens approximates the number of tokens in text for the model.
+// Words count about one token per five letters, numbers one per three digits,
+// and punctuation, symbols, and CJK characters one each.  The model adjusts
+// for tokenizers with smaller vocabularies; it may be empty.
+func EstimateTokens(text string, model string) int {
+	tokens := 0
+	letters, digits := 0, 0
+	flush := func() {
+		tokens += (letters + 4) / 5
+		tokens += (digits + 2) / 3
+		letters, digits = 0, 0
+	}
+	prevNewline := false
+	for _, r := range text {
+		isNewline := r == '\n'
+		switch {
+		case unicode.IsDigit(r):
+			if letters > 0 {
+				flush()
+			}
+			digits++
+		case unicode.In(r, unicode.Latin, unicode.Cyrillic, unicode.Greek):
+			if digits > 0 {
+				flush()
+			}
+			letters++
+		case r == '\'' && letters > 0:
+			letters++ // contractions mostly stay within a word's tokens
+		case unicode.IsSpace(r):
+			flush()
+			if isNewline && !prevNewline {
+				tokens++ // runs of newlines are usually a single token
+			}
+		default:
+			// punctuation, symbols, and ideographic or other scripts
+			flush()
+			tokens++
+		}
+		prevNewline = isNewline
+	}
+	flush()
+
+	model = strings.ToLower(model)
+	for _, family := range smallVocabModels {
+		if strings.Contains(model, family) {
+			return int(math.Ceil(float64(tokens) * 1.15))
+		}
+	}
+	return tokens
+}
+
+// EstimateMessageTokens approximates the number of tokens in the messages for
+// the model, including the chat template around each.
+func EstimateMessageTokens(messages []Message, model string) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += messageTokenOverhead + EstimateTokens(message.Content, model)
+	}
+	return tokens
+}
+
+// CountTokens returns the number of tokens in text for the model.
+// If the backend is a Tokenizer, the count is exact and exact is true;
+// otherwise, or if tokenizing fails, it is the EstimateTokens approximation.
+// The error of a failed tokenization is also returned.
+func CountTokens(ctx context.Context, backend Backend, model string, text string) (count int, exact bool, err error) {
+	if tokenizer, ok := backend.(Tokenizer); ok {
+		tokens, err := tokenizer.Tokenize(ctx, model, text)
+		if err == nil {
+			return len(tokens), true, nil
+		}
+		logger().Debug("tokenize failed, estimating", "model", model, "error", err)
+		return EstimateTokens(text, model), false, err
+	}
+	return EstimateTokens(text, model), false, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// Tokenize returns the tokens of text with the server's model, using /tokenize.
+// A llama.cpp server serves a single model, so model is ignored.
+// Implements the [Tokenizer] interface.
+func (b *LlamaCppBackend) Tokenize(ctx context.Context, model string, text string) ([]int, error) {
+	resp, err := b.do(ctx, http.MethodPost, "/tokenize", map[string]any{"content": text}, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Tokens []int `json:"tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return result.Tokens, nil
+}
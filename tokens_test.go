@@ -0,0 +1,58 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"Hello, world!", 4},
+		{"The year 2024", 4},
+		{"tokenization", 3},
+		{"a\n\n\nb", 3},
+		{"你好世界", 4},
+	}
+	for _, test := range tests {
+		if got := ollamatea.EstimateTokens(test.text, "llama3.2"); got != test.want {
+			t.Errorf("%q: expected %d tokens, got %d", test.text, test.want, got)
+		}
+	}
+
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+	if small, large := ollamatea.EstimateTokens(text, "mistral"), ollamatea.EstimateTokens(text, "llama3.2"); small <= large {
+		t.Errorf("expected more tokens for a small-vocabulary model, got %d <= %d", small, large)
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tokenize" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"tokens": []int{1, 2, 3, 4, 5}})
+	}))
+	defer srv.Close()
+
+	count, exact, err := ollamatea.CountTokens(context.Background(), ollamatea.NewLlamaCppBackend(srv.URL), "", "Hello, world!")
+	if err != nil || !exact || count != 5 {
+		t.Errorf("expected an exact count of 5, got %d %v %v", count, exact, err)
+	}
+	count, exact, err = ollamatea.CountTokens(context.Background(), ollamatea.NewSimulatedBackend(), "", "Hello, world!")
+	if err != nil || exact || count != 4 {
+		t.Errorf("expected an estimate of 4, got %d %v %v", count, exact, err)
+	}
+}
@@ -0,0 +1,74 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Tracing
+//
+// OllamaTea emits spans for generations, embeddings, and model listings
+// through the minimal Tracer interface below, so that it does not depend on
+// a particular tracing library.  Tracing is off until SetTracer is called.
+// An OpenTelemetry TracerProvider is adapted in a few lines; see the README.
+
+// Attribute is a key-value pair annotating a Span or event.
+type Attribute struct {
+	Key   string
+	Value any // string, bool, int, int64, or float64
+}
+
+// Attr returns an Attribute.
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is an in-progress traced operation.
+type Span interface {
+	// AddEvent records an event, such as a streamed chunk, on the Span.
+	AddEvent(name string, attrs ...Attribute)
+	// SetAttributes annotates the Span.
+	SetAttributes(attrs ...Attribute)
+	// RecordError records the error and marks the Span as failed.
+	RecordError(err error)
+	// End completes the Span.
+	End()
+}
+
+// Tracer starts Spans.
+type Tracer interface {
+	// Start starts a Span as a child of any Span in ctx, returning a context holding it.
+	Start(ctx context.Context, spanName string, attrs ...Attribute) (context.Context, Span)
+}
+
+// tracerHolder wraps the Tracer, as atomic.Value requires a consistent concrete type
+type tracerHolder struct {
+	tracer Tracer
+}
+
+var globalTracer atomic.Value // tracerHolder
+
+// SetTracer sets the Tracer used by all OllamaTea components.
+// Pass nil to disable tracing.
+func SetTracer(tracer Tracer) {
+	globalTracer.Store(tracerHolder{tracer: tracer})
+}
+
+// startSpan starts a Span with the global Tracer, or a no-op Span if there is none.
+func startSpan(ctx context.Context, spanName string, attrs ...Attribute) (context.Context, Span) {
+	if holder, ok := globalTracer.Load().(tracerHolder); ok && holder.tracer != nil {
+		return holder.tracer.Start(ctx, spanName, attrs...)
+	}
+	return ctx, noopSpan{}
+}
+
+// noopSpan is the Span used when tracing is disabled
+type noopSpan struct{}
+
+func (noopSpan) AddEvent(string, ...Attribute) {}
+func (noopSpan) SetAttributes(...Attribute)    {}
+func (noopSpan) RecordError(error)             {}
+func (noopSpan) End()                          {}
@@ -3,9 +3,19 @@
 package ollamatea
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	ansitoimage "github.com/pavelpatrin/go-ansi-to-image"
 )
 
@@ -35,6 +45,133 @@ func ConvertTerminalTextToImage(terminalText string, convertConfig *ansitoimage.
 	return pngBytes, nil
 }
 
+// ConvertTheme is the color theme used when rendering terminal text to an image.
+type ConvertTheme string
+
+const (
+	ConvertThemeDark  ConvertTheme = "dark"  // Light text on a black background (default)
+	ConvertThemeLight ConvertTheme = "light" // Dark text on a white background
+)
+
+// ConvertFormat is the image format produced when rendering terminal text.
+type ConvertFormat string
+
+const (
+	ConvertFormatPNG  ConvertFormat = "png" // PNG image (default)
+	ConvertFormatJPEG ConvertFormat = "jpeg"
+	ConvertFormatGIF  ConvertFormat = "gif"
+)
+
+// ConvertOptions holds options for [ConvertTerminalTextToImageWithOptions].
+// Unlike [ConvertTerminalTextToImage], the page is sized to fit the text.
+type ConvertOptions struct {
+	FontSize float64       // FontSize is the font size in points (default: 16)
+	MaxWidth int           // MaxWidth is the maximum page width in columns; wider text is clipped. 0 is unlimited.
+	Theme    ConvertTheme  // Theme is the color theme (default: dark)
+	Format   ConvertFormat // Format is the output image format (default: png)
+}
+
+// DefaultConvertOptions returns the default ConvertOptions.
+func DefaultConvertOptions() ConvertOptions {
+	return ConvertOptions{
+		FontSize: ansitoimage.DefaultConfig.MonoRegularFontPoints,
+		MaxWidth: 0,
+		Theme:    ConvertThemeDark,
+		Format:   ConvertFormatPNG,
+	}
+}
+
+// ConvertTerminalTextToImageWithOptions converts the [terminalText] to an image returned as a []byte.
+// The page is sized to fit the text, and rendered per the passed [ConvertOptions].
+// Returns nil with an error, if any.
+func ConvertTerminalTextToImageWithOptions(terminalText string, opts ConvertOptions) ([]byte, error) {
+	convertConfig := ansitoimage.DefaultConfig
+	if opts.FontSize > 0 {
+		scale := opts.FontSize / ansitoimage.DefaultConfig.MonoRegularFontPoints
+		scaleInt := func(i int) int { return int(math.Round(float64(i) * scale)) }
+		convertConfig.CharWidth = scaleInt(convertConfig.CharWidth)
+		convertConfig.LineHeight = scaleInt(convertConfig.LineHeight)
+		convertConfig.LineShift = scaleInt(convertConfig.LineShift)
+		convertConfig.Padding = scaleInt(convertConfig.Padding)
+		convertConfig.MonoRegularFontPoints = opts.FontSize
+		convertConfig.MonoBoldFontPoints = opts.FontSize
+		convertConfig.MonoObliqueFontPoints = opts.FontSize
+		convertConfig.MonoObliqueBoldFontPoints = opts.FontSize
+	}
+
+	// Size the page to fit the text
+	lines := strings.Split(strings.TrimRight(terminalText, "\n"), "\n")
+	cols := 1
+	for _, line := range lines {
+		cols = max(cols, lipgloss.Width(line))
+	}
+	if opts.MaxWidth > 0 {
+		cols = min(cols, opts.MaxWidth)
+	}
+	convertConfig.PageCols = cols
+	convertConfig.PageRows = max(len(lines), 1)
+
+	pngBytes, err := ConvertTerminalTextToImage(terminalText, &convertConfig)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Theme == ConvertThemeDark || opts.Theme == "" {
+		if opts.Format == ConvertFormatPNG || opts.Format == "" {
+			return pngBytes, nil
+		}
+	}
+
+	img, err := png.Decode(bytes.NewReader(pngBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PNG %w", err)
+	}
+
+	switch opts.Theme {
+	case ConvertThemeDark, "":
+	case ConvertThemeLight:
+		img = invertLightness(img)
+	default:
+		return nil, fmt.Errorf("unknown theme '%s'", opts.Theme)
+	}
+
+	var buf bytes.Buffer
+	switch opts.Format {
+	case ConvertFormatPNG, "":
+		err = png.Encode(&buf, img)
+	case ConvertFormatJPEG:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+	case ConvertFormatGIF:
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", opts.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s %w", opts.Format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// invertLightness inverts the lightness of each pixel while preserving its hue and chroma.
+// Black becomes white and light grey becomes dark grey, while saturated colors stay recognizable.
+func invertLightness(src image.Image) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := dst.RGBAAt(x, y)
+			shift := 255 - int(max(c.R, c.G, c.B)) - int(min(c.R, c.G, c.B))
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(int(c.R) + shift),
+				G: uint8(int(c.G) + shift),
+				B: uint8(int(c.B) + shift),
+				A: c.A,
+			})
+		}
+	}
+	return dst
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 
 // Cmdize is a utility function to convert a given value into a `tea.Cmd`
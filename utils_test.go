@@ -1,15 +1,21 @@
 // Ollama Tea Copyright (c) 2024 Neomantra Corp
 //
 // ./bin/ot-ansi-to-png --in tests/hello.txt --out tests/hello.png
+// or: OLLAMATEA_UPDATE_GOLDEN=1 go test -run TestConvertTerminalTextToImage$
 //
 
-package ollamatea
+package ollamatea_test
 
 import (
+	"bytes"
+	"image"
 	"os"
 	"path"
 	"testing"
 
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+	ansitoimage "github.com/pavelpatrin/go-ansi-to-image"
 	"github.com/stretchr/testify/require"
 )
 
@@ -20,11 +26,39 @@ func TestConvertTerminalTextToImage(t *testing.T) {
 	terminalText, err := os.ReadFile(path.Join("tests", "hello.txt"))
 	assert.NoError(err, "ReadFile TXT should return no error")
 
-	pngBytes, err := os.ReadFile(path.Join("tests", "hello.png"))
-	assert.NoError(err, "ReadFile PNG should return no error")
-
-	convertedBytes, err := ConvertTerminalTextToImage(string(terminalText), nil)
+	convertedBytes, err := ollamatea.ConvertTerminalTextToImage(string(terminalText), nil)
 	assert.NoError(err, "ConvertTerminalTextToImage should return no error")
 
-	assert.Equal(pngBytes, convertedBytes)
+	// fonts rasterize slightly differently across platforms, so compare within tolerance
+	ollamateatest.AssertGoldenImage(t, path.Join("tests", "hello.png"), convertedBytes, ollamateatest.DefaultImageDiffOptions())
+}
+
+// TestConvertTerminalTextToImageWithOptions tests the sized, themed, and formatted conversion.
+func TestConvertTerminalTextToImageWithOptions(t *testing.T) {
+	assert := require.New(t)
+
+	terminalText, err := os.ReadFile(path.Join("tests", "hello.txt"))
+	assert.NoError(err, "ReadFile TXT should return no error")
+
+	opts := ollamatea.DefaultConvertOptions()
+	pngBytes, err := ollamatea.ConvertTerminalTextToImageWithOptions(string(terminalText), opts)
+	assert.NoError(err, "default options should return no error")
+	img, format, err := image.Decode(bytes.NewReader(pngBytes))
+	assert.NoError(err, "default output should decode")
+	assert.Equal("png", format)
+	assert.Less(img.Bounds().Dx(), 80*ansitoimage.DefaultConfig.CharWidth, "page should fit the text")
+
+	opts.Theme = ollamatea.ConvertThemeLight
+	opts.Format = ollamatea.ConvertFormatJPEG
+	jpegBytes, err := ollamatea.ConvertTerminalTextToImageWithOptions(string(terminalText), opts)
+	assert.NoError(err, "light JPEG should return no error")
+	img, format, err = image.Decode(bytes.NewReader(jpegBytes))
+	assert.NoError(err, "light JPEG should decode")
+	assert.Equal("jpeg", format)
+	r, g, b, _ := img.At(0, 0).RGBA()
+	assert.Greater(r+g+b, uint32(3*0xf000), "light theme background should be white")
+
+	opts.Format = "bmp"
+	_, err = ollamatea.ConvertTerminalTextToImageWithOptions(string(terminalText), opts)
+	assert.Error(err, "unknown format should return an error")
 }
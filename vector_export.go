@@ -0,0 +1,158 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Embedding export
+//
+// Embeddings are exported for other tools as VectorRecords, from a VectorStore
+// or an EmbedSession's Records:
+//
+//   - JSONL: one VectorRecord JSON object per line, for DuckDB's read_json
+//     and most vector database loaders.
+//   - CSV: id, text, and metadata (as a JSON object) columns, then one
+//     column per dimension, for DuckDB's read_csv, pandas, and spreadsheets.
+//   - Float32: the embeddings alone, as consecutive little-endian float32s in
+//     record order, for numpy.fromfile(path, "<f4").reshape(-1, dimensions).
+//     Export JSONL or CSV alongside for their IDs and metadata.
+//   - Parquet: id, text, metadata, and embedding (a list of float) columns,
+//     for DuckDB, pandas, and Spark.  It is written without a Parquet
+//     library, to keep this module free of its dependencies; see
+//     vector_parquet.go.
+
+// ExportFormat is a file format for exporting embeddings.
+type ExportFormat string
+
+const (
+	ExportJSONL   ExportFormat = "jsonl"   // ExportJSONL writes a VectorRecord JSON object per line
+	ExportCSV     ExportFormat = "csv"     // ExportCSV writes a CSV row per VectorRecord, with a header
+	ExportFloat32 ExportFormat = "float32" // ExportFloat32 writes the embeddings as little-endian float32s
+	ExportParquet ExportFormat = "parquet" // ExportParquet writes an uncompressed Parquet file
+)
+
+// ExportFormats lists the supported ExportFormats.
+var ExportFormats = []ExportFormat{ExportJSONL, ExportCSV, ExportFloat32, ExportParquet}
+
+// ParseExportFormat returns the ExportFormat named s, or an error if unsupported.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch format := ExportFormat(s); format {
+	case ExportJSONL, ExportCSV, ExportFloat32, ExportParquet:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown export format %q", s)
+}
+
+// WriteVectors writes the records to w in the format.
+func WriteVectors(w io.Writer, format ExportFormat, records []VectorRecord) error {
+	switch format {
+	case ExportJSONL:
+		return WriteVectorsJSONL(w, records)
+	case ExportCSV:
+		return WriteVectorsCSV(w, records)
+	case ExportFloat32:
+		return WriteVectorsFloat32(w, records)
+	case ExportParquet:
+		return WriteVectorsParquet(w, records)
+	}
+	_, err := ParseExportFormat(string(format))
+	return err
+}
+
+// WriteVectorsJSONL writes the records to w as JSON, one per line.
+func WriteVectorsJSONL(w io.Writer, records []VectorRecord) error {
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVectorsCSV writes the records to w as CSV, with a header row of id,
+// text, metadata, and dim_0 through dim_N-1.  Metadata is a JSON object.
+// Returns an error if the records' dimensions differ.
+func WriteVectorsCSV(w io.Writer, records []VectorRecord) error {
+	dimensions, err := exportDimensions(records)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	row := make([]string, 3+dimensions)
+	row[0], row[1], row[2] = "id", "text", "metadata"
+	for i := 0; i < dimensions; i++ {
+		row[3+i] = "dim_" + strconv.Itoa(i)
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for _, record := range records {
+		metadata := ""
+		if len(record.Metadata) > 0 {
+			b, err := json.Marshal(record.Metadata)
+			if err != nil {
+				return err
+			}
+			metadata = string(b)
+		}
+		row[0], row[1], row[2] = record.ID, record.Text, metadata
+		for i, x := range record.Embedding {
+			row[3+i] = strconv.FormatFloat(float64(x), 'g', -1, 32)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteVectorsFloat32 writes the records' embeddings to w as consecutive
+// little-endian float32s, without a header.  Returns an error if the records'
+// dimensions differ.
+func WriteVectorsFloat32(w io.Writer, records []VectorRecord) error {
+	if _, err := exportDimensions(records); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	var buf [4]byte
+	for _, record := range records {
+		for _, x := range record.Embedding {
+			binary.LittleEndian.PutUint32(buf[:], math.Float32bits(x))
+			if _, err := bw.Write(buf[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// Export writes the VectorStore's records to w in the format.
+func (s *VectorStore) Export(w io.Writer, format ExportFormat) error {
+	return WriteVectors(w, format, s.Records())
+}
+
+// exportDimensions returns the dimensions of the records' embeddings, or an error if they differ
+func exportDimensions(records []VectorRecord) (int, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+	dimensions := len(records[0].Embedding)
+	for i, record := range records {
+		if len(record.Embedding) != dimensions {
+			return 0, fmt.Errorf("vector %d has %d dimensions, expected %d", i, len(record.Embedding), dimensions)
+		}
+	}
+	return dimensions, nil
+}
@@ -0,0 +1,98 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func TestWriteVectors(t *testing.T) {
+	records := []ollamatea.VectorRecord{
+		{ID: "a", Text: "apple, red", Metadata: map[string]string{"kind": "fruit"}, Embedding: []float32{1, 0.5}},
+		{ID: "b", Text: "sky", Embedding: []float32{-0.25, 2}},
+	}
+
+	var buf bytes.Buffer
+	if err := ollamatea.WriteVectors(&buf, ollamatea.ExportJSONL, records); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":"a","text":"apple, red","metadata":{"kind":"fruit"},"embedding":[1,0.5]}
+{"id":"b","text":"sky","embedding":[-0.25,2]}
+`
+	if buf.String() != want {
+		t.Errorf("unexpected JSONL:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := ollamatea.WriteVectors(&buf, ollamatea.ExportCSV, records); err != nil {
+		t.Fatal(err)
+	}
+	want = `id,text,metadata,dim_0,dim_1
+a,"apple, red","{""kind"":""fruit""}",1,0.5
+b,sky,,-0.25,2
+`
+	if buf.String() != want {
+		t.Errorf("unexpected CSV:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := ollamatea.WriteVectors(&buf, ollamatea.ExportFloat32, records); err != nil {
+		t.Fatal(err)
+	}
+	floats := make([]float32, buf.Len()/4)
+	binary.Read(&buf, binary.LittleEndian, floats)
+	if !slices.Equal(floats, []float32{1, 0.5, -0.25, 2}) {
+		t.Errorf("unexpected float32s %v", floats)
+	}
+
+	if err := ollamatea.WriteVectors(&buf, ollamatea.ExportCSV, append(records, ollamatea.VectorRecord{Embedding: []float32{1}})); err == nil {
+		t.Error("expected an error for records of other dimensions")
+	}
+	if format, err := ollamatea.ParseExportFormat("parquet"); err != nil || format != ollamatea.ExportParquet {
+		t.Errorf("unexpected parquet format %q, %v", format, err)
+	}
+	if _, err := ollamatea.ParseExportFormat("arrow"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestWriteVectorsParquet(t *testing.T) {
+	records := []ollamatea.VectorRecord{
+		{ID: "a", Text: "apple", Metadata: map[string]string{"kind": "fruit"}, Embedding: []float32{1, 0.5}},
+		{ID: "b", Text: "sky", Embedding: []float32{-0.25, 2}},
+	}
+	var buf bytes.Buffer
+	if err := ollamatea.WriteVectors(&buf, ollamatea.ExportParquet, records); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if len(b) < 12 || string(b[:4]) != "PAR1" || string(b[len(b)-4:]) != "PAR1" {
+		t.Fatalf("expected PAR1 magic at both ends, got %q", b)
+	}
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	if footerLen <= 0 || footerLen > len(b)-12 {
+		t.Fatalf("unexpected footer length %d of %d bytes", footerLen, len(b))
+	}
+	footer := string(b[len(b)-8-footerLen : len(b)-8])
+	for _, name := range []string{"id", "text", "metadata", "embedding", "element"} {
+		if !strings.Contains(footer, name) {
+			t.Errorf("expected column %q in the footer", name)
+		}
+	}
+
+	// the embedding column's PLAIN values are its floats, in record order
+	var floats bytes.Buffer
+	binary.Write(&floats, binary.LittleEndian, []float32{1, 0.5, -0.25, 2})
+	if !bytes.Contains(b, floats.Bytes()) {
+		t.Error("expected the embeddings as consecutive float32s")
+	}
+	if !bytes.Contains(b, []byte(`{"kind":"fruit"}`)) {
+		t.Error("expected the metadata as JSON")
+	}
+}
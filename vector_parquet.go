@@ -0,0 +1,366 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Parquet export
+//
+// WriteVectorsParquet writes a minimal Parquet file, without the dependencies
+// of a Parquet library: a data page of uncompressed, PLAIN-encoded values per
+// column, in row groups of parquetRowGroupSize records.  Its metadata is
+// encoded with Thrift's compact protocol by thriftWriter.  DuckDB reads it as
+//
+//	SELECT id, embedding::FLOAT[768] FROM 'vectors.parquet';
+//
+// See https://parquet.apache.org/docs/file-format/
+
+// parquetRowGroupSize is the number of records in each row group
+const parquetRowGroupSize = 10000
+
+// parquetMagic begins and ends a Parquet file
+const parquetMagic = "PAR1"
+
+// Parquet physical types, encodings, and other enums of its Thrift definitions
+const (
+	parquetFloat     = 4 // Type FLOAT
+	parquetByteArray = 6 // Type BYTE_ARRAY
+
+	parquetRequired = 0 // FieldRepetitionType REQUIRED
+	parquetOptional = 1 // FieldRepetitionType OPTIONAL
+	parquetRepeated = 2 // FieldRepetitionType REPEATED
+
+	parquetUTF8 = 0 // ConvertedType UTF8
+	parquetList = 3 // ConvertedType LIST
+
+	parquetPlain = 0 // Encoding PLAIN
+	parquetRLE   = 3 // Encoding RLE
+
+	parquetDataPage = 0 // PageType DATA_PAGE
+)
+
+// parquetColumn is a column chunk of a row group being written
+type parquetColumn struct {
+	path      []string // path in the schema
+	typ       int32    // physical type
+	maxRep    int      // maximum repetition level; 0 writes no repetition levels
+	maxDef    int      // maximum definition level; 0 writes no definition levels
+	reps      []byte   // repetition level of each value
+	defs      []byte   // definition level of each value
+	values    bytes.Buffer
+	numValues int // count of levels, including nulls and empty lists
+}
+
+// appendString appends a PLAIN BYTE_ARRAY value
+func (c *parquetColumn) appendString(s string) {
+	binary.Write(&c.values, binary.LittleEndian, uint32(len(s)))
+	c.values.WriteString(s)
+}
+
+// appendFloat appends a PLAIN FLOAT value
+func (c *parquetColumn) appendFloat(x float32) {
+	binary.Write(&c.values, binary.LittleEndian, math.Float32bits(x))
+}
+
+// page returns the column's data page: its levels, then its values
+func (c *parquetColumn) page() []byte {
+	var page []byte
+	if c.maxRep > 0 {
+		page = appendParquetLevels(page, c.reps)
+	}
+	if c.maxDef > 0 {
+		page = appendParquetLevels(page, c.defs)
+	}
+	return append(page, c.values.Bytes()...)
+}
+
+// appendParquetLevels appends levels of bit width 1, RLE-encoded after their length
+func appendParquetLevels(b []byte, levels []byte) []byte {
+	var runs []byte
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		runs = binary.AppendUvarint(runs, uint64(j-i)<<1) // an RLE run of j-i levels
+		runs = append(runs, levels[i])
+		i = j
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(runs)))
+	return append(b, runs...)
+}
+
+// parquetColumns returns the column chunks of the records
+func parquetColumns(records []VectorRecord) ([]*parquetColumn, error) {
+	id := &parquetColumn{path: []string{"id"}, typ: parquetByteArray}
+	text := &parquetColumn{path: []string{"text"}, typ: parquetByteArray}
+	metadata := &parquetColumn{path: []string{"metadata"}, typ: parquetByteArray, maxDef: 1}
+	embedding := &parquetColumn{path: []string{"embedding", "list", "element"}, typ: parquetFloat, maxRep: 1, maxDef: 1}
+	for _, record := range records {
+		id.appendString(record.ID)
+		text.appendString(record.Text)
+		if len(record.Metadata) > 0 {
+			b, err := json.Marshal(record.Metadata)
+			if err != nil {
+				return nil, err
+			}
+			metadata.defs = append(metadata.defs, 1)
+			metadata.appendString(string(b))
+		} else {
+			metadata.defs = append(metadata.defs, 0) // null
+		}
+		if len(record.Embedding) == 0 {
+			embedding.reps = append(embedding.reps, 0)
+			embedding.defs = append(embedding.defs, 0) // an empty list
+			embedding.numValues++
+		}
+		for i, x := range record.Embedding {
+			embedding.reps = append(embedding.reps, min(byte(i), 1)) // 0 begins a record's list
+			embedding.defs = append(embedding.defs, 1)
+			embedding.appendFloat(x)
+			embedding.numValues++
+		}
+	}
+	id.numValues, text.numValues, metadata.numValues = len(records), len(records), len(records)
+	return []*parquetColumn{id, text, metadata, embedding}, nil
+}
+
+// WriteVectorsParquet writes the records to w as a Parquet file, with id,
+// text, metadata, and embedding columns.  Metadata is a JSON object string, or
+// null if a record has none; embedding is a list of float.
+func WriteVectorsParquet(w io.Writer, records []VectorRecord) error {
+	out := &countingWriter{w: bufio.NewWriter(w)}
+	out.Write([]byte(parquetMagic))
+
+	footer := &thriftWriter{}
+	footer.begin() // FileMetaData
+	footer.i32(1, 1)
+	writeParquetSchema(footer)
+	footer.i64(3, int64(len(records)))
+	groups := (len(records) + parquetRowGroupSize - 1) / parquetRowGroupSize
+	footer.list(4, thriftStruct, groups)
+	for start := 0; start < len(records); start += parquetRowGroupSize {
+		group := records[start:min(start+parquetRowGroupSize, len(records))]
+		columns, err := parquetColumns(group)
+		if err != nil {
+			return err
+		}
+		footer.begin() // RowGroup
+		footer.list(1, thriftStruct, len(columns))
+		var groupSize int64
+		for _, column := range columns {
+			offset := out.n
+			page := column.page()
+			header := &thriftWriter{}
+			header.begin() // PageHeader
+			header.i32(1, parquetDataPage)
+			header.i32(2, int32(len(page)))
+			header.i32(3, int32(len(page)))
+			header.structField(5) // DataPageHeader
+			header.i32(1, int32(column.numValues))
+			header.i32(2, parquetPlain)
+			header.i32(3, parquetRLE)
+			header.i32(4, parquetRLE)
+			header.end()
+			header.end()
+			out.Write(header.buf.Bytes())
+			out.Write(page)
+			size := out.n - offset
+			groupSize += size
+
+			footer.begin() // ColumnChunk
+			footer.i64(2, offset)
+			footer.structField(3) // ColumnMetaData
+			footer.i32(1, column.typ)
+			footer.list(2, thriftI32, 2)
+			footer.rawI32(parquetPlain)
+			footer.rawI32(parquetRLE)
+			footer.list(3, thriftBinary, len(column.path))
+			for _, name := range column.path {
+				footer.rawString(name)
+			}
+			footer.i32(4, 0) // UNCOMPRESSED
+			footer.i64(5, int64(column.numValues))
+			footer.i64(6, size)
+			footer.i64(7, size)
+			footer.i64(9, offset)
+			footer.end()
+			footer.end()
+		}
+		footer.i64(2, groupSize)
+		footer.i64(3, int64(len(group)))
+		footer.end()
+	}
+	footer.binary(6, "ollamatea")
+	footer.end()
+
+	out.Write(footer.buf.Bytes())
+	binary.Write(out, binary.LittleEndian, uint32(footer.buf.Len()))
+	out.Write([]byte(parquetMagic))
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.(*bufio.Writer).Flush()
+}
+
+// writeParquetSchema writes the FileMetaData's schema of WriteVectorsParquet
+func writeParquetSchema(t *thriftWriter) {
+	t.list(2, thriftStruct, 7)
+	t.begin() // the root
+	t.binary(4, "schema")
+	t.i32(5, 4)
+	t.end()
+	for _, name := range []string{"id", "text", "metadata"} {
+		t.begin()
+		t.i32(1, parquetByteArray)
+		if name == "metadata" {
+			t.i32(3, parquetOptional)
+		} else {
+			t.i32(3, parquetRequired)
+		}
+		t.binary(4, name)
+		t.i32(6, parquetUTF8)
+		t.structField(10) // LogicalType
+		t.structField(1)  // STRING
+		t.end()
+		t.end()
+		t.end()
+	}
+	t.begin() // a LIST group, its repeated group, and its element
+	t.i32(3, parquetRequired)
+	t.binary(4, "embedding")
+	t.i32(5, 1)
+	t.i32(6, parquetList)
+	t.structField(10) // LogicalType
+	t.structField(3)  // LIST
+	t.end()
+	t.end()
+	t.end()
+	t.begin()
+	t.i32(3, parquetRepeated)
+	t.binary(4, "list")
+	t.i32(5, 1)
+	t.end()
+	t.begin()
+	t.i32(1, parquetFloat)
+	t.i32(3, parquetRequired)
+	t.binary(4, "element")
+	t.end()
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// countingWriter counts the bytes written to w, keeping the first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// Write writes p unless an earlier Write failed.
+// Implements the io.Writer interface.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in Thrift's compact protocol.
+// Each begin or structField must be matched by an end.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // ID of the last field of each open struct
+}
+
+// begin starts a struct, such as an element of a list
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+// end ends the innermost struct
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0) // stop
+	t.last = t.last[:len(t.last)-1]
+}
+
+// field writes the header of the field id of the type
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(uint64(int64(id)<<1 ^ int64(id)>>63))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+// i32 writes an i32 field, which is also the encoding of enums
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.rawI32(v)
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(uint64(v<<1 ^ v>>63))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.rawString(s)
+}
+
+// structField starts a struct field, to be ended by end
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// list writes the header of a list field of n elements of the type, which
+// follow as raw values, or as structs between begin and end
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.varint(uint64(n))
+	}
+}
+
+// rawI32 writes an i32 value without a field header, such as a list element
+func (t *thriftWriter) rawI32(v int32) {
+	t.varint(uint64(uint32(v<<1 ^ v>>31)))
+}
+
+// rawString writes a binary value without a field header, such as a list element
+func (t *thriftWriter) rawString(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
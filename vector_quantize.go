@@ -0,0 +1,92 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Embedding quantization
+//
+// Embeddings are float32, four bytes per dimension.  QuantizeInt8 scales each
+// vector so its largest magnitude is 127 and rounds it to int8, one byte per
+// dimension, keeping the scale to restore it with Dequantize.  The rounding
+// error of each value is at most half the Scale, which barely moves cosine
+// similarities; set VectorStore.Quantize to persist a store this way.
+
+// QuantizationInt8 names int8 quantization in a saved VectorStore
+const QuantizationInt8 = "int8"
+
+// QuantizedVector is a vector quantized by QuantizeInt8.  Each value of the
+// vector is approximately its Value times its Scale.  As JSON, its Values are
+// base64-encoded bytes, about a third of the size of float32 numbers.
+type QuantizedVector struct {
+	Scale  float32 // Scale of the Values; 0 for a zero vector
+	Values []int8  // Values of the vector, divided by the Scale and rounded
+}
+
+// quantizedVectorJSON is the JSON form of a QuantizedVector
+type quantizedVectorJSON struct {
+	Scale  float32 `json:"scale"`
+	Values string  `json:"values"`
+}
+
+// QuantizeInt8 returns the vector v quantized to int8 with a per-vector scale.
+func QuantizeInt8[T Float](v []T) QuantizedVector {
+	var largest float64
+	for _, x := range v {
+		largest = max(largest, math.Abs(float64(x)))
+	}
+	q := QuantizedVector{Values: make([]int8, len(v))}
+	if largest == 0 || math.IsNaN(largest) || math.IsInf(largest, 0) {
+		return q
+	}
+	q.Scale = float32(largest / math.MaxInt8)
+	for i, x := range v {
+		value := math.Round(float64(x) / float64(q.Scale))
+		q.Values[i] = int8(max(min(value, math.MaxInt8), -math.MaxInt8))
+	}
+	return q
+}
+
+// Dequantize returns the float32 vector approximated by the QuantizedVector.
+func (q QuantizedVector) Dequantize() []float32 {
+	v := make([]float32, len(q.Values))
+	for i, value := range q.Values {
+		v[i] = float32(value) * q.Scale
+	}
+	return v
+}
+
+// MarshalJSON encodes the QuantizedVector as its scale and base64 values.
+// Implements the json.Marshaler interface.
+func (q QuantizedVector) MarshalJSON() ([]byte, error) {
+	values := make([]byte, len(q.Values))
+	for i, value := range q.Values {
+		values[i] = byte(value)
+	}
+	return json.Marshal(quantizedVectorJSON{Scale: q.Scale, Values: base64.StdEncoding.EncodeToString(values)})
+}
+
+// UnmarshalJSON decodes a QuantizedVector encoded by MarshalJSON.
+// Implements the json.Unmarshaler interface.
+func (q *QuantizedVector) UnmarshalJSON(data []byte) error {
+	var encoded quantizedVectorJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	values, err := base64.StdEncoding.DecodeString(encoded.Values)
+	if err != nil {
+		return fmt.Errorf("invalid quantized values: %w", err)
+	}
+	q.Scale = encoded.Scale
+	q.Values = make([]int8, len(values))
+	for i, value := range values {
+		q.Values[i] = int8(value)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestQuantizeInt8(t *testing.T) {
+	q := ollamatea.QuantizeInt8([]float32{0.6, -1, 0.25, 0})
+	if q.Scale != float32(1.0/127) || !slices.Equal(q.Values, []int8{76, -127, 32, 0}) {
+		t.Errorf("unexpected %+v", q)
+	}
+	if zero := ollamatea.QuantizeInt8([]float64{0, 0}); zero.Scale != 0 || !slices.Equal(zero.Dequantize(), []float32{0, 0}) {
+		t.Errorf("unexpected zero vector %+v", zero)
+	}
+
+	for i := 0; i < 20; i++ {
+		v := ollamateatest.FakeEmbedding(fmt.Sprintf("text %d", i), 768)
+		q := ollamatea.QuantizeInt8(v)
+		restored := q.Dequantize()
+		for j := range v {
+			if diff := math.Abs(float64(v[j] - restored[j])); diff > float64(q.Scale)/2+1e-7 {
+				t.Fatalf("value %d off by %v, more than half the scale %v", j, diff, q.Scale)
+			}
+		}
+		if similarity := ollamatea.CosineSimilarity(v, restored); similarity < 0.9999 {
+			t.Errorf("expected the direction preserved, got similarity %v", similarity)
+		}
+	}
+}
+
+func TestVectorStoreQuantize(t *testing.T) {
+	store := ollamatea.NewVectorStore()
+	for i := 0; i < 10; i++ {
+		text := fmt.Sprintf("text %d", i)
+		store.Add(ollamatea.VectorRecord{ID: text, Text: text, Embedding: ollamateatest.FakeEmbedding(text, 384)})
+	}
+	var floats, quantized bytes.Buffer
+	if err := store.Save(&floats); err != nil {
+		t.Fatal(err)
+	}
+	store.Quantize = true
+	if err := store.Save(&quantized); err != nil {
+		t.Fatal(err)
+	}
+	if quantized.Len()*3 > floats.Len() {
+		t.Errorf("expected the quantized store under a third the size, got %d of %d bytes", quantized.Len(), floats.Len())
+	}
+
+	loaded := ollamatea.NewVectorStore()
+	if err := loaded.Load(&quantized); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !loaded.Quantize || loaded.Len() != store.Len() || loaded.Dimensions() != 384 {
+		t.Fatalf("unexpected store of %d records", loaded.Len())
+	}
+	for i, record := range loaded.Records() {
+		original := store.Records()[i]
+		if record.ID != original.ID || record.Text != original.Text {
+			t.Errorf("unexpected record %+v", record)
+		}
+		if similarity := ollamatea.CosineSimilarity(record.Embedding, original.Embedding); similarity < 0.9999 {
+			t.Errorf("expected %s restored, got similarity %v", record.ID, similarity)
+		}
+	}
+	results, err := loaded.TopK(ollamateatest.FakeEmbedding("text 3", 384), 1)
+	if err != nil || len(results) != 1 || results[0].ID != "text 3" {
+		t.Errorf("unexpected search results %+v, error %v", results, err)
+	}
+}
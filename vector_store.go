@@ -0,0 +1,388 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Vector storage
+//
+// A VectorStore keeps embeddings in memory with their source text and
+// metadata, for retrieval.  It is filled from an EmbedSession's output with
+// AddEmbedSession, searched for the records most similar to a query with TopK
+// and Search, and persisted as JSON with Save and Load, or SaveFile and
+// LoadVectorStore, optionally quantized to int8.  Other databases, such as SQLite, can be filled from its
+// Records without adding their drivers to this module.
+
+// ErrVectorNotFound is returned when deleting an unknown VectorRecord.
+var ErrVectorNotFound = errors.New("vector not found")
+
+// Metadata keys set by AddEmbedSession on the records of chunks
+const (
+	MetadataChunkStart = "chunk_start" // byte offset of the chunk in its source
+	MetadataChunkEnd   = "chunk_end"   // byte offset following the chunk in its source
+)
+
+// VectorRecord is a stored embedding with its source text and metadata.
+type VectorRecord struct {
+	ID        string            `json:"id"`                 // ID is assigned by Add, if empty
+	Text      string            `json:"text,omitempty"`     // Text that was embedded, if kept
+	Metadata  map[string]string `json:"metadata,omitempty"` // Metadata, such as a file name, for filtering and display
+	Embedding []float32         `json:"embedding"`          // Embedding of the Text
+}
+
+// VectorStore is an in-memory store of VectorRecords, all of the same dimensions.
+// It is safe for concurrent use.
+type VectorStore struct {
+	// Quantize, if true, saves embeddings quantized to int8 by QuantizeInt8,
+	// shrinking large stores.  Embeddings are float32 in memory regardless.
+	// Load sets it if the store read was quantized.
+	Quantize bool
+
+	mu      sync.RWMutex
+	records []VectorRecord
+	index   map[string]int // record index by ID
+}
+
+// vectorStoreFile is the JSON form of a VectorStore
+type vectorStoreFile struct {
+	Dimensions   int               `json:"dimensions"`
+	Quantization string            `json:"quantization,omitempty"`
+	Records      []VectorRecord    `json:"records,omitempty"`
+	Quantized    []quantizedRecord `json:"quantized_records,omitempty"`
+}
+
+// quantizedRecord is the JSON form of a VectorRecord of a quantized VectorStore
+type quantizedRecord struct {
+	ID        string            `json:"id"`
+	Text      string            `json:"text,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Embedding QuantizedVector   `json:"embedding"`
+}
+
+// NewVectorStore returns a new, empty VectorStore.
+func NewVectorStore() *VectorStore {
+	return &VectorStore{index: make(map[string]int)}
+}
+
+// Len returns the number of records in the VectorStore
+func (s *VectorStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// Dimensions returns the length of the VectorStore's embeddings, or 0 if it is empty
+func (s *VectorStore) Dimensions() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.records) == 0 {
+		return 0
+	}
+	return len(s.records[0].Embedding)
+}
+
+// Add stores the records, assigning IDs to those without, and replacing any
+// with the same ID.  Returns an error, storing none, if any embedding is
+// empty or differs in dimensions from the others.
+func (s *VectorStore) Add(records ...VectorRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dimensions := 0
+	if len(s.records) > 0 {
+		dimensions = len(s.records[0].Embedding)
+	}
+	for i, record := range records {
+		if len(record.Embedding) == 0 {
+			return fmt.Errorf("vector %d has no embedding", i)
+		}
+		if dimensions == 0 {
+			dimensions = len(record.Embedding)
+		} else if len(record.Embedding) != dimensions {
+			return fmt.Errorf("vector %d has %d dimensions, expected %d", i, len(record.Embedding), dimensions)
+		}
+	}
+	for _, record := range records {
+		if record.ID == "" {
+			record.ID = newTimeOrderedID()
+		}
+		record.Metadata = maps.Clone(record.Metadata)
+		record.Embedding = slices.Clone(record.Embedding)
+		if i, ok := s.index[record.ID]; ok {
+			s.records[i] = record
+			continue
+		}
+		s.index[record.ID] = len(s.records)
+		s.records = append(s.records, record)
+	}
+	return nil
+}
+
+// Get returns the record with the ID, and whether it was found
+func (s *VectorStore) Get(id string) (VectorRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.index[id]
+	if !ok {
+		return VectorRecord{}, false
+	}
+	return s.records[i], true
+}
+
+// Delete removes the record with the ID, or returns ErrVectorNotFound.
+func (s *VectorStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.index[id]
+	if !ok {
+		return ErrVectorNotFound
+	}
+	s.records = slices.Delete(s.records, i, i+1)
+	delete(s.index, id)
+	for j := i; j < len(s.records); j++ {
+		s.index[s.records[j].ID] = j
+	}
+	return nil
+}
+
+// Records returns the records, in the order they were added.
+// The records share their Embeddings with the store, which must not be modified.
+func (s *VectorStore) Records() []VectorRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.records)
+}
+
+// AddEmbedSession stores the embeddings of the EmbedSession's last Response,
+// each with its input text and a copy of the metadata, as by [EmbedSession.Records].
+// Returns the IDs of the new records.
+func (s *VectorStore) AddEmbedSession(session *EmbedSession, metadata map[string]string) ([]string, error) {
+	records, err := session.Records(metadata)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(records))
+	for i := range records {
+		ids[i] = records[i].ID
+	}
+	return ids, s.Add(records...)
+}
+
+// Records returns the embeddings of the EmbedSession's last Response as
+// VectorRecords with new IDs, each with its input text and a copy of the
+// metadata.  The chunks of a chunked Input have their offsets, as
+// MetadataChunkStart and MetadataChunkEnd.
+func (s *EmbedSession) Records(metadata map[string]string) ([]VectorRecord, error) {
+	resp := s.Response()
+	if resp == nil {
+		return nil, errors.New("embed session has no response")
+	}
+	var records []VectorRecord
+	if chunks := s.ChunkEmbeddings(); chunks != nil {
+		for _, chunk := range chunks {
+			md := maps.Clone(metadata)
+			if md == nil {
+				md = make(map[string]string)
+			}
+			md[MetadataChunkStart] = strconv.Itoa(chunk.Start)
+			md[MetadataChunkEnd] = strconv.Itoa(chunk.End)
+			records = append(records, VectorRecord{Text: chunk.Text, Metadata: md, Embedding: chunk.Embedding})
+		}
+	} else {
+		var texts []string
+		switch input := s.Input.(type) {
+		case string:
+			texts = []string{input}
+		case []string:
+			texts = input
+		}
+		if len(texts) != len(resp.Embeddings) {
+			return nil, fmt.Errorf("embed session has %d embeddings of %d inputs", len(resp.Embeddings), len(texts))
+		}
+		for i, text := range texts {
+			records = append(records, VectorRecord{Text: text, Metadata: maps.Clone(metadata), Embedding: resp.Embeddings[i]})
+		}
+	}
+	for i := range records {
+		records[i].ID = newTimeOrderedID()
+	}
+	return records, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// Save writes the VectorStore to w as JSON, with its embeddings quantized if Quantize is set
+func (s *VectorStore) Save(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var file vectorStoreFile
+	if len(s.records) > 0 {
+		file.Dimensions = len(s.records[0].Embedding)
+	}
+	if !s.Quantize {
+		file.Records = s.records
+		return json.NewEncoder(w).Encode(file)
+	}
+	file.Quantization = QuantizationInt8
+	file.Quantized = make([]quantizedRecord, len(s.records))
+	for i, record := range s.records {
+		file.Quantized[i] = quantizedRecord{ID: record.ID, Text: record.Text, Metadata: record.Metadata,
+			Embedding: QuantizeInt8(record.Embedding)}
+	}
+	return json.NewEncoder(w).Encode(file)
+}
+
+// Load replaces the VectorStore's records with those read from r, as written by Save
+func (s *VectorStore) Load(r io.Reader) error {
+	var file vectorStoreFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return fmt.Errorf("failed to read vector store: %w", err)
+	}
+	switch file.Quantization {
+	case "":
+	case QuantizationInt8:
+		for _, record := range file.Quantized {
+			file.Records = append(file.Records, VectorRecord{ID: record.ID, Text: record.Text, Metadata: record.Metadata,
+				Embedding: record.Embedding.Dequantize()})
+		}
+	default:
+		return fmt.Errorf("failed to read vector store: unsupported quantization %q", file.Quantization)
+	}
+	loaded := NewVectorStore()
+	if err := loaded.Add(file.Records...); err != nil {
+		return fmt.Errorf("failed to read vector store: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records, s.index = loaded.records, loaded.index
+	s.Quantize = file.Quantization != ""
+	return nil
+}
+
+// SaveFile writes the VectorStore to the file at path as JSON.
+// The file is written atomically, so concurrent readers never see a partial store.
+func (s *VectorStore) SaveFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = s.Save(tmp)
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// LoadVectorStore returns a new VectorStore read from the file at path, as written by SaveFile
+func LoadVectorStore(path string) (*VectorStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := NewVectorStore()
+	if err := s.Load(f); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// Similarity search
+
+// DefaultSearchK is the number of results of a search whose K is not positive
+const DefaultSearchK = 5
+
+// SearchOptions are the options of a VectorStore Search
+type SearchOptions struct {
+	K        int                     // K is the maximum number of results (default: DefaultSearchK)
+	MinScore float64                 // MinScore, if non-zero, excludes results scoring below it
+	Filter   func(VectorRecord) bool // Filter, if set, excludes records for which it returns false
+}
+
+// SearchResult is a VectorRecord found by a search, with its score
+type SearchResult struct {
+	VectorRecord
+	Score float64 // Score is the cosine similarity of the record to the query
+}
+
+// MatchMetadata returns a SearchOptions Filter of records whose Metadata has the value for the key
+func MatchMetadata(key string, value string) func(VectorRecord) bool {
+	return func(record VectorRecord) bool {
+		v, ok := record.Metadata[key]
+		return ok && v == value
+	}
+}
+
+// TopK returns the k records most similar to the query, most similar first
+func (s *VectorStore) TopK(query []float32, k int) ([]SearchResult, error) {
+	return s.Search(query, SearchOptions{K: k})
+}
+
+// Search returns the records most similar to the query by cosine similarity,
+// most similar first, with the options' limit, threshold, and filter.
+// Returns an error if the query's dimensions differ from the store's.
+func (s *VectorStore) Search(query []float32, opts SearchOptions) ([]SearchResult, error) {
+	k := opts.K
+	if k <= 0 {
+		k = DefaultSearchK
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.records) > 0 && len(query) != len(s.records[0].Embedding) {
+		return nil, fmt.Errorf("query has %d dimensions, expected %d", len(query), len(s.records[0].Embedding))
+	}
+	var results []SearchResult
+	for _, record := range s.records {
+		if opts.Filter != nil && !opts.Filter(record) {
+			continue
+		}
+		score := CosineSimilarity(query, record.Embedding)
+		if opts.MinScore != 0 && score < opts.MinScore {
+			continue
+		}
+		results = append(results, SearchResult{VectorRecord: record, Score: score})
+	}
+	slices.SortStableFunc(results, func(a, b SearchResult) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// SearchResultMsg is sent when a SearchCmd completes.
+type SearchResultMsg struct {
+	ID      int64          // ID given to SearchCmd
+	Results []SearchResult // Results, most similar first
+	Error   error          // Error, if the search failed
+}
+
+// SearchCmd returns a command which searches the VectorStore like [VectorStore.Search],
+// returning a [SearchResultMsg].  The id is an arbitrary identifier to correlate the message.
+func SearchCmd(id int64, store *VectorStore, query []float32, opts SearchOptions) tea.Cmd {
+	query = slices.Clone(query)
+	return func() tea.Msg {
+		results, err := store.Search(query, opts)
+		return SearchResultMsg{ID: id, Results: results, Error: err}
+	}
+}
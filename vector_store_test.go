@@ -0,0 +1,117 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestVectorStore(t *testing.T) {
+	store := ollamatea.NewVectorStore()
+	err := store.Add(
+		ollamatea.VectorRecord{ID: "a", Text: "apple", Embedding: []float32{1, 0}},
+		ollamatea.VectorRecord{ID: "b", Text: "banana", Metadata: map[string]string{"color": "yellow"}, Embedding: []float32{0, 1}})
+	if err != nil || store.Len() != 2 || store.Dimensions() != 2 {
+		t.Fatalf("unexpected store of %d, error %v", store.Len(), err)
+	}
+	if err := store.Add(ollamatea.VectorRecord{Embedding: []float32{1, 2, 3}}); err == nil || store.Len() != 2 {
+		t.Errorf("expected an error adding a vector of other dimensions")
+	}
+	if err := store.Add(ollamatea.VectorRecord{ID: "a", Text: "apricot", Embedding: []float32{1, 1}}); err != nil || store.Len() != 2 {
+		t.Errorf("expected the record replaced, got %d records, error %v", store.Len(), err)
+	}
+	if record, ok := store.Get("a"); !ok || record.Text != "apricot" {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	if err := store.SaveFile(path); err != nil {
+		t.Fatalf("SaveFile failed: %v", err)
+	}
+	if err := store.Delete("a"); err != nil || store.Len() != 1 {
+		t.Errorf("expected the record deleted, error %v", err)
+	}
+	if err := store.Delete("a"); !errors.Is(err, ollamatea.ErrVectorNotFound) {
+		t.Errorf("expected ErrVectorNotFound, got %v", err)
+	}
+	loaded, err := ollamatea.LoadVectorStore(path)
+	if err != nil {
+		t.Fatalf("LoadVectorStore failed: %v", err)
+	}
+	if !slices.EqualFunc(loaded.Records(), []ollamatea.VectorRecord{
+		{ID: "a", Text: "apricot", Embedding: []float32{1, 1}},
+		{ID: "b", Text: "banana", Metadata: map[string]string{"color": "yellow"}, Embedding: []float32{0, 1}},
+	}, func(a, b ollamatea.VectorRecord) bool {
+		return a.ID == b.ID && a.Text == b.Text && a.Metadata["color"] == b.Metadata["color"] && slices.Equal(a.Embedding, b.Embedding)
+	}) {
+		t.Errorf("unexpected records %+v", loaded.Records())
+	}
+}
+
+func TestVectorStoreAddEmbedSession(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+
+	text := "The sky is blue. Grass is green."
+	s := ollamatea.NewEmbedSession(
+		ollamatea.WithHost(srv.URL),
+		ollamatea.WithInput(text),
+		ollamatea.WithChunker(ollamatea.NewChunker(ollamatea.ChunkBySentences, 1, 0)))
+	if _, err := s.Embed(context.Background()); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	store := ollamatea.NewVectorStore()
+	ids, err := store.AddEmbedSession(&s, map[string]string{"source": "colors.txt"})
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("unexpected IDs %v, error %v", ids, err)
+	}
+	record, _ := store.Get(ids[1])
+	if record.Text != "Grass is green." || record.Metadata["source"] != "colors.txt" ||
+		record.Metadata[ollamatea.MetadataChunkStart] != "17" || record.Metadata[ollamatea.MetadataChunkEnd] != "32" {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestVectorStoreSearch(t *testing.T) {
+	store := ollamatea.NewVectorStore()
+	store.Add(
+		ollamatea.VectorRecord{ID: "east", Metadata: map[string]string{"kind": "cardinal"}, Embedding: []float32{1, 0}},
+		ollamatea.VectorRecord{ID: "northeast", Embedding: []float32{1, 1}},
+		ollamatea.VectorRecord{ID: "north", Metadata: map[string]string{"kind": "cardinal"}, Embedding: []float32{0, 1}},
+		ollamatea.VectorRecord{ID: "west", Metadata: map[string]string{"kind": "cardinal"}, Embedding: []float32{-1, 0}})
+	ids := func(results []ollamatea.SearchResult) []string {
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return ids
+	}
+
+	results, err := store.TopK([]float32{1, 0.1}, 2)
+	if err != nil || !slices.Equal(ids(results), []string{"east", "northeast"}) || results[0].Score <= results[1].Score {
+		t.Errorf("unexpected results %+v, error %v", results, err)
+	}
+	results, _ = store.Search([]float32{1, 0.1}, ollamatea.SearchOptions{K: 10, MinScore: 0.5})
+	if !slices.Equal(ids(results), []string{"east", "northeast"}) {
+		t.Errorf("expected the threshold to exclude dissimilar records, got %v", ids(results))
+	}
+	results, _ = store.Search([]float32{1, 0.1}, ollamatea.SearchOptions{K: 10, Filter: ollamatea.MatchMetadata("kind", "cardinal")})
+	if !slices.Equal(ids(results), []string{"east", "north", "west"}) {
+		t.Errorf("expected the filter to exclude northeast, got %v", ids(results))
+	}
+	if _, err := store.TopK([]float32{1, 0, 0}, 1); err == nil {
+		t.Error("expected an error for a query of other dimensions")
+	}
+
+	msg := ollamatea.SearchCmd(7, store, []float32{0, 1}, ollamatea.SearchOptions{K: 1})()
+	if result, ok := msg.(ollamatea.SearchResultMsg); !ok || result.ID != 7 || !slices.Equal(ids(result.Results), []string{"north"}) {
+		t.Errorf("unexpected %+v", msg)
+	}
+}
@@ -0,0 +1,84 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"fmt"
+	"math"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Vector math
+//
+// Embeddings are compared by the angle between them.  These helpers work on
+// the []float32 vectors of an EmbedResponse, or on []float64, accumulating in
+// float64 for accuracy.  Vectors compared must have the same length, as
+// embeddings of the same model do; mismatched lengths are a programming
+// error, and panic.
+
+// Float is the element type of the vectors of the vector math helpers
+type Float interface {
+	~float32 | ~float64
+}
+
+// Dot returns the dot product of the vectors a and b
+func Dot[T Float](a, b []T) float64 {
+	checkLengths(a, b)
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// Norm returns the Euclidean (L2) length of the vector v
+func Norm[T Float](v []T) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
+
+// Normalize returns a copy of the vector v scaled to unit length.
+// A zero vector is returned as a copy, unscaled.
+func Normalize[T Float](v []T) []T {
+	normalized := make([]T, len(v))
+	copy(normalized, v)
+	if norm := Norm(v); norm > 0 {
+		for i := range normalized {
+			normalized[i] = T(float64(normalized[i]) / norm)
+		}
+	}
+	return normalized
+}
+
+// CosineSimilarity returns the cosine of the angle between the vectors a and b,
+// from -1 for opposite to 1 for the same direction.  Returns 0 if either is a zero vector.
+// For unit vectors, such as those of Normalize, it equals their Dot product.
+func CosineSimilarity[T Float](a, b []T) float64 {
+	normA, normB := Norm(a), Norm(b)
+	if normA == 0 || normB == 0 {
+		checkLengths(a, b)
+		return 0
+	}
+	return Dot(a, b) / (normA * normB)
+}
+
+// EuclideanDistance returns the straight-line distance between the vectors a and b
+func EuclideanDistance[T Float](a, b []T) float64 {
+	checkLengths(a, b)
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// checkLengths panics if the vectors a and b have different lengths
+func checkLengths[T Float](a, b []T) {
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("ollamatea: vectors of different lengths %d and %d", len(a), len(b)))
+	}
+}
@@ -0,0 +1,60 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestVectorMath(t *testing.T) {
+	a := []float32{1, 2, 3}
+	b := []float32{4, -5, 6}
+	if got := ollamatea.Dot(a, b); !closeTo(got, 12) {
+		t.Errorf("Dot: expected 12, got %v", got)
+	}
+	if got := ollamatea.Norm([]float64{3, 4}); !closeTo(got, 5) {
+		t.Errorf("Norm: expected 5, got %v", got)
+	}
+	if got := ollamatea.EuclideanDistance([]float64{1, 1}, []float64{4, 5}); !closeTo(got, 5) {
+		t.Errorf("EuclideanDistance: expected 5, got %v", got)
+	}
+	if got := ollamatea.CosineSimilarity(a, b); !closeTo(got, 12/(math.Sqrt(14)*math.Sqrt(77))) {
+		t.Errorf("CosineSimilarity: expected 0.3655, got %v", got)
+	}
+	for _, test := range []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 0}, []float64{2, 0}, 1},
+		{[]float64{1, 0}, []float64{0, 3}, 0},
+		{[]float64{1, 1}, []float64{-1, -1}, -1},
+		{[]float64{0, 0}, []float64{1, 1}, 0},
+	} {
+		if got := ollamatea.CosineSimilarity(test.a, test.b); !closeTo(got, test.want) {
+			t.Errorf("CosineSimilarity(%v, %v): expected %v, got %v", test.a, test.b, test.want, got)
+		}
+	}
+
+	normalized := ollamatea.Normalize([]float32{3, 4})
+	if !slices.Equal(normalized, []float32{0.6, 0.8}) || !closeTo(ollamatea.Norm(normalized), 1) {
+		t.Errorf("Normalize: expected [0.6 0.8], got %v", normalized)
+	}
+	if zero := ollamatea.Normalize([]float64{0, 0}); !slices.Equal(zero, []float64{0, 0}) {
+		t.Errorf("Normalize: expected the zero vector unchanged, got %v", zero)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for vectors of different lengths")
+		}
+	}()
+	ollamatea.Dot(a, []float32{1})
+}
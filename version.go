@@ -0,0 +1,173 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Server versions
+//
+// Ollama features arrive in server releases, so an application may need to
+// know what a server supports before using a feature.  FetchVersion queries a
+// server's version, and ServerVersion.Supports gates Features upon it.
+
+// Versioner is a Backend that reports its server version.
+// It is optional; the Ollama API client [ollama.Client] satisfies it.
+type Versioner interface {
+	Version(ctx context.Context) (string, error)
+}
+
+// ServerVersion is a parsed server version, such as "0.4.2".
+type ServerVersion struct {
+	Major int
+	Minor int
+	Patch int
+	Raw   string // Raw is the version as reported, such as "0.5.0-rc1"
+}
+
+// ParseServerVersion parses a version such as "0.4.2", "v0.4", or "0.5.0-rc1".
+func ParseServerVersion(version string) (ServerVersion, error) {
+	v := ServerVersion{Raw: version}
+	core, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(version), "v"), "-")
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid server version %q", version)
+	}
+	fields := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid server version %q", version)
+		}
+		*fields[i] = n
+	}
+	return v, nil
+}
+
+// String returns the version as reported, or as "major.minor.patch".
+func (v ServerVersion) String() string {
+	if v.Raw != "" {
+		return v.Raw
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast returns true if v is the given version or later.
+func (v ServerVersion) AtLeast(major int, minor int, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+// IsDevelopment returns true for development builds, which report "0.0.0".
+func (v ServerVersion) IsDevelopment() bool {
+	return v.Major == 0 && v.Minor == 0 && v.Patch == 0
+}
+
+// Feature is a server capability which depends on the server version.
+type Feature string
+
+const (
+	FeatureEmbed             Feature = "embed"              // FeatureEmbed is /api/embed; older servers only have /api/embeddings
+	FeatureTools             Feature = "tools"              // FeatureTools is tool calling in /api/chat
+	FeatureStructuredOutputs Feature = "structured_outputs" // FeatureStructuredOutputs is a JSON schema as the request Format
+)
+
+// featureVersions are the first Ollama releases with each Feature
+var featureVersions = map[Feature]ServerVersion{
+	FeatureEmbed:             {Major: 0, Minor: 3, Patch: 0},
+	FeatureTools:             {Major: 0, Minor: 3, Patch: 0},
+	FeatureStructuredOutputs: {Major: 0, Minor: 5, Patch: 0},
+}
+
+// MinVersion returns the first server version with the Feature.
+// Returns false if the Feature is unknown.
+func (f Feature) MinVersion() (ServerVersion, bool) {
+	v, ok := featureVersions[f]
+	return v, ok
+}
+
+// Supports returns true if a server of version v supports the Feature.
+// Development builds are assumed to support everything.
+func (v ServerVersion) Supports(f Feature) bool {
+	min, ok := f.MinVersion()
+	if !ok {
+		return false
+	}
+	return v.IsDevelopment() || v.AtLeast(min.Major, min.Minor, min.Patch)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// BubbleTea messages
+
+// VersionMsg is sent when a FetchVersion succeeds.
+type VersionMsg struct {
+	OllamaHost string        // Ollama Host which reported the version
+	Version    ServerVersion // Version of the server
+}
+
+// VersionErrorMsg is sent when a FetchVersion fails.
+type VersionErrorMsg struct {
+	OllamaHost string // Ollama Host generating the error
+	Error      error  // Error returned
+}
+
+// FetchVersion returns a command which queries the version of the Ollama host.
+// It returns a [VersionMsg], or a [VersionErrorMsg] if there is an error.
+func FetchVersion(ollamaHost string) tea.Cmd {
+	return func() tea.Msg {
+		return FetchVersionFromBackend(nil, ollamaHost)
+	}
+}
+
+// FetchVersionFromBackend is like [FetchVersion], but queries the given Backend.
+// If backend is nil, the shared Backend for ollamaHost from [SharedBackend] is used.
+// The Backend must be a [Versioner].
+func FetchVersionFromBackend(backend Backend, ollamaHost string) tea.Msg {
+	return fetchVersion(backend, ollamaHost, logger())
+}
+
+// fetchVersion implements FetchVersionFromBackend, logging to log
+func fetchVersion(backend Backend, ollamaHost string, log *slog.Logger) tea.Msg {
+	backend, err := resolveBackend(backend, ollamaHost)
+	if err != nil {
+		log.Error("version backend failed", "host", ollamaHost, "error", err)
+		return VersionErrorMsg{OllamaHost: ollamaHost, Error: err}
+	}
+	versioner, ok := backend.(Versioner)
+	if !ok {
+		return VersionErrorMsg{OllamaHost: ollamaHost, Error: fmt.Errorf("backend %T does not report a version", backend)}
+	}
+
+	ctx, span := startSpan(context.Background(), "ollamatea.version",
+		Attr("ollamatea.host", ollamaHost))
+	defer span.End()
+	raw, err := versioner.Version(ctx)
+	if err != nil {
+		err = ClassifyError(err, ollamaHost, "")
+		log.Error("version failed", "host", ollamaHost, "error", err)
+		span.RecordError(err)
+		return VersionErrorMsg{OllamaHost: ollamaHost, Error: err}
+	}
+	version, err := ParseServerVersion(raw)
+	if err != nil {
+		log.Error("version failed", "host", ollamaHost, "error", err)
+		span.RecordError(err)
+		return VersionErrorMsg{OllamaHost: ollamaHost, Error: err}
+	}
+	span.SetAttributes(Attr("ollamatea.version", version.String()))
+	log.Debug("version done", "host", ollamaHost, "version", version.String())
+	return VersionMsg{OllamaHost: ollamaHost, Version: version}
+}
@@ -0,0 +1,69 @@
+// OllamaTea Copyright (c) 2024 Neomantra Corp
+
+package ollamatea_test
+
+import (
+	"testing"
+
+	"github.com/NimbleMarkets/ollamatea"
+	"github.com/NimbleMarkets/ollamatea/ollamateatest"
+)
+
+func TestServerVersionSupports(t *testing.T) {
+	tests := []struct {
+		version string
+		feature ollamatea.Feature
+		want    bool
+	}{
+		{"0.2.8", ollamatea.FeatureEmbed, false},
+		{"0.3.0", ollamatea.FeatureEmbed, true},
+		{"v0.4", ollamatea.FeatureTools, true},
+		{"0.4.7", ollamatea.FeatureStructuredOutputs, false},
+		{"0.5.0-rc1", ollamatea.FeatureStructuredOutputs, true},
+		{"1.0.0", ollamatea.FeatureStructuredOutputs, true},
+		{"0.0.0", ollamatea.FeatureStructuredOutputs, true},
+		{"1.0.0", ollamatea.Feature("unknown"), false},
+	}
+	for _, test := range tests {
+		v, err := ollamatea.ParseServerVersion(test.version)
+		if err != nil {
+			t.Fatalf("%s: %v", test.version, err)
+		}
+		if got := v.Supports(test.feature); got != test.want {
+			t.Errorf("%s supports %s: expected %v, got %v", test.version, test.feature, test.want, got)
+		}
+	}
+	if _, err := ollamatea.ParseServerVersion("latest"); err == nil {
+		t.Error("expected an error for an invalid version")
+	}
+}
+
+func TestFetchVersion(t *testing.T) {
+	srv := ollamateatest.NewServer()
+	defer srv.Close()
+	srv.SetVersion("0.4.2")
+
+	msg := ollamatea.FetchVersion(srv.URL)()
+	versionMsg, ok := msg.(ollamatea.VersionMsg)
+	if !ok {
+		t.Fatalf("expected VersionMsg, got %#v", msg)
+	}
+	if v := versionMsg.Version; v.Major != 0 || v.Minor != 4 || v.Patch != 2 || v.String() != "0.4.2" {
+		t.Errorf("unexpected version %#v", v)
+	}
+
+	chooser := ollamatea.NewModelChooser(srv.URL)
+	if _, ok := chooser.ServerVersion(); ok {
+		t.Error("expected no version before fetching")
+	}
+	chooser, _ = chooser.Update(versionMsg)
+	if v, ok := chooser.ServerVersion(); !ok || !v.Supports(ollamatea.FeatureTools) {
+		t.Errorf("expected the chooser to record the version, got %v %v", v, ok)
+	}
+
+	// a SimulatedBackend does not report a version
+	msg = ollamatea.FetchVersionFromBackend(ollamatea.NewSimulatedBackend(), "simulate://")
+	if _, ok := msg.(ollamatea.VersionErrorMsg); !ok {
+		t.Errorf("expected VersionErrorMsg, got %#v", msg)
+	}
+}